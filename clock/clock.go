@@ -0,0 +1,45 @@
+// Package clock 提供可注入的节点本地时钟，用于模拟车辆节点之间的时钟偏差
+package clock
+
+import "time"
+
+// Clock 节点本地时钟
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock 系统时钟，所有节点共用时即假设时钟完全同步（默认）
+type SystemClock struct{}
+
+// Now 返回系统当前时间
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// SkewedClock 在基准时钟上叠加固定偏差的时钟，Offset 为正时本地时间快于基准时钟
+type SkewedClock struct {
+	Base   Clock         // 基准时钟，为 nil 时使用系统时钟
+	Offset time.Duration // 本地时间相对基准时钟的偏差
+}
+
+// NewSkewedClock 创建相对系统时钟偏差 offset 的时钟
+func NewSkewedClock(offset time.Duration) SkewedClock {
+	return SkewedClock{Base: SystemClock{}, Offset: offset}
+}
+
+// Now 返回基准时钟的当前时间加上偏差
+func (c SkewedClock) Now() time.Time {
+	base := c.Base
+	if base == nil {
+		base = SystemClock{}
+	}
+	return base.Now().Add(c.Offset)
+}
+
+// Fixed 始终返回同一时刻的时钟，便于测试
+type Fixed time.Time
+
+// Now 返回固定时刻
+func (f Fixed) Now() time.Time {
+	return time.Time(f)
+}
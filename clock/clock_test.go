@@ -0,0 +1,24 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSkewedClockOffsetsBase(t *testing.T) {
+	base := Fixed(time.Unix(1000, 0))
+	fast := SkewedClock{Base: base, Offset: 3 * time.Second}
+	slow := SkewedClock{Base: base, Offset: -2 * time.Second}
+	if got := fast.Now().Sub(slow.Now()); got != 5*time.Second {
+		t.Errorf("两个偏差时钟相差 %v, 期望 5s", got)
+	}
+
+	before := time.Now()
+	got := NewSkewedClock(time.Hour).Now()
+	if got.Sub(before) < time.Hour {
+		t.Errorf("偏差 1h 的系统时钟返回 %v, 期望不早于 %v", got, before.Add(time.Hour))
+	}
+	if (SkewedClock{Offset: time.Hour}).Now().Sub(before) < time.Hour {
+		t.Error("Base 为 nil 时应以系统时钟为基准")
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"block/config"
+	"block/dataloader"
+	"block/emergency"
+	"block/logging"
+	"block/reputation"
+)
+
+// StrategyResult 一种出块者选择策略的模拟结果
+type StrategyResult struct {
+	Strategy                emergency.ProposerStrategy
+	Blocks                  int            // 确认的紧急区块数
+	ProposedBlocks          map[string]int // 各验证器确认的出块数，担任过验证器但未出块的节点为 0
+	MaliciousProposerBlocks int            // 由恶意节点提议的区块数
+	MaliciousTxBlocks       int            // 含恶意节点所发交易的区块数
+}
+
+// 对比紧急区块出块者选择策略：在相同的轨迹数据、配置与随机种子下，
+// 依次以每种策略运行简化的双链模拟（普通链只产生信誉交互，不运行 PBFT），
+// 向标准输出写出 CSV 对比表，进度与告警写到标准错误：
+//
+//	strategy                   出块者选择策略
+//	blocks                     确认的紧急区块数
+//	validators                 模拟期间担任过验证器的节点数
+//	gini                       出块数在担任过验证器的节点之间分布的基尼系数，0 为完全均衡
+//	malicious_proposer_blocks  由恶意节点（作恶概率>0）提议的区块数
+//	malicious_tx_blocks        含恶意节点所发紧急交易的区块数
+func main() {
+	configPath := flag.String("config", "config/config.json", "配置文件路径")
+	dataPath := flag.String("data", "data.xlsx", "轨迹数据文件路径（.xlsx 或 .csv）")
+	rounds := flag.Int("rounds", 20, "每种策略的模拟轮数，超过最长轨迹长度时取最长轨迹长度")
+	seed := flag.Int64("seed", 1, "随机种子，每种策略使用相同的种子")
+	cooldown := flag.Int("cooldown", 0, "highest-reputation 策略下出块者的冷却轮数（0 表示不限制）")
+	flag.Parse()
+
+	stderr := logging.New(os.Stderr, logging.LevelInfo)
+	if *cooldown < 0 {
+		stderr.Errorf("-cooldown=%d 不能为负\n", *cooldown)
+		os.Exit(1)
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		stderr.Errorf("加载配置失败: %v\n", err)
+		os.Exit(1)
+	}
+
+	dataMap, loadReport, err := dataloader.LoadTrajectories(*dataPath, dataloader.LoadOptions{
+		RejectOutliers:  cfg.RejectTrajectoryOutliers,
+		MaxAcceleration: cfg.MaxAcceleration,
+	})
+	if err != nil {
+		stderr.Errorf("读取数据文件失败: %v\n", err)
+		os.Exit(1)
+	}
+	if len(loadReport.Skipped) > 0 {
+		stderr.Warnf("⚠️ 数据文件%s\n", loadReport.Summary())
+	}
+
+	trajMap := dataloader.BuildVectorMap(dataMap)
+	if n := dataloader.MaxRounds(trajMap); *rounds > n {
+		*rounds = n
+	}
+
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{"strategy", "blocks", "validators", "gini", "malicious_proposer_blocks", "malicious_tx_blocks"})
+	for _, strategy := range emergency.ProposerStrategies {
+		stderr.Infof("运行策略 %s (%d 轮)\n", strategy, *rounds)
+		res := simulate(cfg, dataMap, trajMap, strategy, *cooldown, *rounds, *seed)
+
+		counts := make([]float64, 0, len(res.ProposedBlocks))
+		for _, n := range res.ProposedBlocks {
+			counts = append(counts, float64(n))
+		}
+		w.Write([]string{
+			strategy.String(),
+			strconv.Itoa(res.Blocks),
+			strconv.Itoa(len(res.ProposedBlocks)),
+			strconv.FormatFloat(gini(counts), 'f', 4, 64),
+			strconv.Itoa(res.MaliciousProposerBlocks),
+			strconv.Itoa(res.MaliciousTxBlocks),
+		})
+		w.Flush()
+	}
+	if err := w.Error(); err != nil {
+		stderr.Errorf("写出结果失败: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// simulate 以指定的出块者选择策略与出块冷却轮数运行 rounds 轮简化双链模拟
+// 交互与紧急交易的生成方式与 cmd/dualchain 一致，但普通链不出块，紧急交易不引用普通区块
+func simulate(
+	cfg config.Config,
+	dataMap map[string][]dataloader.RawData,
+	trajMap map[string][]reputation.Vector,
+	strategy emergency.ProposerStrategy,
+	cooldown int,
+	rounds int,
+	seed int64,
+) StrategyResult {
+	rng := rand.New(rand.NewSource(seed))
+	malice := cfg.MaliceProbabilities
+	isMaliciousTx := func(sender string) bool {
+		p := malice[sender]
+		return p > 0 && rng.Float64() < p
+	}
+
+	var vehicleIDs []string
+	for vid := range dataMap {
+		if !cfg.IsRSU(vid) {
+			vehicleIDs = append(vehicleIDs, vid)
+		}
+	}
+	sort.Strings(vehicleIDs)
+	rsuIDs := slices.Sorted(slices.Values(cfg.RSUNodes))
+	nodeIDs := append(slices.Clone(vehicleIDs), rsuIDs...)
+
+	urgencyCfg := emergency.UrgencyConfig{
+		Model:       emergency.UrgencyExponential,
+		Omega:       0.5,
+		MaxDeadline: 15 * time.Second,
+		MinUrgency:  0.01,
+		MaxUrgency:  5.0,
+	}
+	blockchain := emergency.NewEmergencyBlockchain(urgencyCfg, 5, 3*time.Second)
+	blockchain.MinBlockTransactions = cfg.MinBlockTransactions
+	blockchain.TxPool.MaxPoolSize = cfg.MaxTxPoolSize
+
+	groupSize := int(math.Ceil(float64(len(nodeIDs)) * 0.3))
+	if groupSize < emergency.MinBFTValidators {
+		groupSize = emergency.MinBFTValidators
+	}
+	validatorGroup := emergency.NewValidatorGroup(groupSize, 10)
+	validatorGroup.SetSeed(seed)
+	validatorGroup.ProposerStrategy = strategy
+	validatorGroup.ProposerCooldown = cooldown
+	validatorGroup.EvictionThreshold = cfg.ValidatorEvictionThreshold
+
+	// 节点日志只保留错误，避免与 CSV 输出混杂
+	nodeLog := logging.New(os.Stderr, logging.LevelError)
+	managers := make(map[string]*reputation.ReputationManager)
+	providers := make(map[string]reputation.ReputationProvider)
+	nodes := make(map[string]*emergency.EmergencyNode)
+	txValidator := emergency.NewMaliceProbabilityValidator(malice)
+	var peers []*emergency.EmergencyNode
+	for _, id := range nodeIDs {
+		rm := reputation.NewReputationManager(cfg)
+		rm.SetLogger(logging.Discard())
+		managers[id] = rm
+		providers[id] = rm
+
+		node := emergency.NewEmergencyNode(id, blockchain, rm, validatorGroup)
+		engine, err := emergency.NewConsensusEngine(cfg.EmergencyConsensus, node)
+		if err != nil {
+			// 配置已通过校验，共识引擎名称不会不合法
+			panic(err)
+		}
+		node.SetConsensusEngine(engine)
+		node.SetTransactionValidator(txValidator)
+		node.CheckValidity = true
+		node.ProposerReward = 1
+		node.Logger = nodeLog
+		nodes[id] = node
+		peers = append(peers, node)
+	}
+	for _, node := range peers {
+		node.SetPeers(peers)
+	}
+
+	// 验证器在共识协程中读取轨迹，当前轮以原子变量共享
+	var currentRound atomic.Int64
+	trajSource := func(nodeID string) []reputation.Vector {
+		traj := trajMap[nodeID]
+		return traj[:min(int(currentRound.Load())+1, len(traj))]
+	}
+	for _, node := range peers {
+		node.SetTrajectorySource(trajSource)
+	}
+	blockchain.SetRoundSource(func() int { return int(currentRound.Load()) + 1 })
+
+	res := StrategyResult{Strategy: strategy, ProposedBlocks: make(map[string]int)}
+	emergencyTxCounter := make(map[string]int)
+	for r := 0; r < rounds; r++ {
+		currentRound.Store(int64(r))
+
+		// 1. 普通交互：本轮有轨迹数据的车辆作为发送者，RSU 只作为接收者
+		activeIDs := dataloader.ActiveVehicles(trajMap, vehicleIDs, r)
+		receiverIDs := append(slices.Clone(activeIDs), rsuIDs...)
+		for _, sender := range activeIDs {
+			for k := rng.Intn(3); k > 0; k-- {
+				receiver := receiverIDs[rng.Intn(len(receiverIDs))]
+				if receiver == sender {
+					continue
+				}
+				posEvents, negEvents := 1, 0
+				if isMaliciousTx(sender) {
+					posEvents, negEvents = 0, 1
+				}
+				var trajUser []reputation.Vector
+				if !cfg.IsRSU(receiver) {
+					trajUser = trajMap[receiver][:r+1]
+				}
+				baseTime := time.Now().Add(-time.Duration(dataMap[sender][r].Time) * time.Second)
+				inter := reputation.Interaction{
+					From:         receiver,
+					To:           sender,
+					PosEvents:    posEvents,
+					NegEvents:    negEvents,
+					Timestamp:    baseTime.Add(time.Duration(rng.Intn(500)) * time.Millisecond),
+					TrajUser:     trajUser,
+					TrajProvider: trajMap[sender][:r+1],
+					TxType:       reputation.NormalTransaction,
+				}
+				if err := managers[sender].AddInteraction(inter); err != nil {
+					nodeLog.Errorf("记录交互失败: %v\n", err)
+				}
+			}
+		}
+
+		// 2. 刷新验证器组：活跃周期结束，或有验证器信誉跌破驱逐阈值时刷新
+		evicted := validatorGroup.ShouldEvict(providers, time.Now())
+		if r == 0 || validatorGroup.NeedRefresh() || len(evicted) > 0 {
+			validatorGroup.SelectValidators(nodeIDs, providers, time.Now())
+			for _, node := range peers {
+				node.UpdateValidatorStatus()
+			}
+		}
+		for _, v := range validatorGroup.Validators {
+			if _, ok := res.ProposedBlocks[v.ID]; !ok {
+				res.ProposedBlocks[v.ID] = 0
+			}
+		}
+
+		// 3. 生成紧急交易
+		numEmergencyTx := cfg.MinEmergencyTxPerRound
+		if cfg.MaxEmergencyTxPerRound > cfg.MinEmergencyTxPerRound {
+			numEmergencyTx += rng.Intn(cfg.MaxEmergencyTxPerRound - cfg.MinEmergencyTxPerRound + 1)
+		}
+		for i := 0; i < numEmergencyTx; i++ {
+			senderID := vehicleIDs[rng.Intn(len(vehicleIDs))]
+			emergencyTxCounter[senderID]++
+			now := time.Now()
+			tx := emergency.NewEmergencyTransaction(
+				fmt.Sprintf("ETx-%d-%s-%d", r, senderID, i),
+				senderID,
+				[]byte(fmt.Sprintf("Emergency data from %s", senderID)),
+				now.Add(-time.Duration(rng.Intn(5))*time.Second),
+				now.Add(time.Duration(5+rng.Intn(10))*time.Second),
+				now,
+				emergencyTxCounter[senderID],
+				urgencyCfg,
+			)
+			for _, node := range peers {
+				node.AddEmergencyTransaction(tx)
+			}
+		}
+
+		// 4. 按策略选出的出块者提议紧急区块
+		if proposer := validatorGroup.NextProposer(); proposer != nil {
+			block, err := nodes[proposer.ID].ProposeEmergencyBlock()
+			if err == nil {
+				res.Blocks++
+				res.ProposedBlocks[proposer.ID]++
+				if malice[proposer.ID] > 0 {
+					res.MaliciousProposerBlocks++
+				}
+				if slices.ContainsFunc(block.Transactions, func(tx *emergency.EmergencyTransaction) bool {
+					return malice[tx.VehicleID] > 0
+				}) {
+					res.MaliciousTxBlocks++
+				}
+			}
+			// 等待其余验证器完成提交，确定链顶后记录评价
+			time.Sleep(100 * time.Millisecond)
+			for _, node := range nodes {
+				node.SettleCommits()
+			}
+		}
+		validatorGroup.IncrementRound()
+	}
+	return res
+}
+
+// gini 返回非负样本的基尼系数 G = Σ(2i-n-1)·x_(i) / (n·Σx)，x_(i) 为升序排列后的第 i 个样本
+// 样本为空或全为 0 时返回 0
+func gini(xs []float64) float64 {
+	sorted := slices.Sorted(slices.Values(xs))
+	n := float64(len(sorted))
+	var total, weighted float64
+	for i, x := range sorted {
+		total += x
+		weighted += (2*float64(i+1) - n - 1) * x
+	}
+	if total == 0 {
+		return 0
+	}
+	return weighted / (n * total)
+}
@@ -1,23 +1,30 @@
 package main
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
 	"math"
 	"math/rand"
 	"os"
+	"os/signal"
+	"slices"
 	"sort"
-	"strconv"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"block/config"
+	"block/dataloader"
 	"block/emergency"
+	"block/logging"
 	"block/reputation"
-
-	"github.com/xuri/excelize/v2"
+	"block/roundlog"
+	"block/trace"
 )
 
 // -------- 普通区块链（PBFT）部分 --------
@@ -53,6 +60,11 @@ type NormalNode struct {
 	mutex  sync.Mutex
 	view   int
 	seq    int
+
+	// committed 已确认的 (View, Seq)，重复的提交消息不会再次追加区块
+	committed map[[2]int]bool
+	// committedSeq 已确认的最高序号
+	committedSeq int
 }
 
 func NewNormalNode(id string, cfg config.Config) *NormalNode {
@@ -68,13 +80,36 @@ func (n *NormalNode) Broadcast(msg NormalMessage) {
 func (n *NormalNode) Receive(msg NormalMessage) {
 	n.mutex.Lock()
 	defer n.mutex.Unlock()
-	if msg.Type == NormalCommit {
-		n.ledger = append(n.ledger, msg.Block)
+	if msg.Type != NormalCommit {
+		return
+	}
+	// 同一 (View, Seq) 只确认一次，防止重放或重复投递的提交消息使同一区块重复上链
+	key := [2]int{msg.View, msg.Seq}
+	if n.committed[key] {
+		return
+	}
+	if n.committed == nil {
+		n.committed = make(map[[2]int]bool)
 	}
+	n.committed[key] = true
+	if msg.Seq > n.committedSeq {
+		n.committedSeq = msg.Seq
+	}
+	n.ledger = append(n.ledger, msg.Block)
+}
+
+// CommittedSeq 返回本节点已确认的最高序号
+func (n *NormalNode) CommittedSeq() int {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.committedSeq
 }
 
 func (n *NormalNode) Propose(data []byte) {
-	n.seq++
+	// 提议者轮换，序号在已确认的最高序号之后继续递增，避免与其他提议者的区块冲突
+	n.mutex.Lock()
+	n.seq = max(n.seq, n.committedSeq) + 1
+	n.mutex.Unlock()
 	block := NormalBlock{Index: len(n.ledger) + 1, Timestamp: time.Now(), Data: data, PrevHash: n.lastHash()}
 	h := sha256.Sum256(append([]byte(block.PrevHash), data...))
 	block.Hash = hex.EncodeToString(h[:])
@@ -84,6 +119,13 @@ func (n *NormalNode) Propose(data []byte) {
 	n.Broadcast(msg)
 }
 
+// Height 返回本节点普通链账本的高度（已确认的区块数）
+func (n *NormalNode) Height() int {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return len(n.ledger)
+}
+
 func (n *NormalNode) lastHash() string {
 	if len(n.ledger) == 0 {
 		return ""
@@ -91,26 +133,73 @@ func (n *NormalNode) lastHash() string {
 	return n.ledger[len(n.ledger)-1].Hash
 }
 
-// RawData 从 Excel 导入的轨迹数据（包含时间戳）
-type RawData struct {
-	VehicleID    string
-	Time         float64 // 单位：秒
-	X            float64
-	Y            float64
-	Speed        float64
-	Acceleration float64
+// 恶意节点配置：各节点的作恶概率，从配置 maliceProbabilities 加载
+// 作恶概率为该节点每笔交易是恶意交易的概率，1.0 为完全恶意，未列出的节点为诚实节点
+var maliceProbabilities = map[string]float64{}
+
+// 判断节点是否为恶意节点（作恶概率大于 0）
+func isMalicious(nodeID string) bool {
+	return maliceProbabilities[nodeID] > 0
 }
 
-// 恶意节点配置
-var maliciousNodes = map[string]bool{
-	"3": true,
+// isMaliciousTx 按发送者的作恶概率判定本笔交易是否为恶意交易
+func isMaliciousTx(sender string) bool {
+	p := maliceProbabilities[sender]
+	return p > 0 && rand.Float64() < p
 }
 
-func isMalicious(nodeID string) bool {
-	return maliciousNodes[nodeID]
+// minNodes 运行模拟所需的最少节点数（车辆与 RSU 之和）：每笔交互需要一个不同于发送者的接收者
+const minNodes = 2
+
+// ErrTooFewNodes 节点数不足，无法产生任何交互
+var ErrTooFewNodes = errors.New("节点数不足")
+
+// checkNodeCount 检查节点数是否足以运行模拟：至少一辆车发送交易，且车辆与 RSU 共至少 minNodes 个
+func checkNodeCount(vehicleIDs, rsuIDs []string) error {
+	if len(vehicleIDs) == 0 {
+		return fmt.Errorf("%w: 未找到任何车辆数据", ErrTooFewNodes)
+	}
+	if n := len(vehicleIDs) + len(rsuIDs); n < minNodes {
+		return fmt.Errorf("%w: 共 %d 个节点，至少需要 %d 个节点才能产生交互", ErrTooFewNodes, n, minNodes)
+	}
+	return nil
+}
+
+// checkTraceNodes 检查事件轨迹中的节点都是本次模拟的节点，且紧急交易与普通交易的发送者都是车辆（RSU 不发送交易）
+func checkTraceNodes(t *trace.Trace, vehicleIDs, rsuIDs []string) error {
+	for _, id := range t.NodeIDs() {
+		if !slices.Contains(vehicleIDs, id) && !slices.Contains(rsuIDs, id) {
+			return fmt.Errorf("%w: 节点 %s 不在模拟的节点中", trace.ErrInvalidTrace, id)
+		}
+	}
+	for _, inter := range t.Interactions {
+		if slices.Contains(rsuIDs, inter.Sender) {
+			return fmt.Errorf("%w: RSU %s 不能作为交互的发送者", trace.ErrInvalidTrace, inter.Sender)
+		}
+	}
+	for _, tx := range t.EmergencyTransactions {
+		if slices.Contains(rsuIDs, tx.Sender) {
+			return fmt.Errorf("%w: RSU %s 不能发送紧急交易", trace.ErrInvalidTrace, tx.Sender)
+		}
+	}
+	return nil
 }
 
 func main() {
+	logJSON := flag.Bool("logjson", false, "额外输出结构化日志 dualchain_log.jsonl（每轮一行 JSON）")
+	dataPath := flag.String("data", "data.xlsx", "轨迹数据文件路径（.xlsx 或 .csv）")
+	blockPeriod := flag.Duration("blockperiod", 3*time.Second, "紧急区块链出块周期")
+	tickerMode := flag.Bool("ticker", false, "紧急区块链每隔出块周期定时出块，与普通链轮次异步（默认每轮出块一次）")
+	roundPeriod := flag.Duration("roundperiod", time.Second, "定时出块模式下普通链每轮的最短时长")
+	urgencyModelName := flag.String("urgency", emergency.UrgencyExponential.String(), "紧急度模型（exponential/linear）")
+	proposerStrategyName := flag.String("proposer", emergency.HighestReputation.String(),
+		"紧急区块出块者选择策略（highest-reputation/round-robin/weighted-random）")
+	proposerCooldown := flag.Int("cooldown", 0, "highest-reputation 策略下出块者的冷却轮数：最近出过块的验证器让位给得分次高者（0 表示不限制）")
+	msgDrop := flag.Float64("msgdrop", 0, "紧急区块链共识消息的丢失概率 [0,1)，大于 0 时经有损网络传输（默认 0 即不丢失）")
+	tracePath := flag.String("trace", "", "事件轨迹文件（JSON，格式见 trace 包）：按记录的交互与紧急交易重放，不再随机生成")
+	txPoolPath := flag.String("txpool", "", "紧急交易池文件：启动时恢复其中未过期的待处理交易，结束时保存（默认不保存）")
+	flag.Parse()
+
 	rand.Seed(time.Now().UnixNano())
 
 	// 创建日志文件
@@ -121,99 +210,104 @@ func main() {
 	}
 	defer logFile.Close()
 
-	log.SetOutput(logFile)
-	log.SetFlags(0)
+	// 详细日志写入文件，简要进度输出到控制台，加载配置后按 logLevel 过滤
+	fileLog := logging.New(logFile, logging.LevelInfo)
+	console := logging.New(os.Stdout, logging.LevelInfo)
 
-	log.Printf("========================================\n")
-	log.Printf("双链区块链系统启动时间: %s\n", time.Now().Format("2006-01-02 15:04:05"))
-	log.Printf("========================================\n\n")
+	fileLog.Infof("========================================\n")
+	fileLog.Infof("双链区块链系统启动时间: %s\n", time.Now().Format("2006-01-02 15:04:05"))
+	fileLog.Infof("========================================\n\n")
 
 	// 加载配置
 	cfg, err := config.LoadConfig("config/config.json")
 	if err != nil {
-		log.Printf("错误: 加载配置失败: %v\n", err)
-		fmt.Println("加载配置失败:", err)
+		fileLog.Errorf("错误: 加载配置失败: %v\n", err)
+		console.Errorf("加载配置失败: %v\n", err)
 		return
 	}
-	log.Printf("配置加载成功\n\n")
+	fileLog.SetLevel(cfg.Level())
+	console.SetLevel(cfg.Level())
+	maliceProbabilities = cfg.MaliceProbabilities
+	fileLog.Infof("配置加载成功\n\n")
+
+	// 结构化日志（可选）
+	var jsonLog *roundlog.Writer
+	if *logJSON {
+		jsonLog, err = roundlog.Create("dualchain_log.jsonl")
+		if err != nil {
+			fileLog.Errorf("错误: 创建结构化日志失败: %v\n", err)
+			console.Errorf("创建结构化日志失败: %v\n", err)
+			return
+		}
+		defer jsonLog.Close()
+	}
 
-	// 读取 Excel
-	f, err := excelize.OpenFile("data.xlsx")
+	// 读取轨迹数据（支持 .xlsx 与 .csv）
+	dataMap, loadReport, err := dataloader.LoadTrajectories(*dataPath, dataloader.LoadOptions{
+		RejectOutliers:  cfg.RejectTrajectoryOutliers,
+		MaxAcceleration: cfg.MaxAcceleration,
+	})
 	if err != nil {
-		log.Printf("错误: 打开 data.xlsx 失败: %v\n", err)
-		fmt.Println("打开 data.xlsx 失败:", err)
-		return
-	}
-	log.Printf("成功打开数据文件: data.xlsx\n")
-	sheet := f.GetSheetName(0)
-	rows, err := f.GetRows(sheet)
-	if err != nil || len(rows) < 2 {
-		log.Printf("错误: 读取表格失败或无数据\n")
-		fmt.Println("读取表格失败或无数据")
+		fileLog.Errorf("错误: 读取数据文件失败: %v\n", err)
+		console.Errorf("读取数据文件失败: %v\n", err)
 		return
 	}
-
-	// 解析表头
-	header := rows[0]
-	var iVID, iTime, iLong, iSpd, iLane, iAcc int
-	for idx, title := range header {
-		switch title {
-		case "vehicleID":
-			iVID = idx
-		case "time(s)":
-			iTime = idx
-		case "longitudinalDistance(m)":
-			iLong = idx
-		case "speed(m/s)":
-			iSpd = idx
-		case "laneID":
-			iLane = idx
-		case "acceleration(m/s^2)":
-			iAcc = idx
-		}
-	}
-
-	// 读取数据
-	dataMap := make(map[string][]RawData)
-	for _, row := range rows[1:] {
-		vid := row[iVID]
-		t, _ := strconv.ParseFloat(row[iTime], 64)
-		lon, _ := strconv.ParseFloat(row[iLong], 64)
-		x := lon
-		laneIDInt, _ := strconv.Atoi(row[iLane])
-		y := float64(laneIDInt-1) * 3.5
-		spd, _ := strconv.ParseFloat(row[iSpd], 64)
-		acc, _ := strconv.ParseFloat(row[iAcc], 64)
-
-		dataMap[vid] = append(dataMap[vid], RawData{
-			VehicleID:    vid,
-			Time:         t,
-			X:            x,
-			Y:            y,
-			Speed:        spd,
-			Acceleration: acc,
-		})
-	}
-
-	// 按时间排序
-	for _, slice := range dataMap {
-		sort.Slice(slice, func(i, j int) bool { return slice[i].Time < slice[j].Time })
+	fileLog.Infof("成功读取数据文件: %s\n", *dataPath)
+	if len(loadReport.Skipped) > 0 {
+		for _, rowErr := range loadReport.Skipped {
+			fileLog.Warnf("跳过数据行: %v\n", rowErr)
+		}
+		fileLog.Warnf("⚠️ %s\n", loadReport.Summary())
+		console.Warnf("⚠️ 数据文件%s，详见日志\n", loadReport.Summary())
 	}
 
 	// 获取车辆ID列表
+	// RSU 不产生轨迹数据，数据文件中出现的 RSU 节点ID不作为车辆参与模拟
 	var vehicleIDs []string
 	for vid := range dataMap {
+		if cfg.IsRSU(vid) {
+			fileLog.Warnf("⚠️ 节点 %s 被配置为 RSU，忽略其轨迹数据\n", vid)
+			continue
+		}
 		vehicleIDs = append(vehicleIDs, vid)
 	}
 	sort.Strings(vehicleIDs)
+	// 全部节点：车辆与 RSU；RSU 参与两条链的共识并可入选验证器，但不发送交易
+	rsuIDs := slices.Sorted(slices.Values(cfg.RSUNodes))
+	nodeIDs := append(slices.Clone(vehicleIDs), rsuIDs...)
+	if err := checkNodeCount(vehicleIDs, rsuIDs); err != nil {
+		fileLog.Errorf("错误: %v\n", err)
+		console.Errorf("%v\n", err)
+		return
+	}
 
-	log.Printf("\n节点初始化:\n")
-	log.Printf("总节点数: %d\n", len(vehicleIDs))
-	log.Printf("节点列表: %v\n\n", vehicleIDs)
+	// 事件轨迹（可选）：重放记录的交互与紧急交易，轨迹中的节点必须是本次模拟的节点
+	var eventTrace *trace.Trace
+	if *tracePath != "" {
+		eventTrace, err = trace.Load(*tracePath)
+		if err == nil {
+			err = checkTraceNodes(eventTrace, vehicleIDs, rsuIDs)
+		}
+		if err != nil {
+			fileLog.Errorf("错误: 读取事件轨迹失败: %v\n", err)
+			console.Errorf("读取事件轨迹失败: %v\n", err)
+			return
+		}
+		fileLog.Infof("重放事件轨迹 %s: %d 轮, %d 个交互, %d 笔紧急交易\n", *tracePath,
+			eventTrace.RoundCount(), len(eventTrace.Interactions), len(eventTrace.EmergencyTransactions))
+	}
+
+	fileLog.Infof("\n节点初始化:\n")
+	fileLog.Infof("总节点数: %d\n", len(nodeIDs))
+	fileLog.Infof("节点列表: %v\n", vehicleIDs)
+	if len(rsuIDs) > 0 {
+		fileLog.Infof("RSU 节点: %v\n", rsuIDs)
+	}
+	fileLog.Infof("\n")
 
 	// ======== 初始化普通区块链（所有节点参与PBFT） ========
 	normalNodes := make(map[string]*NormalNode)
-	for _, vid := range vehicleIDs {
+	for _, vid := range nodeIDs {
 		normalNodes[vid] = NewNormalNode(vid, cfg)
 	}
 	for _, n := range normalNodes {
@@ -223,33 +317,88 @@ func main() {
 			}
 		}
 	}
-	log.Printf("普通区块链初始化完成 (PBFT共识, 所有 %d 个节点参与)\n\n", len(vehicleIDs))
+	fileLog.Infof("普通区块链初始化完成 (PBFT共识, 所有 %d 个节点参与)\n\n", len(nodeIDs))
 
 	// ======== 初始化紧急区块链（高信誉值节点组成验证器委员会） ========
 	// 紧急度配置
+	urgencyModel, err := emergency.ParseUrgencyModel(*urgencyModelName)
+	if err != nil {
+		fileLog.Errorf("错误: %v\n", err)
+		console.Errorf("%v\n", err)
+		return
+	}
 	urgencyCfg := emergency.UrgencyConfig{
-		Omega: 0.5, // 已申请紧急交易数量的影响权重
+		Model:       urgencyModel,
+		Omega:       0.5,              // 已申请紧急交易数量的影响权重
+		MaxDeadline: 15 * time.Second, // 线性模型的期望延迟上限，与生成交易的最长截止时间一致
+		MinUrgency:  0.01,             // 紧急度下限
+		MaxUrgency:  5.0,              // 紧急度上限，防止频繁申请的车辆紧急度无限增长
+	}
+
+	// 创建紧急区块链，指定了交易池文件时恢复上次保存的待处理交易
+	var emergencyBlockchain *emergency.EmergencyBlockchain
+	if *txPoolPath != "" {
+		emergencyBlockchain, err = emergency.NewEmergencyBlockchainWithPool(urgencyCfg, 5, *blockPeriod, *txPoolPath)
+		if err != nil {
+			fileLog.Errorf("错误: 恢复紧急交易池失败: %v\n", err)
+			console.Errorf("恢复紧急交易池失败: %v\n", err)
+			return
+		}
+		fileLog.Infof("从 %s 恢复紧急交易池: %d 笔待处理交易\n", *txPoolPath, emergencyBlockchain.TxPool.Size())
+	} else {
+		emergencyBlockchain = emergency.NewEmergencyBlockchain(
+			urgencyCfg,
+			5,            // 每个区块包含5笔交易
+			*blockPeriod, // 出块周期（默认3秒）
+		)
 	}
 
-	// 创建紧急区块链
-	emergencyBlockchain := emergency.NewEmergencyBlockchain(
-		urgencyCfg,
-		5,             // 每个区块包含5笔交易
-		3*time.Second, // 出块周期3秒
-	)
+	// 待处理交易不足 MinBlockTransactions 笔时等待积累，超过出块周期仍未达到时照常出块
+	emergencyBlockchain.MinBlockTransactions = cfg.MinBlockTransactions
+	// 交易池容量上限，已满时淘汰有效紧急度最低的交易
+	emergencyBlockchain.TxPool.MaxPoolSize = cfg.MaxTxPoolSize
+
+	// 紧急交易可引用普通链区块高度，被引用的区块在普通链上确认之前交易不会被打包
+	emergencyBlockchain.SetNormalChainHeight(normalNodes[vehicleIDs[0]].Height)
+
+	// 按发送者限制紧急交易的提交速率，防止滥用紧急通道
+	if cfg.MaxEmergencyTxPerSenderPerWindow > 0 {
+		emergencyBlockchain.RateLimiter = emergency.NewSenderRateLimiter(
+			cfg.MaxEmergencyTxPerSenderPerWindow,
+			time.Duration(cfg.EmergencyTxWindow*float64(time.Second)),
+		)
+	}
 
 	// 创建验证器节点组（选取前30%信誉值最高的节点）
-	validatorGroupSize := int(math.Ceil(float64(len(vehicleIDs)) * 0.3))
-	if validatorGroupSize < 4 {
-		validatorGroupSize = 4 // 至少4个验证器节点以支持拜占庭容错
+	validatorGroupSize := int(math.Ceil(float64(len(nodeIDs)) * 0.3))
+	if validatorGroupSize < emergency.MinBFTValidators {
+		validatorGroupSize = emergency.MinBFTValidators // 至少4个验证器节点以支持拜占庭容错
 	}
 	validatorGroup := emergency.NewValidatorGroup(validatorGroupSize, 10) // 10个区块周期后刷新
+	validatorGroup.ProposerStrategy, err = emergency.ParseProposerStrategy(*proposerStrategyName)
+	if err != nil {
+		fileLog.Errorf("错误: %v\n", err)
+		console.Errorf("%v\n", err)
+		return
+	}
+	if *proposerCooldown < 0 {
+		fileLog.Errorf("错误: -cooldown=%d 不能为负\n", *proposerCooldown)
+		console.Errorf("-cooldown=%d 不能为负\n", *proposerCooldown)
+		return
+	}
+	validatorGroup.ProposerCooldown = *proposerCooldown
+	if *msgDrop < 0 || *msgDrop >= 1 {
+		fileLog.Errorf("错误: -msgdrop=%v 超出范围 [0,1)\n", *msgDrop)
+		console.Errorf("-msgdrop=%v 超出范围 [0,1)\n", *msgDrop)
+		return
+	}
+	validatorGroup.EvictionThreshold = cfg.ValidatorEvictionThreshold
 
 	// 创建紧急区块链节点
 	emergencyNodes := make(map[string]*emergency.EmergencyNode)
-	reputationManagers := make(map[string]*reputation.ReputationManager)
+	reputationManagers := make(map[string]reputation.ReputationProvider)
 
-	for _, vid := range vehicleIDs {
+	for _, vid := range nodeIDs {
 		reputationManagers[vid] = normalNodes[vid].Rm
 		emergencyNodes[vid] = emergency.NewEmergencyNode(
 			vid,
@@ -257,6 +406,39 @@ func main() {
 			normalNodes[vid].Rm,
 			validatorGroup,
 		)
+
+		// 按配置选用紧急区块链的共识引擎
+		engine, err := emergency.NewConsensusEngine(cfg.EmergencyConsensus, emergencyNodes[vid])
+		if err != nil {
+			fileLog.Errorf("错误: 创建共识引擎失败: %v\n", err)
+			console.Errorf("创建共识引擎失败: %v\n", err)
+			return
+		}
+		emergencyNodes[vid].SetConsensusEngine(engine)
+	}
+
+	// 紧急交易评价：无效交易（数据为空、出块时已过截止时间）给负面评价，
+	// 有效交易与普通链一致，按发送者的作恶概率评价
+	// 重放事件轨迹时按轨迹记录的 malicious 评价
+	var txValidator emergency.TransactionValidator = emergency.NewMaliceProbabilityValidator(maliceProbabilities)
+	if eventTrace != nil {
+		txValidator = trace.NewValidator(eventTrace, txValidator)
+	}
+	// 出块激励：区块确认后验证器给提议者一次正面评价，并在奖励账本中记账
+	rewardLedger := emergency.NewRewardLedger(1)
+	for _, node := range emergencyNodes {
+		node.SetTransactionValidator(txValidator)
+		node.CheckValidity = true
+		node.ProposerReward = 1
+		node.Rewards = rewardLedger
+		node.RateLimitPenalty = 1
+		node.Logger = console
+	}
+
+	// 节点数不足以组成共识引擎要求的验证器组时，紧急区块链无法确认区块，只运行普通链与信誉计算
+	if err := emergencyNodes[nodeIDs[0]].Engine.CheckValidators(len(nodeIDs)); err != nil {
+		fileLog.Warnf("⚠️ 紧急区块链将不会出块: %v\n", err)
+		console.Warnf("⚠️ 紧急区块链将不会出块: %v\n", err)
 	}
 
 	// 设置对等节点
@@ -267,136 +449,264 @@ func main() {
 	for _, node := range emergencyNodes {
 		node.SetPeers(emergencyNodeList)
 	}
-
-	log.Printf("紧急区块链初始化完成 (PoE共识)\n")
-	log.Printf("验证器组大小: %d (占总节点的 %.0f%%)\n\n", validatorGroupSize, float64(validatorGroupSize)/float64(len(vehicleIDs))*100)
-
-	// 构建轨迹向量
-	trajMap := make(map[string][]reputation.Vector)
-	for _, vid := range vehicleIDs {
-		pts := dataMap[vid]
-		var vecs []reputation.Vector
-		for i := range pts {
-			var dir float64
-			if i > 0 {
-				dx := pts[i].X - pts[i-1].X
-				dy := pts[i].Y - pts[i-1].Y
-				dir = math.Atan2(dy, dx)
-			}
-			vecs = append(vecs, reputation.Vector{
-				Speed:        pts[i].Speed,
-				Direction:    dir,
-				Acceleration: pts[i].Acceleration,
-			})
+	if *msgDrop > 0 {
+		network := emergency.NewLossyNetwork(*msgDrop, 0, 0)
+		for _, node := range emergencyNodes {
+			node.SetNetwork(network)
 		}
-		trajMap[vid] = vecs
+		fileLog.Infof("紧急区块链共识消息经有损网络传输，丢失概率 %.2f\n", *msgDrop)
 	}
 
+	fileLog.Infof("紧急区块链初始化完成 (PoE共识, 共识引擎: %s, 紧急度模型: %s)\n", cfg.EmergencyConsensus, urgencyModel)
+	fileLog.Infof("出块者选择策略: %s\n", validatorGroup.ProposerStrategy)
+	fileLog.Infof("验证器组大小: %d (占总节点的 %.0f%%)\n\n", validatorGroupSize, float64(validatorGroupSize)/float64(len(nodeIDs))*100)
+
+	// 构建轨迹向量：Speed, Direction, Acceleration
+	trajMap := dataloader.BuildVectorMap(dataMap)
+
 	// ======== 运行双链系统 ========
-	rounds := len(trajMap[vehicleIDs[0]])
+	// 车辆可能中途进入或离开场景，总轮数取最长轨迹长度
+	// 重放事件轨迹时轮数由轨迹决定
+	rounds := dataloader.MaxRounds(trajMap)
 	if rounds > 20 { // 限制运行轮数用于演示
 		rounds = 20
 	}
+	if eventTrace != nil {
+		rounds = eventTrace.RoundCount()
+	}
 
-	log.Printf("开始运行双链系统，共 %d 轮\n", rounds)
-	log.Printf("========================================\n\n")
+	// 紧急交互的轨迹：取各节点截至当前轮的轨迹，与普通交互保持一致
+	var currentRound atomic.Int64
+	trajUpTo := func(nodeID string, r int) []reputation.Vector {
+		traj := trajMap[nodeID]
+		return traj[:min(r+1, len(traj))]
+	}
+	trajSource := func(nodeID string) []reputation.Vector {
+		return trajUpTo(nodeID, int(currentRound.Load()))
+	}
+	// 紧急区块记录产生它的轮次（从 1 开始）
+	emergencyBlockchain.SetRoundSource(func() int { return int(currentRound.Load()) + 1 })
+	for _, node := range emergencyNodes {
+		node.SetTrajectorySource(trajSource)
+	}
+
+	fileLog.Infof("开始运行双链系统，共 %d 轮\n", rounds)
+	fileLog.Infof("========================================\n\n")
 
 	interChan := make(chan reputation.Interaction, 1000)
 	var wg sync.WaitGroup
 
-	go func() {
-		for inter := range interChan {
-			normalNodes[inter.To].Rm.AddInteraction(inter)
-			wg.Done()
-		}
-	}()
+	// 交互消费者：InteractionWorkers 个协程并发写入信誉管理器
+	// 各协程之间不保证交互的写入顺序，信誉聚合与写入顺序无关，结果不受影响
+	var consumers sync.WaitGroup
+	for w := 0; w < cfg.InteractionWorkers; w++ {
+		consumers.Add(1)
+		go func() {
+			defer consumers.Done()
+			for inter := range interChan {
+				if err := normalNodes[inter.To].Rm.AddInteraction(inter); err != nil {
+					fileLog.Errorf("错误: 记录交互失败: %v\n", err)
+				}
+				wg.Done()
+			}
+		}()
+	}
+
+	// 优雅退出：收到 SIGINT/SIGTERM 后不再开始新的轮次，
+	// 处理完已发出的交互后照常输出最终统计
+	stopChan := make(chan os.Signal, 1)
+	signal.Notify(stopChan, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stopChan)
+	interrupted := false
+	completedRounds := 0
 
 	// 紧急交易计数器（用于计算θ）
 	emergencyTxCounter := make(map[string]int)
 
+	// 每轮的诚实/恶意信誉区分度，用于观察收敛过程
+	maliciousSet := make(map[string]bool)
+	for _, vid := range vehicleIDs {
+		maliciousSet[vid] = isMalicious(vid)
+	}
+	var discriminationHistory []float64
+
+	// 已写入结构化日志的紧急区块数
+	loggedBlocks := 0
+
+	// 每轮活跃的验证器集合，用于审计各轮由哪一届验证器组出块
+	committees := emergency.NewCommitteeHistory()
+
+	// logProposal 记录一次紧急区块提议的结果
+	logProposal := func(proposerID string, block *emergency.EmergencyBlock, err error) {
+		switch {
+		case err == nil:
+			fileLog.Infof("紧急区块链: 节点 %s 提议的区块 %d 已确认 (%d 笔交易)\n",
+				proposerID, block.Index, len(block.Transactions))
+		case errors.Is(err, emergency.ErrQuorumNotReached):
+			fileLog.Warnf("紧急区块链: 节点 %s 提议的区块 %d 未达成共识: %v\n",
+				proposerID, block.Index, err)
+		default:
+			fileLog.Infof("紧急区块链: 节点 %s 未出块: %v\n", proposerID, err)
+		}
+	}
+
+	// 定时出块模式：紧急区块链按出块周期独立出块，轮次中刷新验证器组时与提议互斥
+	exclusive := func(fn func()) { fn() }
+	var stopProducer context.CancelFunc
+	producerDone := make(chan struct{})
+	if *tickerMode {
+		producer := emergency.NewBlockProducer(emergencyBlockchain, validatorGroup, emergencyNodes)
+		producer.OnProposal = logProposal
+		exclusive = producer.Exclusive
+
+		var ctx context.Context
+		ctx, stopProducer = context.WithCancel(context.Background())
+		go func() {
+			defer close(producerDone)
+			if err := producer.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				fileLog.Errorf("错误: 紧急区块链定时出块失败: %v\n", err)
+			}
+		}()
+		fileLog.Infof("紧急区块链定时出块: 每 %v 出块一次\n\n", *blockPeriod)
+	}
+
 	for r := 0; r < rounds; r++ {
+		select {
+		case sig := <-stopChan:
+			fileLog.Infof("收到信号 %v，在第 %d 轮前停止模拟\n\n", sig, r+1)
+			console.Warnf("收到信号 %v，停止模拟并输出已完成 %d 轮的结果\n", sig, r)
+			interrupted = true
+		default:
+		}
+		if interrupted {
+			break
+		}
+
 		roundStartTime := time.Now()
+		currentRound.Store(int64(r))
 
-		fmt.Printf("\n========== 第 %d 轮 ==========\n", r+1)
-		log.Printf("========== 第 %d 轮 ==========\n", r+1)
+		console.Infof("\n========== 第 %d 轮 ==========\n", r+1)
+		fileLog.Infof("========== 第 %d 轮 ==========\n", r+1)
 
 		// 1. 普通区块链：提议区块
 		proposer := normalNodes[vehicleIDs[r%len(vehicleIDs)]]
 		proposer.Propose([]byte(fmt.Sprintf("Normal Round %d", r+1)))
-		log.Printf("普通区块链: 节点 %s 提议区块\n", proposer.ID)
-
-		// 2. 信誉交互（与原代码类似，但简化）
-		for _, sender := range vehicleIDs {
-			// 随机选择几个接收者进行交互
-			numInteractions := rand.Intn(3) // 0-2次交互
-			for k := 0; k < numInteractions; k++ {
-				receiver := vehicleIDs[rand.Intn(len(vehicleIDs))]
-				if receiver == sender {
-					continue
-				}
-
-				raw := dataMap[sender][r]
-				baseTime := time.Now().Add(-time.Duration(raw.Time) * time.Second)
-				delay := time.Duration(rand.Intn(500)) * time.Millisecond
-				ts := baseTime.Add(delay)
-
-				var posEvents, negEvents int
-				if isMalicious(sender) {
-					posEvents = 0
-					negEvents = 1
-				} else {
-					posEvents = 1
-					negEvents = 0
-				}
+		fileLog.Infof("普通区块链: 节点 %s 提议区块\n", proposer.ID)
+
+		// 2. 信誉交互（与原代码类似，但简化；重放时按事件轨迹产生）
+		// 本轮只有有轨迹数据的车辆参与交互，RSU 只作为接收者
+		var counts roundlog.InteractionCounts
+		// recordInteraction 发送者 sender 向 receiver 发送一笔交易，receiver 按 malicious 给出评价
+		recordInteraction := func(sender, receiver string, malicious bool, ts time.Time) {
+			var posEvents, negEvents int
+			if malicious {
+				posEvents = 0
+				negEvents = 1
+			} else {
+				posEvents = 1
+				negEvents = 0
+			}
+			// RSU 没有轨迹，其参与的交互不计算轨迹相似度
+			var trajUser []reputation.Vector
+			if !cfg.IsRSU(receiver) {
+				trajUser = trajUpTo(receiver, r)
+			}
 
-				inter := reputation.Interaction{
-					From:          receiver,
-					To:            sender,
-					PosEvents:     posEvents,
-					NegEvents:     negEvents,
-					Timestamp:     ts,
-					TrajUser:      trajMap[receiver][:r+1],
-					TrajProvider:  trajMap[sender][:r+1],
-					TxType:        reputation.NormalTransaction, // ⭐ 标记为普通交易
-					UrgencyDegree: 0.0,                          // 普通交易无紧急度
+			inter := reputation.Interaction{
+				From:          receiver,
+				To:            sender,
+				PosEvents:     posEvents,
+				NegEvents:     negEvents,
+				Timestamp:     ts,
+				TrajUser:      trajUser,
+				TrajProvider:  trajUpTo(sender, r),
+				TxType:        reputation.NormalTransaction, // ⭐ 标记为普通交易
+				UrgencyDegree: 0.0,                          // 普通交易无紧急度
+			}
+			wg.Add(1)
+			interChan <- inter
+
+			counts.Total++
+			if isMalicious(sender) {
+				counts.Malicious++
+			} else {
+				counts.Honest++
+			}
+		}
+		if eventTrace != nil {
+			for _, inter := range eventTrace.InteractionsIn(r + 1) {
+				recordInteraction(inter.Sender, inter.Receiver, inter.Malicious, inter.Timestamp(time.Now()))
+			}
+		} else {
+			activeIDs := dataloader.ActiveVehicles(trajMap, vehicleIDs, r)
+			receiverIDs := append(slices.Clone(activeIDs), rsuIDs...)
+			for _, sender := range activeIDs {
+				// 随机选择几个接收者进行交互
+				numInteractions := rand.Intn(3) // 0-2次交互
+				for k := 0; k < numInteractions; k++ {
+					receiver := receiverIDs[rand.Intn(len(receiverIDs))]
+					if receiver == sender {
+						continue
+					}
+
+					raw := dataMap[sender][r]
+					baseTime := time.Now().Add(-time.Duration(raw.Time) * time.Second)
+					delay := time.Duration(rand.Intn(500)) * time.Millisecond
+					recordInteraction(sender, receiver, isMaliciousTx(sender), baseTime.Add(delay))
 				}
-				wg.Add(1)
-				interChan <- inter
 			}
 		}
 		wg.Wait()
 
-		// 3. 更新验证器节点组（每轮或定期更新）
-		if r == 0 || validatorGroup.NeedRefresh() {
-			validatorGroup.SelectValidators(vehicleIDs, reputationManagers, time.Now())
-			log.Printf("\n验证器节点组已更新:\n")
+		// 3. 更新验证器节点组（活跃周期结束，或有验证器信誉跌破驱逐阈值时更新）
+		evicted := validatorGroup.ShouldEvict(reputationManagers, time.Now())
+		refreshed := r == 0 || validatorGroup.NeedRefresh() || len(evicted) > 0
+		if refreshed {
+			if len(evicted) > 0 {
+				fileLog.Infof("\n验证器 %v 的信誉值低于驱逐阈值 %.2f，提前刷新验证器组\n", evicted, validatorGroup.EvictionThreshold)
+				console.Infof("验证器 %v 的信誉值低于驱逐阈值，提前刷新验证器组\n", evicted)
+			}
+			exclusive(func() {
+				validatorGroup.SelectValidators(nodeIDs, reputationManagers, time.Now())
+				// 更新所有节点的验证器状态
+				for _, node := range emergencyNodes {
+					node.UpdateValidatorStatus()
+				}
+			})
+			fileLog.Infof("\n验证器节点组已更新:\n")
 			for i, v := range validatorGroup.Validators {
-				log.Printf("  验证器 %d: 节点 %s (信誉值=%.4f)\n", i+1, v.ID, v.Reputation)
+				fileLog.Infof("  验证器 %d: 节点 %s (信誉值=%.4f)\n", i+1, v.ID, v.Reputation)
 			}
-			log.Printf("\n")
+			fileLog.Infof("\n")
 
-			// 更新所有节点的验证器状态
-			for _, node := range emergencyNodes {
-				node.UpdateValidatorStatus()
-			}
+			console.Infof("验证器节点组已更新，共 %d 个验证器\n", len(validatorGroup.Validators))
 
-			fmt.Printf("验证器节点组已更新，共 %d 个验证器\n", len(validatorGroup.Validators))
+			// 混入验证器组的恶意节点超过可容忍的拜占庭节点数时告警
+			var maliciousIDs []string
+			for vid, bad := range maliciousSet {
+				if bad {
+					maliciousIDs = append(maliciousIDs, vid)
+				}
+			}
+			if !validatorGroup.IsSafe(maliciousIDs) {
+				fileLog.Warnf("⚠️ 验证器组中的恶意节点超过可容忍的拜占庭节点数 f=%d\n", validatorGroup.ByzantineTolerance())
+				console.Warnf("⚠️ 验证器组中的恶意节点超过可容忍的拜占庭节点数 f=%d\n", validatorGroup.ByzantineTolerance())
+			}
 		}
 
-		// 4. 生成紧急交易（随机生成1-3笔）
-		numEmergencyTx := 1 + rand.Intn(3)
-		for i := 0; i < numEmergencyTx; i++ {
-			// 随机选择一个节点发送紧急交易
-			senderID := vehicleIDs[rand.Intn(len(vehicleIDs))]
-			emergencyTxCounter[senderID]++
+		// 记录本轮活跃的验证器集合（出块前，含刷新与中途变更后的结果）
+		var committee []emergency.Validator
+		exclusive(func() { committee = committees.Record(r+1, validatorGroup) })
 
-			// 生成紧急交易
-			productTime := time.Now().Add(-time.Duration(rand.Intn(5)) * time.Second)
-			deadlineTime := time.Now().Add(time.Duration(5+rand.Intn(10)) * time.Second)
+		// 4. 生成紧急交易（随机生成 MinEmergencyTxPerRound~MaxEmergencyTxPerRound 笔，重放时按事件轨迹生成）
+		// 每轮生成数超过区块大小时交易池会积压，可通过每轮输出的交易池大小观察
+		// submitEmergencyTx 生成紧急交易并广播到所有节点的交易池
+		submitEmergencyTx := func(id, senderID string, productTime, deadlineTime time.Time) {
+			emergencyTxCounter[senderID]++
 			arrivalTime := time.Now()
 
 			tx := emergency.NewEmergencyTransaction(
-				fmt.Sprintf("ETx-%d-%s-%d", r, senderID, i),
+				id,
 				senderID,
 				[]byte(fmt.Sprintf("Emergency data from %s", senderID)),
 				productTime,
@@ -405,112 +715,258 @@ func main() {
 				emergencyTxCounter[senderID],
 				urgencyCfg,
 			)
+			// 紧急交易依赖发送者所见的最新普通区块（本轮的位置数据）
+			tx.NormalChainRef = normalNodes[senderID].Height()
 
 			// 广播到所有节点的交易池
 			for _, node := range emergencyNodes {
 				node.AddEmergencyTransaction(tx)
 			}
 
-			fmt.Printf("紧急交易: %s (发送者=%s, 紧急度=%.4f)\n", tx.ID, senderID, tx.UrgencyDegree)
-			log.Printf("紧急交易: %s (发送者=%s, 紧急度=%.4f)\n", tx.ID, senderID, tx.UrgencyDegree)
+			console.Debugf("紧急交易: %s (发送者=%s, 紧急度=%.4f)\n", tx.ID, senderID, tx.UrgencyDegree)
+			fileLog.Debugf("紧急交易: %s (发送者=%s, 紧急度=%.4f)\n", tx.ID, senderID, tx.UrgencyDegree)
+		}
+		if eventTrace != nil {
+			now := time.Now()
+			for _, tx := range eventTrace.EmergencyTransactionsIn(r + 1) {
+				submitEmergencyTx(tx.ID, tx.Sender, tx.ProductTime(now), tx.DeadlineTime(now))
+			}
+		} else {
+			numEmergencyTx := cfg.MinEmergencyTxPerRound
+			if cfg.MaxEmergencyTxPerRound > cfg.MinEmergencyTxPerRound {
+				numEmergencyTx += rand.Intn(cfg.MaxEmergencyTxPerRound - cfg.MinEmergencyTxPerRound + 1)
+			}
+			for i := 0; i < numEmergencyTx; i++ {
+				// 随机选择一个节点发送紧急交易
+				senderID := vehicleIDs[rand.Intn(len(vehicleIDs))]
+				productTime := time.Now().Add(-time.Duration(rand.Intn(5)) * time.Second)
+				deadlineTime := time.Now().Add(time.Duration(5+rand.Intn(10)) * time.Second)
+				submitEmergencyTx(fmt.Sprintf("ETx-%d-%s-%d", r, senderID, i), senderID, productTime, deadlineTime)
+			}
 		}
 
-		// 5. 紧急区块链：验证器节点提议紧急区块
-		if validatorGroup.GetSize() > 0 {
-			proposerValidator := validatorGroup.SelectProposer()
+		// 5. 紧急区块链：验证器节点提议紧急区块（定时出块模式下由出块驱动器异步完成）
+		var emergencyProposerID string
+		if !*tickerMode && validatorGroup.GetSize() > 0 {
+			proposerValidator := validatorGroup.NextProposer()
 			if proposerValidator != nil {
 				emergencyProposer := emergencyNodes[proposerValidator.ID]
+				emergencyProposerID = emergencyProposer.ID
 
 				// 等待一小段时间让交易广播完成
 				time.Sleep(100 * time.Millisecond)
 
-				emergencyProposer.ProposeEmergencyBlock()
+				block, err := emergencyProposer.ProposeEmergencyBlock()
+				logProposal(emergencyProposer.ID, block, err)
 
-				// 等待共识完成
+				// 等待其余验证器完成提交，确定链顶后各节点记录信誉交互与出块奖励
 				time.Sleep(500 * time.Millisecond)
+				for _, node := range emergencyNodes {
+					node.SettleCommits()
+				}
 			}
 		}
 
 		// 增加验证器组轮数
-		validatorGroup.IncrementRound()
+		exclusive(validatorGroup.IncrementRound)
+
+		// 本轮诚实/恶意信誉区分度
+		// 同时计算朴素基线 pos/(pos+neg)，用于对比模型相对简单计数的区分能力
+		roundReputations := make(map[string]float64)
+		roundSimpleScores := make(map[string]float64)
+		// 各节点的信誉管理器缓存上一轮的意见，只重新计算新增交互或随时间衰减的被评价者
+		for _, vid := range vehicleIDs {
+			roundReputations[vid] = normalNodes[vid].Rm.ComputeReputationDelta([]string{vid}, time.Now())[vid]
+			roundSimpleScores[vid] = normalNodes[vid].Rm.SimpleScore(vid)
+		}
+		score := reputation.DiscriminationScore(roundReputations, maliciousSet)
+		simpleScore := reputation.DiscriminationScore(roundSimpleScores, maliciousSet)
+		discriminationHistory = append(discriminationHistory, score)
 
 		// 输出当前状态
-		fmt.Printf("\n普通区块链长度: %d\n", len(proposer.ledger))
-		fmt.Printf("紧急区块链长度: %d\n", emergencyBlockchain.GetChainLength())
-		fmt.Printf("紧急交易池大小: %d\n", emergencyBlockchain.TxPool.Size())
+		console.Infof("\n普通区块链长度: %d\n", len(proposer.ledger))
+		console.Infof("紧急区块链长度: %d\n", emergencyBlockchain.GetChainLength())
+		console.Infof("紧急交易池大小: %d\n", emergencyBlockchain.TxPool.Size())
+		console.Infof("区分度: %.6f (简单评分区分度: %.6f)\n", score, simpleScore)
+
+		fileLog.Infof("\n状态统计:\n")
+		fileLog.Infof("  普通区块链长度: %d\n", len(proposer.ledger))
+		fileLog.Infof("  紧急区块链长度: %d\n", emergencyBlockchain.GetChainLength())
+		fileLog.Infof("  紧急交易池大小: %d\n", emergencyBlockchain.TxPool.Size())
+		fileLog.Infof("  区分度: %.6f\n", score)
+		fileLog.Infof("  简单评分区分度: %.6f (模型高出 %.6f)\n", simpleScore, score-simpleScore)
+		fileLog.Infof("  各节点信誉值 / 简单评分:\n")
+		for _, vid := range vehicleIDs {
+			fileLog.Infof("    节点 %s: %.6f / %.6f\n", vid, roundReputations[vid], roundSimpleScores[vid])
+		}
+		fileLog.Infof("  本轮耗时: %v\n", time.Since(roundStartTime))
+		fileLog.Infof("========================================\n\n")
 
-		log.Printf("\n状态统计:\n")
-		log.Printf("  普通区块链长度: %d\n", len(proposer.ledger))
-		log.Printf("  紧急区块链长度: %d\n", emergencyBlockchain.GetChainLength())
-		log.Printf("  紧急交易池大小: %d\n", emergencyBlockchain.TxPool.Size())
-		log.Printf("  本轮耗时: %v\n", time.Since(roundStartTime))
-		log.Printf("========================================\n\n")
+		console.Infof("本轮耗时: %v\n", time.Since(roundStartTime))
 
-		fmt.Printf("本轮耗时: %v\n", time.Since(roundStartTime))
+		if jsonLog != nil {
+			roundOpinions := make(map[string]roundlog.Opinion)
+			for _, vid := range vehicleIDs {
+				if op, ok := normalNodes[vid].Rm.ComputeOpinion(vid, time.Now()); ok {
+					roundOpinions[vid] = roundlog.Opinion{T: op.T, D: op.D, I: op.I}
+				}
+			}
+			rec := roundlog.RoundRecord{
+				Round:               r + 1,
+				Proposer:            proposer.ID,
+				EmergencyProposer:   emergencyProposerID,
+				Reputations:         roundReputations,
+				Opinions:            roundOpinions,
+				Interactions:        counts,
+				Validators:          committees.IDs(r + 1),
+				DiscriminationScore: score,
+			}
+			rec.ValidatorReputations = make(map[string]float64, len(committee))
+			for _, v := range committee {
+				rec.ValidatorReputations[v.ID] = v.Reputation
+			}
+			if refreshed {
+				rec.SelectionReputations = validatorGroup.CandidateReputations
+			}
+			blocks := emergencyBlockchain.Blocks()
+			for _, block := range blocks[min(loggedBlocks, len(blocks)):] {
+				rec.EmergencyBlocks = append(rec.EmergencyBlocks, roundlog.BlockRecord{
+					Index:    block.Index,
+					Hash:     block.Hash,
+					PrevHash: block.PrevHash,
+				})
+			}
+			loggedBlocks = len(blocks)
+			err := jsonLog.Write(rec)
+			if err != nil {
+				fileLog.Errorf("错误: 写入结构化日志失败: %v\n", err)
+			}
+		}
+
+		completedRounds++
+
+		// 定时出块模式下普通链按 roundPeriod 的节奏推进，紧急区块链在此期间独立出块
+		if *tickerMode {
+			time.Sleep(time.Until(roundStartTime.Add(*roundPeriod)))
+		}
 	}
 
+	if stopProducer != nil {
+		stopProducer()
+		<-producerDone
+	}
 	close(interChan)
+	consumers.Wait()
 
 	// ======== 输出最终统计 ========
-	fmt.Printf("\n\n╔════════════════════════════════════════╗\n")
-	fmt.Printf("║         双链系统运行总结               ║\n")
-	fmt.Printf("╚════════════════════════════════════════╝\n\n")
+	console.Infof("\n\n╔════════════════════════════════════════╗\n")
+	console.Infof("║         双链系统运行总结               ║\n")
+	console.Infof("╚════════════════════════════════════════╝\n\n")
 
-	log.Printf("\n\n╔════════════════════════════════════════╗\n")
-	log.Printf("║         双链系统运行总结               ║\n")
-	log.Printf("╚════════════════════════════════════════╝\n\n")
+	fileLog.Infof("\n\n╔════════════════════════════════════════╗\n")
+	fileLog.Infof("║         双链系统运行总结               ║\n")
+	fileLog.Infof("╚════════════════════════════════════════╝\n\n")
 
 	// 输出普通区块链统计
-	fmt.Printf("【普通区块链 - PBFT共识】\n")
-	fmt.Printf("  所有节点参与: %d 个节点\n", len(vehicleIDs))
-	fmt.Printf("  区块总数: %d\n", len(normalNodes[vehicleIDs[0]].ledger))
+	console.Infof("完成轮数: %d/%d\n", completedRounds, rounds)
+	fileLog.Infof("完成轮数: %d/%d\n", completedRounds, rounds)
 
-	log.Printf("【普通区块链 - PBFT共识】\n")
-	log.Printf("  所有节点参与: %d 个节点\n", len(vehicleIDs))
-	log.Printf("  区块总数: %d\n", len(normalNodes[vehicleIDs[0]].ledger))
+	console.Infof("【普通区块链 - PBFT共识】\n")
+	console.Infof("  所有节点参与: %d 个节点\n", len(nodeIDs))
+	console.Infof("  区块总数: %d\n", len(normalNodes[vehicleIDs[0]].ledger))
+
+	fileLog.Infof("【普通区块链 - PBFT共识】\n")
+	fileLog.Infof("  所有节点参与: %d 个节点\n", len(nodeIDs))
+	fileLog.Infof("  区块总数: %d\n", len(normalNodes[vehicleIDs[0]].ledger))
 
 	// 输出紧急区块链统计
-	fmt.Printf("\n【紧急区块链 - PoE共识】\n")
-	fmt.Printf("  验证器节点: %d 个 (%.0f%%)\n", validatorGroup.GetSize(),
-		float64(validatorGroup.GetSize())/float64(len(vehicleIDs))*100)
-	fmt.Printf("  区块总数: %d\n", emergencyBlockchain.GetChainLength()-1) // 减去创世区块
+	console.Infof("\n【紧急区块链 - PoE共识】\n")
+	console.Infof("  验证器节点: %d 个 (%.0f%%)\n", validatorGroup.GetSize(),
+		float64(validatorGroup.GetSize())/float64(len(nodeIDs))*100)
+	console.Infof("  区块总数: %d\n", emergencyBlockchain.GetChainLength()-1) // 减去创世区块
 
-	log.Printf("\n【紧急区块链 - PoE共识】\n")
-	log.Printf("  验证器节点: %d 个 (%.0f%%)\n", validatorGroup.GetSize(),
-		float64(validatorGroup.GetSize())/float64(len(vehicleIDs))*100)
-	log.Printf("  区块总数: %d\n", emergencyBlockchain.GetChainLength()-1)
+	fileLog.Infof("\n【紧急区块链 - PoE共识】\n")
+	fileLog.Infof("  验证器节点: %d 个 (%.0f%%)\n", validatorGroup.GetSize(),
+		float64(validatorGroup.GetSize())/float64(len(nodeIDs))*100)
+	fileLog.Infof("  区块总数: %d\n", emergencyBlockchain.GetChainLength()-1)
 
 	// 统计紧急区块中的交易
 	totalEmergencyTx := 0
 	var totalUrgency float64
-	for i := 1; i < len(emergencyBlockchain.Chain); i++ {
-		block := emergencyBlockchain.Chain[i]
+	for _, block := range emergencyBlockchain.Blocks()[1:] {
 		totalEmergencyTx += len(block.Transactions)
 		totalUrgency += block.TotalUrgency
 	}
 
-	fmt.Printf("  紧急交易总数: %d\n", totalEmergencyTx)
+	console.Infof("  紧急交易总数: %d\n", totalEmergencyTx)
 	if totalEmergencyTx > 0 {
-		fmt.Printf("  平均紧急度: %.4f\n", totalUrgency/float64(totalEmergencyTx))
+		console.Infof("  平均紧急度: %.4f\n", totalUrgency/float64(totalEmergencyTx))
 	}
 
-	log.Printf("  紧急交易总数: %d\n", totalEmergencyTx)
+	fileLog.Infof("  紧急交易总数: %d\n", totalEmergencyTx)
 	if totalEmergencyTx > 0 {
-		log.Printf("  平均紧急度: %.4f\n", totalUrgency/float64(totalEmergencyTx))
+		fileLog.Infof("  平均紧急度: %.4f\n", totalUrgency/float64(totalEmergencyTx))
+	}
+
+	poolStats := emergencyBlockchain.TxPool.PoolStats()
+	console.Infof("  交易池: 接收 %d 笔, 上链 %d, 过期 %d, 淘汰 %d, 待处理 %d\n", poolStats.Received,
+		poolStats.Committed, poolStats.Expired, poolStats.Evicted, poolStats.Pending)
+	console.Infof("  交易池等待时间: 平均 %v, 最长 %v\n",
+		poolStats.AvgWait.Round(time.Millisecond), poolStats.MaxWait.Round(time.Millisecond))
+	console.Infof("  交易池积压: 峰值 %d, 时间加权平均 %.2f\n", poolStats.MaxBacklog, poolStats.AvgBacklog)
+	console.Infof("  上链交易紧急度: %v\n", poolStats.CommittedUrgency)
+	console.Infof("  过期/淘汰交易紧急度: %v\n", poolStats.DroppedUrgency)
+
+	fileLog.Infof("  交易池: 接收 %d 笔, 上链 %d, 过期 %d, 淘汰 %d, 待处理 %d\n", poolStats.Received,
+		poolStats.Committed, poolStats.Expired, poolStats.Evicted, poolStats.Pending)
+	fileLog.Infof("  交易池等待时间: 平均 %v, 最长 %v\n",
+		poolStats.AvgWait.Round(time.Millisecond), poolStats.MaxWait.Round(time.Millisecond))
+	fileLog.Infof("  交易池积压: 峰值 %d, 时间加权平均 %.2f\n", poolStats.MaxBacklog, poolStats.AvgBacklog)
+	fileLog.Infof("  上链交易紧急度: %v\n", poolStats.CommittedUrgency)
+	fileLog.Infof("  过期/淘汰交易紧急度: %v\n", poolStats.DroppedUrgency)
+	if len(poolStats.Backlog) > 0 {
+		fileLog.Infof("  交易池积压变化（距首次采样的时间: 待处理交易数）:\n")
+		for _, sample := range poolStats.Backlog {
+			fileLog.Infof("    %v: %d\n", sample.At.Sub(poolStats.Backlog[0].At).Round(time.Millisecond), sample.Size)
+		}
+	}
+
+	if limiter := emergencyBlockchain.RateLimiter; limiter != nil {
+		console.Infof("  超限被拒绝的紧急交易: %d\n", limiter.RejectedCount())
+		fileLog.Infof("  超限被拒绝的紧急交易: %d\n", limiter.RejectedCount())
+	}
+
+	if *txPoolPath != "" {
+		if err := emergencyBlockchain.TxPool.SaveJSON(*txPoolPath); err != nil {
+			fileLog.Errorf("错误: 保存紧急交易池失败: %v\n", err)
+			console.Errorf("保存紧急交易池失败: %v\n", err)
+		} else {
+			fileLog.Infof("  紧急交易池已保存到 %s\n", *txPoolPath)
+		}
+	}
+
+	// 输出出块奖励
+	console.Infof("  出块奖励:\n")
+	fileLog.Infof("  出块奖励:\n")
+	for _, vid := range nodeIDs {
+		if n := rewardLedger.ProposedBlocks(vid); n > 0 {
+			console.Infof("    节点 %s: 成功出块 %d 个, 累计奖励 %.2f\n", vid, n, rewardLedger.Reward(vid))
+			fileLog.Infof("    节点 %s: 成功出块 %d 个, 累计奖励 %.2f\n", vid, n, rewardLedger.Reward(vid))
+		}
 	}
 
 	// 输出验证器节点信息
-	fmt.Printf("\n【验证器节点信息】\n")
-	log.Printf("\n【验证器节点信息】\n")
+	console.Infof("\n【验证器节点信息】\n")
+	fileLog.Infof("\n【验证器节点信息】\n")
 
 	for i, v := range validatorGroup.Validators {
-		fmt.Printf("  第 %d 名: 节点 %s (信誉值=%.4f)\n", i+1, v.ID, v.Reputation)
-		log.Printf("  第 %d 名: 节点 %s (信誉值=%.4f)\n", i+1, v.ID, v.Reputation)
+		console.Infof("  第 %d 名: 节点 %s (信誉值=%.4f)\n", i+1, v.ID, v.Reputation)
+		fileLog.Infof("  第 %d 名: 节点 %s (信誉值=%.4f)\n", i+1, v.ID, v.Reputation)
 	}
 
 	// 输出所有节点的最终信誉值
-	fmt.Printf("\n【所有节点最终信誉值】\n")
-	log.Printf("\n【所有节点最终信誉值】\n")
+	console.Infof("\n【所有节点最终信誉值】\n")
+	fileLog.Infof("\n【所有节点最终信誉值】\n")
 
 	type NodeReputation struct {
 		ID          string
@@ -519,7 +975,7 @@ func main() {
 	}
 	var allNodeReputation []NodeReputation
 
-	for _, vid := range vehicleIDs {
+	for _, vid := range nodeIDs {
 		repu := normalNodes[vid].Rm.ComputeReputation(vid, time.Now())
 		isValidator := validatorGroup.IsValidator(vid)
 		allNodeReputation = append(allNodeReputation, NodeReputation{
@@ -535,6 +991,9 @@ func main() {
 
 	for i, nr := range allNodeReputation {
 		nodeType := "普通节点"
+		if cfg.IsRSU(nr.ID) {
+			nodeType = "RSU"
+		}
 		if nr.IsValidator {
 			nodeType = "✅验证器"
 		}
@@ -542,16 +1001,53 @@ func main() {
 			nodeType += " ⚠️恶意"
 		}
 
-		fmt.Printf("  第 %d 名: 节点 %s [%s] = %.6f\n", i+1, nr.ID, nodeType, nr.Reputation)
-		log.Printf("  第 %d 名: 节点 %s [%s] = %.6f\n", i+1, nr.ID, nodeType, nr.Reputation)
+		console.Infof("  第 %d 名: 节点 %s [%s] = %.6f\n", i+1, nr.ID, nodeType, nr.Reputation)
+		fileLog.Infof("  第 %d 名: 节点 %s [%s] = %.6f\n", i+1, nr.ID, nodeType, nr.Reputation)
+	}
+
+	// 以信誉阈值判定恶意节点的检测效果（只评估车辆，RSU 不参与恶意判定）
+	finalReputations := make(map[string]float64, len(vehicleIDs))
+	for _, nr := range allNodeReputation {
+		if !cfg.IsRSU(nr.ID) {
+			finalReputations[nr.ID] = nr.Reputation
+		}
+	}
+	configured := reputation.EvaluateDetection(finalReputations, maliciousSet, cfg.DetectionThreshold)
+	optimal := reputation.OptimalDetectionThreshold(finalReputations, maliciousSet)
+	console.Infof("\n【恶意节点检测效果（信誉值低于阈值判定为恶意）】\n")
+	console.Infof("  配置阈值: %v\n", configured)
+	console.Infof("  最优阈值: %v\n", optimal)
+	fileLog.Infof("\n【恶意节点检测效果（信誉值低于阈值判定为恶意）】\n")
+	fileLog.Infof("  配置阈值: %v\n", configured)
+	fileLog.Infof("  最优阈值: %v\n", optimal)
+
+	// 信誉按交易类型拆分，观察紧急交易的加权对信誉的影响
+	// 普通交互记录在被评价者的管理器中，紧急交互记录在评价它的验证器的管理器中，合并后才是完整的双链视图
+	combined := reputation.NewReputationManager(cfg)
+	combined.SetLogger(logging.Discard())
+	for _, vid := range nodeIDs {
+		if err := combined.Merge(normalNodes[vid].Rm); err != nil {
+			fileLog.Warnf("合并节点 %s 的交互记录: %v\n", vid, err)
+			console.Warnf("合并节点 %s 的交互记录: %v\n", vid, err)
+		}
+	}
+	fileLog.Infof("\n【信誉按交易类型拆分（普通 / 紧急，合并全部节点的交互记录）】\n")
+	for _, vid := range vehicleIDs {
+		normalRepu, emergencyRepu := combined.ReputationByTxType(vid, time.Now())
+		fileLog.Infof("  节点 %s: %.6f / %.6f\n", vid, normalRepu, emergencyRepu)
+	}
+
+	fileLog.Infof("\n区分度变化（每轮）:\n")
+	for i, score := range discriminationHistory {
+		fileLog.Infof("  第 %d 轮: %.6f\n", i+1, score)
 	}
 
-	fmt.Printf("\n========================================\n")
-	fmt.Printf("双链系统运行完成！\n")
-	fmt.Printf("详细日志已保存到 dualchain_log.txt\n")
-	fmt.Printf("========================================\n")
+	console.Infof("\n========================================\n")
+	console.Infof("双链系统运行完成！\n")
+	console.Infof("详细日志已保存到 dualchain_log.txt\n")
+	console.Infof("========================================\n")
 
-	log.Printf("\n========================================\n")
-	log.Printf("结束时间: %s\n", time.Now().Format("2006-01-02 15:04:05"))
-	log.Printf("========================================\n")
+	fileLog.Infof("\n========================================\n")
+	fileLog.Infof("结束时间: %s\n", time.Now().Format("2006-01-02 15:04:05"))
+	fileLog.Infof("========================================\n")
 }
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"block/config"
+	"block/reputation"
+)
+
+// 信誉计算复现工具：读取 JSON 数组格式的交互记录，全部加入信誉管理器后输出每个节点的信誉值，
+// 不经过轨迹数据读取与 PBFT 模拟，用于为信誉计算相关的问题附上最小复现
+// 交互记录的 JSON 格式见 reputation.Interaction 的说明，示例见 example.json
+func main() {
+	inputPath := flag.String("in", "", "交互记录文件路径（JSON 数组）")
+	configPath := flag.String("config", "config/config.json", "配置文件路径")
+	nowFlag := flag.String("now", "", "计算信誉的时刻（RFC 3339），默认取最晚交互的时间戳")
+	flag.Parse()
+
+	if *inputPath == "" {
+		fmt.Println("用法: repl -in interactions.json [-config config/config.json] [-now 2024-01-01T00:00:00Z]")
+		os.Exit(2)
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Println("加载配置失败:", err)
+		os.Exit(2)
+	}
+
+	data, err := os.ReadFile(*inputPath)
+	if err != nil {
+		fmt.Println("读取交互记录失败:", err)
+		os.Exit(2)
+	}
+	var interactions []reputation.Interaction
+	if err := json.Unmarshal(data, &interactions); err != nil {
+		fmt.Println("解析交互记录失败:", err)
+		os.Exit(2)
+	}
+
+	rm := reputation.NewReputationManager(cfg)
+	var latest time.Time
+	for i, inter := range interactions {
+		if err := rm.AddInteraction(inter); err != nil {
+			fmt.Printf("跳过第 %d 条交互: %v\n", i+1, err)
+			continue
+		}
+		if inter.Timestamp.After(latest) {
+			latest = inter.Timestamp
+		}
+	}
+
+	now := latest
+	if *nowFlag != "" {
+		now, err = time.Parse(time.RFC3339, *nowFlag)
+		if err != nil {
+			fmt.Println("解析 -now 失败:", err)
+			os.Exit(2)
+		}
+	}
+
+	reputations := rm.ComputeAllReputations(now)
+	ids := make([]string, 0, len(reputations))
+	for id := range reputations {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	fmt.Printf("共 %d 条交互，计算时刻 %s\n", len(interactions), now.Format(time.RFC3339))
+	for _, id := range ids {
+		fmt.Printf("节点 %s: 信誉值 %.6f\n", id, reputations[id])
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+
+	"block/roundlog"
+)
+
+// Violation 一条不变量违例
+type Violation struct {
+	Round   int
+	Message string
+}
+
+// 重放 -logjson 产生的结构化日志并检查以下不变量：
+//  1. 信誉值在 [0,1] 内（NaN 视为违例）
+//  2. 每个融合意见满足 T+D+I≈1
+//  3. 重选验证器的轮次中，验证器均来自信誉最高的候选节点（假设未启用探索席位）
+//  4. 紧急区块高度连续，且父哈希指向前一个区块
+func main() {
+	logPath := flag.String("log", "", "结构化日志路径（JSON Lines，由 -logjson 生成）")
+	tol := flag.Float64("tol", 1e-6, "浮点比较容差")
+	flag.Parse()
+
+	if *logPath == "" {
+		fmt.Println("用法: verify -log dualchain_log.jsonl")
+		os.Exit(2)
+	}
+
+	records, err := roundlog.ReadAll(*logPath)
+	if err != nil {
+		fmt.Println("读取日志失败:", err)
+		os.Exit(2)
+	}
+
+	var violations []Violation
+	var prevBlock *roundlog.BlockRecord
+	for _, rec := range records {
+		violations = append(violations, checkReputations(rec, *tol)...)
+		violations = append(violations, checkOpinions(rec, *tol)...)
+		violations = append(violations, checkValidators(rec, *tol)...)
+
+		var blockViolations []Violation
+		blockViolations, prevBlock = checkBlocks(rec, prevBlock)
+		violations = append(violations, blockViolations...)
+	}
+
+	fmt.Printf("共检查 %d 轮记录\n", len(records))
+	if len(violations) == 0 {
+		fmt.Println("✅ 所有不变量均成立")
+		return
+	}
+
+	for _, v := range violations {
+		fmt.Printf("第 %d 轮: %s\n", v.Round, v.Message)
+	}
+	fmt.Printf("⚠️ 共发现 %d 处违例\n", len(violations))
+	os.Exit(1)
+}
+
+// sortedKeys 返回按字典序排列的键，保证报告顺序稳定
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// checkReputations 检查信誉值在 [0,1] 内
+func checkReputations(rec roundlog.RoundRecord, tol float64) []Violation {
+	var violations []Violation
+	for _, id := range sortedKeys(rec.Reputations) {
+		repu := rec.Reputations[id]
+		if math.IsNaN(repu) || repu < -tol || repu > 1+tol {
+			violations = append(violations, Violation{rec.Round,
+				fmt.Sprintf("节点 %s 的信誉值 %.6f 超出 [0,1]", id, repu)})
+		}
+	}
+	return violations
+}
+
+// checkOpinions 检查融合意见满足 T+D+I≈1
+func checkOpinions(rec roundlog.RoundRecord, tol float64) []Violation {
+	var violations []Violation
+	for _, id := range sortedKeys(rec.Opinions) {
+		op := rec.Opinions[id]
+		sum := op.T + op.D + op.I
+		if math.IsNaN(sum) || math.Abs(sum-1) > tol {
+			violations = append(violations, Violation{rec.Round,
+				fmt.Sprintf("节点 %s 的意见 T=%.6f D=%.6f I=%.6f，T+D+I=%.6f ≠ 1", id, op.T, op.D, op.I, sum)})
+		}
+	}
+	return violations
+}
+
+// checkValidators 检查重选的验证器均不低于未入选的候选节点
+func checkValidators(rec roundlog.RoundRecord, tol float64) []Violation {
+	if len(rec.SelectionReputations) == 0 {
+		return nil
+	}
+
+	selected := make(map[string]bool)
+	minSelected := math.Inf(1)
+	var violations []Violation
+	for _, id := range rec.Validators {
+		selected[id] = true
+		repu, ok := rec.SelectionReputations[id]
+		if !ok {
+			violations = append(violations, Violation{rec.Round,
+				fmt.Sprintf("验证器 %s 不在候选节点中", id)})
+			continue
+		}
+		minSelected = math.Min(minSelected, repu)
+	}
+
+	for _, id := range sortedKeys(rec.SelectionReputations) {
+		repu := rec.SelectionReputations[id]
+		if !selected[id] && repu > minSelected+tol {
+			violations = append(violations, Violation{rec.Round,
+				fmt.Sprintf("候选节点 %s (信誉值 %.6f) 高于最低验证器信誉值 %.6f 却未入选", id, repu, minSelected)})
+		}
+	}
+	return violations
+}
+
+// checkBlocks 检查紧急区块的链接关系，返回违例和本轮最后一个区块
+func checkBlocks(rec roundlog.RoundRecord, prev *roundlog.BlockRecord) ([]Violation, *roundlog.BlockRecord) {
+	var violations []Violation
+	for i := range rec.EmergencyBlocks {
+		block := &rec.EmergencyBlocks[i]
+		if prev != nil {
+			if block.Index != prev.Index+1 {
+				violations = append(violations, Violation{rec.Round,
+					fmt.Sprintf("区块高度不连续: %d 之后是 %d", prev.Index, block.Index)})
+			}
+			if block.PrevHash != prev.Hash {
+				violations = append(violations, Violation{rec.Round,
+					fmt.Sprintf("区块 %d 的父哈希 %s 与前一区块哈希 %s 不符", block.Index, block.PrevHash, prev.Hash)})
+			}
+		}
+		prev = block
+	}
+	return violations, prev
+}
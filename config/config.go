@@ -2,16 +2,69 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
+	"math"
 	"os"
+	"slices"
+	"sort"
+
+	"block/logging"
 )
 
 // Config 定义所有信誉计算参数，可从 JSON 文件加载
 // ρ1,ρ2,ρ3: 三权重系数
 // Eta, Epsilon: 时效性参数
-// Tau1,Tau2: 轨迹相似性权重
+// TimeDecay: 时效性衰减方式（power/exponential，默认 power）：power 为 TIM = Eta × delta^(-Epsilon)，
+// exponential 为 TIM = Eta × exp(-ln2 × delta/HalfLife)，即交互的时效性每 HalfLife 秒减半
+// HalfLife: 指数衰减的半衰期（秒，默认 60），仅在 exponential 方式下使用
+// EpsilonEmergency: 紧急交易的时间衰减指数，未设置时与 Epsilon 相同；小于 Epsilon 时紧急交易证据衰减更慢
+// Tau1,Tau2,Tau3: 轨迹相似性中速度、方向、加速度分量的权重
 // Mu: Pearl 增长曲线调整因子
 // Gamma: 不确定性影响系数
-// ρ1+ρ2+ρ3=1, Tau1+Tau2=1
+// TrajDistanceMetric: 轨迹相似度度量方式（cosine/euclidean/manhattan，默认 cosine）
+// TrajDimensions: 参与轨迹相似度计算的分量（speed/direction/acceleration，默认三者全部），
+// 未列出的分量既不计算也不加权，列出分量的 Tau 按其和重新归一化，如只用 speed 与 direction 时权重为
+// Tau1/(Tau1+Tau2) 与 Tau2/(Tau1+Tau2)；列出分量的 Tau 之和必须大于 0
+// UseTrajectorySimilarity: 是否计算轨迹相似度（默认 true）；false 时完全跳过轨迹相似度的计算（用于消融实验），
+// 直接意见权重改为 (ρ1×Fi + ρ2×TIM)/(ρ1+ρ2)，即频率与时效性两项按 ρ1、ρ2 重新归一化为权重和 1，此时 ρ1+ρ2 必须大于 0
+// MinTrajSimilarity: 轨迹相似度计入权重前的下限 [-1,1]（默认 0），余弦相似度为负（轨迹反相关）时按该值计入；
+// 设为 -1 保留负相似度，此时由负相似度拉低的权重仍不低于 0
+// RSUNodes, RSUInitialReputation: 路侧单元（RSU）节点ID，RSU 负责验证与转发，不产生轨迹数据，
+// 也不发送普通交易或紧急交易；没有被评价记录时信誉值为 RSUInitialReputation（默认 0.9）而非车辆的初始信誉值，
+// 因此可以入选紧急区块链的验证器；RSU 参与的交互不计算轨迹相似度
+// MaliceProbabilities: 各节点的作恶概率 [0,1]，即该节点每笔交易为恶意交易的概率，未列出的节点为诚实节点
+// ThetaSteepness, ThetaMidpoint: Pearl 增长曲线 θ = Mu/(1+exp(steepness×(ratio-midpoint))) 的陡峭度与中点
+// PosWeight, NegWeight: 正面/负面事件计数的权重（默认 1），NegWeight>PosWeight 即"慢信任、快失信"
+// NegEmergencyMultiplier: 含负面事件的紧急交易在交易类型权重之外再乘的惩罚倍数（默认 1），
+// 大于 1 时恶意紧急行为受到的惩罚重于诚实紧急行为获得的奖励
+// DirectWeight: 融合时直接意见的权重 [0,1]，1 只采信直接意见，0 完全依赖间接意见，默认 0.5 为原始共识融合
+// MinEmergencyTxPerRound, MaxEmergencyTxPerRound: 双链模拟中每轮生成的紧急交易数范围（默认 1~3）
+// MinBlockTransactions: 提议紧急区块所需的最少待处理交易数（默认 1），未达到时等待交易积累，
+// 但距上一个紧急区块超过出块周期时仍将现有交易打包出块
+// MaxTxPoolSize: 紧急交易池的容量上限（默认 0 表示不限制），已满时淘汰有效紧急度最低的交易
+// MaxEmergencyTxPerSenderPerWindow, EmergencyTxWindow: 每个发送者在 EmergencyTxWindow 秒（默认 10）内
+// 最多被接受的紧急交易数，超出的交易被拒绝并给发送者负面评价；0 表示不限制（默认）
+// RejectTrajectoryOutliers, MaxAcceleration: 读取轨迹数据时是否跳过物理上不可能的行（默认 false），
+// 即速度为负或加速度绝对值超过 MaxAcceleration（m/s²，默认 10，0 表示不检查加速度）的行
+// NoInteractionProb, OneInteractionProb, MultiInteractionProb: 诚实节点每对节点每轮无交互/1 次/多次交互的概率（百分比，之和为 100）
+// MaxInteractionsPerPair: 多次交互时的最大次数（至少 2）
+// InteractionWorkers: 并发写入信誉管理器的交互消费协程数（默认 1）
+// CompactThreshold: 信誉管理器自上次压缩后新增的交互数达到该值时，自动将交互记录压缩为每对节点每种交易类型一条聚合交互，
+// 使长时间运行的内存占用有界；0 表示不压缩（默认），压缩不改变当前信誉值，但丢失逐条交互的历史
+// RaterCredibility: 是否按评价者的信誉缩放其评价的权重（默认 false），开启后低信誉节点的恶意差评影响更小
+// MaxPaths: 计算间接意见时每对 (source,target) 最多收集的路径数，0 表示不限制（默认）；
+// 达到上限后停止搜索，间接意见只基于按节点ID顺序最先找到的路径，是对全部路径的近似
+// Lambda: 间接意见的逐跳衰减因子 (0,1]（默认 1），每条路径的权重再乘以 Lambda^(边数-1)，
+// 小于 1 时同一 source 的较短路径占更大比重，1 与不衰减的原始行为一致
+// UseIndirect: 是否计算间接意见（默认 true），false 时跳过路径搜索，信誉只由直接意见决定
+// ValidatorEvictionThreshold: 紧急区块链验证器的驱逐阈值 [0,1]（默认 0 即不检查），活跃周期内任一验证器的信誉值
+// 跌破该值（如节点开始作恶）时当轮立即刷新验证器组，不必等到活跃周期结束
+// DetectionThreshold: 模拟结束时评估恶意节点检测效果所用的信誉阈值 [0,1]（默认 0.5），信誉值低于它的节点判定为恶意
+// ConvergenceEpsilon, ConvergenceRounds: 模拟的收敛判据（以 -converge 参数启用），连续 ConvergenceRounds 轮（默认 3）
+// 所有节点信誉值相对上一轮的最大变化量都小于 ConvergenceEpsilon（默认 0.001）时提前结束模拟
+// EmergencyConsensus: 紧急区块链的共识引擎（pbft/simple-majority，默认 pbft）
+// LogLevel: 日志级别（error/warn/info/debug，默认 info），info 输出每轮汇总，debug 额外输出逐节点对的意见计算
+// ρ1+ρ2+ρ3=1, Tau1+Tau2+Tau3=1
 
 type Config struct {
 	Rho1    float64 `json:"rho1"`
@@ -24,6 +77,376 @@ type Config struct {
 	Tau3    float64 `json:"tau3"`
 	Mu      float64 `json:"mu"`
 	Gamma   float64 `json:"gamma"`
+
+	EpsilonEmergency *float64 `json:"epsilonEmergency,omitempty"`
+
+	TimeDecay string  `json:"timeDecay"`
+	HalfLife  float64 `json:"halfLife"`
+
+	UseTrajectorySimilarity bool    `json:"useTrajectorySimilarity"`
+	TrajDistanceMetric      string  `json:"trajDistanceMetric"`
+	MinTrajSimilarity       float64 `json:"minTrajSimilarity"`
+
+	TrajDimensions []string `json:"trajDimensions"`
+
+	MaliceProbabilities map[string]float64 `json:"maliceProbabilities"`
+
+	RSUNodes             []string `json:"rsuNodes"`
+	RSUInitialReputation float64  `json:"rsuInitialReputation"`
+
+	ThetaSteepness float64 `json:"thetaSteepness"`
+	ThetaMidpoint  float64 `json:"thetaMidpoint"`
+
+	PosWeight float64 `json:"posWeight"`
+	NegWeight float64 `json:"negWeight"`
+
+	NegEmergencyMultiplier float64 `json:"negEmergencyMultiplier"`
+
+	DirectWeight float64 `json:"directWeight"`
+
+	MinEmergencyTxPerRound int `json:"minEmergencyTxPerRound"`
+	MaxEmergencyTxPerRound int `json:"maxEmergencyTxPerRound"`
+	MinBlockTransactions   int `json:"minBlockTransactions"`
+	MaxTxPoolSize          int `json:"maxTxPoolSize"`
+
+	MaxEmergencyTxPerSenderPerWindow int     `json:"maxEmergencyTxPerSenderPerWindow"`
+	EmergencyTxWindow                float64 `json:"emergencyTxWindow"`
+
+	RejectTrajectoryOutliers bool    `json:"rejectTrajectoryOutliers"`
+	MaxAcceleration          float64 `json:"maxAcceleration"`
+
+	NoInteractionProb      int `json:"noInteractionProb"`
+	OneInteractionProb     int `json:"oneInteractionProb"`
+	MultiInteractionProb   int `json:"multiInteractionProb"`
+	MaxInteractionsPerPair int `json:"maxInteractionsPerPair"`
+
+	InteractionWorkers int `json:"interactionWorkers"`
+	CompactThreshold   int `json:"compactThreshold"`
+
+	MaxPaths int     `json:"maxPaths"`
+	Lambda   float64 `json:"lambda"`
+
+	RaterCredibility bool `json:"raterCredibility"`
+
+	UseIndirect bool `json:"useIndirect"`
+
+	ValidatorEvictionThreshold float64 `json:"validatorEvictionThreshold"`
+
+	DetectionThreshold float64 `json:"detectionThreshold"`
+
+	ConvergenceEpsilon float64 `json:"convergenceEpsilon"`
+	ConvergenceRounds  int     `json:"convergenceRounds"`
+
+	EmergencyConsensus string `json:"emergencyConsensus"`
+
+	LogLevel string `json:"logLevel"`
+}
+
+// DefaultConfig 返回默认配置，JSON 文件中未出现的字段保持默认值
+func DefaultConfig() Config {
+	return Config{
+		Rho1:               0.4,
+		Rho2:               0.4,
+		Rho3:               0.2,
+		Eta:                1,
+		Epsilon:            0.5,
+		Tau1:               0.4,
+		Tau2:               0.4,
+		Tau3:               0.2,
+		Mu:                 1.5,
+		Gamma:              0.2,
+		TrajDistanceMetric: TrajMetricCosine,
+		MinTrajSimilarity:  0,
+		TimeDecay:          TimeDecayPower,
+		HalfLife:           60,
+		ThetaSteepness:     1,
+		ThetaMidpoint:      0,
+		PosWeight:          1,
+		NegWeight:          1,
+		DirectWeight:       0.5,
+
+		UseTrajectorySimilarity: true,
+
+		TrajDimensions: slices.Clone(TrajDimensionNames),
+
+		RSUInitialReputation: 0.9,
+
+		NegEmergencyMultiplier: 1,
+
+		MinEmergencyTxPerRound: 1,
+		MaxEmergencyTxPerRound: 3,
+		MinBlockTransactions:   1,
+		MaxTxPoolSize:          0,
+
+		MaxEmergencyTxPerSenderPerWindow: 0,
+		EmergencyTxWindow:                10,
+
+		RejectTrajectoryOutliers: false,
+		MaxAcceleration:          10,
+
+		NoInteractionProb:      70,
+		OneInteractionProb:     20,
+		MultiInteractionProb:   10,
+		MaxInteractionsPerPair: 5,
+
+		InteractionWorkers: 1,
+
+		Lambda:      1,
+		UseIndirect: true,
+
+		ValidatorEvictionThreshold: 0,
+
+		DetectionThreshold: 0.5,
+
+		ConvergenceEpsilon: 0.001,
+		ConvergenceRounds:  3,
+
+		EmergencyConsensus: ConsensusPBFT,
+
+		LogLevel: logging.LevelInfo.String(),
+	}
+}
+
+// 轨迹相似度度量方式
+const (
+	// TrajMetricCosine 余弦相似度：只比较各分量序列的形状（方向），忽略幅值
+	TrajMetricCosine = "cosine"
+	// TrajMetricEuclidean 欧氏距离：按点的均方根差计算距离 d，相似度 = 1/(1+d)
+	TrajMetricEuclidean = "euclidean"
+	// TrajMetricManhattan 曼哈顿距离：按点的平均绝对差计算距离 d，相似度 = 1/(1+d)
+	TrajMetricManhattan = "manhattan"
+)
+
+// 轨迹相似度的分量，依次对应 Tau1、Tau2、Tau3
+const (
+	// TrajDimSpeed 速度分量
+	TrajDimSpeed = "speed"
+	// TrajDimDirection 方向分量
+	TrajDimDirection = "direction"
+	// TrajDimAcceleration 加速度分量
+	TrajDimAcceleration = "acceleration"
+)
+
+// TrajDimensionNames 轨迹相似度的全部分量，顺序与 TrajWeights 一致
+var TrajDimensionNames = []string{TrajDimSpeed, TrajDimDirection, TrajDimAcceleration}
+
+// 时效性衰减方式
+const (
+	// TimeDecayPower 幂律衰减 TIM = Eta × delta^(-Epsilon)
+	TimeDecayPower = "power"
+	// TimeDecayExponential 指数衰减 TIM = Eta × exp(-ln2 × delta/HalfLife)
+	TimeDecayExponential = "exponential"
+)
+
+// 紧急区块链的共识引擎
+const (
+	// ConsensusPBFT 三阶段 PBFT 共识（PrePrepare/Prepare/Commit）
+	ConsensusPBFT = "pbft"
+	// ConsensusSimpleMajority 出块者提议、验证器单轮投票，过半数即确认
+	ConsensusSimpleMajority = "simple-majority"
+)
+
+// weightSumTolerance 权重之和与 1 比较时允许的浮点误差
+const weightSumTolerance = 1e-9
+
+// IsRSU 判断节点是否为 RSUNodes 中的路侧单元
+func (c Config) IsRSU(id string) bool {
+	return slices.Contains(c.RSUNodes, id)
+}
+
+// EmergencyEpsilon 返回紧急交易的时间衰减指数，未设置 EpsilonEmergency 时为 Epsilon
+func (c Config) EmergencyEpsilon() float64 {
+	if c.EpsilonEmergency == nil {
+		return c.Epsilon
+	}
+	return *c.EpsilonEmergency
+}
+
+// Level 返回日志级别，LogLevel 未设置或不合法时为 info
+func (c Config) Level() logging.Level {
+	level, err := logging.ParseLevel(c.LogLevel)
+	if err != nil {
+		return logging.LevelInfo
+	}
+	return level
+}
+
+// TrajWeights 返回轨迹相似度中速度、方向、加速度分量的权重 [Tau1, Tau2, Tau3]
+func (c Config) TrajWeights() [3]float64 {
+	return [3]float64{c.Tau1, c.Tau2, c.Tau3}
+}
+
+// TrajDimensionWeights 返回按 TrajDimensions 重新归一化的速度、方向、加速度分量权重
+// 未启用的分量权重为 0，启用分量的权重为其 Tau 除以启用分量的 Tau 之和；Tau 之和为 0 时全部为 0
+func (c Config) TrajDimensionWeights() [3]float64 {
+	taus := c.TrajWeights()
+	var weights [3]float64
+	var total float64
+	for i, name := range TrajDimensionNames {
+		if slices.Contains(c.TrajDimensions, name) {
+			weights[i] = taus[i]
+			total += taus[i]
+		}
+	}
+	if total == 0 {
+		return [3]float64{}
+	}
+	for i := range weights {
+		weights[i] /= total
+	}
+	return weights
+}
+
+// paramRange 参数的取值范围，Min/Max 为 ±Inf 表示无界，MinOpen 表示不含下界
+type paramRange struct {
+	Name     string
+	Value    float64
+	Min, Max float64
+	MinOpen  bool
+}
+
+// check 检查参数是否在取值范围内，超出时返回包含字段名与取值范围的错误
+func (r paramRange) check() error {
+	below := r.Value < r.Min || (r.MinOpen && r.Value == r.Min)
+	if !below && r.Value <= r.Max {
+		return nil
+	}
+	lower, upper := "[", "]"
+	if r.MinOpen {
+		lower = "("
+	}
+	if math.IsInf(r.Max, 1) {
+		upper = ")"
+	}
+	return fmt.Errorf("%s=%g 超出取值范围 %s%g, %g%s", r.Name, r.Value, lower, r.Min, r.Max, upper)
+}
+
+// Validate 检查配置参数是否合法
+// 先逐个检查参数的取值范围，再检查各组权重之和，错误信息包含字段名与合法范围
+func (c Config) Validate() error {
+	inf := math.Inf(1)
+	ranges := []paramRange{
+		{Name: "rho1", Value: c.Rho1, Min: 0, Max: 1},
+		{Name: "rho2", Value: c.Rho2, Min: 0, Max: 1},
+		{Name: "rho3", Value: c.Rho3, Min: 0, Max: 1},
+		{Name: "tau1", Value: c.Tau1, Min: 0, Max: 1},
+		{Name: "tau2", Value: c.Tau2, Min: 0, Max: 1},
+		{Name: "tau3", Value: c.Tau3, Min: 0, Max: 1},
+		{Name: "eta", Value: c.Eta, Min: 0, Max: inf},
+		{Name: "epsilon", Value: c.Epsilon, Min: 0, Max: inf},
+		{Name: "epsilonEmergency", Value: c.EmergencyEpsilon(), Min: 0, Max: inf},
+		{Name: "halfLife", Value: c.HalfLife, Min: 0, Max: inf, MinOpen: true},
+		{Name: "mu", Value: c.Mu, Min: 0, Max: inf, MinOpen: true},
+		{Name: "gamma", Value: c.Gamma, Min: 0, Max: 1},
+		{Name: "minTrajSimilarity", Value: c.MinTrajSimilarity, Min: -1, Max: 1},
+		{Name: "posWeight", Value: c.PosWeight, Min: 0, Max: inf},
+		{Name: "negWeight", Value: c.NegWeight, Min: 0, Max: inf},
+		{Name: "negEmergencyMultiplier", Value: c.NegEmergencyMultiplier, Min: 0, Max: inf},
+		{Name: "directWeight", Value: c.DirectWeight, Min: 0, Max: 1},
+		{Name: "minEmergencyTxPerRound", Value: float64(c.MinEmergencyTxPerRound), Min: 0, Max: inf},
+		{Name: "maxEmergencyTxPerRound", Value: float64(c.MaxEmergencyTxPerRound), Min: float64(c.MinEmergencyTxPerRound), Max: inf},
+		{Name: "minBlockTransactions", Value: float64(c.MinBlockTransactions), Min: 1, Max: inf},
+		{Name: "maxTxPoolSize", Value: float64(c.MaxTxPoolSize), Min: 0, Max: inf},
+		{Name: "maxEmergencyTxPerSenderPerWindow", Value: float64(c.MaxEmergencyTxPerSenderPerWindow), Min: 0, Max: inf},
+		{Name: "emergencyTxWindow", Value: c.EmergencyTxWindow, Min: 0, Max: inf, MinOpen: true},
+		{Name: "rsuInitialReputation", Value: c.RSUInitialReputation, Min: 0, Max: 1},
+		{Name: "maxAcceleration", Value: c.MaxAcceleration, Min: 0, Max: inf},
+		{Name: "compactThreshold", Value: float64(c.CompactThreshold), Min: 0, Max: inf},
+		{Name: "maxPaths", Value: float64(c.MaxPaths), Min: 0, Max: inf},
+		{Name: "lambda", Value: c.Lambda, Min: 0, Max: 1, MinOpen: true},
+		{Name: "validatorEvictionThreshold", Value: c.ValidatorEvictionThreshold, Min: 0, Max: 1},
+		{Name: "detectionThreshold", Value: c.DetectionThreshold, Min: 0, Max: 1},
+		{Name: "convergenceEpsilon", Value: c.ConvergenceEpsilon, Min: 0, Max: inf, MinOpen: true},
+		{Name: "convergenceRounds", Value: float64(c.ConvergenceRounds), Min: 1, Max: inf},
+	}
+	for _, id := range sortedKeys(c.MaliceProbabilities) {
+		ranges = append(ranges, paramRange{
+			Name: fmt.Sprintf("maliceProbabilities[%s]", id), Value: c.MaliceProbabilities[id], Min: 0, Max: 1,
+		})
+	}
+	for _, r := range ranges {
+		if err := r.check(); err != nil {
+			return err
+		}
+	}
+	seenRSU := make(map[string]bool, len(c.RSUNodes))
+	for _, id := range c.RSUNodes {
+		if id == "" || seenRSU[id] {
+			return fmt.Errorf("rsuNodes 中的节点ID %q 为空或重复", id)
+		}
+		seenRSU[id] = true
+	}
+	switch c.TrajDistanceMetric {
+	case TrajMetricCosine, TrajMetricEuclidean, TrajMetricManhattan:
+	default:
+		return fmt.Errorf("trajDistanceMetric=%q 不是合法的度量方式（%s/%s/%s）",
+			c.TrajDistanceMetric, TrajMetricCosine, TrajMetricEuclidean, TrajMetricManhattan)
+	}
+	if len(c.TrajDimensions) == 0 {
+		return fmt.Errorf("trajDimensions 至少需要一个分量（%s/%s/%s）",
+			TrajDimSpeed, TrajDimDirection, TrajDimAcceleration)
+	}
+	seenDim := make(map[string]bool, len(c.TrajDimensions))
+	for _, name := range c.TrajDimensions {
+		if !slices.Contains(TrajDimensionNames, name) || seenDim[name] {
+			return fmt.Errorf("trajDimensions 中的分量 %q 不合法或重复（%s/%s/%s）",
+				name, TrajDimSpeed, TrajDimDirection, TrajDimAcceleration)
+		}
+		seenDim[name] = true
+	}
+	switch c.TimeDecay {
+	case TimeDecayPower, TimeDecayExponential:
+	default:
+		return fmt.Errorf("timeDecay=%q 不是合法的衰减方式（%s/%s）",
+			c.TimeDecay, TimeDecayPower, TimeDecayExponential)
+	}
+	switch c.EmergencyConsensus {
+	case ConsensusPBFT, ConsensusSimpleMajority:
+	default:
+		return fmt.Errorf("emergencyConsensus=%q 不是合法的共识引擎（%s/%s）",
+			c.EmergencyConsensus, ConsensusPBFT, ConsensusSimpleMajority)
+	}
+	if _, err := logging.ParseLevel(c.LogLevel); err != nil {
+		return fmt.Errorf("logLevel: %w", err)
+	}
+
+	if sum := c.Rho1 + c.Rho2 + c.Rho3; math.Abs(sum-1) > weightSumTolerance {
+		return fmt.Errorf("rho1+rho2+rho3 必须等于 1，当前为 %g", sum)
+	}
+	if !c.UseTrajectorySimilarity && c.Rho1+c.Rho2 <= 0 {
+		return fmt.Errorf("useTrajectorySimilarity=false 时 rho1+rho2 必须大于 0，当前为 %g", c.Rho1+c.Rho2)
+	}
+	w := c.TrajWeights()
+	if sum := w[0] + w[1] + w[2]; math.Abs(sum-1) > weightSumTolerance {
+		return fmt.Errorf("tau1+tau2+tau3 必须等于 1，当前为 %g", sum)
+	}
+	if c.TrajDimensionWeights() == [3]float64{} {
+		return fmt.Errorf("trajDimensions %v 对应的 tau 之和必须大于 0", c.TrajDimensions)
+	}
+	if c.NoInteractionProb < 0 || c.OneInteractionProb < 0 || c.MultiInteractionProb < 0 {
+		return fmt.Errorf("交互概率不能为负: noInteractionProb=%d, oneInteractionProb=%d, multiInteractionProb=%d",
+			c.NoInteractionProb, c.OneInteractionProb, c.MultiInteractionProb)
+	}
+	if sum := c.NoInteractionProb + c.OneInteractionProb + c.MultiInteractionProb; sum != 100 {
+		return fmt.Errorf("noInteractionProb+oneInteractionProb+multiInteractionProb 必须等于 100，当前为 %d", sum)
+	}
+	if c.MaxInteractionsPerPair < 2 {
+		return fmt.Errorf("maxInteractionsPerPair 必须不小于 2，当前为 %d", c.MaxInteractionsPerPair)
+	}
+	if c.InteractionWorkers < 1 {
+		return fmt.Errorf("interactionWorkers 必须不小于 1，当前为 %d", c.InteractionWorkers)
+	}
+	return nil
+}
+
+// sortedKeys 返回按字典序排列的键，保证错误信息稳定
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
 // LoadConfig 从指定路径加载 JSON 配置
@@ -32,9 +455,12 @@ func LoadConfig(path string) (Config, error) {
 	if err != nil {
 		return Config{}, err
 	}
-	var cfg Config
+	cfg := DefaultConfig()
 	if err := json.Unmarshal(file, &cfg); err != nil {
 		return Config{}, err
 	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, fmt.Errorf("配置 %s 不合法: %w", path, err)
+	}
 	return cfg, nil
 }
@@ -0,0 +1,169 @@
+package config
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateWeightSums(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr bool
+	}{
+		{"默认配置", func(c *Config) {}, false},
+		{"rho 之和不为 1", func(c *Config) { c.Rho3 = 0.3 }, true},
+		{"tau3 被忽略时之和不为 1", func(c *Config) { c.Tau1, c.Tau2 = 0.5, 0.5 }, true},
+		{"tau 重新分配后之和为 1", func(c *Config) { c.Tau1, c.Tau2, c.Tau3 = 0.5, 0.3, 0.2 }, false},
+		{"浮点误差内视为 1", func(c *Config) { c.Tau1, c.Tau2, c.Tau3 = 0.1, 0.7, 0.2 }, false},
+		{"交互概率之和不为 100", func(c *Config) { c.NoInteractionProb = 60 }, true},
+		{"交互概率重新分配后之和为 100", func(c *Config) { c.NoInteractionProb, c.OneInteractionProb = 50, 40 }, false},
+		{"交互概率为负", func(c *Config) { c.NoInteractionProb, c.OneInteractionProb = 110, -20 }, true},
+		{"多次交互上限小于 2", func(c *Config) { c.MaxInteractionsPerPair = 1 }, true},
+		{"交互消费协程数为 0", func(c *Config) { c.InteractionWorkers = 0 }, true},
+		{"关闭轨迹相似度且 rho1+rho2 为 0", func(c *Config) {
+			c.UseTrajectorySimilarity, c.Rho1, c.Rho2, c.Rho3 = false, 0, 0, 1
+		}, true},
+		{"关闭轨迹相似度", func(c *Config) { c.UseTrajectorySimilarity = false }, false},
+		{"启用分量的 tau 之和为 0", func(c *Config) {
+			c.TrajDimensions = []string{TrajDimAcceleration}
+			c.Tau1, c.Tau2, c.Tau3 = 0.5, 0.5, 0
+		}, true},
+		{"只启用部分分量", func(c *Config) { c.TrajDimensions = []string{TrajDimSpeed, TrajDimDirection} }, false},
+	}
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		tt.modify(&cfg)
+		if err := cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() = %v, 期望出错 %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestTrajWeights(t *testing.T) {
+	cfg := DefaultConfig()
+	if got := cfg.TrajWeights(); got != [3]float64{cfg.Tau1, cfg.Tau2, cfg.Tau3} {
+		t.Errorf("TrajWeights() = %v", got)
+	}
+	if got := cfg.TrajDimensionWeights(); got != cfg.TrajWeights() {
+		t.Errorf("启用全部分量时 TrajDimensionWeights() = %v, 期望与 TrajWeights() 相同", got)
+	}
+	cfg.TrajDimensions = []string{TrajDimDirection, TrajDimAcceleration}
+	if got := cfg.TrajDimensionWeights(); math.Abs(got[1]-cfg.Tau2/(cfg.Tau2+cfg.Tau3)) > 1e-12 ||
+		math.Abs(got[2]-cfg.Tau3/(cfg.Tau2+cfg.Tau3)) > 1e-12 || got[0] != 0 {
+		t.Errorf("只启用方向与加速度时 TrajDimensionWeights() = %v", got)
+	}
+}
+
+func TestLoadConfigRejectsInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"tau1": 0.5, "tau2": 0.5}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(path); err == nil {
+		t.Error("tau1+tau2+tau3 != 1 的配置应加载失败")
+	}
+}
+
+func TestLoadConfigRepoFile(t *testing.T) {
+	if _, err := LoadConfig("config.json"); err != nil {
+		t.Errorf("仓库自带的 config.json 应通过校验: %v", err)
+	}
+}
+
+func TestValidateParameterRanges(t *testing.T) {
+	tests := []struct {
+		field  string
+		modify func(*Config)
+	}{
+		{"rho1", func(c *Config) { c.Rho1, c.Rho2 = -0.2, 1.0 }},
+		{"rho2", func(c *Config) { c.Rho1, c.Rho2, c.Rho3 = 0, 1.2, -0.2 }},
+		{"rho3", func(c *Config) { c.Rho3 = 1.5 }},
+		{"tau1", func(c *Config) { c.Tau1 = -0.1 }},
+		{"tau2", func(c *Config) { c.Tau2 = 1.1 }},
+		{"tau3", func(c *Config) { c.Tau3 = -1 }},
+		{"eta", func(c *Config) { c.Eta = -1 }},
+		{"epsilon", func(c *Config) { c.Epsilon = -0.5 }},
+		{"mu", func(c *Config) { c.Mu = 0 }},
+		{"mu", func(c *Config) { c.Mu = -1 }},
+		{"gamma", func(c *Config) { c.Gamma = -0.1 }},
+		{"gamma", func(c *Config) { c.Gamma = 1.5 }},
+		{"minTrajSimilarity", func(c *Config) { c.MinTrajSimilarity = -2 }},
+		{"posWeight", func(c *Config) { c.PosWeight = -1 }},
+		{"negWeight", func(c *Config) { c.NegWeight = -1 }},
+		{"negEmergencyMultiplier", func(c *Config) { c.NegEmergencyMultiplier = -1 }},
+		{"directWeight", func(c *Config) { c.DirectWeight = 2 }},
+		{"minEmergencyTxPerRound", func(c *Config) { c.MinEmergencyTxPerRound = -1 }},
+		{"maxEmergencyTxPerRound", func(c *Config) { c.MaxEmergencyTxPerRound = 0 }},
+		{"minBlockTransactions", func(c *Config) { c.MinBlockTransactions = 0 }},
+		{"maxTxPoolSize", func(c *Config) { c.MaxTxPoolSize = -1 }},
+		{"maxEmergencyTxPerSenderPerWindow", func(c *Config) { c.MaxEmergencyTxPerSenderPerWindow = -1 }},
+		{"emergencyTxWindow", func(c *Config) { c.EmergencyTxWindow = 0 }},
+		{"maxAcceleration", func(c *Config) { c.MaxAcceleration = -1 }},
+		{"compactThreshold", func(c *Config) { c.CompactThreshold = -1 }},
+		{"lambda", func(c *Config) { c.Lambda = 0 }},
+		{"lambda", func(c *Config) { c.Lambda = 1.5 }},
+		{"detectionThreshold", func(c *Config) { c.DetectionThreshold = -0.1 }},
+		{"validatorEvictionThreshold", func(c *Config) { c.ValidatorEvictionThreshold = 1.5 }},
+		{"rsuInitialReputation", func(c *Config) { c.RSUInitialReputation = 1.5 }},
+		{"rsuNodes", func(c *Config) { c.RSUNodes = []string{"R1", "R1"} }},
+		{"maliceProbabilities[3]", func(c *Config) { c.MaliceProbabilities = map[string]float64{"3": 1.2} }},
+		{"trajDistanceMetric", func(c *Config) { c.TrajDistanceMetric = "chebyshev" }},
+		{"emergencyConsensus", func(c *Config) { c.EmergencyConsensus = "raft" }},
+		{"logLevel", func(c *Config) { c.LogLevel = "verbose" }},
+		{"timeDecay", func(c *Config) { c.TimeDecay = "linear" }},
+		{"halfLife", func(c *Config) { c.HalfLife = 0 }},
+		{"trajDimensions", func(c *Config) { c.TrajDimensions = nil }},
+		{"trajDimensions", func(c *Config) { c.TrajDimensions = []string{TrajDimSpeed, "jerk"} }},
+		{"trajDimensions", func(c *Config) { c.TrajDimensions = []string{TrajDimSpeed, TrajDimSpeed} }},
+	}
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		tt.modify(&cfg)
+		err := cfg.Validate()
+		if err == nil {
+			t.Errorf("%s 超出范围时 Validate() 应返回错误", tt.field)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), tt.field) {
+			t.Errorf("%s 超出范围时错误信息应以字段名开头，实际为 %q", tt.field, err)
+		}
+	}
+}
+
+func TestValidateBoundaryValues(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Epsilon, cfg.Gamma, cfg.DirectWeight = 0, 1, 0
+	cfg.Rho1, cfg.Rho2, cfg.Rho3 = 1, 0, 0
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("取值范围边界上的配置应合法: %v", err)
+	}
+}
+
+func TestEmergencyEpsilonDefaultsToEpsilon(t *testing.T) {
+	cfg := DefaultConfig()
+	if got := cfg.EmergencyEpsilon(); got != cfg.Epsilon {
+		t.Errorf("未设置时 EmergencyEpsilon = %v, 期望 epsilon %v", got, cfg.Epsilon)
+	}
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"epsilon": 0.5, "epsilonEmergency": 0.2}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+	if got := loaded.EmergencyEpsilon(); got != 0.2 {
+		t.Errorf("EmergencyEpsilon = %v, 期望 0.2", got)
+	}
+
+	negative := -0.1
+	cfg.EpsilonEmergency = &negative
+	if err := cfg.Validate(); err == nil || !strings.HasPrefix(err.Error(), "epsilonEmergency") {
+		t.Errorf("负的 epsilonEmergency 应被拒绝，实际 %v", err)
+	}
+}
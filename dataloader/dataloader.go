@@ -0,0 +1,321 @@
+package dataloader
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"block/reputation"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// RawData 从数据文件导入的轨迹数据（包含时间戳）
+type RawData struct {
+	VehicleID    string
+	Time         float64 // 单位：秒
+	X            float64
+	Y            float64
+	Speed        float64
+	Acceleration float64
+}
+
+// 数据文件的表头名称，XLSX 与 CSV 相同
+const (
+	ColVehicleID    = "vehicleID"
+	ColTime         = "time(s)"
+	ColLongitudinal = "longitudinalDistance(m)"
+	ColSpeed        = "speed(m/s)"
+	ColLaneID       = "laneID"
+	ColAcceleration = "acceleration(m/s^2)"
+)
+
+// requiredColumns 数据文件必须包含的列，缺少任意一列时拒绝读取
+var requiredColumns = []string{ColVehicleID, ColTime, ColLongitudinal, ColSpeed, ColLaneID, ColAcceleration}
+
+// ErrMissingColumns 数据文件的表头缺少必需的列
+var ErrMissingColumns = errors.New("数据文件缺少必需的列")
+
+// ErrInvalidNumber 数据行的数值字段无法解析
+var ErrInvalidNumber = errors.New("数值无法解析")
+
+// ErrOutlier 数据行的数值超出物理上可能的范围
+var ErrOutlier = errors.New("数值超出物理范围")
+
+// LoadOptions 读取轨迹数据的校验选项
+type LoadOptions struct {
+	// RejectOutliers 是否跳过物理上不可能的数据行：速度为负，或加速度绝对值超过 MaxAcceleration
+	RejectOutliers bool
+	// MaxAcceleration 加速度绝对值的上限（m/s²），RejectOutliers 为 true 且大于 0 时生效
+	MaxAcceleration float64
+}
+
+// RowError 被跳过的数据行及原因，Row 为数据文件中的行号（从 1 开始，表头为第 1 行）
+type RowError struct {
+	Row    int
+	Column string
+	Value  string
+	Err    error // ErrInvalidNumber 或 ErrOutlier
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("第 %d 行 %s=%q: %v", e.Row, e.Column, e.Value, e.Err)
+}
+
+func (e RowError) Unwrap() error { return e.Err }
+
+// LoadReport 读取轨迹数据的汇总：数据行总数与被跳过的行（按行号升序）
+type LoadReport struct {
+	Rows    int
+	Skipped []RowError
+}
+
+// Count 返回因 target 原因被跳过的行数
+func (r LoadReport) Count(target error) int {
+	n := 0
+	for _, e := range r.Skipped {
+		if errors.Is(e, target) {
+			n++
+		}
+	}
+	return n
+}
+
+// Summary 返回被跳过的行的一行汇总
+func (r LoadReport) Summary() string {
+	return fmt.Sprintf("共 %d 行数据，跳过 %d 行（数值无法解析 %d 行，超出物理范围 %d 行）",
+		r.Rows, len(r.Skipped), r.Count(ErrInvalidNumber), r.Count(ErrOutlier))
+}
+
+// LaneWidth 车道宽度（米），用于由车道号换算横向坐标
+const LaneWidth = 3.5
+
+// LoadTrajectories 读取轨迹数据文件，按车辆ID分组并按时间排序
+// 根据扩展名选择读取方式：.xlsx 读取第一个工作表，.csv 按逗号分隔读取
+// 表头缺少必需的列时返回 ErrMissingColumns，表头中的其他列被忽略；
+// 数值无法解析或（按 opts）超出物理范围的行被跳过，并记录在返回的 LoadReport 中
+func LoadTrajectories(path string, opts LoadOptions) (map[string][]RawData, LoadReport, error) {
+	var rows [][]string
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".xlsx":
+		rows, err = readXLSX(path)
+	case ".csv":
+		rows, err = readCSV(path)
+	default:
+		return nil, LoadReport{}, fmt.Errorf("不支持的数据文件格式: %s", path)
+	}
+	if err != nil {
+		return nil, LoadReport{}, err
+	}
+	if len(rows) < 2 {
+		return nil, LoadReport{}, fmt.Errorf("数据文件 %s 没有数据行", path)
+	}
+	dataMap, report, err := parseRows(rows, opts)
+	if err != nil {
+		return nil, report, fmt.Errorf("%s: %w", path, err)
+	}
+	return dataMap, report, nil
+}
+
+// readXLSX 读取 Excel 第一个工作表的所有行
+func readXLSX(path string) ([][]string, error) {
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.GetRows(f.GetSheetName(0))
+}
+
+// readCSV 读取 CSV 文件的所有行
+func readCSV(path string) ([][]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	r := csv.NewReader(file)
+	r.FieldsPerRecord = -1 // 允许各行列数不同
+	r.TrimLeadingSpace = true
+	return r.ReadAll()
+}
+
+// parseHeader 解析表头，返回各必需列的下标 [列名]下标
+// 缺少必需的列时返回 ErrMissingColumns，错误信息按 requiredColumns 的顺序列出全部缺失的列
+func parseHeader(header []string) (map[string]int, error) {
+	columns := make(map[string]int, len(requiredColumns))
+	for idx, title := range header {
+		title = strings.TrimSpace(title)
+		if _, seen := columns[title]; !seen {
+			columns[title] = idx
+		}
+	}
+
+	var missing []string
+	for _, name := range requiredColumns {
+		if _, ok := columns[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrMissingColumns, strings.Join(missing, ", "))
+	}
+	return columns, nil
+}
+
+// cell 返回行中第 idx 列去除首尾空白后的值，行比表头短时缺失的列视为空
+func cell(row []string, idx int) string {
+	if idx >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[idx])
+}
+
+// parseNumber 解析数值单元格，空单元格（包括比表头短的行中缺失的列）按 0 处理
+func parseNumber(value string) (float64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+// parseRows 解析表头与数据行，生成按时间排序的轨迹数据
+// 比表头短的行中缺失的数值按 0 处理，车辆ID为空的行（如表格末尾的空行）被跳过且不计入报告；
+// 数值无法解析的行与（按 opts）超出物理范围的行被跳过，每行记录第一个出错的字段
+func parseRows(rows [][]string, opts LoadOptions) (map[string][]RawData, LoadReport, error) {
+	var report LoadReport
+	columns, err := parseHeader(rows[0])
+	if err != nil {
+		return nil, report, err
+	}
+	iVID, iTime, iLong := columns[ColVehicleID], columns[ColTime], columns[ColLongitudinal]
+	iSpd, iLane, iAcc := columns[ColSpeed], columns[ColLaneID], columns[ColAcceleration]
+
+	// 读取并归一化坐标，同时读取加速度
+	dataMap := make(map[string][]RawData)
+	numeric := []struct {
+		col string
+		idx int
+	}{{ColTime, iTime}, {ColLongitudinal, iLong}, {ColLaneID, iLane}, {ColSpeed, iSpd}, {ColAcceleration, iAcc}}
+	for i, row := range rows[1:] {
+		vid := cell(row, iVID)
+		if vid == "" {
+			continue
+		}
+		report.Rows++
+		line := i + 2 // 表头为第 1 行
+		skip := func(col string, idx int, reason error) {
+			report.Skipped = append(report.Skipped, RowError{Row: line, Column: col, Value: cell(row, idx), Err: reason})
+		}
+
+		var values [5]float64
+		valid := true
+		for k, f := range numeric {
+			v, err := parseNumber(cell(row, f.idx))
+			if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+				skip(f.col, f.idx, ErrInvalidNumber)
+				valid = false
+				break
+			}
+			values[k] = v
+		}
+		if !valid {
+			continue
+		}
+		t, lon, laneID, spd, acc := values[0], values[1], values[2], values[3], values[4]
+		if laneID != math.Trunc(laneID) {
+			skip(ColLaneID, iLane, ErrInvalidNumber)
+			continue
+		}
+		if opts.RejectOutliers {
+			if spd < 0 {
+				skip(ColSpeed, iSpd, ErrOutlier)
+				continue
+			}
+			if opts.MaxAcceleration > 0 && math.Abs(acc) > opts.MaxAcceleration {
+				skip(ColAcceleration, iAcc, ErrOutlier)
+				continue
+			}
+		}
+		x := lon
+		y := (laneID - 1) * LaneWidth
+
+		dataMap[vid] = append(dataMap[vid], RawData{
+			VehicleID:    vid,
+			Time:         t,
+			X:            x,
+			Y:            y,
+			Speed:        spd,
+			Acceleration: acc,
+		})
+	}
+
+	// 按时间排序
+	for _, slice := range dataMap {
+		sort.Slice(slice, func(i, j int) bool { return slice[i].Time < slice[j].Time })
+	}
+	return dataMap, report, nil
+}
+
+// BuildVectors 由按时间排序的轨迹点构建轨迹向量：Speed, Direction, Acceleration，
+// 并记录各点的绝对时间，用于计算轨迹相似度时按时间对齐
+// Direction 为相邻两点位移的方向角（弧度），第一个点没有前驱，方向记为 0
+func BuildVectors(pts []RawData) []reputation.Vector {
+	vecs := make([]reputation.Vector, 0, len(pts))
+	for i := range pts {
+		var dir float64
+		if i > 0 {
+			dx := pts[i].X - pts[i-1].X
+			dy := pts[i].Y - pts[i-1].Y
+			dir = math.Atan2(dy, dx)
+		}
+		vecs = append(vecs, reputation.Vector{
+			Speed:        pts[i].Speed,
+			Direction:    dir,
+			Acceleration: pts[i].Acceleration,
+			Time:         pts[i].Time,
+		})
+	}
+	return vecs
+}
+
+// BuildVectorMap 为每辆车构建轨迹向量
+func BuildVectorMap(dataMap map[string][]RawData) map[string][]reputation.Vector {
+	trajMap := make(map[string][]reputation.Vector, len(dataMap))
+	for vid, pts := range dataMap {
+		trajMap[vid] = BuildVectors(pts)
+	}
+	return trajMap
+}
+
+// MaxRounds 返回最长轨迹的长度，即模拟的总轮数
+// 车辆可能中途进入或离开场景，各车辆的轨迹长度不一定相同
+func MaxRounds(trajMap map[string][]reputation.Vector) int {
+	rounds := 0
+	for _, traj := range trajMap {
+		if len(traj) > rounds {
+			rounds = len(traj)
+		}
+	}
+	return rounds
+}
+
+// ActiveVehicles 返回在第 r 轮（从 0 开始）有轨迹数据的车辆，保持 vehicleIDs 中的顺序
+// 轨迹长度不超过 r 的车辆在该轮不参与交互
+func ActiveVehicles(trajMap map[string][]reputation.Vector, vehicleIDs []string, r int) []string {
+	active := make([]string, 0, len(vehicleIDs))
+	for _, vid := range vehicleIDs {
+		if r < len(trajMap[vid]) {
+			active = append(active, vid)
+		}
+	}
+	return active
+}
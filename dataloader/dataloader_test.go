@@ -0,0 +1,187 @@
+package dataloader
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestBuildVectorsDirection(t *testing.T) {
+	pts := []RawData{
+		{Time: 0, X: 0, Y: 0, Speed: 10, Acceleration: 1},
+		{Time: 1, X: 10, Y: 0, Speed: 11, Acceleration: 0.5}, // 沿 x 轴前进
+		{Time: 2, X: 10, Y: 3.5, Speed: 12, Acceleration: 0}, // 向左换道
+		{Time: 3, X: 5, Y: 3.5, Speed: 5, Acceleration: -2},  // 后退
+	}
+	vecs := BuildVectors(pts)
+	if len(vecs) != len(pts) {
+		t.Fatalf("向量数 = %d, 期望 %d", len(vecs), len(pts))
+	}
+
+	wantDir := []float64{0, 0, math.Pi / 2, math.Pi}
+	for i, v := range vecs {
+		if math.Abs(v.Direction-wantDir[i]) > 1e-12 {
+			t.Errorf("第 %d 个点方向 = %v, 期望 %v", i, v.Direction, wantDir[i])
+		}
+		if v.Speed != pts[i].Speed || v.Acceleration != pts[i].Acceleration {
+			t.Errorf("第 %d 个点速度/加速度 = %v/%v, 期望 %v/%v",
+				i, v.Speed, v.Acceleration, pts[i].Speed, pts[i].Acceleration)
+		}
+		if v.Time != pts[i].Time {
+			t.Errorf("第 %d 个点时间 = %v, 期望 %v", i, v.Time, pts[i].Time)
+		}
+	}
+}
+
+func TestBuildVectorsEmpty(t *testing.T) {
+	if vecs := BuildVectors(nil); len(vecs) != 0 {
+		t.Errorf("空轨迹应得到空向量，实际 %d 个", len(vecs))
+	}
+}
+
+func TestBuildVectorMap(t *testing.T) {
+	dataMap := map[string][]RawData{
+		"1": {{X: 0, Y: 0}, {X: 1, Y: 1}},
+		"2": {{X: 0, Y: 0}},
+	}
+	trajMap := BuildVectorMap(dataMap)
+	if len(trajMap) != 2 || len(trajMap["1"]) != 2 || len(trajMap["2"]) != 1 {
+		t.Fatalf("轨迹向量分组错误: %v", trajMap)
+	}
+	if got := trajMap["1"][1].Direction; math.Abs(got-math.Pi/4) > 1e-12 {
+		t.Errorf("方向 = %v, 期望 %v", got, math.Pi/4)
+	}
+}
+
+func TestRaggedTrajectories(t *testing.T) {
+	// 车辆 B 中途离开，车辆 C 只有一个轨迹点
+	dataMap := map[string][]RawData{
+		"A": {{X: 0}, {X: 1}, {X: 2}, {X: 3}},
+		"B": {{X: 0}, {X: 1}},
+		"C": {{X: 0}},
+	}
+	trajMap := BuildVectorMap(dataMap)
+	ids := []string{"A", "B", "C"}
+
+	rounds := MaxRounds(trajMap)
+	if rounds != 4 {
+		t.Fatalf("总轮数 = %d, 期望最长轨迹长度 4", rounds)
+	}
+
+	want := [][]string{{"A", "B", "C"}, {"A", "B"}, {"A"}, {"A"}}
+	for r := 0; r < rounds; r++ {
+		active := ActiveVehicles(trajMap, ids, r)
+		if len(active) != len(want[r]) {
+			t.Fatalf("第 %d 轮参与车辆 = %v, 期望 %v", r, active, want[r])
+		}
+		for i := range active {
+			if active[i] != want[r][i] {
+				t.Fatalf("第 %d 轮参与车辆 = %v, 期望 %v", r, active, want[r])
+			}
+			// 参与车辆在该轮一定有数据，按模拟中的方式取值不会越界
+			_ = dataMap[active[i]][r]
+			_ = trajMap[active[i]][:r+1]
+		}
+	}
+
+	if got := MaxRounds(nil); got != 0 {
+		t.Errorf("空数据的总轮数 = %d, 期望 0", got)
+	}
+}
+
+func TestParseRowsMissingColumns(t *testing.T) {
+	rows := [][]string{
+		{"time(s)", "longitudinalDistance(m)", "speed(m/s)", "laneID", "extra"},
+		{"0", "1", "10", "1", "x"},
+	}
+	_, _, err := parseRows(rows, LoadOptions{})
+	if !errors.Is(err, ErrMissingColumns) {
+		t.Fatalf("缺少列时应返回 ErrMissingColumns，实际 %v", err)
+	}
+	for _, col := range []string{ColVehicleID, ColAcceleration} {
+		if !strings.Contains(err.Error(), col) {
+			t.Errorf("错误信息 %q 应列出缺失的列 %s", err, col)
+		}
+	}
+	if strings.Contains(err.Error(), ColSpeed) {
+		t.Errorf("错误信息 %q 不应列出已存在的列", err)
+	}
+}
+
+func TestParseRowsShortRow(t *testing.T) {
+	rows := [][]string{
+		{"vehicleID", "time(s)", "longitudinalDistance(m)", "speed(m/s)", "laneID", "acceleration(m/s^2)", "note"},
+		{"A", "0", "5", "10", "2", "0.5", "ok"},
+		{"A", "1", "15", "11"}, // 缺少车道号与加速度
+		{},                     // 表格末尾的空行
+	}
+	dataMap, _, err := parseRows(rows, LoadOptions{})
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if len(dataMap) != 1 || len(dataMap["A"]) != 2 {
+		t.Fatalf("解析结果 = %v, 期望车辆 A 的 2 个轨迹点", dataMap)
+	}
+	short := dataMap["A"][1]
+	if short.X != 15 || short.Speed != 11 || short.Acceleration != 0 {
+		t.Errorf("短行解析结果 = %+v, 期望已有列正常读取、缺失的加速度为 0", short)
+	}
+	if full := dataMap["A"][0]; full.Y != LaneWidth || full.Acceleration != 0.5 {
+		t.Errorf("完整行解析结果 = %+v", full)
+	}
+}
+
+func TestParseRowsSkipsMalformedAndOutlierRows(t *testing.T) {
+	rows := [][]string{
+		{"vehicleID", "time(s)", "longitudinalDistance(m)", "speed(m/s)", "laneID", "acceleration(m/s^2)"},
+		{"A", "0", "5", "10", "1", "0.5"},
+		{"A", "1", "abc", "11", "1", "0.5"}, // 第 3 行：纵向距离无法解析
+		{"A", "2", "25", "12", "1.5", "0"},  // 第 4 行：车道号不是整数
+		{"A", "3", "35", "-4", "1", "0"},    // 第 5 行：速度为负
+		{"B", "0", "0", "9", "2", "45"},     // 第 6 行：加速度超过上限
+		{"B", "1", "9", "9", "2", "NaN"},    // 第 7 行：加速度不是有限数
+		{"B", "2", "18", "9", "2", "-1"},
+	}
+
+	dataMap, report, err := parseRows(rows, LoadOptions{RejectOutliers: true, MaxAcceleration: 10})
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if len(dataMap["A"]) != 1 || len(dataMap["B"]) != 1 {
+		t.Fatalf("解析结果 = %v, 期望车辆 A、B 各保留 1 个轨迹点", dataMap)
+	}
+	if report.Rows != 7 || len(report.Skipped) != 5 {
+		t.Fatalf("报告 = %+v, 期望 7 行数据中跳过 5 行", report)
+	}
+	want := []struct {
+		row    int
+		column string
+		reason error
+	}{
+		{3, ColLongitudinal, ErrInvalidNumber},
+		{4, ColLaneID, ErrInvalidNumber},
+		{5, ColSpeed, ErrOutlier},
+		{6, ColAcceleration, ErrOutlier},
+		{7, ColAcceleration, ErrInvalidNumber},
+	}
+	for i, w := range want {
+		got := report.Skipped[i]
+		if got.Row != w.row || got.Column != w.column || !errors.Is(got, w.reason) {
+			t.Errorf("第 %d 条跳过记录 = %v, 期望第 %d 行 %s: %v", i, got, w.row, w.column, w.reason)
+		}
+	}
+	if report.Count(ErrInvalidNumber) != 3 || report.Count(ErrOutlier) != 2 {
+		t.Errorf("Summary() = %s", report.Summary())
+	}
+	if !strings.Contains(report.Skipped[0].Error(), "第 3 行") {
+		t.Errorf("错误信息 %q 应包含行号", report.Skipped[0])
+	}
+
+	// 不检查异常值时只跳过无法解析的行
+	dataMap, report, _ = parseRows(rows, LoadOptions{})
+	if len(report.Skipped) != 3 || len(dataMap["A"])+len(dataMap["B"]) != 4 {
+		t.Errorf("未开启异常值检查时跳过 %d 行、保留 %d 个轨迹点, 期望跳过 3 行、保留 4 个",
+			len(report.Skipped), len(dataMap["A"])+len(dataMap["B"]))
+	}
+}
@@ -2,11 +2,29 @@ package emergency
 
 import (
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
 	"time"
 )
 
+// 区块上链失败的原因
+var (
+	ErrInvalidHeight  = errors.New("区块高度不是链顶高度+1")
+	ErrDuplicateBlock = errors.New("区块已在链上")
+	ErrCompetingBlock = errors.New("与链上区块高度相同的竞争区块，已记录待分叉裁决")
+	ErrStaleBlock     = errors.New("竞争区块的高度已确定，不再接受分叉")
+	ErrBlockNotFound  = errors.New("区块高度超出链的范围")
+
+	// ErrUnresolvedNormalRef 交易引用的普通链区块尚未上链
+	ErrUnresolvedNormalRef = errors.New("交易引用的普通链区块尚不存在")
+)
+
 // EmergencyBlock 紧急区块结构
 // 根据论文 3.4.1.2 紧急区块结构设计
 type EmergencyBlock struct {
@@ -16,12 +34,22 @@ type EmergencyBlock struct {
 	PrevHash     string    // 父区块哈希
 	Hash         string    // 当前区块哈希
 	MerkleRoot   string    // 默克尔根
-	Signature    string    // 数字签名
+	Signature    string    // 数字签名（提议者对区块哈希的签名）
+	Proposer     string    // 提议者节点ID，计入区块哈希
+	Round        int       // 产生该区块的模拟轮次（从 1 开始，0 表示未记录），计入区块哈希
 	ValidatorIDs []string  // 参与验证的验证器节点ID列表
 
+	// ValidatorReputations 出块时验证器节点的信誉快照，计入区块哈希，
+	// 用于事后证明区块是在怎样的信任环境下产生的
+	ValidatorReputations map[string]float64
+
 	// 区块体
 	Transactions []*EmergencyTransaction // k 笔按时间顺序排列的紧急交易
 	TotalUrgency float64                 // 总紧急度 ED^total = ∑ED_i
+
+	// CommitSignatures 区块确认时收集的提交签名 [验证器ID]对区块哈希的签名（十六进制），
+	// 不计入区块哈希；与区块一起构成提交证书，可用 VerifyCommitQuorum 校验
+	CommitSignatures map[string]string
 }
 
 // CalculateMerkleRoot 计算默克尔根
@@ -30,16 +58,64 @@ func (b *EmergencyBlock) CalculateMerkleRoot() string {
 		return ""
 	}
 
-	// 简化的默克尔树实现：将所有交易ID连接后哈希
-	var txIDs string
+	// 简化的默克尔树实现：每笔交易的ID与数据先哈希为叶子，再将所有叶子连接后哈希
+	// 叶子覆盖交易数据，仅篡改 Data 也会改变默克尔根与区块哈希
+	var leaves []byte
 	for _, tx := range b.Transactions {
-		txIDs += tx.ID
+		leaf := txLeafHash(tx)
+		leaves = append(leaves, leaf[:]...)
 	}
 
-	hash := sha256.Sum256([]byte(txIDs))
+	hash := sha256.Sum256(leaves)
 	return hex.EncodeToString(hash[:])
 }
 
+// txLeafHash 计算交易的叶子哈希 SHA256(ID || 0x00 || Data || NormalChainRef)
+// 分隔符避免 ID 与数据边界不同但拼接结果相同的两笔交易产生相同叶子，
+// NormalChainRef 以定长 8 字节大端序追加在末尾，篡改跨链引用同样会改变默克尔根
+func txLeafHash(tx *EmergencyTransaction) [sha256.Size]byte {
+	buf := make([]byte, 0, len(tx.ID)+1+len(tx.Data)+8)
+	buf = append(buf, tx.ID...)
+	buf = append(buf, 0)
+	buf = append(buf, tx.Data...)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(tx.NormalChainRef))
+	return sha256.Sum256(buf)
+}
+
+// txLess 区块内交易的规范顺序：按到达时间升序，到达时间相同时按交易ID升序
+func txLess(a, b *EmergencyTransaction) bool {
+	if !a.ArrivalTime.Equal(b.ArrivalTime) {
+		return a.ArrivalTime.Before(b.ArrivalTime)
+	}
+	return a.ID < b.ID
+}
+
+// SortTransactionsByTime 将交易按规范的时间顺序原地排序
+func SortTransactionsByTime(txs []*EmergencyTransaction) {
+	sort.SliceStable(txs, func(i, j int) bool {
+		return txLess(txs[i], txs[j])
+	})
+}
+
+// IsTimeOrdered 判断区块内交易是否按规范的时间顺序排列
+func (b *EmergencyBlock) IsTimeOrdered() bool {
+	for i := 1; i < len(b.Transactions); i++ {
+		if txLess(b.Transactions[i], b.Transactions[i-1]) {
+			return false
+		}
+	}
+	return true
+}
+
+// TransactionIDs 返回区块内交易的ID，顺序与区块内交易顺序一致
+func (b *EmergencyBlock) TransactionIDs() []string {
+	ids := make([]string, 0, len(b.Transactions))
+	for _, tx := range b.Transactions {
+		ids = append(ids, tx.ID)
+	}
+	return ids
+}
+
 // CalculateTotalUrgency 计算区块总紧急度
 func (b *EmergencyBlock) CalculateTotalUrgency() float64 {
 	var total float64
@@ -49,19 +125,32 @@ func (b *EmergencyBlock) CalculateTotalUrgency() float64 {
 	return total
 }
 
+// SizeBytes 返回区块序列化（JSON）后的字节数，交易数据按 base64 编码计入
+func (b *EmergencyBlock) SizeBytes() int {
+	data, _ := json.Marshal(b)
+	return len(data)
+}
+
 // CalculateHash 计算区块哈希
 func (b *EmergencyBlock) CalculateHash() string {
 	// 将区块头信息序列化
+	// map 序列化时按键排序，哈希结果与插入顺序无关
 	blockData := struct {
-		Index      int
-		Timestamp  string
-		PrevHash   string
-		MerkleRoot string
+		Index                int
+		Timestamp            string
+		PrevHash             string
+		MerkleRoot           string
+		ValidatorReputations map[string]float64 `json:",omitempty"`
+		Proposer             string             `json:",omitempty"`
+		Round                int                `json:",omitempty"`
 	}{
-		Index:      b.Index,
-		Timestamp:  b.Timestamp.Format(time.RFC3339Nano),
-		PrevHash:   b.PrevHash,
-		MerkleRoot: b.MerkleRoot,
+		Index:                b.Index,
+		Timestamp:            b.Timestamp.Format(time.RFC3339Nano),
+		PrevHash:             b.PrevHash,
+		MerkleRoot:           b.MerkleRoot,
+		ValidatorReputations: b.ValidatorReputations,
+		Proposer:             b.Proposer,
+		Round:                b.Round,
 	}
 
 	jsonData, _ := json.Marshal(blockData)
@@ -75,13 +164,15 @@ func NewEmergencyBlock(
 	prevHash string,
 	transactions []*EmergencyTransaction,
 	validatorIDs []string,
+	validatorReputations map[string]float64,
 ) *EmergencyBlock {
 	block := &EmergencyBlock{
-		Index:        index,
-		Timestamp:    time.Now(),
-		PrevHash:     prevHash,
-		Transactions: transactions,
-		ValidatorIDs: validatorIDs,
+		Index:                index,
+		Timestamp:            time.Now(),
+		PrevHash:             prevHash,
+		Transactions:         transactions,
+		ValidatorIDs:         validatorIDs,
+		ValidatorReputations: validatorReputations,
 	}
 
 	// 计算默克尔根
@@ -97,12 +188,118 @@ func NewEmergencyBlock(
 }
 
 // EmergencyBlockchain 紧急区块链
+// 区块链由所有节点共享，各节点的共识协程并发读写，方法可并发调用；
+// 直接访问 Chain 只适用于没有并发共识的场合，并发时用 Blocks 获取快照
 type EmergencyBlockchain struct {
+	// mutex 保护 Chain 与 forks
+	mutex       sync.RWMutex
 	Chain       []*EmergencyBlock // 紧急区块链
 	TxPool      *TransactionPool  // 交易池
 	UrgencyCfg  UrgencyConfig     // 紧急度配置
 	BlockSize   int               // 每个区块包含的交易数量 k
 	BlockPeriod time.Duration     // 出块周期（例如 kms）
+
+	// MaxBlockBytes 区块序列化后的字节数上限，0 表示不限制
+	// 打包时即使交易数未达到 BlockSize，加入下一笔交易会超过上限时也停止打包
+	MaxBlockBytes int
+
+	// forks 与链顶高度相同的竞争区块 [区块高度][]区块
+	forks map[int][]*EmergencyBlock
+	// finalized 由 Finalize 确定的最高区块高度；链顶以下的区块总是已确定，
+	// 已确定的区块不会再被竞争区块替换
+	finalized int
+
+	// MinBlockTransactions 提议区块所需的最少待处理交易数；
+	// 距上一个区块超过 BlockPeriod 时不受此限制，以保证稀疏的紧急交易也能及时上链
+	MinBlockTransactions int
+
+	// normalChainHeight 查询普通链当前高度，用于校验交易的 NormalChainRef；为 nil 时不做跨链校验
+	normalChainHeight NormalChainHeight
+	// currentRound 查询当前模拟轮次，提议区块时记入 EmergencyBlock.Round；为 nil 时不记录轮次
+	currentRound RoundSource
+
+	// RateLimiter 按发送者限制紧急交易的提交速率，为 nil 时不限制
+	RateLimiter *SenderRateLimiter
+
+	// commitHooks 区块确认上链后的通知回调，由 OnCommit 注册
+	commitHooks []CommitHook
+	hookMutex   sync.Mutex
+}
+
+// CommitHook 区块确认上链后的通知回调，validators 为提交证书中签名的验证器ID（升序）
+type CommitHook func(block *EmergencyBlock, validators []string)
+
+// OnCommit 注册区块确认上链后的通知回调，用于与外部系统集成
+// 每个上链的区块只通知一次（由使其上链的节点发出）；回调在节点释放共识锁之后调用，
+// 因此可以安全地回调区块链或节点的方法，回调的耗时会推迟该节点处理后续共识消息
+func (ebc *EmergencyBlockchain) OnCommit(hook CommitHook) {
+	ebc.hookMutex.Lock()
+	defer ebc.hookMutex.Unlock()
+	ebc.commitHooks = append(ebc.commitHooks, hook)
+}
+
+// notifyCommit 依次调用已注册的区块确认回调
+func (ebc *EmergencyBlockchain) notifyCommit(block *EmergencyBlock, validators []string) {
+	ebc.hookMutex.Lock()
+	hooks := append([]CommitHook(nil), ebc.commitHooks...)
+	ebc.hookMutex.Unlock()
+
+	for _, hook := range hooks {
+		hook(block, validators)
+	}
+}
+
+// NormalChainHeight 查询普通链当前高度（已上链的区块数）的函数
+type NormalChainHeight func() int
+
+// RoundSource 查询当前模拟轮次（从 1 开始）的函数
+type RoundSource func() int
+
+// SetRoundSource 设置模拟轮次查询函数，传入 nil 时提议的区块不记录轮次
+func (ebc *EmergencyBlockchain) SetRoundSource(round RoundSource) {
+	ebc.currentRound = round
+}
+
+// Round 返回当前模拟轮次，未设置轮次查询函数时为 0
+func (ebc *EmergencyBlockchain) Round() int {
+	if ebc.currentRound == nil {
+		return 0
+	}
+	return ebc.currentRound()
+}
+
+// SetNormalChainHeight 设置普通链高度查询函数，传入 nil 关闭跨链引用校验
+func (ebc *EmergencyBlockchain) SetNormalChainHeight(height NormalChainHeight) {
+	ebc.normalChainHeight = height
+}
+
+// CheckNormalChainRef 检查交易引用的普通链区块是否已上链
+// 交易不引用普通链或未设置普通链高度查询函数时直接通过
+func (ebc *EmergencyBlockchain) CheckNormalChainRef(tx *EmergencyTransaction) error {
+	if tx.NormalChainRef <= 0 || ebc.normalChainHeight == nil {
+		return nil
+	}
+	if height := ebc.normalChainHeight(); tx.NormalChainRef > height {
+		return fmt.Errorf("%w: 交易 %s 引用普通区块 %d, 普通链高度=%d",
+			ErrUnresolvedNormalRef, tx.ID, tx.NormalChainRef, height)
+	}
+	return nil
+}
+
+// NewGenesisBlock 创建指定时间戳的创世区块
+// 区块哈希由区块头计算得出，相同时间戳生成的创世区块哈希相同，便于复现链上哈希
+func NewGenesisBlock(timestamp time.Time) *EmergencyBlock {
+	genesisBlock := &EmergencyBlock{
+		Index:        0,
+		Timestamp:    timestamp,
+		PrevHash:     "0",
+		MerkleRoot:   "",
+		Transactions: make([]*EmergencyTransaction, 0),
+		TotalUrgency: 0,
+		ValidatorIDs: []string{},
+	}
+	genesisBlock.Hash = genesisBlock.CalculateHash()
+	return genesisBlock
 }
 
 // NewEmergencyBlockchain 创建新的紧急区块链
@@ -119,22 +316,104 @@ func NewEmergencyBlockchain(urgencyCfg UrgencyConfig, blockSize int, blockPeriod
 		ValidatorIDs: []string{},
 	}
 
+	return NewEmergencyBlockchainWithGenesis(genesisBlock, urgencyCfg, blockSize, blockPeriod)
+}
+
+// NewEmergencyBlockchainWithGenesis 使用指定的创世区块创建紧急区块链
+// 用于分叉实验和可复现的测试，创世区块通常由 NewGenesisBlock 以固定时间戳生成
+func NewEmergencyBlockchainWithGenesis(
+	genesisBlock *EmergencyBlock,
+	urgencyCfg UrgencyConfig,
+	blockSize int,
+	blockPeriod time.Duration,
+) *EmergencyBlockchain {
 	return &EmergencyBlockchain{
-		Chain:       []*EmergencyBlock{genesisBlock},
-		TxPool:      NewTransactionPool(),
-		UrgencyCfg:  urgencyCfg,
-		BlockSize:   blockSize,
-		BlockPeriod: blockPeriod,
+		Chain:                []*EmergencyBlock{genesisBlock},
+		TxPool:               NewTransactionPool(),
+		UrgencyCfg:           urgencyCfg,
+		BlockSize:            blockSize,
+		BlockPeriod:          blockPeriod,
+		MinBlockTransactions: 1,
+		forks:                make(map[int][]*EmergencyBlock),
+	}
+}
+
+// AddTransaction 添加紧急交易到交易池，返回交易是否被接受
+// 设置了 RateLimiter 时，发送者在时间窗口内被接受的交易数达到上限后，新交易被拒绝
+func (ebc *EmergencyBlockchain) AddTransaction(tx *EmergencyTransaction) bool {
+	accepted, _ := ebc.addTransaction(tx)
+	return accepted
+}
+
+// addTransaction 添加紧急交易到交易池，因速率限制被拒绝时返回 ErrRateLimited
+// 交易池已见过的交易（如广播到多个节点的同一笔交易）不计入发送者的提交次数；
+// 交易按到达时间计入时间窗口，未设置到达时间时取当前时间
+func (ebc *EmergencyBlockchain) addTransaction(tx *EmergencyTransaction) (bool, error) {
+	status, _ := ebc.TxPool.Status(tx.ID)
+	if ebc.RateLimiter == nil || status != TxUnknown {
+		return ebc.TxPool.AddTransaction(tx), nil
+	}
+
+	at := tx.ArrivalTime
+	if at.IsZero() {
+		at = time.Now()
+	}
+	if !ebc.RateLimiter.Allow(tx.VehicleID, at) {
+		ebc.RateLimiter.reject(tx.ID)
+		return false, fmt.Errorf("%w: 发送者 %s 在 %v 内已提交 %d 笔",
+			ErrRateLimited, tx.VehicleID, ebc.RateLimiter.Window, ebc.RateLimiter.MaxPerWindow)
 	}
+	if !ebc.TxPool.AddTransaction(tx) {
+		return false, nil
+	}
+	ebc.RateLimiter.record(tx.VehicleID, at)
+	return true, nil
 }
 
-// AddTransaction 添加紧急交易到交易池
-func (ebc *EmergencyBlockchain) AddTransaction(tx *EmergencyTransaction) {
-	ebc.TxPool.AddTransaction(tx)
+// ReadyToPropose 判断交易池是否满足出块条件
+// 待处理交易数达到 MinBlockTransactions 时可出块；
+// 未达到但距最新区块已超过 BlockPeriod 时，只要交易池非空也强制出块
+func (ebc *EmergencyBlockchain) ReadyToPropose(now time.Time) bool {
+	pending := ebc.TxPool.Size()
+	if pending == 0 {
+		return false
+	}
+	if pending >= ebc.MinBlockTransactions {
+		return true
+	}
+	latestBlock := ebc.GetLatestBlock()
+	return latestBlock != nil && now.Sub(latestBlock.Timestamp) >= ebc.BlockPeriod
+}
+
+// fitMaxBlockBytes 按顺序保留交易，直到加入下一笔交易会使区块超过 MaxBlockBytes
+// build 根据候选交易构造区块以计算其大小；返回保留的交易与未能打包的交易
+func (ebc *EmergencyBlockchain) fitMaxBlockBytes(
+	txs []*EmergencyTransaction,
+	build func([]*EmergencyTransaction) *EmergencyBlock,
+) (kept, rest []*EmergencyTransaction) {
+	if ebc.MaxBlockBytes <= 0 {
+		return txs, nil
+	}
+	n := 0
+	for n < len(txs) {
+		candidate := append([]*EmergencyTransaction(nil), txs[:n+1]...)
+		if build(candidate).SizeBytes() > ebc.MaxBlockBytes {
+			break
+		}
+		n++
+	}
+	return txs[:n], txs[n:]
 }
 
 // GetLatestBlock 获取最新区块
 func (ebc *EmergencyBlockchain) GetLatestBlock() *EmergencyBlock {
+	ebc.mutex.RLock()
+	defer ebc.mutex.RUnlock()
+	return ebc.latestBlock()
+}
+
+// latestBlock 同 GetLatestBlock，调用方需持有区块链锁
+func (ebc *EmergencyBlockchain) latestBlock() *EmergencyBlock {
 	if len(ebc.Chain) == 0 {
 		return nil
 	}
@@ -142,15 +421,176 @@ func (ebc *EmergencyBlockchain) GetLatestBlock() *EmergencyBlock {
 }
 
 // AddBlock 添加新区块到链
-func (ebc *EmergencyBlockchain) AddBlock(block *EmergencyBlock) {
-	ebc.Chain = append(ebc.Chain, block)
+// 只接受高度恰为链顶+1 且父哈希指向链顶的区块；
+// 与链顶同高度、同父区块的不同区块视为分叉，记录为竞争区块并返回 ErrCompetingBlock，
+// 由 ResolveFork 裁决；链顶已确定或竞争区块的高度低于链顶时返回 ErrStaleBlock
+// signatures 为区块的提交签名（可为 nil），在区块上链或记录为竞争区块之前于锁内附上，
+// 区块对其他节点可见后不再修改
+func (ebc *EmergencyBlockchain) AddBlock(block *EmergencyBlock, signatures map[string]string) error {
+	ebc.mutex.Lock()
+	defer ebc.mutex.Unlock()
+	latestBlock := ebc.latestBlock()
+
+	if block.Index == latestBlock.Index+1 && block.PrevHash == latestBlock.Hash {
+		block.CommitSignatures = signatures
+		ebc.Chain = append(ebc.Chain, block)
+		ebc.commitTransactions(block)
+		// 原链顶已确定，其竞争区块不再有机会胜出
+		delete(ebc.forks, latestBlock.Index)
+		return nil
+	}
+
+	if block.Index >= 0 && block.Index < len(ebc.Chain) {
+		existing := ebc.Chain[block.Index]
+		if existing.Hash == block.Hash {
+			return ErrDuplicateBlock
+		}
+		if existing.PrevHash == block.PrevHash {
+			if block.Index <= ebc.finalizedHeight() {
+				return fmt.Errorf("%w: 区块高度=%d, 链顶高度=%d", ErrStaleBlock, block.Index, latestBlock.Index)
+			}
+			for _, fork := range ebc.forks[block.Index] {
+				if fork.Hash == block.Hash {
+					return ErrCompetingBlock
+				}
+			}
+			block.CommitSignatures = signatures
+			ebc.forks[block.Index] = append(ebc.forks[block.Index], block)
+			return ErrCompetingBlock
+		}
+	}
+
+	return fmt.Errorf("%w: 区块高度=%d, 链顶高度=%d", ErrInvalidHeight, block.Index, latestBlock.Index)
+}
+
+// commitTransactions 结束上链区块中交易的在途状态，并记为已上链
+func (ebc *EmergencyBlockchain) commitTransactions(block *EmergencyBlock) {
+	ebc.TxPool.commit(block.Transactions, block.Index)
+}
+
+// TransactionStatus 查询紧急交易的状态（pending/committed/expired/evicted），
+// 已上链时同时返回所在区块高度，否则高度为 -1；交易池从未接受过的交易为 TxUnknown
+// 发送者据此判断交易是否送达，未送达时可重发或升级处理
+func (ebc *EmergencyBlockchain) TransactionStatus(txID string) (TxStatus, int) {
+	return ebc.TxPool.Status(txID)
+}
+
+// CompetingBlocks 返回指定高度上记录的竞争区块
+func (ebc *EmergencyBlockchain) CompetingBlocks(index int) []*EmergencyBlock {
+	ebc.mutex.RLock()
+	defer ebc.mutex.RUnlock()
+	return append([]*EmergencyBlock(nil), ebc.forks[index]...)
+}
+
+// forkPreferred 分叉裁决规则：总紧急度更高者胜出，相同时哈希字典序更小者胜出
+func forkPreferred(a, b *EmergencyBlock) bool {
+	if a.TotalUrgency != b.TotalUrgency {
+		return a.TotalUrgency > b.TotalUrgency
+	}
+	return a.Hash < b.Hash
+}
+
+// ResolveFork 裁决链顶的分叉，返回胜出的区块
+// 只有尚未确定的链顶可以被替换：index 低于链顶或已确定时返回 ErrStaleBlock；
+// 若竞争区块胜出，则替换链顶，原链顶中未进入胜出区块的交易放回交易池等待重新打包
+func (ebc *EmergencyBlockchain) ResolveFork(index int) (*EmergencyBlock, error) {
+	ebc.mutex.Lock()
+	defer ebc.mutex.Unlock()
+	if index <= 0 || index >= len(ebc.Chain) {
+		return nil, fmt.Errorf("%w: 区块高度=%d", ErrInvalidHeight, index)
+	}
+	if index <= ebc.finalizedHeight() {
+		delete(ebc.forks, index)
+		return nil, fmt.Errorf("%w: 区块高度=%d, 链顶高度=%d", ErrStaleBlock, index, ebc.latestBlock().Index)
+	}
+
+	replaced := ebc.Chain[index]
+	winner := replaced
+	for _, fork := range ebc.forks[index] {
+		if forkPreferred(fork, winner) {
+			winner = fork
+		}
+	}
+
+	if winner != replaced {
+		ebc.Chain[index] = winner
+		ebc.commitTransactions(winner)
+		included := make(map[string]bool, len(winner.Transactions))
+		for _, tx := range winner.Transactions {
+			included[tx.ID] = true
+		}
+		var orphaned []*EmergencyTransaction
+		for _, tx := range replaced.Transactions {
+			if !included[tx.ID] {
+				orphaned = append(orphaned, tx)
+			}
+		}
+		ebc.TxPool.reopen(orphaned)
+		ebc.TxPool.ReturnTransactions(orphaned)
+	}
+	delete(ebc.forks, index)
+
+	return winner, nil
+}
+
+// Finalize 确定当前链顶，此后不再接受链顶高度的竞争区块，返回确定的高度
+// 应在一轮共识结束、不会再有该高度的提议时调用
+func (ebc *EmergencyBlockchain) Finalize() int {
+	ebc.mutex.Lock()
+	defer ebc.mutex.Unlock()
+	ebc.finalized = ebc.latestBlock().Index
+	delete(ebc.forks, ebc.finalized)
+	return ebc.finalized
+}
+
+// FinalizedHeight 返回已确定的最高区块高度，该高度及以下的区块不会再被替换
+func (ebc *EmergencyBlockchain) FinalizedHeight() int {
+	ebc.mutex.RLock()
+	defer ebc.mutex.RUnlock()
+	return ebc.finalizedHeight()
+}
+
+// finalizedHeight 同 FinalizedHeight，调用方需持有区块链锁
+func (ebc *EmergencyBlockchain) finalizedHeight() int {
+	return max(ebc.finalized, ebc.latestBlock().Index-1)
+}
+
+// HasBlock 判断指定哈希的区块是否已在链上
+func (ebc *EmergencyBlockchain) HasBlock(hash string) bool {
+	ebc.mutex.RLock()
+	defer ebc.mutex.RUnlock()
+	for _, block := range ebc.Chain {
+		if block.Hash == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// GetBlock 获取指定高度的区块，高度超出 [0, 链长度) 时返回 ErrBlockNotFound
+func (ebc *EmergencyBlockchain) GetBlock(index int) (*EmergencyBlock, error) {
+	ebc.mutex.RLock()
+	defer ebc.mutex.RUnlock()
+	if index < 0 || index >= len(ebc.Chain) {
+		return nil, fmt.Errorf("%w: 区块高度=%d, 链长度=%d", ErrBlockNotFound, index, len(ebc.Chain))
+	}
+	return ebc.Chain[index], nil
 }
 
 // GetChainLength 获取区块链长度
 func (ebc *EmergencyBlockchain) GetChainLength() int {
+	ebc.mutex.RLock()
+	defer ebc.mutex.RUnlock()
 	return len(ebc.Chain)
 }
 
+// Blocks 返回链上区块（含创世区块）的快照，之后上链的区块不会出现在返回的切片中
+func (ebc *EmergencyBlockchain) Blocks() []*EmergencyBlock {
+	ebc.mutex.RLock()
+	defer ebc.mutex.RUnlock()
+	return append([]*EmergencyBlock(nil), ebc.Chain...)
+}
+
 // VerifyBlock 验证区块合法性
 func (ebc *EmergencyBlockchain) VerifyBlock(block *EmergencyBlock) bool {
 	// 1. 验证区块高度
@@ -182,5 +622,27 @@ func (ebc *EmergencyBlockchain) VerifyBlock(block *EmergencyBlock) bool {
 		return false
 	}
 
+	// 6. 验证交易按时间顺序排列
+	if !block.IsTimeOrdered() {
+		return false
+	}
+
+	// 7. 验证交易引用的普通链区块均已上链
+	for _, tx := range block.Transactions {
+		if ebc.CheckNormalChainRef(tx) != nil {
+			return false
+		}
+	}
+
+	// 8. 记录了提议者时，提议者必须是区块记录的验证器之一
+	if block.Proposer != "" && len(block.ValidatorIDs) > 0 && !slices.Contains(block.ValidatorIDs, block.Proposer) {
+		return false
+	}
+
+	// 9. 区块轮次不早于父区块的轮次
+	if block.Round < latestBlock.Round {
+		return false
+	}
+
 	return true
 }
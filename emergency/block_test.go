@@ -0,0 +1,452 @@
+package emergency
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"block/clock"
+	"block/logging"
+)
+
+func TestMaxBlockBytesTripsBeforeBlockSize(t *testing.T) {
+	base := time.Unix(1000, 0)
+	ebc := NewEmergencyBlockchainWithGenesis(NewGenesisBlock(base), UrgencyConfig{Omega: 0.5}, 5, time.Second)
+	ebc.MaxBlockBytes = 4096
+
+	// 5 笔各带 1KB 数据的交易，base64 编码后每笔约 1.4KB，超过上限前只能打包 2 笔
+	var txs []*EmergencyTransaction
+	for i := 0; i < 5; i++ {
+		txs = append(txs, NewEmergencyTransaction(
+			fmt.Sprintf("tx%d", i), "1", bytes.Repeat([]byte{'x'}, 1024),
+			base, base.Add(10*time.Second), base.Add(time.Duration(i+1)*time.Second), 0, ebc.UrgencyCfg,
+		))
+	}
+
+	genesis := ebc.GetLatestBlock()
+	build := func(txs []*EmergencyTransaction) *EmergencyBlock {
+		block := NewEmergencyBlock(1, genesis.Hash, txs, []string{"1", "2"}, nil)
+		block.Timestamp = base
+		block.Hash = block.CalculateHash()
+		return block
+	}
+
+	kept, rest := ebc.fitMaxBlockBytes(txs, build)
+	if len(kept) == 0 || len(kept) >= ebc.BlockSize {
+		t.Fatalf("打包 %d 笔交易，期望字节上限先于交易数上限生效", len(kept))
+	}
+	if len(kept)+len(rest) != len(txs) {
+		t.Fatalf("打包 %d 笔 + 剩余 %d 笔 != %d 笔", len(kept), len(rest), len(txs))
+	}
+	if size := build(kept).SizeBytes(); size > ebc.MaxBlockBytes {
+		t.Errorf("区块大小 %d 字节超过上限 %d", size, ebc.MaxBlockBytes)
+	}
+	if size := build(txs[:len(kept)+1]).SizeBytes(); size <= ebc.MaxBlockBytes {
+		t.Errorf("再加入一笔交易后区块大小 %d 字节仍未超过上限 %d", size, ebc.MaxBlockBytes)
+	}
+}
+
+func TestMaxBlockBytesUnlimited(t *testing.T) {
+	ebc := NewEmergencyBlockchain(UrgencyConfig{}, 5, time.Second)
+	txs := []*EmergencyTransaction{{ID: "a", Data: bytes.Repeat([]byte{'x'}, 1<<20)}}
+	kept, rest := ebc.fitMaxBlockBytes(txs, func(txs []*EmergencyTransaction) *EmergencyBlock {
+		t.Fatal("未设置字节上限时不应试算区块大小")
+		return nil
+	})
+	if len(kept) != 1 || len(rest) != 0 {
+		t.Errorf("未设置字节上限时应保留全部交易，实际保留 %d 笔", len(kept))
+	}
+}
+
+func TestTamperedDataChangesMerkleRootAndHash(t *testing.T) {
+	base := time.Unix(1000, 0)
+	txs := []*EmergencyTransaction{
+		{ID: "tx1", VehicleID: "1", Data: []byte("brake"), ArrivalTime: base},
+		{ID: "tx2", VehicleID: "2", Data: []byte("collision"), ArrivalTime: base.Add(time.Second)},
+	}
+	block := NewEmergencyBlock(1, "prev", txs, []string{"1", "2"}, nil)
+	root, hash := block.MerkleRoot, block.Hash
+
+	txs[1].Data = []byte("all clear")
+
+	if got := block.CalculateMerkleRoot(); got == root {
+		t.Error("仅篡改交易数据后默克尔根未变化")
+	}
+	block.MerkleRoot = block.CalculateMerkleRoot()
+	if got := block.CalculateHash(); got == hash {
+		t.Error("仅篡改交易数据后区块哈希未变化")
+	}
+}
+
+func TestGetBlockAndTransactionIDs(t *testing.T) {
+	base := time.Unix(1000, 0)
+	ebc := NewEmergencyBlockchainWithGenesis(NewGenesisBlock(base), UrgencyConfig{Omega: 0.5}, 1, time.Second)
+	high := NewEmergencyTransaction("high", "1", nil, base, base.Add(2*time.Second), base.Add(time.Second), 0, ebc.UrgencyCfg)
+	low := NewEmergencyTransaction("low", "2", nil, base, base.Add(time.Hour), base.Add(time.Second), 0, ebc.UrgencyCfg)
+	ebc.AddTransaction(high)
+	ebc.AddTransaction(low)
+
+	genesis := ebc.GetLatestBlock()
+	block := NewEmergencyBlock(1, genesis.Hash, ebc.TxPool.GetTopKTransactions(ebc.BlockSize), nil, nil)
+	if err := ebc.AddBlock(block, nil); err != nil {
+		t.Fatalf("添加区块失败: %v", err)
+	}
+
+	got, err := ebc.GetBlock(1)
+	if err != nil {
+		t.Fatalf("获取区块 1 失败: %v", err)
+	}
+	if ids := got.TransactionIDs(); len(ids) != 1 || ids[0] != "high" {
+		t.Errorf("区块 1 的交易 = %v, 期望只包含高紧急度交易 high", ids)
+	}
+
+	for _, index := range []int{-1, 2} {
+		if _, err := ebc.GetBlock(index); !errors.Is(err, ErrBlockNotFound) {
+			t.Errorf("GetBlock(%d) 应返回 ErrBlockNotFound，实际 %v", index, err)
+		}
+	}
+}
+
+func TestNormalChainRefGatesTransactions(t *testing.T) {
+	base := time.Unix(1000, 0)
+	ebc := NewEmergencyBlockchainWithGenesis(NewGenesisBlock(base), UrgencyConfig{Omega: 0.5}, 5, time.Second)
+	normalHeight := 1
+	ebc.SetNormalChainHeight(func() int { return normalHeight })
+
+	newTx := func(id string, deadline time.Duration, ref int) *EmergencyTransaction {
+		tx := NewEmergencyTransaction(id, "1", nil, base, base.Add(deadline), base.Add(time.Second), 0, ebc.UrgencyCfg)
+		tx.NormalChainRef = ref
+		return tx
+	}
+	// 引用尚未上链的普通区块 2 的交易紧急度最高
+	pending := newTx("pending", 2*time.Second, 2)
+	ebc.AddTransaction(pending)
+	ebc.AddTransaction(newTx("resolved", 5*time.Second, 1))
+	ebc.AddTransaction(newTx("independent", 10*time.Second, 0))
+
+	ready := func(tx *EmergencyTransaction) bool { return ebc.CheckNormalChainRef(tx) == nil }
+	picked := ebc.TxPool.GetTopKReadyTransactions(ebc.BlockSize, ready)
+	var ids []string
+	for _, tx := range picked {
+		ids = append(ids, tx.ID)
+	}
+	if len(ids) != 2 || ids[0] != "resolved" || ids[1] != "independent" {
+		t.Fatalf("选出的交易 = %v, 期望 [resolved independent]", ids)
+	}
+	if ebc.TxPool.Size() != 1 {
+		t.Fatalf("交易池剩余 %d 笔, 期望等待中的交易留在池中", ebc.TxPool.Size())
+	}
+
+	if err := ebc.CheckNormalChainRef(pending); !errors.Is(err, ErrUnresolvedNormalRef) {
+		t.Errorf("CheckNormalChainRef 应返回 ErrUnresolvedNormalRef，实际 %v", err)
+	}
+	block := NewEmergencyBlock(1, ebc.GetLatestBlock().Hash, []*EmergencyTransaction{pending}, nil, nil)
+	if ebc.VerifyBlock(block) {
+		t.Error("包含未上链普通区块引用的区块通过了验证")
+	}
+
+	// 普通区块 2 上链后交易可以打包
+	normalHeight = 2
+	if !ebc.VerifyBlock(block) {
+		t.Error("引用的普通区块上链后区块仍未通过验证")
+	}
+
+	// 关闭跨链校验后不再检查引用
+	normalHeight = 1
+	ebc.SetNormalChainHeight(nil)
+	if !ebc.VerifyBlock(block) {
+		t.Error("关闭跨链校验后区块未通过验证")
+	}
+}
+
+func TestTamperedNormalChainRefChangesMerkleRoot(t *testing.T) {
+	txs := []*EmergencyTransaction{{ID: "tx1", VehicleID: "1", Data: []byte("brake"), NormalChainRef: 3}}
+	block := NewEmergencyBlock(1, "prev", txs, nil, nil)
+	root := block.MerkleRoot
+
+	txs[0].NormalChainRef = 1
+	if got := block.CalculateMerkleRoot(); got == root {
+		t.Error("仅篡改跨链引用后默克尔根未变化")
+	}
+}
+
+func TestOnCommitFiresOncePerCommittedBlock(t *testing.T) {
+	ebc := NewEmergencyBlockchain(UrgencyConfig{}, 5, time.Second)
+	vg := NewValidatorGroup(4, 10)
+	fake := &fakeReputation{}
+	var peers []*EmergencyNode
+	for _, id := range []string{"1", "2", "3", "4"} {
+		node := NewEmergencyNode(id, ebc, fake, vg)
+		node.CommitTimeout = time.Second
+		vg.Validators = append(vg.Validators, &Validator{ID: id, Reputation: 0.9})
+		peers = append(peers, node)
+	}
+	for _, node := range peers {
+		node.SetPeers(peers)
+		node.UpdateValidatorStatus()
+	}
+
+	var mutex sync.Mutex
+	fired := make(map[string]int)
+	var signers [][]string
+	ebc.OnCommit(func(block *EmergencyBlock, validators []string) {
+		// 回调在共识锁之外执行，访问节点的加锁方法不会死锁
+		for _, node := range peers {
+			node.PendingRounds()
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		fired[block.Hash]++
+		signers = append(signers, validators)
+	})
+
+	var committed []*EmergencyBlock
+	for i := 0; i < 3; i++ {
+		now := time.Now()
+		ebc.AddTransaction(&EmergencyTransaction{
+			ID: fmt.Sprintf("tx-%d", i), VehicleID: "9", ArrivalTime: now, DeadlineTime: now.Add(time.Minute),
+		})
+		block, err := peers[0].ProposeEmergencyBlock()
+		if err != nil {
+			t.Fatalf("第 %d 个区块提议失败: %v", i+1, err)
+		}
+		committed = append(committed, block)
+	}
+
+	// 回调在使区块上链的节点释放锁后触发，等待其完成
+	deadline := time.Now().Add(time.Second)
+	for {
+		mutex.Lock()
+		n := len(signers)
+		mutex.Unlock()
+		if n >= len(committed) || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// 其余节点随后确认同一区块，不应重复触发
+	time.Sleep(50 * time.Millisecond)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	for _, block := range committed {
+		if fired[block.Hash] != 1 {
+			t.Errorf("区块 %d 触发回调 %d 次, 期望 1 次", block.Index, fired[block.Hash])
+		}
+	}
+	if len(fired) != len(committed) {
+		t.Errorf("回调涉及 %d 个区块, 期望 %d 个", len(fired), len(committed))
+	}
+	_, required := BFT.Thresholds(vg.GetSize())
+	for _, ids := range signers {
+		if len(ids) < required || !sort.StringsAreSorted(ids) {
+			t.Errorf("签名验证器 = %v, 期望至少 %d 个且按ID升序", ids, required)
+		}
+	}
+}
+
+func TestProposerAndRoundAreHashedAndVerified(t *testing.T) {
+	base := time.Unix(1000, 0)
+	ebc := NewEmergencyBlockchainWithGenesis(NewGenesisBlock(base), UrgencyConfig{}, 5, time.Second)
+	genesis := ebc.GetLatestBlock()
+	build := func(proposer string, round int) *EmergencyBlock {
+		block := NewEmergencyBlock(1, genesis.Hash, nil, []string{"1", "2", "3", "4"}, nil)
+		block.Timestamp = base.Add(time.Second)
+		block.Proposer = proposer
+		block.Round = round
+		block.Hash = block.CalculateHash()
+		return block
+	}
+
+	block := build("2", 3)
+	if !ebc.VerifyBlock(block) {
+		t.Fatal("提议者为验证器之一的区块应通过验证")
+	}
+	if unset := build("", 0); unset.Hash == block.Hash {
+		t.Error("提议者与轮次应计入区块哈希")
+	}
+
+	// 篡改提议者或轮次后哈希不再匹配
+	forged := *block
+	forged.Proposer = "3"
+	if ebc.VerifyBlock(&forged) {
+		t.Error("篡改提议者的区块不应通过验证")
+	}
+	forged = *block
+	forged.Round = 4
+	if ebc.VerifyBlock(&forged) {
+		t.Error("篡改轮次的区块不应通过验证")
+	}
+
+	if ebc.VerifyBlock(build("5", 3)) {
+		t.Error("提议者不在验证器列表中的区块不应通过验证")
+	}
+
+	// 下一个区块的轮次不得早于父区块
+	ebc.Chain = append(ebc.Chain, block)
+	next := NewEmergencyBlock(2, block.Hash, nil, []string{"1", "2", "3", "4"}, nil)
+	next.Timestamp = base.Add(2 * time.Second)
+	next.Proposer = "1"
+	next.Round = 2
+	next.Hash = next.CalculateHash()
+	if ebc.VerifyBlock(next) {
+		t.Error("轮次早于父区块的区块不应通过验证")
+	}
+
+	ebc.SetRoundSource(func() int { return 7 })
+	if got := ebc.Round(); got != 7 {
+		t.Errorf("Round() = %d, 期望 7", got)
+	}
+}
+
+func TestConcurrentChainAccess(t *testing.T) {
+	base := time.Unix(1000, 0)
+	ebc := NewEmergencyBlockchainWithGenesis(NewGenesisBlock(base), UrgencyConfig{}, 5, time.Second)
+	const blocks = 50
+
+	// 一个协程依次追加区块，其余协程并发读取链（go test -race 下检查数据竞争）
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+		for i := 1; i <= blocks; i++ {
+			latest := ebc.GetLatestBlock()
+			tx := &EmergencyTransaction{ID: fmt.Sprintf("tx%d", i), VehicleID: "1"}
+			ebc.AddTransaction(tx)
+			block := NewEmergencyBlock(latest.Index+1, latest.Hash, ebc.TxPool.GetTopKTransactions(1), nil, nil)
+			if err := ebc.AddBlock(block, nil); err != nil {
+				t.Errorf("AddBlock(%d): %v", i, err)
+				return
+			}
+		}
+	}()
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				latest := ebc.GetLatestBlock()
+				if !ebc.HasBlock(latest.Hash) {
+					t.Errorf("链顶区块 %d 不在链上", latest.Index)
+					return
+				}
+				if n := len(ebc.Blocks()); n < latest.Index+1 {
+					t.Errorf("快照长度 %d 小于链顶高度 %d+1", n, latest.Index)
+					return
+				}
+				ebc.TransactionStatus("tx1")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := ebc.GetChainLength(); got != blocks+1 {
+		t.Errorf("链长度 = %d, 期望 %d", got, blocks+1)
+	}
+}
+
+func TestReadyToProposeWaitsForMinTransactions(t *testing.T) {
+	base := time.Unix(1000, 0)
+	ebc := NewEmergencyBlockchainWithGenesis(NewGenesisBlock(base), UrgencyConfig{}, 5, 3*time.Second)
+	ebc.MinBlockTransactions = 3
+
+	if ebc.ReadyToPropose(base.Add(time.Hour)) {
+		t.Error("交易池为空时不应出块，即使已超过出块周期")
+	}
+	for i := 0; i < 2; i++ {
+		ebc.AddTransaction(&EmergencyTransaction{ID: fmt.Sprintf("tx%d", i), VehicleID: "9", ArrivalTime: base})
+	}
+	if ebc.ReadyToPropose(base.Add(time.Second)) {
+		t.Error("待处理交易不足 3 笔且未到出块周期时不应出块")
+	}
+	ebc.AddTransaction(&EmergencyTransaction{ID: "tx2", VehicleID: "9", ArrivalTime: base})
+	if !ebc.ReadyToPropose(base.Add(time.Second)) {
+		t.Error("待处理交易达到 3 笔时应出块")
+	}
+}
+
+func TestPartialBlockFlushedAfterBlockPeriod(t *testing.T) {
+	base := time.Unix(1000, 0)
+	ebc := NewEmergencyBlockchainWithGenesis(NewGenesisBlock(base), UrgencyConfig{}, 5, 3*time.Second)
+	ebc.MinBlockTransactions = 3
+	vg := NewValidatorGroup(4, 10)
+	var peers []*EmergencyNode
+	for _, id := range []string{"1", "2", "3", "4"} {
+		node := NewEmergencyNode(id, ebc, &fakeReputation{}, vg)
+		node.Logger = logging.Discard()
+		vg.Validators = append(vg.Validators, &Validator{ID: id, Reputation: 0.9})
+		peers = append(peers, node)
+	}
+	for _, node := range peers {
+		node.SetPeers(peers)
+		node.UpdateValidatorStatus()
+	}
+	for i := 0; i < 2; i++ {
+		ebc.AddTransaction(&EmergencyTransaction{ID: fmt.Sprintf("tx%d", i), VehicleID: "9", ArrivalTime: base})
+	}
+
+	// 出块周期内交易不足时等待
+	proposer := peers[0]
+	proposer.Clock = clock.Fixed(base.Add(time.Second))
+	if _, err := proposer.ProposeEmergencyBlock(); !errors.Is(err, ErrBelowMinTxs) {
+		t.Fatalf("出块周期内交易不足时的错误 = %v, 期望 ErrBelowMinTxs", err)
+	}
+	if ebc.TxPool.Size() != 2 {
+		t.Fatalf("等待出块时交易池大小 = %d, 期望 2", ebc.TxPool.Size())
+	}
+
+	// 超过出块周期后把现有交易打包成不满的区块
+	proposer.Clock = clock.Fixed(base.Add(3 * time.Second))
+	block, err := proposer.ProposeEmergencyBlock()
+	if err != nil {
+		t.Fatalf("超过出块周期后提议失败: %v", err)
+	}
+	if len(block.Transactions) != 2 || !ebc.HasBlock(block.Hash) {
+		t.Errorf("超过出块周期后上链的区块包含 %d 笔交易, 期望打包现有的 2 笔", len(block.Transactions))
+	}
+	if ebc.TxPool.Size() != 0 {
+		t.Errorf("出块后交易池大小 = %d, 期望 0", ebc.TxPool.Size())
+	}
+}
+
+func TestVerifyBlockRequiresTimeOrderedTransactions(t *testing.T) {
+	base := time.Unix(1000, 0)
+	ebc := NewEmergencyBlockchainWithGenesis(NewGenesisBlock(base), UrgencyConfig{Omega: 0.5}, 5, time.Second)
+	genesis := ebc.GetLatestBlock()
+	newTx := func(id string, arrival time.Duration) *EmergencyTransaction {
+		return NewEmergencyTransaction(id, "1", nil, base, base.Add(10*time.Second), base.Add(arrival), 0, ebc.UrgencyCfg)
+	}
+	early, late := newTx("b", time.Second), newTx("a", 2*time.Second)
+	tieA, tieB := newTx("x", 3*time.Second), newTx("y", 3*time.Second)
+
+	tests := []struct {
+		name string
+		txs  []*EmergencyTransaction
+		want bool
+	}{
+		{"按到达时间升序", []*EmergencyTransaction{early, late, tieA, tieB}, true},
+		{"到达时间倒序", []*EmergencyTransaction{late, early}, false},
+		{"到达时间相同时按交易ID升序", []*EmergencyTransaction{tieB, tieA}, false},
+	}
+	for _, tt := range tests {
+		// 哈希与默克尔根按给定顺序计算，只有时间顺序可能不合法
+		block := NewEmergencyBlock(1, genesis.Hash, tt.txs, nil, nil)
+		if got := block.IsTimeOrdered(); got != tt.want {
+			t.Errorf("%s: IsTimeOrdered = %v, 期望 %v", tt.name, got, tt.want)
+		}
+		if got := ebc.VerifyBlock(block); got != tt.want {
+			t.Errorf("%s: VerifyBlock = %v, 期望 %v", tt.name, got, tt.want)
+		}
+	}
+}
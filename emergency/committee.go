@@ -0,0 +1,69 @@
+package emergency
+
+import (
+	"sort"
+	"sync"
+)
+
+// CommitteeHistory 按模拟轮次记录活跃的验证器集合，便于事后审计哪一届验证器组产出了哪个区块
+// 验证器组只在刷新时输出新的集合，而 PenalizeInactiveValidators 可能在活跃周期中途改变集合，
+// 因此每轮都记录一次快照；可并发调用
+type CommitteeHistory struct {
+	mutex  sync.RWMutex
+	rounds map[int][]Validator // [轮次]验证器快照（按验证器组中的顺序）
+}
+
+// NewCommitteeHistory 创建验证器集合历史
+func NewCommitteeHistory() *CommitteeHistory {
+	return &CommitteeHistory{rounds: make(map[int][]Validator)}
+}
+
+// Record 记录验证器组在 round 轮的验证器ID与信誉值，同一轮重复记录时以最后一次为准
+func (h *CommitteeHistory) Record(round int, vg *ValidatorGroup) []Validator {
+	snapshot := make([]Validator, len(vg.Validators))
+	for i, v := range vg.Validators {
+		snapshot[i] = *v
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.rounds[round] = snapshot
+	return snapshot
+}
+
+// At 返回 round 轮的验证器快照（副本），未记录的轮次返回 false
+func (h *CommitteeHistory) At(round int) ([]Validator, bool) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	snapshot, ok := h.rounds[round]
+	if !ok {
+		return nil, false
+	}
+	return append([]Validator(nil), snapshot...), true
+}
+
+// IDs 返回 round 轮的验证器ID列表，未记录的轮次返回 nil
+func (h *CommitteeHistory) IDs(round int) []string {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	snapshot := h.rounds[round]
+	if snapshot == nil {
+		return nil
+	}
+	ids := make([]string, len(snapshot))
+	for i, v := range snapshot {
+		ids[i] = v.ID
+	}
+	return ids
+}
+
+// Rounds 返回已记录的轮次（升序）
+func (h *CommitteeHistory) Rounds() []int {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	rounds := make([]int, 0, len(h.rounds))
+	for r := range h.rounds {
+		rounds = append(rounds, r)
+	}
+	sort.Ints(rounds)
+	return rounds
+}
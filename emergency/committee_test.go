@@ -0,0 +1,45 @@
+package emergency
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCommitteeHistoryCapturesMidPeriodChanges(t *testing.T) {
+	ids := []string{"1", "2", "3", "4", "5", "6"}
+	fake := &fakeReputation{reputations: map[string]float64{"1": 0.9, "2": 0.8, "3": 0.7, "4": 0.6, "5": 0.5, "6": 0.4}}
+	now := time.Now()
+	vg := NewValidatorGroup(4, 10)
+	history := NewCommitteeHistory()
+
+	vg.SelectValidators(ids, fake.providers(ids...), now)
+	history.Record(1, vg)
+
+	// 活跃周期中途移除不活跃的验证器，集合随之变化
+	vg.PenalizeInactiveValidators([]string{"2"}, fake.providers(ids...), []string{"5", "6"}, now)
+	history.Record(2, vg)
+
+	if got := history.IDs(1); fmt.Sprint(got) != fmt.Sprint([]string{"1", "2", "3", "4"}) {
+		t.Errorf("第 1 轮验证器 = %v", got)
+	}
+	if got := history.IDs(2); fmt.Sprint(got) != fmt.Sprint([]string{"1", "3", "4", "5"}) {
+		t.Errorf("第 2 轮验证器 = %v", got)
+	}
+	snapshot, ok := history.At(2)
+	if !ok || snapshot[3].ID != "5" || snapshot[3].Reputation != 0.5 {
+		t.Errorf("第 2 轮快照 = %+v", snapshot)
+	}
+
+	// 快照与验证器组相互独立
+	vg.Validators[0].Reputation = 0
+	if snapshot, _ := history.At(1); snapshot[0].Reputation != 0.9 {
+		t.Error("验证器组的后续修改不应影响已记录的快照")
+	}
+	if _, ok := history.At(3); ok {
+		t.Error("未记录的轮次不应返回快照")
+	}
+	if got := history.Rounds(); fmt.Sprint(got) != "[1 2]" {
+		t.Errorf("Rounds() = %v, 期望 [1 2]", got)
+	}
+}
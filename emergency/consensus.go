@@ -1,64 +1,171 @@
 package emergency
 
 import (
+	"block/clock"
+	"block/logging"
 	"block/reputation"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
 	"fmt"
-	"math/rand"
+	"os"
+	"sort"
 	"sync"
 	"time"
 )
 
-// MessageType PBFT消息类型
+// 提议紧急区块失败的原因
+var (
+	ErrNotValidator     = errors.New("节点不是验证器节点")
+	ErrNoTransactions   = errors.New("交易池中没有待处理的紧急交易")
+	ErrBelowMinTxs      = errors.New("待处理交易数未达到出块下限，且未到出块周期")
+	ErrInvalidBlock     = errors.New("提议的区块未通过本地验证")
+	ErrQuorumNotReached = errors.New("共识超时，未收到足够的提交投票")
+	ErrTxTooLarge       = errors.New("单笔交易超过区块字节上限，已从交易池丢弃")
+	ErrTooFewValidators = errors.New("验证器数量不足以容忍拜占庭节点")
+)
+
+// MinBFTValidators BFT 下容忍至少 1 个拜占庭节点所需的最少验证器数（N=3f+1, f=1）
+// N<4 时 f=0，单个 Commit 投票即可确认区块，失去容错能力
+const MinBFTValidators = 4
+
+// defaultCommitTimeout 默认的共识等待时间
+const defaultCommitTimeout = 500 * time.Millisecond
+
+// QuorumPolicy 共识投票阈值策略
+type QuorumPolicy int
+
+const (
+	// BFT 拜占庭容错（默认）：f=(N-1)/3，Prepare 阈值 f+1，Commit 阈值 2f+1
+	BFT QuorumPolicy = iota
+	// CFT 崩溃容错：Prepare 与 Commit 阈值均为 N/2+1（简单多数）
+	// 只能容忍节点宕机，无法抵御拜占庭节点（伪造或双重投票），仅用于对比实验
+	CFT
+)
+
+// Thresholds 返回 N 个验证器时 Prepare 与 Commit 阶段所需的投票数
+func (p QuorumPolicy) Thresholds(n int) (prepare, commit int) {
+	if p == CFT {
+		majority := n/2 + 1
+		return majority, majority
+	}
+	// 在拜占庭容错中，f = (N-1)/3，N是验证器总数
+	f := (n - 1) / 3
+	return f + 1, 2*f + 1
+}
+
+// MessageType 共识消息类型
 type MessageType int
 
 const (
+	// PBFT 三阶段消息
 	PrePrepare MessageType = iota
 	Prepare
 	Commit
+
+	// 简单多数共识消息：出块者发送 Proposal，验证器回复签名的 Vote
+	Proposal
+	Vote
 )
 
-// ConsensusMessage PBFT共识消息
+// ConsensusMessage 共识消息
 type ConsensusMessage struct {
 	Type      MessageType     // 消息类型
 	BlockHash string          // 区块哈希
 	Block     *EmergencyBlock // 紧急区块
 	From      string          // 发送者ID
 	Timestamp time.Time       // 时间戳
+	Signature string          // 发送者对区块哈希的签名（Commit、Vote 消息携带）
 }
 
+// TrajectorySource 查询节点当前轨迹的函数
+// 返回节点截至当前时刻的轨迹向量序列，没有数据时返回 nil
+type TrajectorySource func(nodeID string) []reputation.Vector
+
 // EmergencyNode 紧急区块链节点
 type EmergencyNode struct {
 	ID                string                        // 节点ID
 	IsValidator       bool                          // 是否是验证器节点
 	Blockchain        *EmergencyBlockchain          // 紧急区块链
-	ReputationManager *reputation.ReputationManager // 信誉管理器
+	ReputationManager reputation.ReputationProvider // 信誉服务
 	ValidatorGroup    *ValidatorGroup               // 验证器节点组
 	Peers             []*EmergencyNode              // 对等节点
+	TxValidator       TransactionValidator          // 紧急交易评价器
+	CheckValidity     bool                          // 评价前按出块时间检查交易有效性，无效交易直接给负面评价
+	Trajectories      TrajectorySource              // 节点轨迹查询（为空时紧急交互不带轨迹）
+	CommitTimeout     time.Duration                 // 提议区块后等待共识确认的最长时间
+	RoundTimeout      time.Duration                 // 未确认区块的投票记录保留时间（0 表示不清理）
+	MaxPendingRounds  int                           // 同时进行的共识轮次上限（0 表示不限制）
+	Quorum            QuorumPolicy                  // PBFT 共识投票阈值策略（默认 BFT）
+	Engine            ConsensusEngine               // 共识引擎（默认 PBFT），可用 SetConsensusEngine 替换
+	Network           Network                       // 共识消息传输层（默认 DirectNetwork），运行中用 SetNetwork 替换
+	ProposerReward    int                           // 区块确认后验证器给提议者的正面事件数（0 表示不评价）
+	Rewards           *RewardLedger                 // 出块奖励账本（为空时不记账）
+	RateLimitPenalty  int                           // 发送者超限提交紧急交易时给予的负面事件数（0 表示不评价）
+	Logger            logging.Logger                // 日志输出（默认标准输出、info 级别）
+	Clock             clock.Clock                   // 本地时钟（默认沿用信誉管理器的时钟，否则为系统时钟），决定消息、交互与区块的时间戳
+	privateKey        ed25519.PrivateKey            // 提交签名私钥，公钥登记在验证器组中
 	mutex             sync.Mutex                    // 互斥锁
+	networkMutex      sync.RWMutex                  // 保护 Network；广播通常在持有 mutex 时进行，因此单独加锁
 
 	// PBFT共识相关
 	prePrepareReceived map[string]*ConsensusMessage // PrePrepare消息缓存
 	prepareVotes       map[string]map[string]bool   // Prepare投票记录 [blockHash][voterID]
-	commitVotes        map[string]map[string]bool   // Commit投票记录 [blockHash][voterID]
+	commitVotes        map[string]map[string]string // Commit投票记录 [blockHash][voterID]签名
+	committed          map[string]bool              // 本节点已确认的区块哈希
+	roundStarted       map[string]time.Time         // 进行中的共识轮次 [blockHash]首次收到消息的时间
+	pendingCommits     []*EmergencyBlock            // 本节点使其上链、尚未通知 OnCommit 回调的区块
+	unsettled          []*EmergencyBlock            // 本节点已确认、尚未确定因而未记录信誉交互与奖励的区块
+	droppedRounds      int                          // 因轮次达到上限而丢弃的消息数
 }
 
 // NewEmergencyNode 创建新的紧急区块链节点
+// 节点生成自己的签名密钥，并将公钥登记到验证器组
 func NewEmergencyNode(
 	id string,
 	blockchain *EmergencyBlockchain,
-	reputationManager *reputation.ReputationManager,
+	reputationManager reputation.ReputationProvider,
 	validatorGroup *ValidatorGroup,
 ) *EmergencyNode {
-	return &EmergencyNode{
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(fmt.Sprintf("生成节点 %s 的签名密钥失败: %v", id, err))
+	}
+	if validatorGroup != nil {
+		validatorGroup.RegisterPublicKey(id, publicKey)
+	}
+	en := &EmergencyNode{
 		ID:                 id,
 		Blockchain:         blockchain,
 		ReputationManager:  reputationManager,
 		ValidatorGroup:     validatorGroup,
 		Peers:              make([]*EmergencyNode, 0),
+		TxValidator:        NewMaliciousSenderValidator(map[string]bool{}),
+		CommitTimeout:      defaultCommitTimeout,
+		RoundTimeout:       defaultRoundTimeout,
+		MaxPendingRounds:   defaultMaxPendingRounds,
+		Network:            DirectNetwork{},
+		Logger:             logging.New(os.Stdout, logging.LevelInfo),
+		Clock:              clock.SystemClock{},
+		privateKey:         privateKey,
 		prePrepareReceived: make(map[string]*ConsensusMessage),
 		prepareVotes:       make(map[string]map[string]bool),
-		commitVotes:        make(map[string]map[string]bool),
+		commitVotes:        make(map[string]map[string]string),
+		committed:          make(map[string]bool),
+		roundStarted:       make(map[string]time.Time),
 	}
+	// 信誉管理器自带本地时钟（如 *reputation.ReputationManager）时沿用它，
+	// 这样一次 SetClock 即同时决定节点的时间戳与信誉计算的时刻
+	if c, ok := reputationManager.(clock.Clock); ok {
+		en.Clock = c
+	}
+	en.Engine = &pbftEngine{node: en}
+	return en
+}
+
+// PublicKey 返回节点的签名公钥
+func (en *EmergencyNode) PublicKey() ed25519.PublicKey {
+	return en.privateKey.Public().(ed25519.PublicKey)
 }
 
 // SetPeers 设置对等节点
@@ -66,6 +173,42 @@ func (en *EmergencyNode) SetPeers(peers []*EmergencyNode) {
 	en.Peers = peers
 }
 
+// SetTransactionValidator 设置紧急交易评价器
+func (en *EmergencyNode) SetTransactionValidator(v TransactionValidator) {
+	en.mutex.Lock()
+	defer en.mutex.Unlock()
+	en.TxValidator = v
+}
+
+// SetTrajectorySource 设置节点轨迹查询函数
+func (en *EmergencyNode) SetTrajectorySource(src TrajectorySource) {
+	en.mutex.Lock()
+	defer en.mutex.Unlock()
+	en.Trajectories = src
+}
+
+// SetNetwork 设置共识消息传输层
+func (en *EmergencyNode) SetNetwork(network Network) {
+	en.networkMutex.Lock()
+	defer en.networkMutex.Unlock()
+	en.Network = network
+}
+
+// network 返回当前的共识消息传输层
+func (en *EmergencyNode) network() Network {
+	en.networkMutex.RLock()
+	defer en.networkMutex.RUnlock()
+	return en.Network
+}
+
+// trajectoryOf 查询节点当前轨迹
+func (en *EmergencyNode) trajectoryOf(nodeID string) []reputation.Vector {
+	if en.Trajectories == nil {
+		return []reputation.Vector{}
+	}
+	return en.Trajectories(nodeID)
+}
+
 // UpdateValidatorStatus 更新节点的验证器状态
 func (en *EmergencyNode) UpdateValidatorStatus() {
 	en.IsValidator = en.ValidatorGroup.IsValidator(en.ID)
@@ -73,35 +216,80 @@ func (en *EmergencyNode) UpdateValidatorStatus() {
 
 // Broadcast 广播消息给所有节点
 func (en *EmergencyNode) Broadcast(msg ConsensusMessage) {
+	network := en.network()
 	for _, peer := range en.Peers {
 		if peer.ID != en.ID {
-			go peer.ReceiveMessage(msg)
+			network.Send(en, peer, msg)
 		}
 	}
 }
 
 // BroadcastToValidators 广播消息给验证器节点
 func (en *EmergencyNode) BroadcastToValidators(msg ConsensusMessage) {
+	network := en.network()
 	for _, peer := range en.Peers {
 		if peer.ID != en.ID && peer.IsValidator {
-			go peer.ReceiveMessage(msg)
+			network.Send(en, peer, msg)
 		}
 	}
 }
 
 // ReceiveMessage 接收共识消息
+// BlockHash 与区块内容不符的消息被丢弃，并给发送者一次负面评价
 func (en *EmergencyNode) ReceiveMessage(msg ConsensusMessage) {
+	defer en.notifyCommits()
 	en.mutex.Lock()
 	defer en.mutex.Unlock()
 
-	switch msg.Type {
-	case PrePrepare:
-		en.handlePrePrepare(msg)
-	case Prepare:
-		en.handlePrepare(msg)
-	case Commit:
-		en.handleCommit(msg)
+	if !messageHashMatches(msg) {
+		en.Logger.Warnf("节点 %s: 来自 %s 的消息区块哈希与区块内容不符，已丢弃\n", en.ID, msg.From)
+		en.reportTampering(msg)
+		return
+	}
+
+	en.Engine.OnMessage(msg)
+}
+
+// messageHashMatches 检查消息的 BlockHash 与区块声明的哈希及按区块头重新计算的哈希一致
+// BlockHash 用作投票记录的键，不一致时恶意节点可借此把投票计到其他区块上
+func messageHashMatches(msg ConsensusMessage) bool {
+	if msg.Block == nil {
+		return false
 	}
+	return msg.BlockHash == msg.Block.Hash && msg.BlockHash == msg.Block.CalculateHash()
+}
+
+// reportTampering 对发送篡改消息的节点记录一次负面的紧急交互，调用方需持有节点锁
+func (en *EmergencyNode) reportTampering(msg ConsensusMessage) {
+	if en.ReputationManager == nil || msg.From == en.ID {
+		return
+	}
+	inter := reputation.Interaction{
+		From:         en.ID,
+		To:           msg.From,
+		NegEvents:    1,
+		Timestamp:    en.Clock.Now(),
+		TrajUser:     en.trajectoryOf(en.ID),
+		TrajProvider: en.trajectoryOf(msg.From),
+		TxType:       reputation.EmergencyTransaction,
+	}
+	if err := en.ReputationManager.AddInteraction(inter); err != nil {
+		en.Logger.Errorf("  节点 %s 记录对 %s 的负面评价失败: %v\n", en.ID, msg.From, err)
+	}
+}
+
+// proposalAuthentic 校验提议消息的出块者：发送者必须是区块记录的提议者，
+// 且区块签名是提议者对区块哈希的有效签名，否则任一验证器都能以其他验证器的名义提议区块，
+// 出块奖励与出块统计记到被冒名的节点上；不通过时给发送者负面评价，调用方需持有节点锁
+func (en *EmergencyNode) proposalAuthentic(msg ConsensusMessage) bool {
+	block := msg.Block
+	keys := en.ValidatorGroup.PublicKeys([]string{block.Proposer})
+	if msg.From == block.Proposer && verifyHashSignature(keys[block.Proposer], block.Hash, block.Signature) {
+		return true
+	}
+	en.Logger.Warnf("节点 %s: 来自 %s 的区块 %s 提议者 %q 的签名无效，已丢弃\n", en.ID, msg.From, msg.BlockHash, block.Proposer)
+	en.reportTampering(msg)
+	return false
 }
 
 // handlePrePrepare 处理PrePrepare消息
@@ -113,28 +301,36 @@ func (en *EmergencyNode) handlePrePrepare(msg ConsensusMessage) {
 
 	// 验证区块合法性
 	if !en.Blockchain.VerifyBlock(msg.Block) {
-		fmt.Printf("节点 %s: 验证区块 %s 失败\n", en.ID, msg.BlockHash)
+		en.Logger.Warnf("节点 %s: 验证区块 %s 失败\n", en.ID, msg.BlockHash)
+		return
+	}
+	if !en.proposalAuthentic(msg) {
+		return
+	}
+	if !en.trackRound(msg.BlockHash) {
 		return
 	}
 
 	// 缓存PrePrepare消息
 	en.prePrepareReceived[msg.BlockHash] = &msg
 
-	// 发送Prepare消息
+	// 发送Prepare消息，并计入本节点的投票（否则 f 个节点宕机时其余节点凑不齐阈值）
 	prepareMsg := ConsensusMessage{
 		Type:      Prepare,
 		BlockHash: msg.BlockHash,
 		Block:     msg.Block,
 		From:      en.ID,
-		Timestamp: time.Now(),
+		Timestamp: en.Clock.Now(),
 	}
 	en.BroadcastToValidators(prepareMsg)
+	en.handlePrepare(prepareMsg)
 }
 
 // handlePrepare 处理Prepare消息
 func (en *EmergencyNode) handlePrepare(msg ConsensusMessage) {
-	// 验证器节点接收Prepare消息
-	if !en.IsValidator {
+	// 验证器节点接收Prepare消息；已确认的区块不再处理迟到的Prepare消息，
+	// 否则会为其重新开启共识轮次并再次广播Commit消息
+	if !en.IsValidator || en.committed[msg.BlockHash] || !en.trackRound(msg.BlockHash) {
 		return
 	}
 
@@ -144,55 +340,165 @@ func (en *EmergencyNode) handlePrepare(msg ConsensusMessage) {
 	}
 	en.prepareVotes[msg.BlockHash][msg.From] = true
 
-	// 检查是否收到足够的Prepare消息（超过 f+1 个）
-	// 在拜占庭容错中，f = (N-1)/3，N是验证器总数
-	N := en.ValidatorGroup.GetSize()
-	f := (N - 1) / 3
-	requiredVotes := f + 1
+	// 检查是否收到足够的Prepare消息（BFT 下为 f+1 个）
+	requiredVotes, _ := en.Quorum.Thresholds(en.ValidatorGroup.GetSize())
 
 	if len(en.prepareVotes[msg.BlockHash]) >= requiredVotes {
-		// 发送Commit消息
+		// 发送Commit消息，附带本节点对区块哈希的签名，并计入本节点的投票
 		commitMsg := ConsensusMessage{
 			Type:      Commit,
 			BlockHash: msg.BlockHash,
 			Block:     msg.Block,
 			From:      en.ID,
-			Timestamp: time.Now(),
+			Timestamp: en.Clock.Now(),
+			Signature: signHash(en.privateKey, msg.BlockHash),
 		}
 		en.BroadcastToValidators(commitMsg)
+		en.handleCommit(commitMsg)
 	}
 }
 
 // handleCommit 处理Commit消息
 func (en *EmergencyNode) handleCommit(msg ConsensusMessage) {
-	// 记录Commit投票
+	// 已确认的区块不再重复处理后续的Commit消息
+	if en.committed[msg.BlockHash] {
+		return
+	}
+
+	// 非验证器发送或签名无效（含发送者未登记公钥）的Commit消息不计入投票
+	keys := en.ValidatorGroup.PublicKeys([]string{msg.From})
+	if !en.ValidatorGroup.IsValidator(msg.From) ||
+		!verifyHashSignature(keys[msg.From], msg.BlockHash, msg.Signature) {
+		en.Logger.Warnf("节点 %s: 来自 %s 的区块 %s 提交签名无效\n", en.ID, msg.From, msg.BlockHash)
+		return
+	}
+	if !en.trackRound(msg.BlockHash) {
+		return
+	}
+
+	// 记录Commit投票及签名
 	if _, exists := en.commitVotes[msg.BlockHash]; !exists {
-		en.commitVotes[msg.BlockHash] = make(map[string]bool)
+		en.commitVotes[msg.BlockHash] = make(map[string]string)
 	}
-	en.commitVotes[msg.BlockHash][msg.From] = true
+	en.commitVotes[msg.BlockHash][msg.From] = msg.Signature
 
-	// 检查是否收到足够的Commit消息（超过 2f+1 个）
-	N := en.ValidatorGroup.GetSize()
-	f := (N - 1) / 3
-	requiredVotes := 2*f + 1
+	// 检查是否收到足够的Commit消息（BFT 下为 2f+1 个）
+	validatorCount := en.ValidatorGroup.GetSize()
+	_, requiredVotes := en.Quorum.Thresholds(validatorCount)
 
 	if len(en.commitVotes[msg.BlockHash]) >= requiredVotes {
-		// 将区块添加到区块链
-		en.Blockchain.AddBlock(msg.Block)
-		fmt.Printf("节点 %s: 区块 %d 已确认并添加到紧急区块链\n", en.ID, msg.Block.Index)
+		// BFT 下验证器不足 MinBFTValidators 个时拒绝确认区块
+		if en.Quorum == BFT && validatorCount < MinBFTValidators {
+			if len(en.commitVotes[msg.BlockHash]) == requiredVotes {
+				en.Logger.Warnf("⚠️ 节点 %s: 验证器仅 %d 个（BFT 至少需要 %d 个），拒绝确认区块 %d\n",
+					en.ID, validatorCount, MinBFTValidators, msg.Block.Index)
+			}
+			return
+		}
 
-		// ⭐ 新增：记录紧急交易的信誉交互
-		en.recordEmergencyInteractions(msg.Block)
+		en.committed[msg.BlockHash] = true
+		signatures := en.commitVotes[msg.BlockHash]
 
 		// 清理投票记录
-		delete(en.prePrepareReceived, msg.BlockHash)
-		delete(en.prepareVotes, msg.BlockHash)
-		delete(en.commitVotes, msg.BlockHash)
+		en.finishRound(msg.BlockHash)
+
+		en.commitBlock(msg.Block, signatures)
+	}
+}
+
+// commitBlock 共识引擎的完成回调：区块在本节点达成共识后上链
+// 信誉交互与出块奖励在区块确定（不会再被竞争区块替换）后才记录，分叉裁决中落败的区块不记录
+// signatures 为收集到的提交签名 [验证器ID]签名；调用方需持有节点锁
+func (en *EmergencyNode) commitBlock(block *EmergencyBlock, signatures map[string]string) {
+	// 将区块添加到区块链（各节点共享同一条链，其他节点可能已先行添加）
+	// 由成功上链的节点附上收集到的提交签名，使区块成为可独立校验的提交证书
+	err := en.Blockchain.AddBlock(block, signatures)
+	added := false // 区块是否由本节点添加上链，只由上链的节点通知 OnCommit 回调
+	switch {
+	case err == nil:
+		added = true
+		en.Logger.Debugf("节点 %s: 区块 %d 已确认并添加到紧急区块链\n", en.ID, block.Index)
+	case errors.Is(err, ErrDuplicateBlock):
+		en.Logger.Debugf("节点 %s: 区块 %d 已确认\n", en.ID, block.Index)
+	case errors.Is(err, ErrCompetingBlock):
+		winner, err := en.Blockchain.ResolveFork(block.Index)
+		if err != nil {
+			en.Logger.Errorf("节点 %s: 区块 %d 分叉裁决失败: %v\n", en.ID, block.Index, err)
+			return
+		}
+		en.Logger.Warnf("节点 %s: 区块 %d 出现分叉，裁决后保留区块 %s\n", en.ID, block.Index, winner.Hash[:8])
+		if winner != block {
+			return
+		}
+		added = true
+	default:
+		en.Logger.Warnf("节点 %s: 区块 %d 无法上链: %v\n", en.ID, block.Index, err)
+		return
 	}
+
+	if added {
+		en.pendingCommits = append(en.pendingCommits, block)
+	}
+	en.unsettled = append(en.unsettled, block)
+	en.settleCommits()
+}
+
+// SettleCommits 确定紧急区块链的链顶，并为本节点已确认的区块记录信誉交互与出块奖励
+// 在一轮共识结束、不会再有链顶高度的竞争区块时调用；之后到达的链顶竞争区块被拒绝
+func (en *EmergencyNode) SettleCommits() {
+	en.mutex.Lock()
+	defer en.mutex.Unlock()
+	en.Blockchain.Finalize()
+	en.settleCommits()
+}
+
+// settleCommits 为本节点已确认且已确定的区块记录信誉交互与出块奖励，调用方需持有节点锁
+// 链顶之下的区块已确定；已不在链上的区块在分叉裁决中被替换，直接丢弃
+func (en *EmergencyNode) settleCommits() {
+	finalized := en.Blockchain.FinalizedHeight()
+	var waiting []*EmergencyBlock
+	for _, block := range en.unsettled {
+		switch {
+		case !en.Blockchain.HasBlock(block.Hash):
+			en.Logger.Debugf("节点 %s: 区块 %d 在分叉裁决中被替换，不记录信誉交互与出块奖励\n", en.ID, block.Index)
+		case block.Index > finalized:
+			waiting = append(waiting, block)
+		default:
+			// ⭐ 新增：记录紧急交易的信誉交互
+			en.recordEmergencyInteractions(block)
+			en.rewardProposer(block)
+		}
+	}
+	en.unsettled = waiting
+}
+
+// notifyCommits 通知区块链的 OnCommit 回调本节点使其上链的区块
+// 在释放节点锁之后调用（以 defer 先于加锁注册），回调中再访问本节点不会死锁
+func (en *EmergencyNode) notifyCommits() {
+	en.mutex.Lock()
+	blocks := en.pendingCommits
+	en.pendingCommits = nil
+	en.mutex.Unlock()
+
+	for _, block := range blocks {
+		en.Blockchain.notifyCommit(block, sortedSigners(block.CommitSignatures))
+	}
+}
+
+// sortedSigners 返回提交证书中签名的验证器ID（升序）
+func sortedSigners(signatures map[string]string) []string {
+	ids := make([]string, 0, len(signatures))
+	for id := range signatures {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
 }
 
 // recordEmergencyInteractions 记录紧急区块中交易的信誉交互
 // 验证器节点验证紧急交易后，给交易发送者评价
+// 轨迹关联方式：区块确认时，TrajUser 取验证器（评价者）的当前轨迹，
+// TrajProvider 取交易发送者的当前轨迹，与普通交易一样参与轨迹相似度计算
 func (en *EmergencyNode) recordEmergencyInteractions(block *EmergencyBlock) {
 	// 只有验证器节点才记录信誉交互
 	if !en.IsValidator {
@@ -201,19 +507,20 @@ func (en *EmergencyNode) recordEmergencyInteractions(block *EmergencyBlock) {
 
 	// 为区块中的每笔紧急交易创建信誉交互
 	for _, tx := range block.Transactions {
-		// 验证器（当前节点）作为评价者，交易发送者作为被评价者
-		// 假设紧急交易都是合法的（已经通过验证），给予正面评价
-		// 如果发现恶意交易，可以给负面评价
+		// 验证器不评价自己发送的交易，避免自评抬高信誉
+		if tx.VehicleID == en.ID {
+			continue
+		}
 
-		// 随机模拟验证结果（实际中应该是真实的验证逻辑）
-		// 90%概率是诚实交易，10%概率是恶意交易
+		// 验证器（当前节点）作为评价者，交易发送者作为被评价者
+		// 开启 CheckValidity 时无效交易（数据为空、出块时已超过截止时间）记 1 次负面事件，
+		// 有效交易与未开启时的评价结果由可插拔的评价器决定
 		var posEvents, negEvents int
-		if rand.Float64() < 0.9 {
-			posEvents = 1
-			negEvents = 0
+		if valid, reason := tx.IsValid(block.Timestamp); en.CheckValidity && !valid {
+			posEvents, negEvents = 0, 1
+			en.Logger.Debugf("  验证器 %s: 紧急交易 %s 无效: %s\n", en.ID, tx.ID, reason)
 		} else {
-			posEvents = 0
-			negEvents = 1
+			posEvents, negEvents = en.TxValidator.Evaluate(tx)
 		}
 
 		// 创建紧急交易类型的信誉交互
@@ -222,83 +529,164 @@ func (en *EmergencyNode) recordEmergencyInteractions(block *EmergencyBlock) {
 			To:            tx.VehicleID, // 交易发送者（被评价者）
 			PosEvents:     posEvents,
 			NegEvents:     negEvents,
-			Timestamp:     time.Now(),
-			TrajUser:      []reputation.Vector{}, // 可以从节点轨迹数据中获取
-			TrajProvider:  []reputation.Vector{},
+			Timestamp:     en.Clock.Now(),
+			TrajUser:      en.trajectoryOf(en.ID),          // 验证器的轨迹
+			TrajProvider:  en.trajectoryOf(tx.VehicleID),   // 交易发送者的轨迹
 			TxType:        reputation.EmergencyTransaction, // ⭐ 标记为紧急交易
 			UrgencyDegree: tx.UrgencyDegree,                // ⭐ 记录紧急度
 		}
 
 		// 添加到信誉管理器
-		en.ReputationManager.AddInteraction(inter)
+		if err := en.ReputationManager.AddInteraction(inter); err != nil {
+			en.Logger.Errorf("  验证器 %s 记录紧急交易 %s 的评价失败: %v\n", en.ID, tx.ID, err)
+			continue
+		}
 
-		fmt.Printf("  验证器 %s 对紧急交易 %s 的发送者 %s 进行评价 (紧急度=%.2f, 正面=%d, 负面=%d)\n",
+		en.Logger.Debugf("  验证器 %s 对紧急交易 %s 的发送者 %s 进行评价 (紧急度=%.2f, 正面=%d, 负面=%d)\n",
 			en.ID, tx.ID, tx.VehicleID, tx.UrgencyDegree, posEvents, negEvents)
 	}
 }
 
 // ProposeEmergencyBlock 提议新的紧急区块（仅验证器节点）
 // 根据论文 3.4.1.4 紧急区块生成
-func (en *EmergencyNode) ProposeEmergencyBlock() {
+// 提议后在 CommitTimeout 内等待区块上链，返回已确认的区块；
+// 失败时返回 ErrNotValidator、ErrTooFewValidators、ErrNoTransactions、ErrBelowMinTxs、
+// ErrUnresolvedNormalRef、ErrInvalidBlock 或 ErrQuorumNotReached；
+// 返回 ErrQuorumNotReached 时区块中的交易已放回交易池
+func (en *EmergencyNode) ProposeEmergencyBlock() (*EmergencyBlock, error) {
+	newBlock, err := en.broadcastProposal()
+	if err != nil {
+		return nil, err
+	}
+
+	// 等待共识完成（不持有节点锁，以便本节点处理投票消息）
+	deadline := time.Now().Add(en.CommitTimeout)
+	for {
+		if en.Blockchain.HasBlock(newBlock.Hash) {
+			return newBlock, nil
+		}
+		if time.Now().After(deadline) {
+			// 区块未能上链，打包的交易放回交易池，避免交易既不在池中也不在链上
+			en.returnInFlight(newBlock)
+			return newBlock, ErrQuorumNotReached
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// returnInFlight 将未能上链区块中的交易放回交易池
+// 各节点共享同一个交易池，需持有节点锁，避免与本节点处理共识消息时的上链操作交错
+func (en *EmergencyNode) returnInFlight(block *EmergencyBlock) {
+	en.mutex.Lock()
+	defer en.mutex.Unlock()
+
+	if en.Blockchain.HasBlock(block.Hash) {
+		return
+	}
+	if n := en.Blockchain.TxPool.ReturnTransactions(block.Transactions); n > 0 {
+		en.Logger.Warnf("验证器节点 %s: 区块 %d 未能在超时内上链，%d 笔交易放回交易池\n", en.ID, block.Index, n)
+	}
+}
+
+// broadcastProposal 打包交易并交由共识引擎向验证器节点发起共识
+func (en *EmergencyNode) broadcastProposal() (*EmergencyBlock, error) {
+	defer en.notifyCommits()
 	en.mutex.Lock()
 	defer en.mutex.Unlock()
 
 	// 只有验证器节点才能提议区块
 	if !en.IsValidator {
-		return
+		return nil, ErrNotValidator
+	}
+	if err := en.Engine.CheckValidators(en.ValidatorGroup.GetSize()); err != nil {
+		return nil, err
 	}
 
 	// 检查交易池中是否有足够的交易
 	if en.Blockchain.TxPool.Size() == 0 {
-		return
+		return nil, ErrNoTransactions
+	}
+	if !en.Blockchain.ReadyToPropose(en.Clock.Now()) {
+		return nil, ErrBelowMinTxs
 	}
 
 	// 从交易池中获取紧急度最高的 k 笔交易
-	transactions := en.Blockchain.TxPool.GetTopKTransactions(en.Blockchain.BlockSize)
+	// 引用的普通链区块尚未上链的交易留在交易池中，等待依赖满足后再打包
+	transactions := en.Blockchain.TxPool.GetTopKReadyTransactions(en.Blockchain.BlockSize, func(tx *EmergencyTransaction) bool {
+		return en.Blockchain.CheckNormalChainRef(tx) == nil
+	})
 	if len(transactions) == 0 {
-		return
+		return nil, fmt.Errorf("%w: %d 笔待处理交易均在等待普通链区块",
+			ErrUnresolvedNormalRef, en.Blockchain.TxPool.Size())
 	}
 
-	// 创建新区块
 	latestBlock := en.Blockchain.GetLatestBlock()
-	newBlock := NewEmergencyBlock(
-		latestBlock.Index+1,
-		latestBlock.Hash,
-		transactions,
-		en.ValidatorGroup.GetValidatorIDs(),
-	)
-
-	fmt.Printf("验证器节点 %s: 提议紧急区块 %d (包含 %d 笔交易, 总紧急度=%.2f)\n",
-		en.ID, newBlock.Index, len(newBlock.Transactions), newBlock.TotalUrgency)
+	validatorIDs := en.ValidatorGroup.GetValidatorIDs()
+	validatorReputations := en.ValidatorGroup.GetValidatorReputations()
+	// 候选区块使用同一时间戳，保证试算的区块大小与最终区块一致
+	proposedAt := en.Clock.Now()
+	build := func(txs []*EmergencyTransaction) *EmergencyBlock {
+		block := NewEmergencyBlock(latestBlock.Index+1, latestBlock.Hash, txs, validatorIDs, validatorReputations)
+		block.Timestamp = proposedAt
+		block.Proposer = en.ID
+		block.Round = en.Blockchain.Round()
+		block.Hash = block.CalculateHash()
+		return block
+	}
 
-	// 发送PrePrepare消息给所有验证器节点
-	prePrepareMsg := ConsensusMessage{
-		Type:      PrePrepare,
-		BlockHash: newBlock.Hash,
-		Block:     newBlock,
-		From:      en.ID,
-		Timestamp: time.Now(),
+	// 按紧急度顺序打包，区块字节数达到上限时停止，未打包的交易放回交易池
+	transactions, rest := en.Blockchain.fitMaxBlockBytes(transactions, build)
+	if len(transactions) == 0 {
+		// 紧急度最高的交易单独成块也超过上限，永远无法打包，直接丢弃
+		en.Blockchain.TxPool.CompleteInFlight(rest[:1])
+		en.Blockchain.TxPool.evict(rest[:1])
+		en.Blockchain.TxPool.ReturnTransactions(rest[1:])
+		return nil, fmt.Errorf("%w: 交易 %s", ErrTxTooLarge, rest[0].ID)
 	}
-	en.BroadcastToValidators(prePrepareMsg)
+	en.Blockchain.TxPool.ReturnTransactions(rest)
+
+	// 按紧急度选出交易后，区块内按到达时间顺序排列
+	SortTransactionsByTime(transactions)
 
-	// 自己也处理这个消息
-	en.handlePrePrepare(prePrepareMsg)
+	// 创建新区块，出块者对区块哈希签名
+	newBlock := build(transactions)
+	if !en.Blockchain.VerifyBlock(newBlock) {
+		en.Blockchain.TxPool.ReturnTransactions(transactions)
+		return nil, ErrInvalidBlock
+	}
+	newBlock.Signature = signHash(en.privateKey, newBlock.Hash)
+
+	en.Logger.Infof("验证器节点 %s: 提议紧急区块 %d (包含 %d 笔交易, 总紧急度=%.2f)\n",
+		en.ID, newBlock.Index, len(newBlock.Transactions), newBlock.TotalUrgency)
+
+	// 由共识引擎向验证器节点发起共识
+	en.Engine.Propose(newBlock)
+
+	return newBlock, nil
 }
 
-// AddEmergencyTransaction 添加紧急交易（所有节点）
-func (en *EmergencyNode) AddEmergencyTransaction(tx *EmergencyTransaction) {
+// AddEmergencyTransaction 添加紧急交易（所有节点），返回交易是否被交易池接受
+// 发送者超过提交速率限制时交易被拒绝，设置了 RateLimitPenalty 时本节点给发送者负面评价
+func (en *EmergencyNode) AddEmergencyTransaction(tx *EmergencyTransaction) bool {
 	en.mutex.Lock()
 	defer en.mutex.Unlock()
 
-	en.Blockchain.AddTransaction(tx)
+	accepted, err := en.Blockchain.addTransaction(tx)
+	if errors.Is(err, ErrRateLimited) {
+		en.Logger.Warnf("节点 %s: 拒绝紧急交易 %s: %v\n", en.ID, tx.ID, err)
+		en.penalizeRateLimited(tx)
+	}
 
 	// 广播交易到所有节点
-	fmt.Printf("节点 %s: 收到紧急交易 %s (紧急度=%.4f)\n", en.ID, tx.ID, tx.UrgencyDegree)
+	if accepted {
+		en.Logger.Debugf("节点 %s: 收到紧急交易 %s (紧急度=%.4f)\n", en.ID, tx.ID, tx.UrgencyDegree)
+	}
+	return accepted
 }
 
 // GetReputation 获取节点信誉值
 func (en *EmergencyNode) GetReputation() float64 {
-	return en.ReputationManager.ComputeReputation(en.ID, time.Now())
+	return en.ReputationManager.ComputeReputation(en.ID, en.Clock.Now())
 }
 
 // GetBlockchainLength 获取紧急区块链长度
@@ -312,7 +700,7 @@ func (en *EmergencyNode) PrintBlockchain() {
 	defer en.mutex.Unlock()
 
 	fmt.Printf("\n=== 节点 %s 的紧急区块链 ===\n", en.ID)
-	for _, block := range en.Blockchain.Chain {
+	for _, block := range en.Blockchain.Blocks() {
 		fmt.Printf("区块 %d: Hash=%s, TxCount=%d, TotalUrgency=%.2f\n",
 			block.Index, block.Hash[:8], len(block.Transactions), block.TotalUrgency)
 	}
@@ -0,0 +1,624 @@
+package emergency
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"block/clock"
+	"block/config"
+	"block/logging"
+	"block/reputation"
+)
+
+func TestQuorumThresholds(t *testing.T) {
+	tests := []struct {
+		policy                  QuorumPolicy
+		n                       int
+		wantPrepare, wantCommit int
+	}{
+		{BFT, 1, 1, 1},
+		{BFT, 4, 2, 3},
+		{BFT, 6, 2, 3},
+		{BFT, 7, 3, 5},
+		{BFT, 10, 4, 7},
+		{CFT, 1, 1, 1},
+		{CFT, 4, 3, 3},
+		{CFT, 5, 3, 3},
+		{CFT, 6, 4, 4},
+		{CFT, 10, 6, 6},
+	}
+	for _, tt := range tests {
+		prepare, commit := tt.policy.Thresholds(tt.n)
+		if prepare != tt.wantPrepare || commit != tt.wantCommit {
+			t.Errorf("策略 %d, N=%d: 阈值 = (%d, %d), 期望 (%d, %d)",
+				tt.policy, tt.n, prepare, commit, tt.wantPrepare, tt.wantCommit)
+		}
+	}
+}
+
+func TestDefaultQuorumIsBFT(t *testing.T) {
+	en := NewEmergencyNode("1", NewEmergencyBlockchain(UrgencyConfig{}, 5, 0), nil, NewValidatorGroup(4, 10))
+	if en.Quorum != BFT {
+		t.Errorf("默认策略 = %d, 期望 BFT", en.Quorum)
+	}
+}
+
+func TestProposalWaitsForNormalChainRef(t *testing.T) {
+	base := time.Unix(1000, 0)
+	ebc := NewEmergencyBlockchainWithGenesis(NewGenesisBlock(base), UrgencyConfig{Omega: 0.5}, 5, time.Second)
+	ebc.SetNormalChainHeight(func() int { return 0 })
+	tx := NewEmergencyTransaction("tx", "2", nil, base, base.Add(2*time.Second), base.Add(time.Second), 0, ebc.UrgencyCfg)
+	tx.NormalChainRef = 1
+	ebc.AddTransaction(tx)
+
+	vg := NewValidatorGroup(4, 10)
+	for _, id := range []string{"1", "2", "3", "4"} {
+		vg.Validators = append(vg.Validators, &Validator{ID: id, Reputation: 0.9})
+	}
+	en := NewEmergencyNode("1", ebc, nil, vg)
+	en.IsValidator = true
+	if _, err := en.broadcastProposal(); !errors.Is(err, ErrUnresolvedNormalRef) {
+		t.Fatalf("交易均在等待普通链区块时提议应返回 ErrUnresolvedNormalRef，实际 %v", err)
+	}
+	if ebc.TxPool.Size() != 1 {
+		t.Errorf("交易池剩余 %d 笔, 期望等待中的交易仍在池中", ebc.TxPool.Size())
+	}
+}
+
+func TestCommitSignaturesFormQuorumCertificate(t *testing.T) {
+	base := time.Unix(1000, 0)
+	ebc := NewEmergencyBlockchainWithGenesis(NewGenesisBlock(base), UrgencyConfig{}, 5, time.Second)
+	vg := NewValidatorGroup(4, 10)
+	ids := []string{"1", "2", "3", "4"}
+	nodes := make(map[string]*EmergencyNode)
+	for _, id := range ids {
+		nodes[id] = NewEmergencyNode(id, ebc, nil, vg)
+		vg.Validators = append(vg.Validators, &Validator{ID: id, Reputation: 0.9})
+	}
+	outsider := NewEmergencyNode("5", ebc, nil, vg)
+	receiver := nodes["1"]
+	receiver.UpdateValidatorStatus()
+
+	block := NewEmergencyBlock(1, ebc.GetLatestBlock().Hash, nil, ids, vg.GetValidatorReputations())
+	commit := func(from *EmergencyNode, signature string) {
+		receiver.handleCommit(ConsensusMessage{
+			Type: Commit, BlockHash: block.Hash, Block: block, From: from.ID, Signature: signature,
+		})
+	}
+
+	// 伪造签名与非验证器的签名不计入投票（N=4 时 Commit 阈值为 3）
+	commit(nodes["2"], signHash(nodes["2"].privateKey, block.Hash))
+	commit(nodes["3"], signHash(nodes["4"].privateKey, block.Hash))
+	commit(outsider, signHash(outsider.privateKey, block.Hash))
+	if ebc.HasBlock(block.Hash) {
+		t.Fatal("有效提交签名不足时区块不应上链")
+	}
+	commit(nodes["3"], signHash(nodes["3"].privateKey, block.Hash))
+	commit(nodes["4"], signHash(nodes["4"].privateKey, block.Hash))
+	if !ebc.HasBlock(block.Hash) {
+		t.Fatal("收到 2f+1 个有效提交签名后区块应上链")
+	}
+
+	keys := vg.PublicKeys(block.ValidatorIDs)
+	if err := block.VerifyCommitQuorum(keys, BFT); err != nil {
+		t.Fatalf("提交证书校验失败: %v", err)
+	}
+	if len(block.CommitSignatures) != 3 {
+		t.Errorf("提交签名数 = %d, 期望 3", len(block.CommitSignatures))
+	}
+
+	// 篡改签名后有效签名不足
+	block.CommitSignatures["2"] = signHash(outsider.privateKey, block.Hash)
+	if err := block.VerifyCommitQuorum(keys, BFT); !errors.Is(err, ErrCommitQuorumNotMet) {
+		t.Errorf("篡改签名后应返回 ErrCommitQuorumNotMet，实际 %v", err)
+	}
+	block.CommitSignatures["2"] = signHash(nodes["2"].privateKey, block.Hash)
+
+	// 篡改区块头后签名对应的哈希不再匹配
+	block.Index = 2
+	if err := block.VerifyCommitQuorum(keys, BFT); !errors.Is(err, ErrCommitQuorumNotMet) {
+		t.Errorf("篡改区块头后应返回 ErrCommitQuorumNotMet，实际 %v", err)
+	}
+}
+
+func TestTooFewValidatorsRefuseToFinalize(t *testing.T) {
+	base := time.Unix(1000, 0)
+	ebc := NewEmergencyBlockchainWithGenesis(NewGenesisBlock(base), UrgencyConfig{}, 5, time.Second)
+	ebc.AddTransaction(NewEmergencyTransaction("tx", "2", nil, base, base.Add(2*time.Second), base.Add(time.Second), 0, ebc.UrgencyCfg))
+	vg := NewValidatorGroup(4, 10)
+	nodes := make(map[string]*EmergencyNode)
+	for _, id := range []string{"1", "2", "3"} {
+		nodes[id] = NewEmergencyNode(id, ebc, nil, vg)
+		vg.Validators = append(vg.Validators, &Validator{ID: id, Reputation: 0.9})
+	}
+	receiver := nodes["1"]
+	receiver.UpdateValidatorStatus()
+
+	// BFT 下 3 个验证器不允许提议区块
+	if _, err := receiver.broadcastProposal(); !errors.Is(err, ErrTooFewValidators) {
+		t.Errorf("3 个验证器提议区块应返回 ErrTooFewValidators，实际 %v", err)
+	}
+
+	// N=3 时 f=0，单个 Commit 投票已达到阈值，但不应确认区块
+	block := NewEmergencyBlock(1, ebc.GetLatestBlock().Hash, nil, vg.GetValidatorIDs(), nil)
+	for _, id := range []string{"2", "3"} {
+		receiver.handleCommit(ConsensusMessage{
+			Type: Commit, BlockHash: block.Hash, Block: block, From: id,
+			Signature: signHash(nodes[id].privateKey, block.Hash),
+		})
+	}
+	if ebc.HasBlock(block.Hash) {
+		t.Error("BFT 下验证器不足 4 个时区块不应被确认")
+	}
+
+	// CFT 不要求 3f+1 个验证器
+	receiver.Quorum = CFT
+	receiver.handleCommit(ConsensusMessage{
+		Type: Commit, BlockHash: block.Hash, Block: block, From: "2",
+		Signature: signHash(nodes["2"].privateKey, block.Hash),
+	})
+	if !ebc.HasBlock(block.Hash) {
+		t.Error("CFT 下达到多数投票后区块应被确认")
+	}
+}
+
+func TestCommittedBlockRecordsInteractionsThroughProvider(t *testing.T) {
+	base := time.Unix(1000, 0)
+	ebc := NewEmergencyBlockchainWithGenesis(NewGenesisBlock(base), UrgencyConfig{}, 5, time.Second)
+	vg := NewValidatorGroup(4, 10)
+	fake := &fakeReputation{reputations: map[string]float64{"1": 0.9}}
+	nodes := make(map[string]*EmergencyNode)
+	for _, id := range []string{"1", "2", "3", "4"} {
+		nodes[id] = NewEmergencyNode(id, ebc, fake, vg)
+		vg.Validators = append(vg.Validators, &Validator{ID: id, Reputation: 0.9})
+	}
+	receiver := nodes["1"]
+	receiver.UpdateValidatorStatus()
+	if got := receiver.GetReputation(); got != 0.9 {
+		t.Errorf("GetReputation = %v, 期望注入的 0.9", got)
+	}
+
+	txs := []*EmergencyTransaction{
+		{ID: "own", VehicleID: "1", ArrivalTime: base},
+		{ID: "other", VehicleID: "7", ArrivalTime: base.Add(time.Second), UrgencyDegree: 0.5},
+	}
+	block := NewEmergencyBlock(1, ebc.GetLatestBlock().Hash, txs, vg.GetValidatorIDs(), nil)
+	for _, id := range []string{"2", "3", "4"} {
+		receiver.handleCommit(ConsensusMessage{
+			Type: Commit, BlockHash: block.Hash, Block: block, From: id,
+			Signature: signHash(nodes[id].privateKey, block.Hash),
+		})
+	}
+
+	// 区块确定之前不记录交互
+	if len(fake.interactions) != 0 {
+		t.Fatalf("区块确定前记录了 %d 次交互", len(fake.interactions))
+	}
+	receiver.SettleCommits()
+
+	// 验证器不评价自己的交易，只为交易 other 记录一次紧急交互
+	if len(fake.interactions) != 1 {
+		t.Fatalf("记录了 %d 次交互, 期望 1 次", len(fake.interactions))
+	}
+	inter := fake.interactions[0]
+	if inter.From != "1" || inter.To != "7" || inter.TxType != reputation.EmergencyTransaction || inter.UrgencyDegree != 0.5 {
+		t.Errorf("交互 = %+v, 期望验证器 1 对发送者 7 的紧急交互", inter)
+	}
+}
+
+func TestInvalidTransactionsEvaluatedNegatively(t *testing.T) {
+	base := time.Unix(1000, 0)
+	ebc := NewEmergencyBlockchainWithGenesis(NewGenesisBlock(base), UrgencyConfig{}, 5, time.Second)
+	vg := NewValidatorGroup(4, 10)
+	vg.Validators = append(vg.Validators, &Validator{ID: "1", Reputation: 0.9})
+	fake := &fakeReputation{}
+	node := NewEmergencyNode("1", ebc, fake, vg)
+	node.UpdateValidatorStatus()
+	node.CheckValidity = true
+	// 评价器对所有交易给正面评价，负面评价只能来自有效性检查
+	node.SetTransactionValidator(NewMaliciousSenderValidator(map[string]bool{}))
+
+	txs := []*EmergencyTransaction{
+		{ID: "ok", VehicleID: "5", Data: []byte("x"), DeadlineTime: base.Add(time.Minute)},
+		{ID: "empty", VehicleID: "6", DeadlineTime: base.Add(time.Minute)},
+		{ID: "late", VehicleID: "7", Data: []byte("x"), DeadlineTime: base.Add(-time.Second)},
+	}
+	block := NewEmergencyBlock(1, ebc.GetLatestBlock().Hash, txs, vg.GetValidatorIDs(), nil)
+	block.Timestamp = base
+	node.recordEmergencyInteractions(block)
+
+	want := map[string][2]int{"5": {1, 0}, "6": {0, 1}, "7": {0, 1}}
+	if len(fake.interactions) != len(want) {
+		t.Fatalf("记录了 %d 次交互, 期望 %d 次", len(fake.interactions), len(want))
+	}
+	for _, inter := range fake.interactions {
+		if w := want[inter.To]; inter.PosEvents != w[0] || inter.NegEvents != w[1] {
+			t.Errorf("对发送者 %s 的评价 = (%d,%d), 期望 (%d,%d)", inter.To, inter.PosEvents, inter.NegEvents, w[0], w[1])
+		}
+	}
+
+	// 未开启有效性检查时沿用评价器的结果
+	fake.interactions = nil
+	node.CheckValidity = false
+	node.recordEmergencyInteractions(block)
+	for _, inter := range fake.interactions {
+		if inter.NegEvents != 0 {
+			t.Errorf("未开启有效性检查时发送者 %s 收到负面评价", inter.To)
+		}
+	}
+}
+
+func TestMismatchedBlockHashIsDroppedAndPenalized(t *testing.T) {
+	base := time.Unix(1000, 0)
+	ebc := NewEmergencyBlockchainWithGenesis(NewGenesisBlock(base), UrgencyConfig{}, 5, time.Second)
+	vg := NewValidatorGroup(4, 10)
+	fake := &fakeReputation{}
+	nodes := make(map[string]*EmergencyNode)
+	for _, id := range []string{"1", "2", "3", "4"} {
+		nodes[id] = NewEmergencyNode(id, ebc, fake, vg)
+		vg.Validators = append(vg.Validators, &Validator{ID: id, Reputation: 0.9})
+	}
+	receiver := nodes["1"]
+	receiver.UpdateValidatorStatus()
+
+	block := NewEmergencyBlock(1, ebc.GetLatestBlock().Hash, nil, vg.GetValidatorIDs(), nil)
+	forged := "forged-" + block.Hash
+
+	// PrePrepare 声明的哈希与区块不符：不缓存、不投票
+	receiver.ReceiveMessage(ConsensusMessage{Type: PrePrepare, BlockHash: forged, Block: block, From: "2"})
+	if len(receiver.prePrepareReceived) != 0 {
+		t.Error("哈希不符的 PrePrepare 消息不应被缓存")
+	}
+
+	// Commit 声明的哈希与区块不符：即使签名有效也不计票
+	for _, id := range []string{"2", "3", "4"} {
+		receiver.ReceiveMessage(ConsensusMessage{
+			Type: Commit, BlockHash: forged, Block: block, From: id,
+			Signature: signHash(nodes[id].privateKey, forged),
+		})
+	}
+	if len(receiver.commitVotes) != 0 || ebc.HasBlock(block.Hash) {
+		t.Error("哈希不符的 Commit 消息不应计入投票")
+	}
+
+	// 区块头被篡改后声明的哈希与重新计算的哈希不符
+	tampered := *block
+	tampered.Index = 5
+	receiver.ReceiveMessage(ConsensusMessage{Type: Prepare, BlockHash: tampered.Hash, Block: &tampered, From: "3"})
+	if len(receiver.prepareVotes) != 0 {
+		t.Error("区块头被篡改的 Prepare 消息不应计入投票")
+	}
+
+	// 每条被丢弃的消息都给发送者一次负面评价
+	if len(fake.interactions) != 5 {
+		t.Fatalf("记录了 %d 次负面评价, 期望 5 次", len(fake.interactions))
+	}
+	for _, inter := range fake.interactions {
+		if inter.From != "1" || inter.NegEvents != 1 || inter.PosEvents != 0 {
+			t.Errorf("评价 = %+v, 期望节点 1 给出的负面评价", inter)
+		}
+	}
+	if to := fake.interactions[0].To; to != "2" {
+		t.Errorf("首条负面评价针对 %s, 期望 PrePrepare 的发送者 2", to)
+	}
+}
+
+func TestForgedProposerIsRejected(t *testing.T) {
+	for _, engineName := range []string{config.ConsensusPBFT, config.ConsensusSimpleMajority} {
+		base := time.Unix(1000, 0)
+		ebc := NewEmergencyBlockchainWithGenesis(NewGenesisBlock(base), UrgencyConfig{}, 5, time.Second)
+		vg := NewValidatorGroup(4, 10)
+		fake := &fakeReputation{}
+		nodes := make(map[string]*EmergencyNode)
+		for _, id := range []string{"1", "2", "3", "4"} {
+			nodes[id] = NewEmergencyNode(id, ebc, fake, vg)
+			nodes[id].Logger = logging.Discard()
+			vg.Validators = append(vg.Validators, &Validator{ID: id, Reputation: 0.9})
+		}
+		receiver := nodes["1"]
+		receiver.UpdateValidatorStatus()
+		engine, err := NewConsensusEngine(engineName, receiver)
+		if err != nil {
+			t.Fatal(err)
+		}
+		receiver.SetConsensusEngine(engine)
+		msgType := PrePrepare
+		if engineName == config.ConsensusSimpleMajority {
+			msgType = Proposal
+		}
+
+		// 提议者记为 3 的合法区块，signer 对区块哈希签名
+		propose := func(from, signer string) {
+			block := NewEmergencyBlock(1, ebc.GetLatestBlock().Hash, nil, vg.GetValidatorIDs(), nil)
+			block.Timestamp = base.Add(time.Second)
+			block.Proposer = "3"
+			block.Hash = block.CalculateHash()
+			if signer != "" {
+				block.Signature = signHash(nodes[signer].privateKey, block.Hash)
+			}
+			receiver.ReceiveMessage(ConsensusMessage{Type: msgType, BlockHash: block.Hash, Block: block, From: from})
+		}
+
+		// 验证器 2 冒用 3 的名义提议：无签名、用自己的密钥签名、伪造发送者，均不开启共识轮次
+		propose("2", "")
+		propose("2", "2")
+		propose("3", "2")
+		if n := receiver.PendingRounds(); n != 0 {
+			t.Errorf("%s: 冒名提议开启了 %d 个共识轮次", engineName, n)
+		}
+		if len(fake.interactions) != 3 {
+			t.Errorf("%s: 记录了 %d 次负面评价, 期望每条冒名提议 1 次", engineName, len(fake.interactions))
+		}
+		for _, inter := range fake.interactions {
+			if inter.NegEvents != 1 {
+				t.Errorf("%s: 评价 = %+v, 期望负面评价", engineName, inter)
+			}
+		}
+
+		// 提议者本人发送并签名的区块正常进入共识
+		propose("3", "3")
+		if n := receiver.PendingRounds(); n != 1 {
+			t.Errorf("%s: 提议者本人的提议开启了 %d 个共识轮次, 期望 1 个", engineName, n)
+		}
+	}
+}
+
+func TestSkewedClocksTimestampInteractions(t *testing.T) {
+	base := time.Unix(1000, 0)
+	ebc := NewEmergencyBlockchainWithGenesis(NewGenesisBlock(base), UrgencyConfig{}, 5, time.Second)
+	vg := NewValidatorGroup(4, 10)
+	fakes := make(map[string]*fakeReputation)
+	nodes := make(map[string]*EmergencyNode)
+	for _, id := range []string{"1", "2", "3", "4"} {
+		fakes[id] = &fakeReputation{}
+		nodes[id] = NewEmergencyNode(id, ebc, fakes[id], vg)
+		vg.Validators = append(vg.Validators, &Validator{ID: id, Reputation: 0.9})
+	}
+	// 节点 1 的时钟快 3 秒，节点 2 的时钟慢 2 秒
+	nodes["1"].Clock = clock.SkewedClock{Base: clock.Fixed(base), Offset: 3 * time.Second}
+	nodes["2"].Clock = clock.SkewedClock{Base: clock.Fixed(base), Offset: -2 * time.Second}
+
+	txs := []*EmergencyTransaction{{ID: "tx", VehicleID: "7", ArrivalTime: base}}
+	block := NewEmergencyBlock(1, ebc.GetLatestBlock().Hash, txs, vg.GetValidatorIDs(), nil)
+	for _, receiver := range []*EmergencyNode{nodes["1"], nodes["2"]} {
+		receiver.UpdateValidatorStatus()
+		for _, id := range []string{"1", "2", "3", "4"} {
+			receiver.handleCommit(ConsensusMessage{
+				Type: Commit, BlockHash: block.Hash, Block: block, From: id,
+				Signature: signHash(nodes[id].privateKey, block.Hash),
+			})
+		}
+		receiver.SettleCommits()
+	}
+
+	// 两个验证器按各自的本地时钟记录同一笔交易的评价
+	for id, want := range map[string]time.Time{"1": base.Add(3 * time.Second), "2": base.Add(-2 * time.Second)} {
+		if len(fakes[id].interactions) != 1 {
+			t.Fatalf("验证器 %s 记录了 %d 次交互, 期望 1 次", id, len(fakes[id].interactions))
+		}
+		if got := fakes[id].interactions[0].Timestamp; !got.Equal(want) {
+			t.Errorf("验证器 %s 的交互时间戳 = %v, 期望本地时钟 %v", id, got, want)
+		}
+	}
+}
+
+func TestSkewedReputationClocksDecayDifferently(t *testing.T) {
+	base := time.Unix(1000, 0)
+	ebc := NewEmergencyBlockchainWithGenesis(NewGenesisBlock(base), UrgencyConfig{}, 5, time.Second)
+	vg := NewValidatorGroup(4, 10)
+	managers := make(map[string]*reputation.ReputationManager)
+	nodes := make(map[string]*EmergencyNode)
+	for _, id := range []string{"1", "2", "3", "4"} {
+		managers[id] = reputation.NewReputationManager(config.DefaultConfig())
+		managers[id].SetLogger(logging.Discard())
+		// 两个节点事先记录了同一条较早的负面评价，新评价的衰减程度决定它能抵消多少
+		if err := managers[id].AddInteraction(reputation.Interaction{
+			From: "9", To: "7", NegEvents: 1, Timestamp: base.Add(-time.Minute),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		nodes[id] = NewEmergencyNode(id, ebc, managers[id], vg)
+		nodes[id].Logger = logging.Discard()
+		vg.Validators = append(vg.Validators, &Validator{ID: id, Reputation: 0.9})
+	}
+	// 只向信誉管理器注入时钟偏差：节点 1 快 30 秒，节点 2 慢 20 秒
+	skews := map[string]time.Duration{"1": 30 * time.Second, "2": -20 * time.Second}
+	for id, offset := range skews {
+		managers[id].SetClock(clock.SkewedClock{Base: clock.Fixed(base), Offset: offset})
+	}
+
+	txs := []*EmergencyTransaction{{ID: "tx", VehicleID: "7", ArrivalTime: base}}
+	block := NewEmergencyBlock(1, ebc.GetLatestBlock().Hash, txs, vg.GetValidatorIDs(), nil)
+	for _, id := range []string{"1", "2"} {
+		receiver := nodes[id]
+		receiver.UpdateValidatorStatus()
+		for _, from := range []string{"1", "2", "3", "4"} {
+			receiver.handleCommit(ConsensusMessage{
+				Type: Commit, BlockHash: block.Hash, Block: block, From: from,
+				Signature: signHash(nodes[from].privateKey, block.Hash),
+			})
+		}
+		receiver.SettleCommits()
+	}
+
+	// 节点沿用信誉管理器的时钟，交互按偏差后的本地时间记录
+	for id, offset := range skews {
+		if got, want := nodes[id].Clock.Now(), base.Add(offset); !got.Equal(want) {
+			t.Errorf("节点 %s 的本地时间 = %v, 期望信誉管理器的时间 %v", id, got, want)
+		}
+		history := managers[id].History("7")
+		if len(history) != 2 {
+			t.Fatalf("节点 %s 记录了 %d 次关于车辆 7 的交互, 期望 2 次", id, len(history))
+		}
+		if got, want := history[1].Timestamp, base.Add(offset); !got.Equal(want) {
+			t.Errorf("节点 %s 的交互时间戳 = %v, 期望 %v", id, got, want)
+		}
+	}
+
+	// 同一时刻评估时，两条评价的衰减程度不同，得出的信誉值也不同
+	at := base.Add(time.Minute)
+	fast, slow := managers["1"].ComputeReputation("7", at), managers["2"].ComputeReputation("7", at)
+	if fast <= slow {
+		t.Errorf("时钟快的节点算得信誉 %v, 时钟慢的节点算得 %v, 期望较新的评价衰减更少、信誉更高", fast, slow)
+	}
+}
+
+func TestForkAtTipRecordsOnlyWinningBlock(t *testing.T) {
+	base := time.Unix(1000, 0)
+	ids := []string{"1", "2", "3", "4"}
+
+	// run 让节点 1 先确认区块 first、节点 2 再确认同高度的竞争区块 second
+	run := func(firstUrgency, secondUrgency float64) (ebc *EmergencyBlockchain, first, second *EmergencyBlock,
+		fakes map[string]*fakeReputation, ledger *RewardLedger) {
+		ebc = NewEmergencyBlockchainWithGenesis(NewGenesisBlock(base), UrgencyConfig{}, 5, time.Second)
+		vg := NewValidatorGroup(4, 10)
+		ledger = NewRewardLedger(1)
+		fakes = make(map[string]*fakeReputation)
+		nodes := make(map[string]*EmergencyNode)
+		for _, id := range ids {
+			fakes[id] = &fakeReputation{}
+			nodes[id] = NewEmergencyNode(id, ebc, fakes[id], vg)
+			nodes[id].Logger = logging.Discard()
+			nodes[id].Rewards = ledger
+			vg.Validators = append(vg.Validators, &Validator{ID: id, Reputation: 0.9})
+		}
+		for _, node := range nodes {
+			node.UpdateValidatorStatus()
+		}
+
+		genesis := ebc.GetLatestBlock()
+		propose := func(proposer, sender string, urgency float64) *EmergencyBlock {
+			tx := &EmergencyTransaction{ID: "tx-" + sender, VehicleID: sender, ArrivalTime: base, UrgencyDegree: urgency}
+			ebc.TxPool.AddTransaction(tx)
+			ebc.TxPool.GetTopKTransactions(1)
+			block := NewEmergencyBlock(1, genesis.Hash, []*EmergencyTransaction{tx}, ids, nil)
+			block.Proposer = proposer
+			block.Hash = block.CalculateHash()
+			block.Signature = signHash(nodes[proposer].privateKey, block.Hash)
+			return block
+		}
+		commit := func(receiver string, block *EmergencyBlock) {
+			for _, from := range ids {
+				nodes[receiver].handleCommit(ConsensusMessage{
+					Type: Commit, BlockHash: block.Hash, Block: block, From: from,
+					Signature: signHash(nodes[from].privateKey, block.Hash),
+				})
+			}
+		}
+
+		first, second = propose("3", "7", firstUrgency), propose("4", "8", secondUrgency)
+		commit("1", first)
+		commit("2", second)
+		for _, node := range nodes {
+			node.SettleCommits()
+		}
+
+		// 链顶已确定，之后到达的竞争区块被拒绝
+		stale := propose("1", "9", 1)
+		if err := ebc.AddBlock(stale, nil); !errors.Is(err, ErrStaleBlock) {
+			t.Errorf("链顶确定后添加竞争区块的错误 = %v, 期望 ErrStaleBlock", err)
+		}
+		return ebc, first, second, fakes, ledger
+	}
+
+	// assertWinner 检查胜出区块上链并记录评价与奖励，落败区块的交易放回交易池且不记录评价与奖励
+	assertWinner := func(name string, ebc *EmergencyBlockchain, winner, loser *EmergencyBlock,
+		fakes map[string]*fakeReputation, ledger *RewardLedger) {
+		t.Helper()
+		if got := ebc.GetLatestBlock(); got != winner || ebc.GetChainLength() != 2 {
+			t.Fatalf("%s: 链顶 = %s（链长 %d）, 期望胜出区块 %s", name, got.Hash[:8], ebc.GetChainLength(), winner.Hash[:8])
+		}
+		winnerTx, loserTx := winner.Transactions[0], loser.Transactions[0]
+		if status, index := ebc.TransactionStatus(winnerTx.ID); status != TxCommitted || index != 1 {
+			t.Errorf("%s: 胜出区块的交易状态 = %v@%d, 期望 committed@1", name, status, index)
+		}
+		if status, _ := ebc.TransactionStatus(loserTx.ID); status != TxPending {
+			t.Errorf("%s: 落败区块的交易状态 = %v, 期望放回交易池 pending", name, status)
+		}
+		for id, fake := range fakes {
+			for _, inter := range fake.interactions {
+				if inter.To == loserTx.VehicleID {
+					t.Errorf("%s: 验证器 %s 评价了落败区块中交易的发送者 %s", name, id, inter.To)
+				}
+			}
+		}
+		if got := ledger.ProposedBlocks(loser.Proposer); got != 0 {
+			t.Errorf("%s: 落败区块的提议者 %s 获得了 %d 次出块奖励", name, loser.Proposer, got)
+		}
+		if got := ledger.ProposedBlocks(winner.Proposer); got != 1 {
+			t.Errorf("%s: 胜出区块的提议者 %s 获得了 %d 次出块奖励, 期望 1 次", name, winner.Proposer, got)
+		}
+	}
+
+	// 后确认的竞争区块总紧急度更高，替换先上链的区块
+	ebc, first, second, fakes, ledger := run(0.2, 0.8)
+	assertWinner("竞争区块胜出", ebc, second, first, fakes, ledger)
+	if len(fakes["2"].interactions) != 1 {
+		t.Errorf("竞争区块胜出: 验证器 2 记录了 %d 次交互, 期望 1 次", len(fakes["2"].interactions))
+	}
+
+	// 后确认的竞争区块总紧急度更低，先上链的区块保留
+	ebc, first, second, fakes, ledger = run(0.8, 0.2)
+	assertWinner("竞争区块落败", ebc, first, second, fakes, ledger)
+	if len(fakes["1"].interactions) != 1 {
+		t.Errorf("竞争区块落败: 验证器 1 记录了 %d 次交互, 期望 1 次", len(fakes["1"].interactions))
+	}
+}
+
+func TestConcurrentCommitsPublishSignedBlocks(t *testing.T) {
+	base := time.Unix(1000, 0)
+	ebc := NewEmergencyBlockchainWithGenesis(NewGenesisBlock(base), UrgencyConfig{}, 5, time.Second)
+	vg := NewValidatorGroup(4, 10)
+	ids := []string{"1", "2", "3", "4"}
+	nodes := make(map[string]*EmergencyNode)
+	for _, id := range ids {
+		nodes[id] = NewEmergencyNode(id, ebc, &fakeReputation{}, vg)
+		nodes[id].Logger = logging.Discard()
+		vg.Validators = append(vg.Validators, &Validator{ID: id, Reputation: 0.9})
+	}
+	block := NewEmergencyBlock(1, ebc.GetLatestBlock().Hash, nil, ids, nil)
+
+	// 各节点并发确认同一区块，同时读取链上区块的提交证书（go test -race 下检查数据竞争）
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for _, receiver := range nodes {
+		receiver.UpdateValidatorStatus()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, from := range ids {
+				receiver.handleCommit(ConsensusMessage{
+					Type: Commit, BlockHash: block.Hash, Block: block, From: from,
+					Signature: signHash(nodes[from].privateKey, block.Hash),
+				})
+			}
+		}()
+	}
+	var reader sync.WaitGroup
+	reader.Add(1)
+	go func() {
+		defer reader.Done()
+		for {
+			for _, published := range ebc.Blocks()[1:] {
+				if len(published.CommitSignatures) < 3 {
+					t.Errorf("区块 %d 上链时只附有 %d 个提交签名", published.Index, len(published.CommitSignatures))
+					return
+				}
+			}
+			select {
+			case <-done:
+				return
+			default:
+			}
+		}
+	}()
+	wg.Wait()
+	close(done)
+	reader.Wait()
+
+	if err := ebc.GetLatestBlock().VerifyCommitQuorum(vg.PublicKeys(ids), BFT); err != nil {
+		t.Errorf("提交证书校验失败: %v", err)
+	}
+}
@@ -0,0 +1,197 @@
+package emergency
+
+import (
+	"block/config"
+	"errors"
+	"fmt"
+)
+
+// ErrUnknownConsensus 未知的共识引擎名称
+var ErrUnknownConsensus = errors.New("未知的共识引擎")
+
+// ConsensusEngine 紧急区块链的共识引擎
+// EmergencyNode 把区块提议与共识消息的处理委托给引擎；引擎判定区块达成共识后，
+// 调用节点的完成回调 commitBlock 将区块上链并记录信誉交互
+// 引擎的方法均在持有节点锁时调用
+type ConsensusEngine interface {
+	// Name 返回引擎名称（与配置项 emergencyConsensus 的取值一致）
+	Name() string
+	// CheckValidators 检查 n 个验证器能否运行该共识，不能时返回错误
+	CheckValidators(n int) error
+	// Propose 对本节点打包好的区块发起共识
+	Propose(block *EmergencyBlock)
+	// OnMessage 处理一条已通过哈希校验的共识消息
+	OnMessage(msg ConsensusMessage)
+}
+
+// NewConsensusEngine 按名称为节点创建共识引擎，名称见 config.ConsensusPBFT 等常量
+func NewConsensusEngine(name string, en *EmergencyNode) (ConsensusEngine, error) {
+	switch name {
+	case config.ConsensusPBFT:
+		return &pbftEngine{node: en}, nil
+	case config.ConsensusSimpleMajority:
+		return newSimpleMajorityEngine(en), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownConsensus, name)
+	}
+}
+
+// SetConsensusEngine 替换节点的共识引擎，应在节点开始处理共识消息之前设置
+func (en *EmergencyNode) SetConsensusEngine(engine ConsensusEngine) {
+	en.mutex.Lock()
+	defer en.mutex.Unlock()
+	en.Engine = engine
+}
+
+// pbftEngine 三阶段 PBFT 共识（默认），投票阈值由节点的 Quorum 决定
+// 共识状态保存在节点上，由节点的 handlePrePrepare、handlePrepare、handleCommit 处理
+type pbftEngine struct {
+	node *EmergencyNode
+}
+
+func (e *pbftEngine) Name() string { return config.ConsensusPBFT }
+
+func (e *pbftEngine) CheckValidators(n int) error {
+	if e.node.Quorum == BFT && n < MinBFTValidators {
+		return fmt.Errorf("%w: 验证器 %d 个, BFT 至少需要 %d 个", ErrTooFewValidators, n, MinBFTValidators)
+	}
+	return nil
+}
+
+// Propose 向所有验证器节点发送 PrePrepare 消息，自己也处理这个消息
+func (e *pbftEngine) Propose(block *EmergencyBlock) {
+	msg := ConsensusMessage{
+		Type:      PrePrepare,
+		BlockHash: block.Hash,
+		Block:     block,
+		From:      e.node.ID,
+		Timestamp: e.node.Clock.Now(),
+	}
+	e.node.BroadcastToValidators(msg)
+	e.node.handlePrePrepare(msg)
+}
+
+func (e *pbftEngine) OnMessage(msg ConsensusMessage) {
+	switch msg.Type {
+	case PrePrepare:
+		e.node.handlePrePrepare(msg)
+	case Prepare:
+		e.node.handlePrepare(msg)
+	case Commit:
+		e.node.handleCommit(msg)
+	}
+}
+
+// simpleMajorityEngine 轻量的出块者提交共识，用于与 PBFT 对比时延与吞吐
+// 出块者广播 Proposal，验证器校验区块后向所有验证器广播签名的 Vote，
+// 节点收到过半数（N/2+1）验证器的有效投票即确认区块；只有一轮投票，
+// 只能容忍节点宕机，无法抵御拜占庭节点，提交证书可用 VerifyCommitQuorum(keys, CFT) 校验
+type simpleMajorityEngine struct {
+	node    *EmergencyNode
+	votes   map[string]map[string]string // 投票记录 [blockHash][voterID]签名
+	decided map[string]bool              // 本节点已确认的区块哈希
+}
+
+func newSimpleMajorityEngine(en *EmergencyNode) *simpleMajorityEngine {
+	return &simpleMajorityEngine{
+		node:    en,
+		votes:   make(map[string]map[string]string),
+		decided: make(map[string]bool),
+	}
+}
+
+// forgetRound 清理过期或已确认轮次的投票记录
+func (e *simpleMajorityEngine) forgetRound(blockHash string) {
+	delete(e.votes, blockHash)
+}
+
+func (e *simpleMajorityEngine) Name() string { return config.ConsensusSimpleMajority }
+
+func (e *simpleMajorityEngine) CheckValidators(n int) error {
+	if n == 0 {
+		return fmt.Errorf("%w: 没有验证器", ErrTooFewValidators)
+	}
+	return nil
+}
+
+// Propose 向所有验证器节点发送 Proposal 消息，自己也处理这个消息
+func (e *simpleMajorityEngine) Propose(block *EmergencyBlock) {
+	msg := ConsensusMessage{
+		Type:      Proposal,
+		BlockHash: block.Hash,
+		Block:     block,
+		From:      e.node.ID,
+		Timestamp: e.node.Clock.Now(),
+	}
+	e.node.BroadcastToValidators(msg)
+	e.handleProposal(msg)
+}
+
+func (e *simpleMajorityEngine) OnMessage(msg ConsensusMessage) {
+	switch msg.Type {
+	case Proposal:
+		e.handleProposal(msg)
+	case Vote:
+		e.handleVote(msg)
+	}
+}
+
+// handleProposal 验证器校验提议的区块，通过后广播自己的签名投票并计入本节点
+func (e *simpleMajorityEngine) handleProposal(msg ConsensusMessage) {
+	en := e.node
+	if !en.IsValidator {
+		return
+	}
+	if !en.Blockchain.VerifyBlock(msg.Block) {
+		en.Logger.Warnf("节点 %s: 验证区块 %s 失败\n", en.ID, msg.BlockHash)
+		return
+	}
+	if !en.proposalAuthentic(msg) {
+		return
+	}
+
+	vote := ConsensusMessage{
+		Type:      Vote,
+		BlockHash: msg.BlockHash,
+		Block:     msg.Block,
+		From:      en.ID,
+		Timestamp: en.Clock.Now(),
+		Signature: signHash(en.privateKey, msg.BlockHash),
+	}
+	en.BroadcastToValidators(vote)
+	e.handleVote(vote)
+}
+
+// handleVote 记录投票，收到过半数验证器的有效投票后确认区块
+func (e *simpleMajorityEngine) handleVote(msg ConsensusMessage) {
+	en := e.node
+	if e.decided[msg.BlockHash] {
+		return
+	}
+
+	// 非验证器发送或签名无效的投票不计入
+	keys := en.ValidatorGroup.PublicKeys([]string{msg.From})
+	if !en.ValidatorGroup.IsValidator(msg.From) ||
+		!verifyHashSignature(keys[msg.From], msg.BlockHash, msg.Signature) {
+		en.Logger.Warnf("节点 %s: 来自 %s 的区块 %s 投票签名无效\n", en.ID, msg.From, msg.BlockHash)
+		return
+	}
+	if !en.trackRound(msg.BlockHash) {
+		return
+	}
+
+	if _, exists := e.votes[msg.BlockHash]; !exists {
+		e.votes[msg.BlockHash] = make(map[string]string)
+	}
+	e.votes[msg.BlockHash][msg.From] = msg.Signature
+
+	_, required := CFT.Thresholds(en.ValidatorGroup.GetSize())
+	if len(e.votes[msg.BlockHash]) < required {
+		return
+	}
+	e.decided[msg.BlockHash] = true
+	signatures := e.votes[msg.BlockHash]
+	en.finishRound(msg.BlockHash)
+
+	en.commitBlock(msg.Block, signatures)
+}
@@ -0,0 +1,93 @@
+package emergency
+
+import (
+	"block/config"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNewConsensusEngine(t *testing.T) {
+	en := NewEmergencyNode("1", NewEmergencyBlockchain(UrgencyConfig{}, 5, 0), nil, NewValidatorGroup(4, 10))
+	if en.Engine.Name() != config.ConsensusPBFT {
+		t.Errorf("默认共识引擎 = %s, 期望 %s", en.Engine.Name(), config.ConsensusPBFT)
+	}
+	for _, name := range []string{config.ConsensusPBFT, config.ConsensusSimpleMajority} {
+		engine, err := NewConsensusEngine(name, en)
+		if err != nil || engine.Name() != name {
+			t.Errorf("NewConsensusEngine(%q) = %v, %v", name, engine, err)
+		}
+	}
+	if _, err := NewConsensusEngine("raft", en); !errors.Is(err, ErrUnknownConsensus) {
+		t.Errorf("未知引擎应返回 ErrUnknownConsensus，实际 %v", err)
+	}
+}
+
+func TestSimpleMajorityCommitsOnMajorityVotes(t *testing.T) {
+	base := time.Unix(1000, 0)
+	ebc := NewEmergencyBlockchainWithGenesis(NewGenesisBlock(base), UrgencyConfig{}, 5, time.Second)
+	vg := NewValidatorGroup(4, 10)
+	fake := &fakeReputation{}
+	ids := []string{"1", "2", "3", "4"}
+	nodes := make(map[string]*EmergencyNode)
+	for _, id := range ids {
+		nodes[id] = NewEmergencyNode(id, ebc, fake, vg)
+		nodes[id].SetConsensusEngine(newSimpleMajorityEngine(nodes[id]))
+		vg.Validators = append(vg.Validators, &Validator{ID: id, Reputation: 0.9})
+	}
+	outsider := NewEmergencyNode("5", ebc, fake, vg)
+	receiver := nodes["1"]
+	receiver.UpdateValidatorStatus()
+
+	block := NewEmergencyBlock(1, ebc.GetLatestBlock().Hash, nil, ids, nil)
+	vote := func(from *EmergencyNode) {
+		receiver.ReceiveMessage(ConsensusMessage{
+			Type: Vote, BlockHash: block.Hash, Block: block, From: from.ID,
+			Signature: signHash(from.privateKey, block.Hash),
+		})
+	}
+
+	// N=4 时过半数为 3 票，非验证器的投票不计入
+	vote(nodes["2"])
+	vote(outsider)
+	vote(nodes["3"])
+	if ebc.HasBlock(block.Hash) {
+		t.Fatal("有效投票未过半数时区块不应上链")
+	}
+	vote(nodes["4"])
+	if !ebc.HasBlock(block.Hash) {
+		t.Fatal("收到过半数有效投票后区块应上链")
+	}
+	if err := block.VerifyCommitQuorum(vg.PublicKeys(ids), CFT); err != nil {
+		t.Errorf("简单多数的提交证书应按 CFT 阈值校验通过: %v", err)
+	}
+}
+
+func TestSimpleMajorityProposesWithThreeValidators(t *testing.T) {
+	ebc := NewEmergencyBlockchain(UrgencyConfig{}, 5, time.Second)
+	vg := NewValidatorGroup(3, 10)
+	fake := &fakeReputation{}
+	var peers []*EmergencyNode
+	for _, id := range []string{"1", "2", "3"} {
+		node := NewEmergencyNode(id, ebc, fake, vg)
+		node.SetConsensusEngine(newSimpleMajorityEngine(node))
+		node.CommitTimeout = time.Second
+		vg.Validators = append(vg.Validators, &Validator{ID: id, Reputation: 0.9})
+		peers = append(peers, node)
+	}
+	for _, node := range peers {
+		node.SetPeers(peers)
+		node.UpdateValidatorStatus()
+	}
+
+	now := time.Now()
+	ebc.AddTransaction(&EmergencyTransaction{ID: "tx", VehicleID: "9", ArrivalTime: now, DeadlineTime: now.Add(time.Minute)})
+	// BFT 下 3 个验证器无法出块，简单多数只需过半数即可
+	block, err := peers[0].ProposeEmergencyBlock()
+	if err != nil {
+		t.Fatalf("简单多数共识出块失败: %v", err)
+	}
+	if !ebc.HasBlock(block.Hash) {
+		t.Error("提议的区块应已上链")
+	}
+}
@@ -0,0 +1,93 @@
+package emergency
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// TransactionValidator 紧急交易评价器
+// 验证器节点确认区块后，用它判断每笔紧急交易应给发送者正面还是负面评价
+type TransactionValidator interface {
+	// Evaluate 返回该交易产生的正面、负面事件数
+	Evaluate(tx *EmergencyTransaction) (pos, neg int)
+}
+
+// MaliciousSenderValidator 按已知恶意节点列表评价交易（默认评价器）
+// 与普通链的恶意节点模型保持一致：恶意节点发送的交易给负面评价，其余给正面评价
+type MaliciousSenderValidator struct {
+	Malicious map[string]bool // 已知恶意节点ID集合
+}
+
+// NewMaliciousSenderValidator 创建按恶意节点列表评价的评价器
+func NewMaliciousSenderValidator(malicious map[string]bool) *MaliciousSenderValidator {
+	return &MaliciousSenderValidator{Malicious: malicious}
+}
+
+// Evaluate 恶意节点的交易记 1 次负面事件，其余记 1 次正面事件
+func (v *MaliciousSenderValidator) Evaluate(tx *EmergencyTransaction) (pos, neg int) {
+	if v.Malicious[tx.VehicleID] {
+		return 0, 1
+	}
+	return 1, 0
+}
+
+// MaliceProbabilityValidator 按发送者的作恶概率评价交易
+// 作恶概率为 p 的节点，其每笔交易以概率 p 被判定为恶意交易；
+// p=1 等价于 MaliciousSenderValidator 中的恶意节点，未列出的节点视为诚实节点
+type MaliceProbabilityValidator struct {
+	Malice map[string]float64 // 节点ID -> 作恶概率
+	rng    *rand.Rand
+	mutex  sync.Mutex
+}
+
+// NewMaliceProbabilityValidator 创建按作恶概率评价的评价器
+func NewMaliceProbabilityValidator(malice map[string]float64) *MaliceProbabilityValidator {
+	return &MaliceProbabilityValidator{
+		Malice: malice,
+		rng:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Evaluate 以发送者的作恶概率给负面评价，否则给正面评价
+func (v *MaliceProbabilityValidator) Evaluate(tx *EmergencyTransaction) (pos, neg int) {
+	p := v.Malice[tx.VehicleID]
+	if p <= 0 {
+		return 1, 0
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	if v.rng.Float64() < p {
+		return 0, 1
+	}
+	return 1, 0
+}
+
+// RandomValidator 随机评价器，忽略发送者身份，按概率判定交易是否诚实
+// 用于压力测试信誉模型对噪声评价的鲁棒性
+type RandomValidator struct {
+	HonestProb float64 // 判定为诚实交易的概率
+	rng        *rand.Rand
+	mutex      sync.Mutex
+}
+
+// NewRandomValidator 创建随机评价器
+func NewRandomValidator(honestProb float64) *RandomValidator {
+	return &RandomValidator{
+		HonestProb: honestProb,
+		rng:        rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Evaluate 以 HonestProb 的概率给正面评价，否则给负面评价
+func (v *RandomValidator) Evaluate(tx *EmergencyTransaction) (pos, neg int) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	if v.rng.Float64() < v.HonestProb {
+		return 1, 0
+	}
+	return 0, 1
+}
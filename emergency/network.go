@@ -0,0 +1,74 @@
+package emergency
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Network 共识消息的传输层，用于模拟不同的网络条件
+type Network interface {
+	// Send 将消息从 from 发送给 to，不阻塞调用方
+	Send(from, to *EmergencyNode, msg ConsensusMessage)
+}
+
+// DirectNetwork 理想网络：消息立即送达且不丢失（默认）
+type DirectNetwork struct{}
+
+// Send 直接在新的 goroutine 中投递消息
+func (DirectNetwork) Send(from, to *EmergencyNode, msg ConsensusMessage) {
+	go to.ReceiveMessage(msg)
+}
+
+// LossyNetwork 有损网络：每条消息以 DropProb 的概率丢失，
+// 未丢失的消息在 [MinDelay, MaxDelay] 内均匀随机延迟后送达
+// 用于验证共识阈值在消息丢失与延迟下能否容忍 f 个故障节点；
+// 零值可直接使用，首次发送时以当前时间为种子创建随机数生成器
+type LossyNetwork struct {
+	DropProb float64       // 消息丢失概率 [0,1]
+	MinDelay time.Duration // 最小传输延迟
+	MaxDelay time.Duration // 最大传输延迟
+	rng      *rand.Rand
+	mutex    sync.Mutex
+}
+
+// NewLossyNetwork 创建有损网络
+func NewLossyNetwork(dropProb float64, minDelay, maxDelay time.Duration) *LossyNetwork {
+	return &LossyNetwork{
+		DropProb: dropProb,
+		MinDelay: minDelay,
+		MaxDelay: maxDelay,
+		rng:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// SetSeed 设置随机种子，便于复现丢包与延迟序列
+func (n *LossyNetwork) SetSeed(seed int64) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.rng = rand.New(rand.NewSource(seed))
+}
+
+// Send 按丢失概率丢弃消息，否则延迟后投递
+func (n *LossyNetwork) Send(from, to *EmergencyNode, msg ConsensusMessage) {
+	n.mutex.Lock()
+	if n.rng == nil {
+		n.rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	dropped := n.rng.Float64() < n.DropProb
+	delay := n.MinDelay
+	if n.MaxDelay > n.MinDelay {
+		delay += time.Duration(n.rng.Int63n(int64(n.MaxDelay - n.MinDelay + 1)))
+	}
+	n.mutex.Unlock()
+
+	if dropped {
+		return
+	}
+	go func() {
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+		to.ReceiveMessage(msg)
+	}()
+}
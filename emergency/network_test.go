@@ -0,0 +1,92 @@
+package emergency
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"block/logging"
+)
+
+// crashedNetwork 在 inner 之上模拟宕机节点：发往或来自 down 中节点的消息全部丢失
+type crashedNetwork struct {
+	inner Network
+	down  map[string]bool
+}
+
+func (n crashedNetwork) Send(from, to *EmergencyNode, msg ConsensusMessage) {
+	if n.down[from.ID] || n.down[to.ID] {
+		return
+	}
+	n.inner.Send(from, to, msg)
+}
+
+// lossyCluster 创建 n 个验证器共享的紧急区块链，消息经 DropProb=dropProb 的有损网络传输，
+// down 中的节点宕机
+func lossyCluster(t *testing.T, n int, dropProb float64, down map[string]bool) (*EmergencyBlockchain, []*EmergencyNode) {
+	t.Helper()
+	ebc := NewEmergencyBlockchain(UrgencyConfig{}, 5, time.Second)
+	vg := NewValidatorGroup(n, 10)
+	lossy := NewLossyNetwork(dropProb, 0, 2*time.Millisecond)
+	lossy.SetSeed(42)
+	network := crashedNetwork{inner: lossy, down: down}
+
+	var nodes []*EmergencyNode
+	for i := 1; i <= n; i++ {
+		node := NewEmergencyNode(fmt.Sprint(i), ebc, &fakeReputation{}, vg)
+		node.Logger = logging.Discard()
+		node.CommitTimeout = 300 * time.Millisecond
+		node.SetNetwork(network)
+		vg.Validators = append(vg.Validators, &Validator{ID: node.ID, Reputation: 0.9})
+		nodes = append(nodes, node)
+	}
+	for _, node := range nodes {
+		node.SetPeers(nodes)
+		node.UpdateValidatorStatus()
+	}
+	return ebc, nodes
+}
+
+func TestQuorumToleratesFaultyNodesOverLossyNetwork(t *testing.T) {
+	// N=7 时 f=2：2 个节点宕机、其余消息有 5% 丢失时区块仍能上链
+	ebc, nodes := lossyCluster(t, 7, 0.05, map[string]bool{"6": true, "7": true})
+	const blocks = 5
+	for i := 0; i < blocks; i++ {
+		now := time.Now()
+		ebc.AddTransaction(&EmergencyTransaction{
+			ID: fmt.Sprintf("tx%d", i), VehicleID: "9", ArrivalTime: now, DeadlineTime: now.Add(time.Minute),
+		})
+		// 丢失的消息不会重传，一次提议未能凑齐投票时交易放回交易池，由下一个出块者重新提议
+		var err error
+		for attempt := 0; attempt < 3; attempt++ {
+			if _, err = nodes[(i+attempt)%5].ProposeEmergencyBlock(); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			t.Fatalf("区块 %d 三次提议均未上链: %v", i+1, err)
+		}
+		for _, node := range nodes {
+			node.SettleCommits()
+		}
+	}
+	if got := ebc.GetChainLength(); got != blocks+1 {
+		t.Errorf("链长度 = %d, 期望 %d", got, blocks+1)
+	}
+
+	// 宕机节点超过 f 个时诚实节点凑不齐 2f+1 个提交投票，区块无法上链
+	ebc, nodes = lossyCluster(t, 7, 0, map[string]bool{"5": true, "6": true, "7": true})
+	now := time.Now()
+	ebc.AddTransaction(&EmergencyTransaction{ID: "tx", VehicleID: "9", ArrivalTime: now, DeadlineTime: now.Add(time.Minute)})
+	if _, err := nodes[0].ProposeEmergencyBlock(); !errors.Is(err, ErrQuorumNotReached) {
+		t.Errorf("3 个节点宕机时提议的错误 = %v, 期望 ErrQuorumNotReached", err)
+	}
+}
+
+func TestZeroLossyNetworkSends(t *testing.T) {
+	// 零值的有损网络首次发送时创建随机数生成器，不会因 rng 为 nil 而崩溃
+	var network LossyNetwork
+	network.DropProb = 1
+	network.Send(nil, nil, ConsensusMessage{})
+}
@@ -0,0 +1,145 @@
+package emergency
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// poolFileVersion 交易池文件的序列化格式版本
+const poolFileVersion = 1
+
+// poolFileJSON 交易池文件的序列化格式
+type poolFileJSON struct {
+	Version      int      `json:"version"`
+	Transactions []txJSON `json:"transactions"`
+}
+
+// txJSON 紧急交易的序列化格式
+// 时间保存为 Unix 纳秒，零值时间保存为 0，避免时区与单调时钟读数在往返中引入差异
+type txJSON struct {
+	ID             string  `json:"id"`
+	VehicleID      string  `json:"vehicleID"`
+	Data           []byte  `json:"data,omitempty"`
+	Timestamp      int64   `json:"timestampUnixNano"`
+	ProductTime    int64   `json:"productTimeUnixNano"`
+	DeadlineTime   int64   `json:"deadlineTimeUnixNano"`
+	ArrivalTime    int64   `json:"arrivalTimeUnixNano"`
+	Priority       int     `json:"priority,omitempty"`
+	UrgencyDegree  float64 `json:"urgency"`
+	Theta          int     `json:"theta,omitempty"`
+	NormalChainRef int     `json:"normalChainRef,omitempty"`
+}
+
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromUnixNano(ns int64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// SaveJSON 将待处理的交易（交易池中的交易与已取出打包、尚未上链的在途交易）保存到 path，用于崩溃恢复
+// 先写入临时文件再重命名，写入中途崩溃不会损坏已有的文件；
+// 交易状态记录、MaxPoolSize 与信誉查询函数不保存，由调用方重新设置
+func (pool *TransactionPool) SaveJSON(path string) error {
+	pool.mutex.Lock()
+	pending := append([]*EmergencyTransaction(nil), pool.transactions...)
+	inFlight := make([]*EmergencyTransaction, 0, len(pool.inFlight))
+	for _, tx := range pool.inFlight {
+		inFlight = append(inFlight, tx)
+	}
+	pool.mutex.Unlock()
+	sort.Slice(inFlight, func(i, j int) bool { return inFlight[i].ID < inFlight[j].ID })
+	pending = append(pending, inFlight...)
+
+	out := poolFileJSON{Version: poolFileVersion, Transactions: make([]txJSON, 0, len(pending))}
+	for _, tx := range pending {
+		out.Transactions = append(out.Transactions, txJSON{
+			ID:             tx.ID,
+			VehicleID:      tx.VehicleID,
+			Data:           tx.Data,
+			Timestamp:      unixNano(tx.Timestamp),
+			ProductTime:    unixNano(tx.ProductTime),
+			DeadlineTime:   unixNano(tx.DeadlineTime),
+			ArrivalTime:    unixNano(tx.ArrivalTime),
+			Priority:       tx.Priority,
+			UrgencyDegree:  tx.UrgencyDegree,
+			Theta:          tx.Theta,
+			NormalChainRef: tx.NormalChainRef,
+		})
+	}
+	data, err := json.Marshal(out)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadTransactionPool 由 SaveJSON 保存的文件重建交易池
+// 保存后（如离线期间）已超过截止时间的交易在加载时清理，状态记为 TxExpired
+func LoadTransactionPool(path string) (*TransactionPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var in poolFileJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return nil, fmt.Errorf("解析交易池文件 %s: %w", path, err)
+	}
+	if in.Version != poolFileVersion {
+		return nil, fmt.Errorf("交易池文件 %s 的版本 %d 不受支持（期望 %d）", path, in.Version, poolFileVersion)
+	}
+
+	pool := NewTransactionPool()
+	for _, tx := range in.Transactions {
+		pool.AddTransaction(&EmergencyTransaction{
+			ID:             tx.ID,
+			VehicleID:      tx.VehicleID,
+			Data:           tx.Data,
+			Timestamp:      fromUnixNano(tx.Timestamp),
+			ProductTime:    fromUnixNano(tx.ProductTime),
+			DeadlineTime:   fromUnixNano(tx.DeadlineTime),
+			ArrivalTime:    fromUnixNano(tx.ArrivalTime),
+			Priority:       tx.Priority,
+			UrgencyDegree:  tx.UrgencyDegree,
+			Theta:          tx.Theta,
+			NormalChainRef: tx.NormalChainRef,
+		})
+	}
+	pool.PruneExpired(time.Now())
+	return pool, nil
+}
+
+// NewEmergencyBlockchainWithPool 创建紧急区块链，并从 poolPath 恢复上次保存的交易池
+// poolPath 不存在时（如首次运行）使用空交易池
+func NewEmergencyBlockchainWithPool(
+	urgencyCfg UrgencyConfig,
+	blockSize int,
+	blockPeriod time.Duration,
+	poolPath string,
+) (*EmergencyBlockchain, error) {
+	ebc := NewEmergencyBlockchain(urgencyCfg, blockSize, blockPeriod)
+	pool, err := LoadTransactionPool(poolPath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return ebc, nil
+	case err != nil:
+		return nil, err
+	}
+	ebc.TxPool = pool
+	return ebc, nil
+}
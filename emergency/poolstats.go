@@ -0,0 +1,143 @@
+package emergency
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// BacklogSample 交易池积压采样：At 时刻交易池中的待处理交易数（不含在途交易）
+type BacklogSample struct {
+	At   time.Time
+	Size int
+}
+
+// UrgencyDistribution 一组交易入池时紧急度的分布
+type UrgencyDistribution struct {
+	Count  int
+	Mean   float64
+	Min    float64
+	Median float64
+	P90    float64 // 90 分位数
+	Max    float64
+}
+
+// String 返回紧急度分布的摘要
+func (d UrgencyDistribution) String() string {
+	if d.Count == 0 {
+		return "无"
+	}
+	return fmt.Sprintf("n=%d 均值=%.4f 最小=%.4f 中位数=%.4f P90=%.4f 最大=%.4f",
+		d.Count, d.Mean, d.Min, d.Median, d.P90, d.Max)
+}
+
+// PoolStats 交易池在整个运行期间的汇总统计
+type PoolStats struct {
+	Received  int // 交易池接收过的交易数，包括入池时即被淘汰的交易
+	Pending   int // 仍在等待打包或所在区块尚未上链的交易数
+	Committed int // 已上链的交易数
+	Expired   int // 过期被清理的交易数
+	Evicted   int // 被淘汰的交易数
+
+	AvgWait time.Duration // 已上链交易从首次入池到上链的平均等待时间
+	MaxWait time.Duration // 已上链交易的最长等待时间
+
+	Backlog    []BacklogSample // 待处理交易数的变化记录
+	MaxBacklog int             // 待处理交易数的峰值
+	AvgBacklog float64         // 从首次采样到统计时刻按时间加权的平均待处理交易数
+
+	CommittedUrgency UrgencyDistribution // 已上链交易的紧急度分布
+	DroppedUrgency   UrgencyDistribution // 过期或被淘汰交易的紧急度分布
+}
+
+// recordBacklog 待处理交易数与上一个采样不同时追加一个采样，调用方需持有交易池锁
+func (pool *TransactionPool) recordBacklog() {
+	size := len(pool.transactions)
+	if n := len(pool.backlog); n > 0 && pool.backlog[n-1].Size == size {
+		return
+	}
+	pool.backlog = append(pool.backlog, BacklogSample{At: pool.now(), Size: size})
+}
+
+// PoolStats 汇总交易池接收过的全部交易的去向、等待时间、积压变化与紧急度分布
+func (pool *TransactionPool) PoolStats() PoolStats {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	stats := PoolStats{
+		Received: len(pool.status),
+		Backlog:  append([]BacklogSample(nil), pool.backlog...),
+	}
+
+	var committed, dropped []float64
+	var totalWait time.Duration
+	for _, record := range pool.status {
+		switch record.status {
+		case TxPending:
+			stats.Pending++
+		case TxCommitted:
+			stats.Committed++
+			committed = append(committed, record.urgency)
+			wait := record.exitedAt.Sub(record.enqueuedAt)
+			totalWait += wait
+			stats.MaxWait = max(stats.MaxWait, wait)
+		case TxExpired:
+			stats.Expired++
+			dropped = append(dropped, record.urgency)
+		case TxEvicted:
+			stats.Evicted++
+			dropped = append(dropped, record.urgency)
+		}
+	}
+	if stats.Committed > 0 {
+		stats.AvgWait = totalWait / time.Duration(stats.Committed)
+	}
+	stats.CommittedUrgency = summarizeUrgency(committed)
+	stats.DroppedUrgency = summarizeUrgency(dropped)
+
+	// 每个采样的积压持续到下一个采样，最后一个持续到统计时刻
+	if len(stats.Backlog) > 0 {
+		end := pool.now()
+		var area float64
+		for i, sample := range stats.Backlog {
+			stats.MaxBacklog = max(stats.MaxBacklog, sample.Size)
+			until := end
+			if i+1 < len(stats.Backlog) {
+				until = stats.Backlog[i+1].At
+			}
+			area += float64(sample.Size) * until.Sub(sample.At).Seconds()
+		}
+		if span := end.Sub(stats.Backlog[0].At).Seconds(); span > 0 {
+			stats.AvgBacklog = area / span
+		} else {
+			stats.AvgBacklog = float64(stats.Backlog[len(stats.Backlog)-1].Size)
+		}
+	}
+	return stats
+}
+
+// summarizeUrgency 计算紧急度分布，分位数取最近秩（第 ceil(q·n) 个样本）
+func summarizeUrgency(urgencies []float64) UrgencyDistribution {
+	n := len(urgencies)
+	if n == 0 {
+		return UrgencyDistribution{}
+	}
+	sorted := append([]float64(nil), urgencies...)
+	sort.Float64s(sorted)
+	var sum float64
+	for _, u := range sorted {
+		sum += u
+	}
+	quantile := func(q float64) float64 {
+		rank := int(math.Ceil(q*float64(n))) - 1
+		return sorted[min(max(rank, 0), n-1)]
+	}
+	return UrgencyDistribution{
+		Count:  n,
+		Mean:   sum / float64(n),
+		Min:    sorted[0],
+		Median: quantile(0.5),
+		P90:    quantile(0.9),
+		Max:    sorted[n-1],
+	}
+}
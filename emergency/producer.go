@@ -0,0 +1,96 @@
+package emergency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrInvalidBlockPeriod 出块周期不为正，无法定时出块
+var ErrInvalidBlockPeriod = errors.New("出块周期必须为正")
+
+// BlockProducer 紧急区块的定时出块驱动器
+// 每隔 Blockchain.BlockPeriod 由验证器组选出的出块节点提议一次区块（交易池为空时跳过），
+// 出块节奏与普通链的轮次相互独立，对应论文中双链异步运行的时序
+type BlockProducer struct {
+	Blockchain     *EmergencyBlockchain
+	ValidatorGroup *ValidatorGroup
+	Nodes          map[string]*EmergencyNode // [节点ID]紧急区块链节点
+
+	// OnProposal 每次提议结束后调用（可为空），err 为 ProposeEmergencyBlock 的返回值
+	OnProposal func(proposer string, block *EmergencyBlock, err error)
+
+	mutex sync.Mutex // 提议期间持有，Exclusive 借此与提议互斥
+}
+
+// NewBlockProducer 创建定时出块驱动器
+func NewBlockProducer(
+	blockchain *EmergencyBlockchain,
+	validatorGroup *ValidatorGroup,
+	nodes map[string]*EmergencyNode,
+) *BlockProducer {
+	return &BlockProducer{
+		Blockchain:     blockchain,
+		ValidatorGroup: validatorGroup,
+		Nodes:          nodes,
+	}
+}
+
+// Run 每隔 BlockPeriod 调用一次 Tick，直到 ctx 被取消，返回 ctx.Err()
+// 一次提议耗时超过 BlockPeriod 时错过的周期被跳过，不会积压补发
+// BlockPeriod 不为正时立即返回 ErrInvalidBlockPeriod
+func (p *BlockProducer) Run(ctx context.Context) error {
+	period := p.Blockchain.BlockPeriod
+	if period <= 0 {
+		return fmt.Errorf("%w: %v", ErrInvalidBlockPeriod, period)
+	}
+
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			p.Tick()
+		}
+	}
+}
+
+// Tick 执行一次出块：交易池为空或没有可用的出块节点时跳过，返回是否发起了提议
+func (p *BlockProducer) Tick() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.Blockchain.TxPool.Size() == 0 {
+		return false
+	}
+	proposerValidator := p.ValidatorGroup.NextProposer()
+	if proposerValidator == nil {
+		return false
+	}
+	proposer, ok := p.Nodes[proposerValidator.ID]
+	if !ok {
+		return false
+	}
+
+	block, err := proposer.ProposeEmergencyBlock()
+	// 提议结束后确定链顶，各节点记录信誉交互与出块奖励
+	for _, node := range p.Nodes {
+		node.SettleCommits()
+	}
+	if p.OnProposal != nil {
+		p.OnProposal(proposer.ID, block, err)
+	}
+	return true
+}
+
+// Exclusive 在没有提议进行时执行 fn，期间不会开始新的提议
+// 用于在定时出块的同时安全地刷新验证器组等共享状态
+func (p *BlockProducer) Exclusive(fn func()) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	fn()
+}
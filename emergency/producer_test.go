@@ -0,0 +1,73 @@
+package emergency
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// newProducerNetwork 创建共享同一条链的 4 个验证器节点及其定时出块驱动器
+func newProducerNetwork(period time.Duration) (*BlockProducer, *EmergencyBlockchain) {
+	ebc := NewEmergencyBlockchain(UrgencyConfig{}, 5, period)
+	vg := NewValidatorGroup(4, 10)
+	fake := &fakeReputation{}
+	nodes := make(map[string]*EmergencyNode)
+	var peers []*EmergencyNode
+	for _, id := range []string{"1", "2", "3", "4"} {
+		nodes[id] = NewEmergencyNode(id, ebc, fake, vg)
+		vg.Validators = append(vg.Validators, &Validator{ID: id, Reputation: 0.9})
+		peers = append(peers, nodes[id])
+	}
+	for _, node := range peers {
+		node.SetPeers(peers)
+		node.UpdateValidatorStatus()
+		node.CommitTimeout = time.Second
+	}
+	return NewBlockProducer(ebc, vg, nodes), ebc
+}
+
+func TestBlockProducerSkipsEmptyPool(t *testing.T) {
+	producer, _ := newProducerNetwork(time.Second)
+	if producer.Tick() {
+		t.Error("交易池为空时不应发起提议")
+	}
+}
+
+func TestBlockProducerRejectsNonPositivePeriod(t *testing.T) {
+	producer, _ := newProducerNetwork(0)
+	if err := producer.Run(context.Background()); !errors.Is(err, ErrInvalidBlockPeriod) {
+		t.Errorf("出块周期为 0 时应返回 ErrInvalidBlockPeriod，实际 %v", err)
+	}
+}
+
+func TestBlockProducerProposesEveryPeriod(t *testing.T) {
+	producer, ebc := newProducerNetwork(20 * time.Millisecond)
+	proposals := make(chan error, 10)
+	producer.OnProposal = func(proposer string, block *EmergencyBlock, err error) {
+		proposals <- err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- producer.Run(ctx) }()
+
+	now := time.Now()
+	ebc.AddTransaction(&EmergencyTransaction{ID: "tx", VehicleID: "9", ArrivalTime: now, DeadlineTime: now.Add(time.Minute)})
+	select {
+	case err := <-proposals:
+		if err != nil {
+			t.Fatalf("定时提议失败: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("交易池非空时应在出块周期到达后提议区块")
+	}
+	if ebc.GetChainLength() != 2 {
+		t.Errorf("链长度 = %d, 期望定时出块后为 2", ebc.GetChainLength())
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("取消后 Run 应返回 context.Canceled，实际 %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package emergency
+
+import (
+	"block/reputation"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited 发送者在时间窗口内提交的紧急交易数已达上限
+var ErrRateLimited = errors.New("发送者提交紧急交易过于频繁")
+
+// SenderRateLimiter 按发送者限制紧急交易的提交速率
+// 每个发送者在任意 Window 时长内最多有 MaxPerWindow 笔交易被交易池接受，
+// 防止节点通过大量提交紧急交易（θ 随之增长、紧急度升高）滥用紧急通道
+type SenderRateLimiter struct {
+	MaxPerWindow int           // 每个时间窗口内每个发送者最多被接受的交易数
+	Window       time.Duration // 时间窗口长度
+
+	mutex       sync.Mutex
+	submissions map[string][]time.Time // 各发送者窗口内被接受交易的到达时间
+	rejected    map[string]bool        // 因超限被拒绝的交易ID
+}
+
+// NewSenderRateLimiter 创建速率限制器
+func NewSenderRateLimiter(maxPerWindow int, window time.Duration) *SenderRateLimiter {
+	return &SenderRateLimiter{
+		MaxPerWindow: maxPerWindow,
+		Window:       window,
+		submissions:  make(map[string][]time.Time),
+		rejected:     make(map[string]bool),
+	}
+}
+
+// Allow 判断发送者在 at 时刻能否再提交一笔交易，同时清理窗口外的记录
+func (l *SenderRateLimiter) Allow(sender string, at time.Time) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	cutoff := at.Add(-l.Window)
+	kept := l.submissions[sender][:0]
+	for _, t := range l.submissions[sender] {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	l.submissions[sender] = kept
+	return len(kept) < l.MaxPerWindow
+}
+
+// record 记录发送者在 at 时刻有一笔交易被接受
+func (l *SenderRateLimiter) record(sender string, at time.Time) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.submissions[sender] = append(l.submissions[sender], at)
+}
+
+// reject 记录一笔因超限被拒绝的交易，同一交易被多个节点重复提交只计一次
+func (l *SenderRateLimiter) reject(txID string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.rejected[txID] = true
+}
+
+// RejectedCount 返回因超限被拒绝的交易数
+func (l *SenderRateLimiter) RejectedCount() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return len(l.rejected)
+}
+
+// penalizeRateLimited 对超限提交紧急交易的发送者记录 RateLimitPenalty 个负面事件的紧急交互
+// 调用方需持有节点锁
+func (en *EmergencyNode) penalizeRateLimited(tx *EmergencyTransaction) {
+	if en.RateLimitPenalty <= 0 || en.ReputationManager == nil || tx.VehicleID == en.ID {
+		return
+	}
+	inter := reputation.Interaction{
+		From:         en.ID,
+		To:           tx.VehicleID,
+		NegEvents:    en.RateLimitPenalty,
+		Timestamp:    en.Clock.Now(),
+		TrajUser:     en.trajectoryOf(en.ID),
+		TrajProvider: en.trajectoryOf(tx.VehicleID),
+		TxType:       reputation.EmergencyTransaction,
+	}
+	if err := en.ReputationManager.AddInteraction(inter); err != nil {
+		en.Logger.Errorf("  节点 %s 记录对 %s 的负面评价失败: %v\n", en.ID, tx.VehicleID, err)
+	}
+}
@@ -0,0 +1,65 @@
+package emergency
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"block/logging"
+	"block/reputation"
+)
+
+func TestSenderExceedingQuotaIsRejected(t *testing.T) {
+	ebc := NewEmergencyBlockchain(UrgencyConfig{}, 5, time.Second)
+	ebc.RateLimiter = NewSenderRateLimiter(2, 10*time.Second)
+	vg := NewValidatorGroup(4, 10)
+	fake := &fakeReputation{}
+	node := NewEmergencyNode("1", ebc, fake, vg)
+	node.RateLimitPenalty = 1
+	node.Logger = logging.Discard()
+
+	base := time.Now()
+	newTx := func(id, sender string, at time.Time) *EmergencyTransaction {
+		return &EmergencyTransaction{ID: id, VehicleID: sender, ArrivalTime: at, DeadlineTime: at.Add(time.Minute)}
+	}
+
+	// 窗口内前 2 笔被接受，之后的交易被拒绝
+	for i := 0; i < 4; i++ {
+		accepted := node.AddEmergencyTransaction(newTx(fmt.Sprintf("spam-%d", i), "9", base.Add(time.Duration(i)*time.Second)))
+		if want := i < 2; accepted != want {
+			t.Errorf("第 %d 笔交易 accepted = %v, 期望 %v", i, accepted, want)
+		}
+	}
+	if n := ebc.RateLimiter.RejectedCount(); n != 2 {
+		t.Errorf("RejectedCount() = %d, 期望 2", n)
+	}
+	if ebc.TxPool.Size() != 2 {
+		t.Errorf("交易池中有 %d 笔交易, 期望 2 笔", ebc.TxPool.Size())
+	}
+
+	// 同一笔已入池的交易再次提交（如广播到其他节点）不计入提交次数
+	node.AddEmergencyTransaction(newTx("spam-0", "9", base))
+	if n := ebc.RateLimiter.RejectedCount(); n != 2 {
+		t.Errorf("重复提交已入池的交易后 RejectedCount() = %d, 期望仍为 2", n)
+	}
+
+	// 其他发送者不受影响
+	if !node.AddEmergencyTransaction(newTx("other", "8", base.Add(3*time.Second))) {
+		t.Error("未超限的发送者的交易应被接受")
+	}
+
+	// 每笔超限交易给发送者一次负面评价
+	if len(fake.interactions) != 2 {
+		t.Fatalf("记录了 %d 条交互, 期望 2 条", len(fake.interactions))
+	}
+	for _, inter := range fake.interactions {
+		if inter.From != "1" || inter.To != "9" || inter.NegEvents != 1 || inter.TxType != reputation.EmergencyTransaction {
+			t.Errorf("交互 = %+v, 期望节点 1 对发送者 9 的负面紧急交互", inter)
+		}
+	}
+
+	// 窗口滑过最早的提交后，发送者可以再次提交
+	if !node.AddEmergencyTransaction(newTx("later", "9", base.Add(10*time.Second))) {
+		t.Error("窗口滑过后发送者的交易应被接受")
+	}
+}
@@ -0,0 +1,111 @@
+package emergency
+
+import (
+	"crypto/ed25519"
+	"sync"
+
+	"block/reputation"
+)
+
+// RewardLedger 出块奖励账本，记录各节点成功提议（区块被确认上链）的区块数与累计奖励
+// 各节点共享同一账本，同一区块只记账一次；可并发调用
+type RewardLedger struct {
+	BlockReward float64 // 每个确认上链的区块给予提议者的奖励
+
+	mutex    sync.Mutex
+	rewards  map[string]float64 // [节点ID]累计奖励
+	proposed map[string]int     // [节点ID]成功提议的区块数
+	credited map[string]bool    // 已记账的区块哈希
+}
+
+// NewRewardLedger 创建出块奖励账本
+func NewRewardLedger(blockReward float64) *RewardLedger {
+	return &RewardLedger{
+		BlockReward: blockReward,
+		rewards:     make(map[string]float64),
+		proposed:    make(map[string]int),
+		credited:    make(map[string]bool),
+	}
+}
+
+// Credit 为区块的提议者记账，返回是否记账
+// proposerKey 为 block.Proposer 登记的公钥，区块签名不是其对区块哈希的有效签名时不记账，
+// 防止以其他节点的名义提议区块、把奖励记到指定的节点上；
+// 区块没有提议者或已记账时同样返回 false
+func (l *RewardLedger) Credit(block *EmergencyBlock, proposerKey ed25519.PublicKey) bool {
+	if block.Proposer == "" || !verifyHashSignature(proposerKey, block.Hash, block.Signature) {
+		return false
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.credited[block.Hash] {
+		return false
+	}
+	l.credited[block.Hash] = true
+	l.proposed[block.Proposer]++
+	l.rewards[block.Proposer] += l.BlockReward
+	return true
+}
+
+// Reward 返回节点的累计奖励
+func (l *RewardLedger) Reward(nodeID string) float64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.rewards[nodeID]
+}
+
+// ProposedBlocks 返回节点成功提议的区块数
+func (l *RewardLedger) ProposedBlocks(nodeID string) int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.proposed[nodeID]
+}
+
+// Rewards 返回所有获得过奖励的节点的累计奖励（副本）
+func (l *RewardLedger) Rewards() map[string]float64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	rewards := make(map[string]float64, len(l.rewards))
+	for id, r := range l.rewards {
+		rewards[id] = r
+	}
+	return rewards
+}
+
+// rewardProposer 区块确认后奖励其提议者，闭合 PoE 的激励回路
+// 提议者对区块哈希的签名无效时不奖励，防止冒名领取奖励；
+// 设置了奖励账本时为提议者记账，验证器节点再给提议者一次 ProposerReward 个正面事件的紧急交互评价
+func (en *EmergencyNode) rewardProposer(block *EmergencyBlock) {
+	proposer := block.Proposer
+	if proposer == "" {
+		return
+	}
+	key := en.ValidatorGroup.PublicKeys([]string{proposer})[proposer]
+	if !verifyHashSignature(key, block.Hash, block.Signature) {
+		en.Logger.Warnf("节点 %s: 区块 %d 的提议者 %s 签名无效，不予奖励\n", en.ID, block.Index, proposer)
+		return
+	}
+
+	if en.Rewards != nil && en.Rewards.Credit(block, key) {
+		en.Logger.Infof("  提议者 %s 获得区块 %d 的出块奖励 %.2f\n", proposer, block.Index, en.Rewards.BlockReward)
+	}
+
+	// 只有验证器节点评价提议者，且不评价自己
+	if !en.IsValidator || en.ProposerReward <= 0 || proposer == en.ID {
+		return
+	}
+	inter := reputation.Interaction{
+		From:         en.ID,
+		To:           proposer,
+		PosEvents:    en.ProposerReward,
+		Timestamp:    en.Clock.Now(),
+		TrajUser:     en.trajectoryOf(en.ID),
+		TrajProvider: en.trajectoryOf(proposer),
+		TxType:       reputation.EmergencyTransaction,
+	}
+	if err := en.ReputationManager.AddInteraction(inter); err != nil {
+		en.Logger.Errorf("  验证器 %s 记录对提议者 %s 的评价失败: %v\n", en.ID, proposer, err)
+	}
+}
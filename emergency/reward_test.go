@@ -0,0 +1,126 @@
+package emergency
+
+import (
+	"block/config"
+	"block/reputation"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestFrequentProposerAccumulatesReward(t *testing.T) {
+	base := time.Unix(1000, 0)
+	ebc := NewEmergencyBlockchainWithGenesis(NewGenesisBlock(base), UrgencyConfig{}, 5, time.Second)
+	vg := NewValidatorGroup(4, 10)
+	rm := reputation.NewReputationManager(config.DefaultConfig())
+	ledger := NewRewardLedger(2)
+	ids := []string{"1", "2", "3", "4"}
+	nodes := make(map[string]*EmergencyNode)
+	for _, id := range ids {
+		nodes[id] = NewEmergencyNode(id, ebc, rm, vg)
+		nodes[id].ProposerReward = 1
+		nodes[id].Rewards = ledger
+		vg.Validators = append(vg.Validators, &Validator{ID: id, Reputation: 0.9})
+	}
+	for _, node := range nodes {
+		node.UpdateValidatorStatus()
+	}
+
+	// 初始时节点 1 的信誉最低
+	now := time.Now()
+	for _, id := range ids {
+		pos := 2
+		if id == "1" {
+			pos = 1
+		}
+		rm.AddInteraction(reputation.Interaction{From: "9", To: id, PosEvents: pos, Timestamp: now})
+	}
+	rank := func() []string {
+		now := time.Now()
+		ranked := append([]string(nil), ids...)
+		sort.SliceStable(ranked, func(i, j int) bool {
+			return rm.ComputeReputation(ranked[i], now) > rm.ComputeReputation(ranked[j], now)
+		})
+		return ranked
+	}
+	if ranked := rank(); ranked[len(ranked)-1] != "1" {
+		t.Fatalf("初始排名 %v, 期望节点 1 垫底", ranked)
+	}
+
+	// commitOnAll 让每个节点都收到其余验证器的 Commit 消息并确认区块
+	commitOnAll := func(block *EmergencyBlock) {
+		for _, receiver := range ids {
+			for _, from := range ids {
+				if from == receiver {
+					continue
+				}
+				nodes[receiver].handleCommit(ConsensusMessage{
+					Type: Commit, BlockHash: block.Hash, Block: block, From: from,
+					Signature: signHash(nodes[from].privateKey, block.Hash),
+				})
+			}
+		}
+	}
+	propose := func(proposer, signer string) *EmergencyBlock {
+		latest := ebc.GetLatestBlock()
+		block := NewEmergencyBlock(latest.Index+1, latest.Hash, nil, ids, nil)
+		block.Proposer = proposer
+		block.Hash = block.CalculateHash()
+		block.Signature = signHash(nodes[signer].privateKey, block.Hash)
+		return block
+	}
+
+	const blocks = 3
+	for i := 0; i < blocks; i++ {
+		commitOnAll(propose("1", "1"))
+	}
+	// 冒名提议：区块声明提议者为 2，签名却来自节点 1
+	forged := propose("2", "1")
+	commitOnAll(forged)
+	if !ebc.HasBlock(forged.Hash) {
+		t.Fatal("冒名提议的区块本身仍应正常上链")
+	}
+
+	if got := ledger.ProposedBlocks("1"); got != blocks {
+		t.Errorf("节点 1 成功提议 %d 个区块, 期望 %d 个（每个区块只记账一次）", got, blocks)
+	}
+	if got := ledger.Reward("1"); got != blocks*ledger.BlockReward {
+		t.Errorf("节点 1 累计奖励 %.2f, 期望 %.2f", got, blocks*ledger.BlockReward)
+	}
+	if got := ledger.Reward("2"); got != 0 {
+		t.Errorf("冒名提议不应获得奖励，节点 2 累计奖励 %.2f", got)
+	}
+	if ranked := rank(); ranked[0] != "1" {
+		t.Errorf("多次成功出块后排名 %v, 期望节点 1 排名第一", ranked)
+	}
+}
+
+func TestLedgerCreditsOnlyAuthenticatedProposer(t *testing.T) {
+	base := time.Unix(1000, 0)
+	ebc := NewEmergencyBlockchainWithGenesis(NewGenesisBlock(base), UrgencyConfig{}, 5, time.Second)
+	vg := NewValidatorGroup(4, 10)
+	honest := NewEmergencyNode("1", ebc, &fakeReputation{}, vg)
+	forger := NewEmergencyNode("2", ebc, &fakeReputation{}, vg)
+	ledger := NewRewardLedger(1)
+
+	block := NewEmergencyBlock(1, ebc.GetLatestBlock().Hash, nil, []string{"1", "2"}, nil)
+	block.Proposer = "1"
+	block.Hash = block.CalculateHash()
+
+	// 没有签名或签名来自其他节点时，账本不为声明的提议者记账
+	if ledger.Credit(block, honest.PublicKey()) {
+		t.Error("没有签名的区块不应记账")
+	}
+	block.Signature = signHash(forger.privateKey, block.Hash)
+	if ledger.Credit(block, honest.PublicKey()) {
+		t.Error("签名来自其他节点的区块不应记账")
+	}
+	if got := ledger.Reward("1"); got != 0 {
+		t.Fatalf("未认证的区块记账后节点 1 累计奖励 %.2f", got)
+	}
+
+	block.Signature = signHash(honest.privateKey, block.Hash)
+	if !ledger.Credit(block, honest.PublicKey()) || ledger.Reward("1") != ledger.BlockReward {
+		t.Errorf("提议者签名有效的区块应记账一次，节点 1 累计奖励 %.2f", ledger.Reward("1"))
+	}
+}
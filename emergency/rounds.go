@@ -0,0 +1,85 @@
+package emergency
+
+import "time"
+
+// 共识轮次记录的默认上限
+const (
+	// defaultRoundTimeout 投票记录的默认保留时间，远大于 CommitTimeout，超过后视为永远无法确认
+	defaultRoundTimeout = 30 * time.Second
+	// defaultMaxPendingRounds 默认同时进行的共识轮次上限
+	defaultMaxPendingRounds = 64
+)
+
+// roundForgetter 自行保存投票记录的共识引擎实现此接口，节点清理过期轮次时一并清理引擎中的记录
+type roundForgetter interface {
+	forgetRound(blockHash string)
+}
+
+// trackRound 记录区块哈希对应的共识轮次，返回本节点是否处理该区块的共识消息
+// 新轮次开始时先清理过期轮次；进行中的轮次已达 MaxPendingRounds 时丢弃新轮次的消息并告警，
+// 防止大量永远无法确认的区块使投票记录无限增长。调用方需持有节点锁
+func (en *EmergencyNode) trackRound(blockHash string) bool {
+	if _, exists := en.roundStarted[blockHash]; exists {
+		return true
+	}
+	now := time.Now()
+	en.pruneStaleRounds(now)
+	if en.MaxPendingRounds > 0 && len(en.roundStarted) >= en.MaxPendingRounds {
+		en.droppedRounds++
+		en.Logger.Warnf("⚠️ 节点 %s: 进行中的共识轮次已达上限 %d，丢弃区块 %s 的共识消息\n",
+			en.ID, en.MaxPendingRounds, blockHash)
+		return false
+	}
+	en.roundStarted[blockHash] = now
+	return true
+}
+
+// finishRound 区块确认后清理其共识轮次的全部记录，调用方需持有节点锁
+func (en *EmergencyNode) finishRound(blockHash string) {
+	delete(en.roundStarted, blockHash)
+	delete(en.prePrepareReceived, blockHash)
+	delete(en.prepareVotes, blockHash)
+	delete(en.commitVotes, blockHash)
+	if f, ok := en.Engine.(roundForgetter); ok {
+		f.forgetRound(blockHash)
+	}
+}
+
+// pruneStaleRounds 清理开始时间早于 now-RoundTimeout 的共识轮次，返回清理的轮次数
+// 调用方需持有节点锁
+func (en *EmergencyNode) pruneStaleRounds(now time.Time) int {
+	if en.RoundTimeout <= 0 {
+		return 0
+	}
+	cutoff := now.Add(-en.RoundTimeout)
+	pruned := 0
+	for blockHash, started := range en.roundStarted {
+		if started.Before(cutoff) {
+			en.finishRound(blockHash)
+			pruned++
+		}
+	}
+	return pruned
+}
+
+// PruneStaleRounds 清理超过 RoundTimeout 仍未确认的共识轮次，返回清理的轮次数
+// 新轮次开始时会自动清理，空闲期间可定期调用以及时释放投票记录
+func (en *EmergencyNode) PruneStaleRounds(now time.Time) int {
+	en.mutex.Lock()
+	defer en.mutex.Unlock()
+	return en.pruneStaleRounds(now)
+}
+
+// PendingRounds 返回进行中（已收到消息、尚未确认）的共识轮次数
+func (en *EmergencyNode) PendingRounds() int {
+	en.mutex.Lock()
+	defer en.mutex.Unlock()
+	return len(en.roundStarted)
+}
+
+// DroppedRounds 返回因进行中的轮次达到上限而被丢弃的共识轮次消息数
+func (en *EmergencyNode) DroppedRounds() int {
+	en.mutex.Lock()
+	defer en.mutex.Unlock()
+	return en.droppedRounds
+}
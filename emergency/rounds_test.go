@@ -0,0 +1,83 @@
+package emergency
+
+import (
+	"testing"
+	"time"
+
+	"block/logging"
+)
+
+func TestNeverCommittingBlocksDoNotGrowVoteRecords(t *testing.T) {
+	base := time.Unix(1000, 0)
+	ebc := NewEmergencyBlockchainWithGenesis(NewGenesisBlock(base), UrgencyConfig{}, 5, time.Second)
+	vg := NewValidatorGroup(4, 10)
+	for _, id := range []string{"1", "2", "3", "4"} {
+		vg.Validators = append(vg.Validators, &Validator{ID: id, Reputation: 0.9})
+	}
+	receiver := NewEmergencyNode("1", ebc, &fakeReputation{}, vg)
+	receiver.UpdateValidatorStatus()
+	receiver.Logger = logging.Discard()
+	receiver.MaxPendingRounds = 8
+
+	// 大量区块各只收到 1 个 Prepare 投票（BFT 下需要 2 个），永远无法确认
+	const blocks = 500
+	for i := 0; i < blocks; i++ {
+		block := NewEmergencyBlock(1, ebc.GetLatestBlock().Hash, nil, vg.GetValidatorIDs(), nil)
+		block.Timestamp = base.Add(time.Duration(i) * time.Millisecond)
+		block.Hash = block.CalculateHash()
+		receiver.ReceiveMessage(ConsensusMessage{Type: Prepare, BlockHash: block.Hash, Block: block, From: "2"})
+	}
+
+	if n := receiver.PendingRounds(); n != receiver.MaxPendingRounds {
+		t.Errorf("PendingRounds() = %d, 期望达到上限 %d", n, receiver.MaxPendingRounds)
+	}
+	if n := len(receiver.prepareVotes); n > receiver.MaxPendingRounds {
+		t.Errorf("Prepare 投票记录 %d 条，超过上限 %d", n, receiver.MaxPendingRounds)
+	}
+	if n := receiver.DroppedRounds(); n != blocks-receiver.MaxPendingRounds {
+		t.Errorf("DroppedRounds() = %d, 期望 %d", n, blocks-receiver.MaxPendingRounds)
+	}
+
+	// 超过 RoundTimeout 的轮次被清理，投票记录随之释放
+	if n := receiver.PruneStaleRounds(time.Now().Add(receiver.RoundTimeout + time.Second)); n != receiver.MaxPendingRounds {
+		t.Errorf("PruneStaleRounds() = %d, 期望 %d", n, receiver.MaxPendingRounds)
+	}
+	if receiver.PendingRounds() != 0 || len(receiver.prepareVotes) != 0 {
+		t.Errorf("清理后仍有 %d 个轮次、%d 条投票记录", receiver.PendingRounds(), len(receiver.prepareVotes))
+	}
+}
+
+func TestLatePreparesForCommittedBlockOpenNoRound(t *testing.T) {
+	ebc, nodes := lossyCluster(t, 4, 0, nil)
+	now := time.Now()
+	ebc.AddTransaction(&EmergencyTransaction{ID: "tx", VehicleID: "9", ArrivalTime: now, DeadlineTime: now.Add(time.Minute)})
+	block, err := nodes[0].ProposeEmergencyBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 等待网络中迟到的消息全部送达：它们属于已确认的区块，不应留下进行中的轮次
+	time.Sleep(50 * time.Millisecond)
+	for _, node := range nodes {
+		if n := node.PendingRounds(); n != 0 {
+			t.Errorf("节点 %s 在区块确认后仍有 %d 个进行中的轮次", node.ID, n)
+		}
+	}
+
+	// 再次收到已确认区块的 Prepare 消息，既不开启新轮次也不记录投票
+	for _, node := range nodes {
+		for _, from := range []string{"1", "2", "3", "4"} {
+			if from != node.ID {
+				node.ReceiveMessage(ConsensusMessage{Type: Prepare, BlockHash: block.Hash, Block: block, From: from})
+			}
+		}
+		if n := node.PendingRounds(); n != 0 {
+			t.Errorf("迟到的 Prepare 消息为节点 %s 开启了 %d 个轮次", node.ID, n)
+		}
+		node.mutex.Lock()
+		votes := len(node.prepareVotes)
+		node.mutex.Unlock()
+		if votes != 0 {
+			t.Errorf("节点 %s 为已确认的区块记录了 %d 条 Prepare 投票", node.ID, votes)
+		}
+	}
+}
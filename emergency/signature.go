@@ -0,0 +1,49 @@
+package emergency
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// ErrCommitQuorumNotMet 区块携带的有效提交签名不足以证明验证器达成共识
+var ErrCommitQuorumNotMet = errors.New("区块的有效提交签名数未达到共识阈值")
+
+// signHash 用私钥对区块哈希签名，返回十六进制编码的签名
+func signHash(key ed25519.PrivateKey, blockHash string) string {
+	return hex.EncodeToString(ed25519.Sign(key, []byte(blockHash)))
+}
+
+// verifyHashSignature 校验十六进制编码的签名是否为 key 对区块哈希的有效签名
+func verifyHashSignature(key ed25519.PublicKey, blockHash, signature string) bool {
+	sig, err := hex.DecodeString(signature)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return false
+	}
+	return ed25519.Verify(key, []byte(blockHash), sig)
+}
+
+// VerifyCommitQuorum 校验区块是否为有效的提交证书：
+// 区块哈希与区块头一致，且 CommitSignatures 中至少有 Commit 阈值（BFT 下为 2f+1）个
+// 来自 validatorKeys 中验证器的有效签名，N 取 validatorKeys 的大小
+// 轻节点无需重放共识过程，只需产生该区块的验证器组公钥即可校验
+// （通常为 ValidatorGroup.PublicKeys(block.ValidatorIDs)）
+func (b *EmergencyBlock) VerifyCommitQuorum(validatorKeys map[string]ed25519.PublicKey, policy QuorumPolicy) error {
+	if b.Hash != b.CalculateHash() {
+		return fmt.Errorf("%w: 区块哈希与区块头不一致", ErrCommitQuorumNotMet)
+	}
+
+	valid := 0
+	for id, sig := range b.CommitSignatures {
+		if key, ok := validatorKeys[id]; ok && verifyHashSignature(key, b.Hash, sig) {
+			valid++
+		}
+	}
+
+	_, required := policy.Thresholds(len(validatorKeys))
+	if valid < required {
+		return fmt.Errorf("%w: 有效签名 %d 个, 需要 %d 个", ErrCommitQuorumNotMet, valid, required)
+	}
+	return nil
+}
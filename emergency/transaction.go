@@ -1,7 +1,12 @@
 package emergency
 
 import (
+	"block/clock"
+	"errors"
+	"fmt"
 	"math"
+	"sort"
+	"sync"
 	"time"
 )
 
@@ -17,20 +22,108 @@ type EmergencyTransaction struct {
 	Priority      int       // 车辆优先级
 	UrgencyDegree float64   // 紧急度 ED
 	Theta         int       // 车辆在此期间已申请的紧急交易数量
+
+	// NormalChainRef 交易依赖的普通链区块高度，0 表示不依赖普通链
+	// 被引用的普通区块上链之前，交易留在交易池中，不会被打包进紧急区块
+	NormalChainRef int
+}
+
+// UrgencyModel 紧急度计算模型
+type UrgencyModel int
+
+const (
+	// UrgencyExponential 论文公式 (3-13)(3-14)：ED = e^(-Tc/(Tr-Tu)) × e^(ωθ)（默认）
+	UrgencyExponential UrgencyModel = iota
+	// UrgencyLinear 线性截止时间临近度：ED = 1 - Tc/MaxDeadline，截断到 [0,1]，不考虑 θ，
+	// 作为对比实验的简单基线
+	UrgencyLinear
+)
+
+// ErrUnknownUrgencyModel 紧急度模型名称不合法
+var ErrUnknownUrgencyModel = errors.New("未知的紧急度模型")
+
+// String 返回紧急度模型的名称
+func (m UrgencyModel) String() string {
+	switch m {
+	case UrgencyExponential:
+		return "exponential"
+	case UrgencyLinear:
+		return "linear"
+	default:
+		return fmt.Sprintf("UrgencyModel(%d)", int(m))
+	}
 }
 
+// ParseUrgencyModel 按名称（exponential/linear）解析紧急度模型
+func ParseUrgencyModel(name string) (UrgencyModel, error) {
+	for _, m := range []UrgencyModel{UrgencyExponential, UrgencyLinear} {
+		if m.String() == name {
+			return m, nil
+		}
+	}
+	return UrgencyExponential, fmt.Errorf("%w: %q（exponential/linear）", ErrUnknownUrgencyModel, name)
+}
+
+// defaultMaxDeadline 线性模型未设置 MaxDeadline 时使用的截止时间上限
+const defaultMaxDeadline = time.Minute
+
 // UrgencyConfig 紧急度计算配置
 type UrgencyConfig struct {
+	Model UrgencyModel // 紧急度计算模型（默认 UrgencyExponential）
+
 	Omega float64 // ω: 已申请紧急交易数量的影响权重
+
+	// MaxDeadline 线性模型中期望延迟 Tc 的上限，Tc 不小于它时紧急度为 0；为 0 时取 1 分钟
+	MaxDeadline time.Duration
+
+	// MinUrgency、MaxUrgency 紧急度的取值范围，计算结果被截断到 [MinUrgency, MaxUrgency]
+	// e^(ωθ) 随 θ 指数增长，不设上限时少数交易的紧急度会压倒其余交易的排序
+	// MaxUrgency 为 0 表示不设上限
+	MinUrgency float64
+	MaxUrgency float64
 }
 
-// CalculateUrgencyDegree 计算紧急交易的紧急度
+// clamp 将紧急度截断到配置的取值范围内
+func (cfg UrgencyConfig) clamp(ed float64) float64 {
+	if cfg.MaxUrgency > 0 && ed > cfg.MaxUrgency {
+		ed = cfg.MaxUrgency
+	}
+	if ed < cfg.MinUrgency {
+		ed = cfg.MinUrgency
+	}
+	return ed
+}
+
+// CalculateUrgencyDegree 按 cfg.Model 计算紧急交易的紧急度
+// 结果截断到 [cfg.MinUrgency, cfg.MaxUrgency]
+func (tx *EmergencyTransaction) CalculateUrgencyDegree(cfg UrgencyConfig) {
+	switch cfg.Model {
+	case UrgencyLinear:
+		tx.UrgencyDegree = cfg.clamp(tx.linearUrgency(cfg))
+	default:
+		tx.UrgencyDegree = cfg.clamp(tx.exponentialUrgency(cfg))
+	}
+}
+
+// linearUrgency 线性模型：ED = 1 - Tc/MaxDeadline，截断到 [0,1]
+// 截止时间已到或已过时为 1，期望延迟不小于 MaxDeadline 时为 0
+func (tx *EmergencyTransaction) linearUrgency(cfg UrgencyConfig) float64 {
+	maxDeadline := cfg.MaxDeadline
+	if maxDeadline <= 0 {
+		maxDeadline = defaultMaxDeadline
+	}
+	Tc := tx.DeadlineTime.Sub(tx.ArrivalTime).Seconds()
+	return math.Max(0, math.Min(1, 1-Tc/maxDeadline.Seconds()))
+}
+
+// exponentialUrgency 指数模型
 // 根据公式 (3-13): ED = E × e^(ωθ)
 // 其中 E 根据公式 (3-14): E = e^(-Tc/(Tr-Tu))
 // Tc: 交易期望延迟 = td - ta
 // Tu: 交易产生时间 tp
 // Tr: 交易到达RSU时间 ta
-func (tx *EmergencyTransaction) CalculateUrgencyDegree(cfg UrgencyConfig) {
+// E 截断到 [0,1]，e^(ωθ) 使频繁申请的车辆紧急度超过 1，由 MaxUrgency 限制
+func (tx *EmergencyTransaction) exponentialUrgency(cfg UrgencyConfig) float64 {
 	// 计算 Tc (期望延迟)
 	Tc := tx.DeadlineTime.Sub(tx.ArrivalTime).Seconds()
 
@@ -40,15 +133,20 @@ func (tx *EmergencyTransaction) CalculateUrgencyDegree(cfg UrgencyConfig) {
 	// 计算 E = e^(-Tc/(Tr-Tu))
 	var E float64
 	if TrMinusTu > 0 {
-		E = math.Exp(-Tc / TrMinusTu)
+		// 截止时间已过（Tc < 0）时 E 不超过 1，与截止时间恰好到达时一样按最紧急处理
+		E = math.Min(1, math.Exp(-Tc/TrMinusTu))
+	} else if Tc > 0 {
+		// Tr - Tu <= 0 通常是车辆与RSU时钟不同步，传输耗时无法测量
+		// 取 Tr - Tu → 0⁺ 时的极限：截止时间未到时 E → 0
+		E = 0
 	} else {
-		// 如果 Tr - Tu <= 0，说明时间参数异常，设置较低紧急度
-		E = 0.1
+		// 截止时间已到或已过，按最紧急处理（即 Tr - Tu → ∞ 时的极限 E = 1）
+		E = 1
 	}
 
 	// 计算 ED = E × e^(ωθ)
 	theta := float64(tx.Theta)
-	tx.UrgencyDegree = E * math.Exp(cfg.Omega*theta)
+	return E * math.Exp(cfg.Omega*theta)
 }
 
 // NewEmergencyTransaction 创建新的紧急交易
@@ -79,41 +177,239 @@ func NewEmergencyTransaction(
 	return tx
 }
 
+// 紧急交易无效的原因
+const (
+	InvalidReasonEmptyData      = "交易数据为空"
+	InvalidReasonDeadlinePassed = "出块时交易已超过截止时间"
+)
+
+// IsValid 检查交易在 now（出块时间）是否有效，无效时返回原因（见 InvalidReason 常量）
+// 交易数据为空、或设置了截止时间且出块时已超过截止时间的交易无效；
+// 交易目前不带发送者签名，签名校验待交易签名引入后加入
+func (tx *EmergencyTransaction) IsValid(now time.Time) (bool, string) {
+	if len(tx.Data) == 0 {
+		return false, InvalidReasonEmptyData
+	}
+	if !tx.DeadlineTime.IsZero() && now.After(tx.DeadlineTime) {
+		return false, InvalidReasonDeadlinePassed
+	}
+	return true, ""
+}
+
 // TransactionPool 交易池，用于存储待处理的紧急交易
+// 交易池由共享同一条紧急区块链的所有节点并发访问，方法可并发调用
 type TransactionPool struct {
+	// mutex 保护待处理交易、在途交易、交易状态与积压记录
+	mutex        sync.Mutex
 	transactions []*EmergencyTransaction
+
+	// MaxPoolSize 交易池容量上限，0 表示不限制
+	// 交易池已满时先清理过期交易，仍满则淘汰有效紧急度最低的交易（与选取交易的排序一致）
+	MaxPoolSize int
+
+	// reputationOf 查询发送者信誉值，设置后按 紧急度×发送者信誉 排序选取交易，
+	// 防止低信誉节点大量提交高紧急度交易插队；为 nil 时按纯紧急度排序
+	reputationOf ReputationLookup
+
+	// inFlight 已被取出打包、尚未上链的交易 [交易ID]交易
+	// 区块上链后移除；区块未能在超时内上链时由 ReturnTransactions 放回交易池
+	inFlight map[string]*EmergencyTransaction
+
+	// status 交易池接收过的交易的状态 [交易ID]，交易离开交易池后仍保留，供发送者查询
+	status map[string]txRecord
+
+	// Clock 记录交易入池、离池时间与积压采样的时钟，为 nil 时使用系统时钟
+	Clock clock.Clock
+	// backlog 交易池积压的变化记录，每次待处理交易数变化时追加一个采样
+	backlog []BacklogSample
+}
+
+// TxStatus 紧急交易的状态
+type TxStatus int
+
+const (
+	// TxUnknown 交易从未被交易池接受
+	TxUnknown TxStatus = iota
+	// TxPending 交易在交易池中等待打包，或已打包但所在区块尚未上链
+	TxPending
+	// TxCommitted 交易已随区块上链
+	TxCommitted
+	// TxExpired 交易超过截止时间，已从交易池清理
+	TxExpired
+	// TxEvicted 交易被淘汰：交易池已满时被更紧急的交易挤出，或单笔超过区块字节上限
+	TxEvicted
+)
+
+// String 返回交易状态的名称
+func (s TxStatus) String() string {
+	switch s {
+	case TxPending:
+		return "pending"
+	case TxCommitted:
+		return "committed"
+	case TxExpired:
+		return "expired"
+	case TxEvicted:
+		return "evicted"
+	default:
+		return "unknown"
+	}
+}
+
+// txRecord 交易状态及所在区块高度（仅 TxCommitted 时有效），以及 PoolStats 使用的入池与离池记录
+type txRecord struct {
+	status     TxStatus
+	blockIndex int
+	urgency    float64   // 交易首次被交易池接收时的紧急度
+	enqueuedAt time.Time // 交易首次被交易池接收的时间
+	exitedAt   time.Time // 上链、过期或被淘汰的时间，pending 时为零值
+}
+
+// ReputationLookup 查询节点信誉值的函数
+type ReputationLookup func(nodeID string) float64
+
+// SetReputationLookup 设置发送者信誉查询函数，传入 nil 恢复按纯紧急度排序
+func (pool *TransactionPool) SetReputationLookup(lookup ReputationLookup) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	pool.reputationOf = lookup
+}
+
+// EffectiveUrgency 返回交易用于排序的有效紧急度
+// 设置了信誉查询函数时为 UrgencyDegree × 发送者信誉，否则为 UrgencyDegree；
+// 查询函数在交易池锁外调用
+func (pool *TransactionPool) EffectiveUrgency(tx *EmergencyTransaction) float64 {
+	pool.mutex.Lock()
+	lookup := pool.reputationOf
+	pool.mutex.Unlock()
+	return urgencyWith(lookup, tx)
+}
+
+// effectiveUrgency 同 EffectiveUrgency，供交易池内部排序与淘汰使用，调用方需持有交易池锁
+func (pool *TransactionPool) effectiveUrgency(tx *EmergencyTransaction) float64 {
+	return urgencyWith(pool.reputationOf, tx)
+}
+
+// urgencyWith 按信誉查询函数 lookup 计算交易的有效紧急度，lookup 为 nil 时为 UrgencyDegree
+func urgencyWith(lookup ReputationLookup, tx *EmergencyTransaction) float64 {
+	if lookup == nil {
+		return tx.UrgencyDegree
+	}
+	return tx.UrgencyDegree * lookup(tx.VehicleID)
 }
 
 // NewTransactionPool 创建新的交易池
 func NewTransactionPool() *TransactionPool {
 	return &TransactionPool{
 		transactions: make([]*EmergencyTransaction, 0),
+		inFlight:     make(map[string]*EmergencyTransaction),
+		status:       make(map[string]txRecord),
 	}
 }
 
-// AddTransaction 添加交易到交易池
-func (pool *TransactionPool) AddTransaction(tx *EmergencyTransaction) {
+// AddTransaction 添加交易到交易池，返回交易是否被接受
+// 以下情况交易被拒绝：交易池中已有相同ID的交易；相同ID的交易已上链；
+// 交易池已满、清理过期交易后仍满，且新交易的有效紧急度不高于池中最低的有效紧急度（记为 TxEvicted）
+func (pool *TransactionPool) AddTransaction(tx *EmergencyTransaction) bool {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	return pool.addTransaction(tx)
+}
+
+// addTransaction 同 AddTransaction，调用方需持有交易池锁
+func (pool *TransactionPool) addTransaction(tx *EmergencyTransaction) bool {
+	for _, existing := range pool.transactions {
+		if existing.ID == tx.ID {
+			return false
+		}
+	}
+	if pool.status[tx.ID].status == TxCommitted {
+		return false
+	}
+
+	if pool.MaxPoolSize > 0 && len(pool.transactions) >= pool.MaxPoolSize {
+		pool.pruneExpired(pool.now())
+	}
+
+	if pool.MaxPoolSize > 0 && len(pool.transactions) >= pool.MaxPoolSize {
+		// 找出有效紧急度最低的交易：按与选取交易相同的排序淘汰，避免淘汰提议者最先打包的交易
+		lowest, lowestUrgency := 0, pool.effectiveUrgency(pool.transactions[0])
+		for i, existing := range pool.transactions[1:] {
+			if u := pool.effectiveUrgency(existing); u < lowestUrgency {
+				lowest, lowestUrgency = i+1, u
+			}
+		}
+		if pool.effectiveUrgency(tx) <= lowestUrgency {
+			pool.setStatus(tx, TxEvicted, -1)
+			return false
+		}
+		pool.setStatus(pool.transactions[lowest], TxEvicted, -1)
+		pool.transactions = append(pool.transactions[:lowest], pool.transactions[lowest+1:]...)
+	}
+
 	pool.transactions = append(pool.transactions, tx)
+	pool.setStatus(tx, TxPending, -1)
+	pool.recordBacklog()
+	return true
 }
 
-// GetTopKTransactions 获取紧急度最高的 k 笔交易
+// PruneExpired 移除截止时间早于 now 的交易，返回被移除的交易
+// 未设置截止时间的交易不会过期
+func (pool *TransactionPool) PruneExpired(now time.Time) []*EmergencyTransaction {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	return pool.pruneExpired(now)
+}
+
+// pruneExpired 同 PruneExpired，调用方需持有交易池锁
+func (pool *TransactionPool) pruneExpired(now time.Time) []*EmergencyTransaction {
+	var expired []*EmergencyTransaction
+	live := make([]*EmergencyTransaction, 0, len(pool.transactions))
+	for _, tx := range pool.transactions {
+		if !tx.DeadlineTime.IsZero() && tx.DeadlineTime.Before(now) {
+			expired = append(expired, tx)
+			pool.setStatus(tx, TxExpired, -1)
+		} else {
+			live = append(live, tx)
+		}
+	}
+	pool.transactions = live
+	pool.recordBacklog()
+	return expired
+}
+
+// GetTopKTransactions 获取有效紧急度最高的 k 笔交易
 func (pool *TransactionPool) GetTopKTransactions(k int) []*EmergencyTransaction {
+	return pool.GetTopKReadyTransactions(k, nil)
+}
+
+// GetTopKReadyTransactions 在满足 ready 的交易中获取有效紧急度最高的 k 笔交易
+// 不满足 ready 的交易留在交易池中；ready 为 nil 时所有交易均可选
+// 取出的交易记为在途交易，直到 CompleteInFlight 或 ReturnTransactions
+func (pool *TransactionPool) GetTopKReadyTransactions(k int, ready func(*EmergencyTransaction) bool) []*EmergencyTransaction {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
 	if len(pool.transactions) == 0 {
 		return nil
 	}
 
-	// 按紧急度降序排序
-	sorted := make([]*EmergencyTransaction, len(pool.transactions))
-	copy(sorted, pool.transactions)
-
-	// 简单冒泡排序（实际应用中可使用更高效的排序算法）
-	for i := 0; i < len(sorted)-1; i++ {
-		for j := 0; j < len(sorted)-i-1; j++ {
-			if sorted[j].UrgencyDegree < sorted[j+1].UrgencyDegree {
-				sorted[j], sorted[j+1] = sorted[j+1], sorted[j]
-			}
+	// 按有效紧急度降序排序，有效紧急度相同时保持入池顺序
+	// 有效紧急度预先计算，避免排序过程中重复查询信誉
+	priority := make(map[*EmergencyTransaction]float64, len(pool.transactions))
+	sorted := make([]*EmergencyTransaction, 0, len(pool.transactions))
+	for _, tx := range pool.transactions {
+		if ready != nil && !ready(tx) {
+			continue
 		}
+		priority[tx] = pool.effectiveUrgency(tx)
+		sorted = append(sorted, tx)
 	}
+	if len(sorted) == 0 {
+		return nil
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return priority[sorted[i]] > priority[sorted[j]]
+	})
 
 	// 取前 k 笔
 	if k > len(sorted) {
@@ -122,14 +418,135 @@ func (pool *TransactionPool) GetTopKTransactions(k int) []*EmergencyTransaction
 
 	result := sorted[:k]
 
-	// 从交易池中移除已选中的交易
-	pool.RemoveTransactions(result)
+	// 从交易池中移除已选中的交易，记为在途交易
+	pool.removeTransactions(result)
+	for _, tx := range result {
+		pool.inFlight[tx.ID] = tx
+	}
 
 	return result
 }
 
+// ReturnTransactions 将在途交易放回交易池，用于区块未能打包或未能在超时内上链的情况
+// 返回重新被交易池接受的交易数；不在途的交易（如已随其他区块上链）被忽略
+func (pool *TransactionPool) ReturnTransactions(txs []*EmergencyTransaction) int {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	returned := 0
+	for _, tx := range txs {
+		if _, ok := pool.inFlight[tx.ID]; !ok {
+			continue
+		}
+		delete(pool.inFlight, tx.ID)
+		if pool.addTransaction(tx) {
+			returned++
+		}
+	}
+	return returned
+}
+
+// CompleteInFlight 结束交易的在途状态，用于交易已上链或被丢弃的情况
+// 已上链的交易若此前因超时被放回交易池，也一并从交易池中移除
+func (pool *TransactionPool) CompleteInFlight(txs []*EmergencyTransaction) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	pool.completeInFlight(txs)
+}
+
+// completeInFlight 同 CompleteInFlight，调用方需持有交易池锁
+func (pool *TransactionPool) completeInFlight(txs []*EmergencyTransaction) {
+	for _, tx := range txs {
+		delete(pool.inFlight, tx.ID)
+	}
+	pool.removeTransactions(txs)
+}
+
+// commit 结束随区块上链的交易的在途状态，并记为已上链
+func (pool *TransactionPool) commit(txs []*EmergencyTransaction, blockIndex int) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	pool.completeInFlight(txs)
+	for _, tx := range txs {
+		pool.setStatus(tx, TxCommitted, blockIndex)
+	}
+}
+
+// reopen 将已上链的交易恢复为在途状态，用于所在区块在分叉裁决中被替换的情况，
+// 之后由 ReturnTransactions 放回交易池
+func (pool *TransactionPool) reopen(txs []*EmergencyTransaction) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	for _, tx := range txs {
+		pool.inFlight[tx.ID] = tx
+		pool.setStatus(tx, TxPending, -1)
+	}
+}
+
+// evict 将交易记为被淘汰
+func (pool *TransactionPool) evict(txs []*EmergencyTransaction) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	for _, tx := range txs {
+		pool.setStatus(tx, TxEvicted, -1)
+	}
+}
+
+// setStatus 记录交易状态，调用方需持有交易池锁
+// 交易首次被记录时同时记录入池时间与紧急度，放回交易池等再次记录时保留首次的记录
+func (pool *TransactionPool) setStatus(tx *EmergencyTransaction, status TxStatus, blockIndex int) {
+	if pool.status == nil {
+		pool.status = make(map[string]txRecord)
+	}
+	now := pool.now()
+	record, seen := pool.status[tx.ID]
+	if !seen {
+		record.urgency = tx.UrgencyDegree
+		record.enqueuedAt = now
+	}
+	record.status = status
+	record.blockIndex = blockIndex
+	record.exitedAt = time.Time{}
+	if status != TxPending {
+		record.exitedAt = now
+	}
+	pool.status[tx.ID] = record
+}
+
+// now 返回交易池时钟的当前时间
+func (pool *TransactionPool) now() time.Time {
+	if pool.Clock == nil {
+		return time.Now()
+	}
+	return pool.Clock.Now()
+}
+
+// Status 返回交易的状态，已上链时同时返回所在区块高度，否则高度为 -1
+func (pool *TransactionPool) Status(txID string) (TxStatus, int) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	record, ok := pool.status[txID]
+	if !ok {
+		return TxUnknown, -1
+	}
+	return record.status, record.blockIndex
+}
+
+// InFlightSize 返回已取出打包、尚未上链的在途交易数
+func (pool *TransactionPool) InFlightSize() int {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	return len(pool.inFlight)
+}
+
 // RemoveTransactions 从交易池中移除指定的交易
 func (pool *TransactionPool) RemoveTransactions(txs []*EmergencyTransaction) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	pool.removeTransactions(txs)
+}
+
+// removeTransactions 同 RemoveTransactions，调用方需持有交易池锁
+func (pool *TransactionPool) removeTransactions(txs []*EmergencyTransaction) {
 	// 创建一个 map 用于快速查找
 	toRemove := make(map[string]bool)
 	for _, tx := range txs {
@@ -145,9 +562,12 @@ func (pool *TransactionPool) RemoveTransactions(txs []*EmergencyTransaction) {
 	}
 
 	pool.transactions = newTransactions
+	pool.recordBacklog()
 }
 
 // Size 返回交易池大小
 func (pool *TransactionPool) Size() int {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
 	return len(pool.transactions)
 }
@@ -0,0 +1,470 @@
+package emergency
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"block/clock"
+)
+
+func TestTopKOrderedBySenderReputation(t *testing.T) {
+	base := time.Unix(1000, 0)
+	cfg := UrgencyConfig{Omega: 0.5}
+	newTx := func(id, sender string) *EmergencyTransaction {
+		return NewEmergencyTransaction(id, sender, nil, base, base.Add(5*time.Second), base.Add(time.Second), 0, cfg)
+	}
+
+	fill := func(pool *TransactionPool) {
+		// 两笔紧急度相同的交易，低信誉节点的交易先入池
+		pool.AddTransaction(newTx("spam", "low"))
+		pool.AddTransaction(newTx("genuine", "high"))
+	}
+	reputations := map[string]float64{"low": 0.2, "high": 0.9}
+
+	// 纯紧急度排序：紧急度相同时保持入池顺序
+	pure := NewTransactionPool()
+	fill(pure)
+	if top := pure.GetTopKTransactions(1); top[0].ID != "spam" {
+		t.Errorf("纯紧急度排序时首笔交易 = %s, 期望先入池的 spam", top[0].ID)
+	}
+
+	weighted := NewTransactionPool()
+	weighted.SetReputationLookup(func(nodeID string) float64 { return reputations[nodeID] })
+	fill(weighted)
+	if top := weighted.GetTopKTransactions(1); top[0].ID != "genuine" {
+		t.Errorf("按发送者信誉加权时首笔交易 = %s, 期望高信誉节点的 genuine", top[0].ID)
+	}
+
+	// 关闭信誉加权后恢复纯紧急度排序
+	weighted.SetReputationLookup(nil)
+	if got := weighted.EffectiveUrgency(newTx("x", "low")); got != newTx("y", "high").UrgencyDegree {
+		t.Errorf("关闭信誉加权后有效紧急度 = %v, 期望等于紧急度", got)
+	}
+}
+
+func TestReputationLookupSwappedConcurrently(t *testing.T) {
+	base := time.Unix(1000, 0)
+	cfg := UrgencyConfig{Omega: 0.5}
+	pool := NewTransactionPool()
+	lookups := []ReputationLookup{nil, func(string) float64 { return 0.5 }, func(string) float64 { return 1 }}
+
+	// 一个协程反复替换信誉查询函数，其余协程并发排序与查询有效紧急度（go test -race 下检查数据竞争）
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			pool.SetReputationLookup(lookups[i%len(lookups)])
+		}
+	}()
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				tx := NewEmergencyTransaction(fmt.Sprintf("tx%d-%d", r, i), "1", nil, base, base.Add(5*time.Second), base.Add(time.Second), 0, cfg)
+				if u := pool.EffectiveUrgency(tx); u != tx.UrgencyDegree && u != tx.UrgencyDegree*0.5 {
+					t.Errorf("有效紧急度 = %v, 不对应任何一个查询函数", u)
+					return
+				}
+				pool.AddTransaction(tx)
+				pool.GetTopKTransactions(1)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestFullPoolEvictsLowestEffectiveUrgency(t *testing.T) {
+	base := time.Unix(1000, 0)
+	cfg := UrgencyConfig{Omega: 0.5}
+	// 截止时间越近紧急度越高
+	newTx := func(id, sender string, deadline time.Duration) *EmergencyTransaction {
+		return NewEmergencyTransaction(id, sender, []byte(id), base, base.Add(deadline), base.Add(time.Second), 0, cfg)
+	}
+	pending := func(pool *TransactionPool) []string {
+		var ids []string
+		for _, tx := range pool.GetTopKTransactions(pool.Size()) {
+			ids = append(ids, tx.ID)
+		}
+		sort.Strings(ids)
+		return ids
+	}
+
+	pool := NewTransactionPool()
+	pool.Clock = clock.Fixed(base.Add(time.Second))
+	pool.MaxPoolSize = 3
+	for _, tx := range []*EmergencyTransaction{
+		newTx("high", "1", 2*time.Second),
+		newTx("mid", "1", 5*time.Second),
+		newTx("low", "1", 10*time.Second),
+	} {
+		if !pool.AddTransaction(tx) {
+			t.Fatalf("交易池未满时拒绝了交易 %s", tx.ID)
+		}
+	}
+
+	// 比池中最低紧急度更紧急的交易挤出最低的交易，依次淘汰 low、mid
+	if !pool.AddTransaction(newTx("urgent", "1", 3*time.Second)) {
+		t.Fatal("更紧急的交易被拒绝")
+	}
+	if status, _ := pool.Status("low"); status != TxEvicted {
+		t.Errorf("low 的状态 = %v, 期望 evicted", status)
+	}
+	if !pool.AddTransaction(newTx("urgent2", "1", 4*time.Second)) {
+		t.Fatal("更紧急的交易被拒绝")
+	}
+	if status, _ := pool.Status("mid"); status != TxEvicted {
+		t.Errorf("mid 的状态 = %v, 期望 evicted", status)
+	}
+	// 不比池中最低紧急度更紧急的交易被拒绝，池中交易不变
+	if pool.AddTransaction(newTx("lazy", "1", 20*time.Second)) {
+		t.Error("紧急度最低的新交易应被拒绝")
+	}
+	if status, _ := pool.Status("lazy"); status != TxEvicted {
+		t.Errorf("被拒绝的交易状态 = %v, 期望 evicted", status)
+	}
+	if got, want := pending(pool), []string{"high", "urgent", "urgent2"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("池中交易 = %v, 期望 %v", got, want)
+	}
+
+	// 按发送者信誉加权后，淘汰与选取使用同一有效紧急度：
+	// 低信誉节点紧急度最高的交易有效紧急度最低，先被淘汰
+	reputations := map[string]float64{"low": 0.1, "high": 0.9}
+	weighted := NewTransactionPool()
+	weighted.Clock = clock.Fixed(base.Add(time.Second))
+	weighted.MaxPoolSize = 2
+	weighted.SetReputationLookup(func(nodeID string) float64 { return reputations[nodeID] })
+	weighted.AddTransaction(newTx("spam", "low", 3*time.Second))
+	weighted.AddTransaction(newTx("genuine", "high", 4*time.Second))
+	if !weighted.AddTransaction(newTx("genuine2", "high", 4500*time.Millisecond)) {
+		t.Fatal("有效紧急度更高的交易被拒绝")
+	}
+	if status, _ := weighted.Status("spam"); status != TxEvicted {
+		t.Errorf("spam 的状态 = %v, 期望按有效紧急度被淘汰", status)
+	}
+	if got, want := pending(weighted), []string{"genuine", "genuine2"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("池中交易 = %v, 期望 %v", got, want)
+	}
+}
+
+func TestUrgencyClampedAtExtremeTheta(t *testing.T) {
+	base := time.Unix(1000, 0)
+	cfg := UrgencyConfig{Omega: 0.5, MinUrgency: 0.01, MaxUrgency: 5}
+	urgency := func(deadline time.Duration, theta int, cfg UrgencyConfig) float64 {
+		tx := NewEmergencyTransaction("tx", "1", nil, base, base.Add(deadline), base.Add(time.Second), theta, cfg)
+		return tx.UrgencyDegree
+	}
+
+	// θ 很大时 e^(ωθ) 溢出为 +Inf，截断到上限
+	for _, theta := range []int{0, 10, 100, 1000, 10000} {
+		got := urgency(2*time.Second, theta, cfg)
+		if math.IsNaN(got) || got < cfg.MinUrgency || got > cfg.MaxUrgency {
+			t.Errorf("θ=%d 时紧急度 = %v, 超出 [%v, %v]", theta, got, cfg.MinUrgency, cfg.MaxUrgency)
+		}
+	}
+	if got := urgency(2*time.Second, 10000, cfg); got != cfg.MaxUrgency {
+		t.Errorf("θ=10000 时紧急度 = %v, 期望上限 %v", got, cfg.MaxUrgency)
+	}
+
+	// 截止时间很远时 E → 0，截断到下限
+	if got := urgency(time.Hour, 0, cfg); got != cfg.MinUrgency {
+		t.Errorf("截止时间很远时紧急度 = %v, 期望下限 %v", got, cfg.MinUrgency)
+	}
+
+	// MaxUrgency 为 0 时不设上限
+	unbounded := UrgencyConfig{Omega: 0.5}
+	if got := urgency(2*time.Second, 100, unbounded); got <= cfg.MaxUrgency {
+		t.Errorf("未设上限时 θ=100 的紧急度 = %v, 期望不被截断", got)
+	}
+}
+
+func TestUrgencyWithoutMeasurableTransitTime(t *testing.T) {
+	base := time.Unix(1000, 0)
+	cfg := UrgencyConfig{Omega: 0.5, MinUrgency: 0.01, MaxUrgency: 5}
+
+	// 到达时间早于产生时间（时钟不同步），截止时间未到：按最低紧急度处理
+	pending := NewEmergencyTransaction("pending", "1", nil, base, base.Add(5*time.Second), base.Add(-time.Second), 0, cfg)
+	if pending.UrgencyDegree != cfg.MinUrgency {
+		t.Errorf("截止时间未到时紧急度 = %v, 期望下限 %v", pending.UrgencyDegree, cfg.MinUrgency)
+	}
+
+	// 截止时间已过：E = 1，紧急度仍随 θ 增长并受上限约束
+	overdue := NewEmergencyTransaction("overdue", "1", nil, base, base.Add(-2*time.Second), base.Add(-time.Second), 2, cfg)
+	if want := math.Exp(cfg.Omega * 2); math.Abs(overdue.UrgencyDegree-want) > 1e-12 {
+		t.Errorf("截止时间已过时紧急度 = %v, 期望 e^(ωθ) = %v", overdue.UrgencyDegree, want)
+	}
+	overdue = NewEmergencyTransaction("overdue", "1", nil, base, base.Add(-2*time.Second), base.Add(-time.Second), 50, cfg)
+	if overdue.UrgencyDegree != cfg.MaxUrgency {
+		t.Errorf("截止时间已过且 θ=50 时紧急度 = %v, 期望上限 %v", overdue.UrgencyDegree, cfg.MaxUrgency)
+	}
+}
+
+func TestFailedProposalReturnsTransactionsToPool(t *testing.T) {
+	ebc := NewEmergencyBlockchain(UrgencyConfig{}, 5, time.Second)
+	vg := NewValidatorGroup(4, 10)
+	for _, id := range []string{"1", "2", "3", "4"} {
+		vg.Validators = append(vg.Validators, &Validator{ID: id, Reputation: 0.9})
+	}
+	// 提议者没有对等节点，收不到其他验证器的投票，区块无法达成共识
+	proposer := NewEmergencyNode("1", ebc, &fakeReputation{}, vg)
+	proposer.UpdateValidatorStatus()
+	proposer.CommitTimeout = 50 * time.Millisecond
+
+	now := time.Now()
+	for _, id := range []string{"a", "b"} {
+		ebc.AddTransaction(&EmergencyTransaction{ID: id, VehicleID: "9", ArrivalTime: now, DeadlineTime: now.Add(time.Minute)})
+	}
+
+	picked := ebc.TxPool.GetTopKTransactions(1)
+	if ebc.TxPool.Size() != 1 || ebc.TxPool.InFlightSize() != 1 {
+		t.Fatalf("取出 1 笔后池中 %d 笔、在途 %d 笔，期望各 1 笔", ebc.TxPool.Size(), ebc.TxPool.InFlightSize())
+	}
+	ebc.TxPool.ReturnTransactions(picked)
+
+	block, err := proposer.ProposeEmergencyBlock()
+	if !errors.Is(err, ErrQuorumNotReached) {
+		t.Fatalf("无法达成共识时应返回 ErrQuorumNotReached，实际 %v", err)
+	}
+	if len(block.Transactions) != 2 {
+		t.Fatalf("区块包含 %d 笔交易，期望 2 笔", len(block.Transactions))
+	}
+	if ebc.TxPool.Size() != 2 || ebc.TxPool.InFlightSize() != 0 {
+		t.Errorf("提议失败后池中 %d 笔、在途 %d 笔，期望交易全部放回交易池", ebc.TxPool.Size(), ebc.TxPool.InFlightSize())
+	}
+
+	// 超时后区块才上链时，放回的交易从交易池中移除，不会被重复打包
+	if err := ebc.AddBlock(block, nil); err != nil {
+		t.Fatalf("AddBlock: %v", err)
+	}
+	if ebc.TxPool.Size() != 0 {
+		t.Errorf("区块上链后池中仍有 %d 笔交易", ebc.TxPool.Size())
+	}
+}
+
+func TestTransactionStatusLifecycle(t *testing.T) {
+	ebc := NewEmergencyBlockchain(UrgencyConfig{}, 5, time.Second)
+	ebc.TxPool.MaxPoolSize = 2
+	now := time.Now()
+	newTx := func(id string, urgency float64, deadline time.Time) *EmergencyTransaction {
+		return &EmergencyTransaction{ID: id, VehicleID: "9", ArrivalTime: now, DeadlineTime: deadline, UrgencyDegree: urgency}
+	}
+	checkStatus := func(id string, wantStatus TxStatus, wantIndex int) {
+		t.Helper()
+		if status, index := ebc.TransactionStatus(id); status != wantStatus || index != wantIndex {
+			t.Errorf("交易 %s 状态 = %v/%d, 期望 %v/%d", id, status, index, wantStatus, wantIndex)
+		}
+	}
+
+	ebc.AddTransaction(newTx("stale", 0.5, now.Add(-time.Second)))
+	ebc.AddTransaction(newTx("low", 0.1, now.Add(time.Minute)))
+	checkStatus("stale", TxPending, -1)
+
+	// 交易池已满时先清理过期交易，再挤出紧急度最低的交易
+	ebc.AddTransaction(newTx("high", 0.9, now.Add(time.Minute)))
+	checkStatus("stale", TxExpired, -1)
+	ebc.AddTransaction(newTx("higher", 0.95, now.Add(time.Minute)))
+	checkStatus("low", TxEvicted, -1)
+
+	// 已打包、尚未上链的交易仍为 pending
+	block := NewEmergencyBlock(1, ebc.GetLatestBlock().Hash, ebc.TxPool.GetTopKTransactions(ebc.BlockSize), nil, nil)
+	checkStatus("high", TxPending, -1)
+	if err := ebc.AddBlock(block, nil); err != nil {
+		t.Fatalf("AddBlock: %v", err)
+	}
+	checkStatus("high", TxCommitted, 1)
+	checkStatus("higher", TxCommitted, 1)
+	checkStatus("missing", TxUnknown, -1)
+
+	// 已上链的交易不能再次进入交易池
+	if ebc.AddTransaction(newTx("high", 0.9, now.Add(time.Minute))) {
+		t.Error("已上链的交易不应被交易池再次接受")
+	}
+	checkStatus("high", TxCommitted, 1)
+}
+
+func TestPoolStatsSummarizesLifecycle(t *testing.T) {
+	base := time.Unix(1000, 0)
+	ebc := NewEmergencyBlockchainWithGenesis(NewGenesisBlock(base), UrgencyConfig{}, 5, time.Second)
+	pool := ebc.TxPool
+	pool.MaxPoolSize = 3
+	at := func(seconds int) { pool.Clock = clock.Fixed(base.Add(time.Duration(seconds) * time.Second)) }
+	newTx := func(id string, urgency float64, deadline time.Duration) *EmergencyTransaction {
+		return &EmergencyTransaction{ID: id, VehicleID: "9", DeadlineTime: base.Add(deadline), UrgencyDegree: urgency}
+	}
+
+	// t=0 三笔交易入池，其中 stale 在 t=4 时已过期
+	at(0)
+	ebc.AddTransaction(newTx("a", 0.8, time.Minute))
+	ebc.AddTransaction(newTx("b", 0.6, time.Minute))
+	ebc.AddTransaction(newTx("stale", 0.4, 3*time.Second))
+	// t=4 交易池已满：清理过期的 stale 后仍有空位，c 入池；d 挤出紧急度最低的 c
+	at(4)
+	ebc.AddTransaction(newTx("c", 0.2, time.Minute))
+	ebc.AddTransaction(newTx("d", 0.9, time.Minute))
+	// t=6 打包 d 与 a，t=10 上链
+	at(6)
+	block := NewEmergencyBlock(1, ebc.GetLatestBlock().Hash, pool.GetTopKTransactions(2), nil, nil)
+	at(10)
+	if err := ebc.AddBlock(block, nil); err != nil {
+		t.Fatalf("AddBlock: %v", err)
+	}
+
+	stats := pool.PoolStats()
+	if stats.Received != 5 || stats.Committed != 2 || stats.Expired != 1 || stats.Evicted != 1 || stats.Pending != 1 {
+		t.Errorf("统计 = %+v, 期望接收 5、上链 2、过期 1、淘汰 1、待处理 1", stats)
+	}
+	// a 等待 10 秒，d 等待 6 秒
+	if stats.AvgWait != 8*time.Second || stats.MaxWait != 10*time.Second {
+		t.Errorf("等待时间 平均=%v 最长=%v, 期望 8s/10s", stats.AvgWait, stats.MaxWait)
+	}
+	if stats.CommittedUrgency.Count != 2 || stats.CommittedUrgency.Median != 0.8 || stats.CommittedUrgency.Max != 0.9 {
+		t.Errorf("上链交易紧急度分布 = %v", stats.CommittedUrgency)
+	}
+	if stats.DroppedUrgency.Count != 2 || stats.DroppedUrgency.Min != 0.2 || stats.DroppedUrgency.Max != 0.4 {
+		t.Errorf("丢弃交易紧急度分布 = %v", stats.DroppedUrgency)
+	}
+
+	// 积压：t=0 1→2→3，t=4 清理后 2→3，挤出后仍为 3（不重复采样），t=6 打包后 1
+	var sizes []int
+	for _, sample := range stats.Backlog {
+		sizes = append(sizes, sample.Size)
+	}
+	if got := fmt.Sprint(sizes); got != "[1 2 3 2 3 1]" {
+		t.Errorf("积压采样 = %s, 期望 [1 2 3 2 3 1]", got)
+	}
+	// 0~4 秒积压 3，4~6 秒积压 3，6~10 秒积压 1：平均 (12+6+4)/10
+	if stats.MaxBacklog != 3 || math.Abs(stats.AvgBacklog-2.2) > 1e-9 {
+		t.Errorf("积压 峰值=%d 平均=%.4f, 期望 3/2.2", stats.MaxBacklog, stats.AvgBacklog)
+	}
+}
+
+func TestUrgencyModelsOnSameTimings(t *testing.T) {
+	base := time.Unix(1000, 0)
+	exponential := UrgencyConfig{Omega: 0.5}
+	linear := UrgencyConfig{Model: UrgencyLinear, Omega: 0.5, MaxDeadline: 10 * time.Second}
+	urgency := func(tc time.Duration, theta int, cfg UrgencyConfig) float64 {
+		// Tr - Tu = 1s，期望延迟 Tc = deadline - arrival
+		arrival := base.Add(time.Second)
+		tx := NewEmergencyTransaction("tx", "1", nil, base, arrival.Add(tc), arrival, theta, cfg)
+		return tx.UrgencyDegree
+	}
+
+	tests := []struct {
+		tc          time.Duration
+		exponential float64
+		linear      float64
+	}{
+		{0, 1, 1},
+		{time.Second, math.Exp(-1), 0.9},
+		{5 * time.Second, math.Exp(-5), 0.5},
+		{10 * time.Second, math.Exp(-10), 0},
+		{time.Minute, math.Exp(-60), 0},
+		{-time.Second, 1, 1}, // 截止时间已过，按最紧急处理
+	}
+	for _, tt := range tests {
+		if got := urgency(tt.tc, 0, exponential); math.Abs(got-tt.exponential) > 1e-9 {
+			t.Errorf("Tc=%v 指数模型紧急度 = %v, 期望 %v", tt.tc, got, tt.exponential)
+		}
+		if got := urgency(tt.tc, 0, linear); math.Abs(got-tt.linear) > 1e-9 {
+			t.Errorf("Tc=%v 线性模型紧急度 = %v, 期望 %v", tt.tc, got, tt.linear)
+		}
+	}
+
+	// 线性模型不考虑已申请次数 θ，结果始终在 [0,1] 内
+	if got := urgency(5*time.Second, 10, linear); got != 0.5 {
+		t.Errorf("θ=10 时线性模型紧急度 = %v, 期望与 θ 无关的 0.5", got)
+	}
+	// 未设置 MaxDeadline 时取 1 分钟
+	if got := urgency(30*time.Second, 0, UrgencyConfig{Model: UrgencyLinear}); math.Abs(got-0.5) > 1e-9 {
+		t.Errorf("默认 MaxDeadline 下 Tc=30s 的线性紧急度 = %v, 期望 0.5", got)
+	}
+}
+
+func TestParseUrgencyModel(t *testing.T) {
+	for _, m := range []UrgencyModel{UrgencyExponential, UrgencyLinear} {
+		if got, err := ParseUrgencyModel(m.String()); err != nil || got != m {
+			t.Errorf("ParseUrgencyModel(%q) = %v, %v", m.String(), got, err)
+		}
+	}
+	if _, err := ParseUrgencyModel("quadratic"); !errors.Is(err, ErrUnknownUrgencyModel) {
+		t.Errorf("未知模型应返回 ErrUnknownUrgencyModel，实际 %v", err)
+	}
+}
+
+func TestTransactionValidity(t *testing.T) {
+	base := time.Unix(1000, 0)
+	tests := []struct {
+		name       string
+		tx         EmergencyTransaction
+		wantValid  bool
+		wantReason string
+	}{
+		{"有效交易", EmergencyTransaction{Data: []byte("x"), DeadlineTime: base.Add(time.Second)}, true, ""},
+		{"未设置截止时间", EmergencyTransaction{Data: []byte("x")}, true, ""},
+		{"恰好在截止时间出块", EmergencyTransaction{Data: []byte("x"), DeadlineTime: base}, true, ""},
+		{"数据为空", EmergencyTransaction{DeadlineTime: base.Add(time.Second)}, false, InvalidReasonEmptyData},
+		{"已过截止时间", EmergencyTransaction{Data: []byte("x"), DeadlineTime: base.Add(-time.Second)}, false, InvalidReasonDeadlinePassed},
+	}
+	for _, tt := range tests {
+		valid, reason := tt.tx.IsValid(base)
+		if valid != tt.wantValid || reason != tt.wantReason {
+			t.Errorf("%s: IsValid = (%v, %q), 期望 (%v, %q)", tt.name, valid, reason, tt.wantValid, tt.wantReason)
+		}
+	}
+}
+
+func TestTransactionPoolSurvivesRestart(t *testing.T) {
+	now := time.Now()
+	cfg := UrgencyConfig{Omega: 0.5}
+	pool := NewTransactionPool()
+	live := NewEmergencyTransaction("live", "1", []byte("accident"), now.Add(-2*time.Second), now.Add(time.Hour), now.Add(-time.Second), 2, cfg)
+	live.NormalChainRef = 3
+	pool.AddTransaction(live)
+	pool.AddTransaction(NewEmergencyTransaction("inflight", "2", []byte("fire"), now, now.Add(time.Hour), now, 0, cfg))
+	// 离线期间超过截止时间的交易
+	pool.AddTransaction(NewEmergencyTransaction("stale", "3", []byte("jam"), now, now.Add(50*time.Millisecond), now, 0, cfg))
+	// 已取出打包、尚未上链的交易同样需要保存
+	pool.GetTopKReadyTransactions(1, func(tx *EmergencyTransaction) bool { return tx.ID == "inflight" })
+
+	path := filepath.Join(t.TempDir(), "txpool.json")
+	if err := pool.SaveJSON(path); err != nil {
+		t.Fatalf("SaveJSON: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	ebc, err := NewEmergencyBlockchainWithPool(cfg, 5, time.Second, path)
+	if err != nil {
+		t.Fatalf("NewEmergencyBlockchainWithPool: %v", err)
+	}
+	restored := ebc.TxPool
+	if restored.Size() != 2 {
+		t.Fatalf("恢复后交易池大小 = %d, 期望 2（过期交易被清理）", restored.Size())
+	}
+	for id, want := range map[string]TxStatus{"live": TxPending, "inflight": TxPending, "stale": TxExpired} {
+		if status, _ := restored.Status(id); status != want {
+			t.Errorf("交易 %s 的状态 = %v, 期望 %v", id, status, want)
+		}
+	}
+	got := restored.GetTopKReadyTransactions(2, func(tx *EmergencyTransaction) bool { return tx.ID == "live" })
+	if len(got) != 1 {
+		t.Fatalf("未找到恢复的交易 live")
+	}
+	tx := got[0]
+	if string(tx.Data) != "accident" || tx.VehicleID != "1" || tx.Theta != 2 || tx.NormalChainRef != 3 ||
+		tx.UrgencyDegree != live.UrgencyDegree || !tx.DeadlineTime.Equal(live.DeadlineTime) || !tx.ArrivalTime.Equal(live.ArrivalTime) {
+		t.Errorf("恢复的交易 = %+v, 与保存前 %+v 不一致", tx, live)
+	}
+
+	// 交易池文件不存在时使用空交易池
+	fresh, err := NewEmergencyBlockchainWithPool(cfg, 5, time.Second, filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil || fresh.TxPool.Size() != 0 {
+		t.Errorf("文件不存在时应得到空交易池, err=%v", err)
+	}
+}
@@ -2,6 +2,11 @@ package emergency
 
 import (
 	"block/reputation"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
 	"sort"
 	"time"
 )
@@ -12,32 +17,125 @@ type Validator struct {
 	Reputation float64 // 信誉值
 }
 
+// ProposerStrategy 出块者选择策略
+type ProposerStrategy int
+
+const (
+	// HighestReputation 选择选取得分最高的验证器出块（默认）
+	HighestReputation ProposerStrategy = iota
+	// RoundRobin 按验证器列表顺序轮流出块，验证器组刷新后从新列表的下一个位置继续
+	RoundRobin
+	// WeightedRandom 按选取得分加权随机选择出块者
+	WeightedRandom
+)
+
+// ErrUnknownProposerStrategy 出块者选择策略名称不合法
+var ErrUnknownProposerStrategy = errors.New("未知的出块者选择策略")
+
+// ProposerStrategies 所有出块者选择策略，按定义顺序排列
+var ProposerStrategies = []ProposerStrategy{HighestReputation, RoundRobin, WeightedRandom}
+
+// String 返回出块者选择策略的名称
+func (s ProposerStrategy) String() string {
+	switch s {
+	case HighestReputation:
+		return "highest-reputation"
+	case RoundRobin:
+		return "round-robin"
+	case WeightedRandom:
+		return "weighted-random"
+	default:
+		return fmt.Sprintf("ProposerStrategy(%d)", int(s))
+	}
+}
+
+// ParseProposerStrategy 按名称（highest-reputation/round-robin/weighted-random）解析出块者选择策略
+func ParseProposerStrategy(name string) (ProposerStrategy, error) {
+	for _, s := range ProposerStrategies {
+		if s.String() == name {
+			return s, nil
+		}
+	}
+	return HighestReputation, fmt.Errorf("%w: %q（highest-reputation/round-robin/weighted-random）",
+		ErrUnknownProposerStrategy, name)
+}
+
 // ValidatorGroup 验证器节点组
 // 根据论文 3.4.1.3 验证器节点组建
 type ValidatorGroup struct {
 	Validators   []*Validator // 验证器节点列表
 	GroupSize    int          // 验证器组大小 N
+	MinSize      int          // 验证器组最少节点数，移除不活跃验证器时不会低于该值
 	ActivePeriod int          // 验证器组活跃周期（区块周期数）
 	CurrentRound int          // 当前区块周期
 	CreatedAt    time.Time    // 验证器组创建时间
+
+	// 探索席位：保留部分验证器名额给信誉值不低于 MinReputation 的随机节点，
+	// 避免验证器组长期被少数高信誉节点垄断
+	ExplorationFraction float64    // 随机席位占组大小的比例 [0,1]，0 表示纯按信誉选取
+	MinReputation       float64    // 参与随机席位的最低信誉值
+	rng                 *rand.Rand // 随机席位使用的随机源
+
+	// 混合 PoS/PoE：按选取得分 SelectionScore = reputation^ReputationExponent × stake^StakeExponent
+	// 排序验证器与选择出块者；默认指数 (1, 0) 即纯按信誉选取
+	Stake              map[string]float64 // 各节点的质押量，未列出的节点质押为 0
+	ReputationExponent float64            // 信誉值的指数 a
+	StakeExponent      float64            // 质押量的指数 b
+
+	// ProposerStrategy 出块者选择策略（默认 HighestReputation），由 NextProposer 使用
+	ProposerStrategy ProposerStrategy
+	proposerTurn     int // RoundRobin 策略下一次出块的位置
+
+	// ProposerCooldown 出块冷却轮数 k：SelectProposer 跳过最近 k 轮内出过块的验证器，
+	// 由得分次高者出块；所有验证器都在冷却中时不受限制。0 表示不限制
+	ProposerCooldown int
+	proposerRound    int            // SelectProposer 已选出出块者的轮数
+	lastProposed     map[string]int // 各验证器最近一次被 SelectProposer 选中的轮次
+
+	// EvictionThreshold 驱逐阈值：活跃周期内信誉值跌破该值的验证器由 ShouldEvict 报告，
+	// 调用方据此提前刷新验证器组；0 表示不检查
+	EvictionThreshold float64
+
+	// CandidateReputations 最近一次选取验证器时所有候选节点的信誉值
+	CandidateReputations map[string]float64
+
+	// publicKeys 各节点用于提交签名的公钥 [节点ID]公钥，节点创建时登记
+	publicKeys map[string]ed25519.PublicKey
 }
 
 // NewValidatorGroup 创建新的验证器节点组
+// MinSize 默认为 MinBFTValidators，组大小小于该值时为组大小
 func NewValidatorGroup(groupSize int, activePeriod int) *ValidatorGroup {
+	minSize := MinBFTValidators
+	if groupSize < minSize {
+		minSize = groupSize
+	}
 	return &ValidatorGroup{
 		Validators:   make([]*Validator, 0),
 		GroupSize:    groupSize,
+		MinSize:      minSize,
 		ActivePeriod: activePeriod,
 		CurrentRound: 0,
 		CreatedAt:    time.Now(),
+		rng:          rand.New(rand.NewSource(time.Now().UnixNano())),
+
+		ReputationExponent: 1,
+		StakeExponent:      0,
 	}
 }
 
-// SelectValidators 根据信誉值选取验证器节点
-// 选取信誉值最高的 groupSize 个节点作为验证器节点
+// SetSeed 设置随机席位的随机种子，便于复现验证器选取结果
+func (vg *ValidatorGroup) SetSeed(seed int64) {
+	vg.rng = rand.New(rand.NewSource(seed))
+}
+
+// SelectValidators 根据选取得分（默认即信誉值）选取验证器节点
+// 选取得分最高的 groupSize 个节点作为验证器节点；
+// 若设置了 ExplorationFraction，则其中 floor(GroupSize×ExplorationFraction) 个席位
+// 从其余信誉值不低于 MinReputation 的节点中随机选取，合格节点不足时由信誉排名补齐
 func (vg *ValidatorGroup) SelectValidators(
 	nodeIDs []string,
-	reputationManagers map[string]*reputation.ReputationManager,
+	reputationManagers map[string]reputation.ReputationProvider,
 	now time.Time,
 ) {
 	// 计算所有节点的信誉值
@@ -53,22 +151,97 @@ func (vg *ValidatorGroup) SelectValidators(
 		}
 	}
 
-	// 按信誉值降序排序
+	// 按选取得分降序排序，得分相同时按节点ID升序
 	sort.Slice(nodeReputation, func(i, j int) bool {
-		return nodeReputation[i].Reputation > nodeReputation[j].Reputation
+		return vg.rankedBefore(nodeReputation[i], nodeReputation[j])
 	})
 
+	vg.CandidateReputations = make(map[string]float64, len(nodeReputation))
+	for _, v := range nodeReputation {
+		vg.CandidateReputations[v.ID] = v.Reputation
+	}
+
 	// 选取前 groupSize 个节点
 	if len(nodeReputation) < vg.GroupSize {
 		vg.Validators = nodeReputation
 	} else {
-		vg.Validators = nodeReputation[:vg.GroupSize]
+		vg.Validators = vg.pickWithExploration(nodeReputation)
 	}
 
 	vg.CreatedAt = now
 	vg.CurrentRound = 0
 }
 
+// SelectionScore 返回节点的选取得分 reputation^ReputationExponent × stake^StakeExponent
+// 信誉值与质押量为负时按 0 计；指数为 0 的因子恒为 1，默认指数 (1, 0) 时得分即信誉值
+func (vg *ValidatorGroup) SelectionScore(v *Validator) float64 {
+	return math.Pow(math.Max(v.Reputation, 0), vg.ReputationExponent) *
+		math.Pow(math.Max(vg.Stake[v.ID], 0), vg.StakeExponent)
+}
+
+// rankedBefore 判断验证器 a 是否排在 b 之前：按选取得分降序，得分相同时按节点ID升序
+// 启动阶段大量节点信誉值同为初始值，按ID打破平局使验证器组的选取可复现
+func (vg *ValidatorGroup) rankedBefore(a, b *Validator) bool {
+	if sa, sb := vg.SelectionScore(a), vg.SelectionScore(b); sa != sb {
+		return sa > sb
+	}
+	return a.ID < b.ID
+}
+
+// pickWithExploration 从按选取得分降序排列的节点中选出 GroupSize 个验证器
+// 前 GroupSize-explore 个席位按排名选取，其余席位在剩余合格节点中随机选取
+func (vg *ValidatorGroup) pickWithExploration(ranked []*Validator) []*Validator {
+	explore := int(float64(vg.GroupSize) * vg.ExplorationFraction)
+	if explore <= 0 {
+		return ranked[:vg.GroupSize]
+	}
+	if explore > vg.GroupSize {
+		explore = vg.GroupSize
+	}
+
+	top := vg.GroupSize - explore
+	selected := make([]*Validator, 0, vg.GroupSize)
+	selected = append(selected, ranked[:top]...)
+
+	// 收集剩余节点中满足最低信誉要求的候选者
+	var eligible, rest []*Validator
+	for _, v := range ranked[top:] {
+		if v.Reputation >= vg.MinReputation {
+			eligible = append(eligible, v)
+		} else {
+			rest = append(rest, v)
+		}
+	}
+
+	vg.rng.Shuffle(len(eligible), func(i, j int) {
+		eligible[i], eligible[j] = eligible[j], eligible[i]
+	})
+	if len(eligible) > explore {
+		// 未被抽中的合格节点仍按信誉排名参与补位
+		remaining := eligible[explore:]
+		sort.Slice(remaining, func(i, j int) bool {
+			return vg.rankedBefore(remaining[i], remaining[j])
+		})
+		rest = append(remaining, rest...)
+		eligible = eligible[:explore]
+	}
+	selected = append(selected, eligible...)
+
+	// 合格节点不足时，按排名补齐
+	for _, v := range rest {
+		if len(selected) >= vg.GroupSize {
+			break
+		}
+		selected = append(selected, v)
+	}
+
+	// 保持验证器列表按选取得分降序排列
+	sort.Slice(selected, func(i, j int) bool {
+		return vg.rankedBefore(selected[i], selected[j])
+	})
+	return selected
+}
+
 // IsActive 判断验证器组是否仍然活跃
 func (vg *ValidatorGroup) IsActive() bool {
 	return vg.CurrentRound < vg.ActivePeriod
@@ -86,6 +259,26 @@ func (vg *ValidatorGroup) NeedRefresh() bool {
 	return !vg.IsActive() || len(vg.Validators) == 0
 }
 
+// ShouldEvict 返回当前信誉值低于 EvictionThreshold 的验证器ID（按验证器列表顺序）
+// 返回非空时调用方应立即刷新验证器组，而不是等到活跃周期结束；
+// 未设置阈值或验证器没有对应的信誉服务时不报告
+func (vg *ValidatorGroup) ShouldEvict(
+	reputationManagers map[string]reputation.ReputationProvider,
+	now time.Time,
+) []string {
+	if vg.EvictionThreshold <= 0 {
+		return nil
+	}
+	var evicted []string
+	for _, v := range vg.Validators {
+		rm := reputationManagers[v.ID]
+		if rm != nil && rm.ComputeReputation(v.ID, now) < vg.EvictionThreshold {
+			evicted = append(evicted, v.ID)
+		}
+	}
+	return evicted
+}
+
 // GetValidatorIDs 获取所有验证器节点的ID列表
 func (vg *ValidatorGroup) GetValidatorIDs() []string {
 	ids := make([]string, len(vg.Validators))
@@ -95,6 +288,34 @@ func (vg *ValidatorGroup) GetValidatorIDs() []string {
 	return ids
 }
 
+// GetValidatorReputations 获取验证器节点信誉值快照 [节点ID]信誉值
+func (vg *ValidatorGroup) GetValidatorReputations() map[string]float64 {
+	reps := make(map[string]float64, len(vg.Validators))
+	for _, v := range vg.Validators {
+		reps[v.ID] = v.Reputation
+	}
+	return reps
+}
+
+// RegisterPublicKey 登记节点的签名公钥
+func (vg *ValidatorGroup) RegisterPublicKey(nodeID string, key ed25519.PublicKey) {
+	if vg.publicKeys == nil {
+		vg.publicKeys = make(map[string]ed25519.PublicKey)
+	}
+	vg.publicKeys[nodeID] = key
+}
+
+// PublicKeys 返回指定节点已登记的签名公钥，未登记的节点不包含在结果中
+func (vg *ValidatorGroup) PublicKeys(nodeIDs []string) map[string]ed25519.PublicKey {
+	keys := make(map[string]ed25519.PublicKey, len(nodeIDs))
+	for _, id := range nodeIDs {
+		if key, ok := vg.publicKeys[id]; ok {
+			keys[id] = key
+		}
+	}
+	return keys
+}
+
 // IsValidator 判断节点是否是验证器节点
 func (vg *ValidatorGroup) IsValidator(nodeID string) bool {
 	for _, v := range vg.Validators {
@@ -120,34 +341,128 @@ func (vg *ValidatorGroup) GetSize() int {
 	return len(vg.Validators)
 }
 
+// ByzantineTolerance 返回当前验证器组在 BFT 下可容忍的拜占庭验证器数 f=(N-1)/3
+// 与 BFT 投票阈值使用同一个 f；验证器组为空时为 0
+func (vg *ValidatorGroup) ByzantineTolerance() int {
+	if vg.GetSize() == 0 {
+		return 0
+	}
+	return (vg.GetSize() - 1) / 3
+}
+
+// IsSafe 判断当前验证器组中已知的恶意节点数是否未超过可容忍的拜占庭验证器数 f
+// knownMalicious 中不在验证器组内的节点不计入，重复的ID只计一次
+func (vg *ValidatorGroup) IsSafe(knownMalicious []string) bool {
+	counted := make(map[string]bool, len(knownMalicious))
+	for _, id := range knownMalicious {
+		if vg.IsValidator(id) {
+			counted[id] = true
+		}
+	}
+	return len(counted) <= vg.ByzantineTolerance()
+}
+
 // SelectProposer 选择出块节点
-// 根据信誉值和紧急度选择信誉值最高的节点作为出块者
+// 选择选取得分（默认即信誉值）最高的验证器节点作为出块者；
+// 设置了 ProposerCooldown 时跳过冷却中的验证器，每次调用计为一轮
 func (vg *ValidatorGroup) SelectProposer() *Validator {
 	if len(vg.Validators) == 0 {
 		return nil
 	}
 
-	// 选择信誉值最高的验证器节点作为出块者
-	proposer := vg.Validators[0]
+	// 选择得分最高的验证器节点作为出块者，得分相同时取节点ID最小者
+	var proposer, best *Validator
 	for _, v := range vg.Validators {
-		if v.Reputation > proposer.Reputation {
+		if best == nil || vg.rankedBefore(v, best) {
+			best = v
+		}
+		if !vg.inCooldown(v.ID) && (proposer == nil || vg.rankedBefore(v, proposer)) {
 			proposer = v
 		}
 	}
+	// 所有验证器都在冷却中时放宽限制
+	if proposer == nil {
+		proposer = best
+	}
+
+	if vg.lastProposed == nil {
+		vg.lastProposed = make(map[string]int)
+	}
+	vg.proposerRound++
+	vg.lastProposed[proposer.ID] = vg.proposerRound
+	return proposer
+}
+
+// inCooldown 判断验证器是否在最近 ProposerCooldown 轮内出过块
+func (vg *ValidatorGroup) inCooldown(nodeID string) bool {
+	last, ok := vg.lastProposed[nodeID]
+	return ok && vg.proposerRound+1-last <= vg.ProposerCooldown
+}
+
+// SelectProposerWeighted 按选取得分加权随机选择出块节点，得分为 0 的验证器不会被选中
+// 使用验证器组的随机源（可由 SetSeed 固定）；所有验证器得分均为 0 时退化为 SelectProposer
+func (vg *ValidatorGroup) SelectProposerWeighted() *Validator {
+	var total float64
+	for _, v := range vg.Validators {
+		total += vg.SelectionScore(v)
+	}
+	if total <= 0 {
+		return vg.SelectProposer()
+	}
+
+	r := vg.rng.Float64() * total
+	var last *Validator
+	for _, v := range vg.Validators {
+		score := vg.SelectionScore(v)
+		if score <= 0 {
+			continue
+		}
+		if r < score {
+			return v
+		}
+		r -= score
+		last = v
+	}
+	// 浮点误差导致 r 未落入任何区间时取最后一个得分为正的验证器
+	return last
+}
 
+// SelectProposerRoundRobin 按验证器列表顺序轮流选择出块节点
+// 轮转位置跨验证器组刷新保留，刷新后从新列表的相同位置继续
+func (vg *ValidatorGroup) SelectProposerRoundRobin() *Validator {
+	if len(vg.Validators) == 0 {
+		return nil
+	}
+	proposer := vg.Validators[vg.proposerTurn%len(vg.Validators)]
+	vg.proposerTurn++
 	return proposer
 }
 
+// NextProposer 按 ProposerStrategy 选择下一个出块节点，验证器组为空时返回 nil
+func (vg *ValidatorGroup) NextProposer() *Validator {
+	switch vg.ProposerStrategy {
+	case RoundRobin:
+		return vg.SelectProposerRoundRobin()
+	case WeightedRandom:
+		return vg.SelectProposerWeighted()
+	default:
+		return vg.SelectProposer()
+	}
+}
+
 // PenalizeInactiveValidators 惩罚不活跃的验证器节点
-// 如果验证器节点在 N 个区块周期内没有参与验证，将被移除
+// 如果验证器节点在 N 个区块周期内没有参与验证，将被移除；
+// 补充候选节点后组大小仍低于 MinSize 时，按原有顺序保留部分不活跃的验证器，
+// 避免验证器组缩小到失去拜占庭容错能力
 func (vg *ValidatorGroup) PenalizeInactiveValidators(
 	inactiveValidators []string,
-	reputationManagers map[string]*reputation.ReputationManager,
+	reputationManagers map[string]reputation.ReputationProvider,
 	newCandidates []string,
 	now time.Time,
 ) {
 	// 移除不活跃的验证器节点
 	activeValidators := make([]*Validator, 0)
+	var removed []*Validator
 	for _, v := range vg.Validators {
 		isInactive := false
 		for _, inactive := range inactiveValidators {
@@ -158,6 +473,8 @@ func (vg *ValidatorGroup) PenalizeInactiveValidators(
 		}
 		if !isInactive {
 			activeValidators = append(activeValidators, v)
+		} else {
+			removed = append(removed, v)
 		}
 	}
 
@@ -177,9 +494,9 @@ func (vg *ValidatorGroup) PenalizeInactiveValidators(
 			}
 		}
 
-		// 按信誉值降序排序
+		// 按选取得分降序排序，得分相同时按节点ID升序
 		sort.Slice(candidateReputation, func(i, j int) bool {
-			return candidateReputation[i].Reputation > candidateReputation[j].Reputation
+			return vg.rankedBefore(candidateReputation[i], candidateReputation[j])
 		})
 
 		// 补充前 needed 个候选节点
@@ -189,5 +506,13 @@ func (vg *ValidatorGroup) PenalizeInactiveValidators(
 		activeValidators = append(activeValidators, candidateReputation[:needed]...)
 	}
 
+	// 不低于最少节点数：候选节点不足时保留部分不活跃的验证器
+	for _, v := range removed {
+		if len(activeValidators) >= vg.MinSize {
+			break
+		}
+		activeValidators = append(activeValidators, v)
+	}
+
 	vg.Validators = activeValidators
 }
@@ -0,0 +1,354 @@
+package emergency
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"block/reputation"
+)
+
+// fakeReputation 返回固定信誉值并记录收到的交互，用于隔离共识与验证器逻辑
+type fakeReputation struct {
+	mutex        sync.Mutex
+	reputations  map[string]float64
+	interactions []reputation.Interaction
+}
+
+func (f *fakeReputation) ComputeReputation(id string, now time.Time) float64 {
+	return f.reputations[id]
+}
+
+func (f *fakeReputation) AddInteraction(inter reputation.Interaction) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.interactions = append(f.interactions, inter)
+	return nil
+}
+
+// providers 为每个节点返回同一个 fakeReputation
+func (f *fakeReputation) providers(ids ...string) map[string]reputation.ReputationProvider {
+	m := make(map[string]reputation.ReputationProvider, len(ids))
+	for _, id := range ids {
+		m[id] = f
+	}
+	return m
+}
+
+func TestSelectValidatorsWithFakeReputation(t *testing.T) {
+	fake := &fakeReputation{reputations: map[string]float64{"1": 0.2, "2": 0.9, "3": 0.5, "4": 0.7, "5": 0.8}}
+	vg := NewValidatorGroup(3, 10)
+	vg.SelectValidators([]string{"1", "2", "3", "4", "5"}, fake.providers("1", "2", "3", "4", "5"), time.Now())
+
+	want := []string{"2", "5", "4"}
+	got := vg.GetValidatorIDs()
+	if len(got) != len(want) {
+		t.Fatalf("验证器 = %v, 期望 %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("验证器 = %v, 期望 %v", got, want)
+		}
+	}
+	if p := vg.SelectProposer(); p == nil || p.ID != "2" {
+		t.Errorf("出块者 = %v, 期望信誉最高的节点 2", p)
+	}
+}
+
+func TestPenalizeKeepsMinimumGroupSize(t *testing.T) {
+	newGroup := func() *ValidatorGroup {
+		vg := NewValidatorGroup(4, 10)
+		for _, id := range []string{"1", "2", "3", "4"} {
+			vg.Validators = append(vg.Validators, &Validator{ID: id, Reputation: 0.9})
+		}
+		return vg
+	}
+	rms := (&fakeReputation{reputations: map[string]float64{"5": 0.5}}).providers("5")
+	now := time.Now()
+
+	// 没有候选节点：不活跃的验证器全部保留，组大小不低于 MinSize
+	vg := newGroup()
+	vg.PenalizeInactiveValidators([]string{"1", "2"}, rms, nil, now)
+	if got := vg.GetValidatorIDs(); len(got) != 4 {
+		t.Fatalf("验证器组 = %v, 期望保持 4 个", got)
+	}
+
+	// 只有 1 个候选节点：补充后仍差 1 个，按原顺序保留不活跃的验证器 1
+	vg = newGroup()
+	vg.PenalizeInactiveValidators([]string{"1", "2"}, rms, []string{"5"}, now)
+	want := []string{"3", "4", "5", "1"}
+	got := vg.GetValidatorIDs()
+	if len(got) != len(want) {
+		t.Fatalf("验证器组 = %v, 期望 %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("验证器组 = %v, 期望 %v", got, want)
+		}
+	}
+
+	// 降低 MinSize 后允许缩小
+	vg = newGroup()
+	vg.MinSize = 2
+	vg.PenalizeInactiveValidators([]string{"1", "2"}, rms, nil, now)
+	if got := vg.GetValidatorIDs(); len(got) != 2 {
+		t.Errorf("MinSize=2 时验证器组 = %v, 期望缩小到 2 个", got)
+	}
+}
+
+func TestEqualReputationsBreakTiesByID(t *testing.T) {
+	ids := []string{"7", "3", "9", "1", "5", "2"}
+	fake := &fakeReputation{reputations: map[string]float64{}}
+	for _, id := range ids {
+		fake.reputations[id] = reputation.InitialReputation
+	}
+	now := time.Now()
+
+	// 节点顺序不同、重复选取多次，验证器组均按ID排序且结果一致
+	want := []string{"1", "2", "3", "5"}
+	for i := 0; i < 20; i++ {
+		order := append([]string(nil), ids...)
+		if i%2 == 1 {
+			for l, r := 0, len(order)-1; l < r; l, r = l+1, r-1 {
+				order[l], order[r] = order[r], order[l]
+			}
+		}
+		vg := NewValidatorGroup(4, 10)
+		vg.SelectValidators(order, fake.providers(ids...), now)
+		got := vg.GetValidatorIDs()
+		if len(got) != len(want) {
+			t.Fatalf("验证器 = %v, 期望 %v", got, want)
+		}
+		for j := range want {
+			if got[j] != want[j] {
+				t.Fatalf("第 %d 次选取验证器 = %v, 期望 %v", i+1, got, want)
+			}
+		}
+		if p := vg.SelectProposer(); p.ID != "1" {
+			t.Fatalf("出块者 = %s, 期望ID最小的节点 1", p.ID)
+		}
+
+		// 补充候选节点时同样按ID打破平局
+		vg.PenalizeInactiveValidators([]string{"1"}, fake.providers(ids...), []string{"9", "7"}, now)
+		if got := vg.GetValidatorIDs(); got[len(got)-1] != "7" {
+			t.Fatalf("补充的验证器 = %v, 期望ID较小的节点 7", got)
+		}
+	}
+}
+
+func TestByzantineTolerance(t *testing.T) {
+	tests := []struct {
+		size      int
+		tolerance int
+	}{
+		{0, 0}, {1, 0}, {3, 0}, {4, 1}, {6, 1}, {7, 2}, {10, 3}, {13, 4},
+	}
+	for _, tt := range tests {
+		vg := NewValidatorGroup(tt.size, 10)
+		var ids []string
+		for i := 0; i < tt.size; i++ {
+			id := fmt.Sprintf("v%d", i)
+			ids = append(ids, id)
+			vg.Validators = append(vg.Validators, &Validator{ID: id, Reputation: 0.9})
+		}
+		if got := vg.ByzantineTolerance(); got != tt.tolerance {
+			t.Errorf("N=%d: ByzantineTolerance = %d, 期望 %d", tt.size, got, tt.tolerance)
+		}
+
+		// 恰好 f 个恶意验证器时安全，再多一个即不安全；组外节点与重复ID不计入
+		malicious := append([]string(nil), ids[:tt.tolerance]...)
+		malicious = append(malicious, "outsider")
+		if tt.tolerance > 0 {
+			malicious = append(malicious, ids[0])
+		}
+		if !vg.IsSafe(malicious) {
+			t.Errorf("N=%d: %d 个恶意验证器应在容忍范围内", tt.size, tt.tolerance)
+		}
+		if tt.size > tt.tolerance && vg.IsSafe(ids[:tt.tolerance+1]) {
+			t.Errorf("N=%d: %d 个恶意验证器应判定为不安全", tt.size, tt.tolerance+1)
+		}
+	}
+}
+
+func TestStakeShiftsSelectedValidators(t *testing.T) {
+	ids := []string{"1", "2", "3", "4", "5"}
+	fake := &fakeReputation{reputations: map[string]float64{"1": 0.9, "2": 0.8, "3": 0.7, "4": 0.6, "5": 0.5}}
+	stake := map[string]float64{"1": 1, "2": 1, "3": 50, "4": 40, "5": 0}
+	now := time.Now()
+
+	selectWith := func(a, b float64) *ValidatorGroup {
+		vg := NewValidatorGroup(2, 10)
+		vg.Stake = stake
+		vg.ReputationExponent, vg.StakeExponent = a, b
+		vg.SelectValidators(ids, fake.providers(ids...), now)
+		return vg
+	}
+	check := func(vg *ValidatorGroup, want []string, wantProposer string) {
+		t.Helper()
+		if got := vg.GetValidatorIDs(); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("指数 (%g,%g) 下验证器 = %v, 期望 %v", vg.ReputationExponent, vg.StakeExponent, got, want)
+		}
+		if p := vg.SelectProposer(); p == nil || p.ID != wantProposer {
+			t.Errorf("指数 (%g,%g) 下出块者 = %v, 期望 %s", vg.ReputationExponent, vg.StakeExponent, p, wantProposer)
+		}
+	}
+
+	// 默认指数 (1,0) 即使设置了质押也与纯信誉选取一致
+	check(selectWith(1, 0), []string{"1", "2"}, "1")
+	// 计入质押后高质押节点进入验证器组：3 得分 0.7×50=35，4 得分 0.6×40=24
+	check(selectWith(1, 1), []string{"3", "4"}, "3")
+	// 纯质押 (0,1)
+	check(selectWith(0, 1), []string{"3", "4"}, "3")
+}
+
+func TestSelectProposerWeightedFollowsScores(t *testing.T) {
+	vg := NewValidatorGroup(3, 10)
+	vg.SetSeed(42)
+	vg.Validators = []*Validator{{ID: "1", Reputation: 0.9}, {ID: "2", Reputation: 0.9}, {ID: "3", Reputation: 0.9}}
+	vg.Stake = map[string]float64{"1": 3, "2": 1, "3": 0}
+	vg.StakeExponent = 1
+
+	counts := make(map[string]int)
+	const draws = 4000
+	for i := 0; i < draws; i++ {
+		counts[vg.SelectProposerWeighted().ID]++
+	}
+	if counts["3"] != 0 {
+		t.Errorf("质押为 0 的验证器被选为出块者 %d 次", counts["3"])
+	}
+	// 得分比 3:1，节点 1 被选中的比例约为 0.75
+	if ratio := float64(counts["1"]) / draws; math.Abs(ratio-0.75) > 0.05 {
+		t.Errorf("节点 1 被选中的比例 = %.3f, 期望约 0.75", ratio)
+	}
+
+	// 所有得分为 0 时退化为确定性选择
+	vg.Stake = nil
+	if p := vg.SelectProposerWeighted(); p.ID != "1" {
+		t.Errorf("得分全为 0 时出块者 = %s, 期望 1", p.ID)
+	}
+}
+
+func TestNextProposerFollowsStrategy(t *testing.T) {
+	vg := NewValidatorGroup(3, 10)
+	vg.SetSeed(7)
+	vg.Validators = []*Validator{{ID: "1", Reputation: 0.9}, {ID: "2", Reputation: 0.8}, {ID: "3", Reputation: 0}}
+
+	pick := func(n int) []string {
+		ids := make([]string, n)
+		for i := range ids {
+			ids[i] = vg.NextProposer().ID
+		}
+		return ids
+	}
+
+	// 默认策略始终选择得分最高的验证器
+	if got := fmt.Sprint(pick(3)); got != "[1 1 1]" {
+		t.Errorf("highest-reputation 出块者 = %s, 期望 [1 1 1]", got)
+	}
+
+	// 轮流出块包括得分为 0 的验证器，刷新验证器组后从相同位置继续
+	vg.ProposerStrategy = RoundRobin
+	if got := fmt.Sprint(pick(3)); got != "[1 2 3]" {
+		t.Errorf("round-robin 出块者 = %s, 期望 [1 2 3]", got)
+	}
+	vg.Validators = []*Validator{{ID: "4", Reputation: 0.9}, {ID: "5", Reputation: 0.9}}
+	if got := fmt.Sprint(pick(2)); got != "[5 4]" {
+		t.Errorf("刷新后 round-robin 出块者 = %s, 期望 [5 4]", got)
+	}
+
+	// 加权随机不会选中得分为 0 的验证器
+	vg.ProposerStrategy = WeightedRandom
+	vg.Validators = []*Validator{{ID: "1", Reputation: 0.9}, {ID: "2", Reputation: 0.8}, {ID: "3", Reputation: 0}}
+	for _, id := range pick(200) {
+		if id == "3" {
+			t.Fatal("weighted-random 选中了得分为 0 的验证器")
+		}
+	}
+
+	for _, s := range ProposerStrategies {
+		if parsed, err := ParseProposerStrategy(s.String()); err != nil || parsed != s {
+			t.Errorf("ParseProposerStrategy(%q) = %v, %v", s, parsed, err)
+		}
+	}
+	if _, err := ParseProposerStrategy("lottery"); !errors.Is(err, ErrUnknownProposerStrategy) {
+		t.Errorf("未知策略的错误 = %v, 期望 ErrUnknownProposerStrategy", err)
+	}
+}
+
+func TestProposerCooldown(t *testing.T) {
+	vg := NewValidatorGroup(4, 10)
+	vg.Validators = []*Validator{
+		{ID: "1", Reputation: 0.9}, {ID: "2", Reputation: 0.8}, {ID: "3", Reputation: 0.7}, {ID: "4", Reputation: 0.6},
+	}
+	vg.ProposerCooldown = 2
+
+	var ids []string
+	for i := 0; i < 9; i++ {
+		ids = append(ids, vg.SelectProposer().ID)
+	}
+	// 冷却期内由得分次高者出块，冷却结束后得分最高者重新出块
+	if got := fmt.Sprint(ids); got != "[1 2 3 1 2 3 1 2 3]" {
+		t.Errorf("冷却 2 轮时出块者 = %s, 期望 [1 2 3 1 2 3 1 2 3]", got)
+	}
+	for i := range ids {
+		for j := i + 1; j <= i+vg.ProposerCooldown && j < len(ids); j++ {
+			if ids[i] == ids[j] {
+				t.Errorf("验证器 %s 在第 %d 轮与第 %d 轮都出块，未遵守冷却", ids[i], i+1, j+1)
+			}
+		}
+	}
+
+	// 所有验证器都在冷却中时放宽限制，仍选出得分最高者
+	vg.Validators = vg.Validators[:2]
+	vg.ProposerCooldown = 5
+	if p := vg.SelectProposer(); p == nil || p.ID != "1" {
+		t.Errorf("全部冷却时出块者 = %v, 期望 1", p)
+	}
+}
+
+func TestValidatorEvictedMidPeriod(t *testing.T) {
+	ids := []string{"1", "2", "3", "4", "5", "6"}
+	fake := &fakeReputation{reputations: map[string]float64{
+		"1": 0.9, "2": 0.85, "3": 0.8, "4": 0.75, "5": 0.7, "6": 0.65,
+	}}
+	vg := NewValidatorGroup(4, 10)
+	vg.EvictionThreshold = 0.3
+	vg.SelectValidators(ids, fake.providers(ids...), time.Now())
+
+	evictedAt := -1
+	for r := 1; r < vg.ActivePeriod; r++ {
+		vg.IncrementRound()
+		// 第 3 轮节点 2 开始作恶，信誉值骤降
+		if r == 3 {
+			fake.reputations["2"] = 0.1
+		}
+		if len(vg.ShouldEvict(fake.providers(ids...), time.Now())) > 0 {
+			if vg.NeedRefresh() {
+				t.Fatalf("第 %d 轮活跃周期尚未结束，NeedRefresh 不应为 true", r)
+			}
+			evictedAt = r
+			vg.SelectValidators(ids, fake.providers(ids...), time.Now())
+			break
+		}
+	}
+
+	if evictedAt != 3 {
+		t.Fatalf("节点 2 在第 %d 轮被驱逐, 期望作恶当轮（第 3 轮）", evictedAt)
+	}
+	if vg.IsValidator("2") || !vg.IsValidator("5") {
+		t.Errorf("驱逐后验证器 = %v, 期望节点 2 被节点 5 替换", vg.GetValidatorIDs())
+	}
+	if got := vg.ShouldEvict(fake.providers(ids...), time.Now()); got != nil {
+		t.Errorf("刷新后 ShouldEvict = %v, 期望为空", got)
+	}
+
+	// 未设置阈值时不检查
+	vg.EvictionThreshold = 0
+	fake.reputations["1"] = 0
+	if got := vg.ShouldEvict(fake.providers(ids...), time.Now()); got != nil {
+		t.Errorf("未设置阈值时 ShouldEvict = %v, 期望为空", got)
+	}
+}
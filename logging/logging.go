@@ -0,0 +1,112 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Level 日志级别，数值越大输出越详细
+type Level int
+
+const (
+	// LevelError 只输出错误
+	LevelError Level = iota
+	// LevelWarn 输出错误与警告（如无效签名、被丢弃的消息）
+	LevelWarn
+	// LevelInfo 输出每轮汇总等常规运行信息（默认）
+	LevelInfo
+	// LevelDebug 输出逐节点对的意见计算、逐笔交易评价等调试信息
+	LevelDebug
+)
+
+// levelNames 各级别在配置文件中的名称
+var levelNames = map[Level]string{
+	LevelError: "error",
+	LevelWarn:  "warn",
+	LevelInfo:  "info",
+	LevelDebug: "debug",
+}
+
+// ErrUnknownLevel 日志级别名称不合法
+var ErrUnknownLevel = errors.New("未知的日志级别")
+
+// String 返回日志级别的名称
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("Level(%d)", int(l))
+}
+
+// ParseLevel 解析日志级别名称（error/warn/info/debug）
+func ParseLevel(name string) (Level, error) {
+	for level, n := range levelNames {
+		if n == name {
+			return level, nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("%w: %q（error/warn/info/debug）", ErrUnknownLevel, name)
+}
+
+// Logger 分级日志接口，低于阈值的消息被丢弃
+// 消息格式与 fmt.Printf 相同，需自行包含换行
+type Logger interface {
+	Errorf(format string, args ...any)
+	Warnf(format string, args ...any)
+	Infof(format string, args ...any)
+	Debugf(format string, args ...any)
+}
+
+// Leveled 按级别过滤后写入 io.Writer 的 Logger，可被多个协程并发使用
+type Leveled struct {
+	mutex sync.Mutex
+	w     io.Writer
+	level Level
+}
+
+// New 创建输出到 w、阈值为 level 的日志
+func New(w io.Writer, level Level) *Leveled {
+	return &Leveled{w: w, level: level}
+}
+
+// Discard 返回丢弃所有消息的日志
+func Discard() *Leveled {
+	return New(io.Discard, LevelError)
+}
+
+// SetLevel 修改日志阈值，用于加载配置后调整启动阶段创建的日志
+func (l *Leveled) SetLevel(level Level) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.level = level
+}
+
+// Enabled 判断 level 级别的消息是否会被输出
+func (l *Leveled) Enabled(level Level) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return level <= l.level
+}
+
+func (l *Leveled) logf(level Level, format string, args ...any) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if level > l.level {
+		return
+	}
+	fmt.Fprintf(l.w, format, args...)
+}
+
+// Errorf 输出错误消息
+func (l *Leveled) Errorf(format string, args ...any) { l.logf(LevelError, format, args...) }
+
+// Warnf 输出警告消息
+func (l *Leveled) Warnf(format string, args ...any) { l.logf(LevelWarn, format, args...) }
+
+// Infof 输出常规运行信息
+func (l *Leveled) Infof(format string, args ...any) { l.logf(LevelInfo, format, args...) }
+
+// Debugf 输出调试信息
+func (l *Leveled) Debugf(format string, args ...any) { l.logf(LevelDebug, format, args...) }
@@ -0,0 +1,42 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestLeveledSuppressesBelowThreshold(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, LevelInfo)
+	l.Errorf("e\n")
+	l.Warnf("w\n")
+	l.Infof("i %d\n", 1)
+	l.Debugf("d\n")
+	if got := buf.String(); got != "e\nw\ni 1\n" {
+		t.Errorf("info 级别输出 %q，期望不含调试信息", got)
+	}
+
+	buf.Reset()
+	l.SetLevel(LevelError)
+	l.Warnf("w\n")
+	l.Errorf("e\n")
+	if got := buf.String(); got != "e\n" {
+		t.Errorf("error 级别输出 %q，期望只有错误", got)
+	}
+	if l.Enabled(LevelWarn) || !l.Enabled(LevelError) {
+		t.Error("Enabled 与阈值不符")
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for _, level := range []Level{LevelError, LevelWarn, LevelInfo, LevelDebug} {
+		got, err := ParseLevel(level.String())
+		if err != nil || got != level {
+			t.Errorf("ParseLevel(%q) = %v, %v", level.String(), got, err)
+		}
+	}
+	if _, err := ParseLevel("verbose"); !errors.Is(err, ErrUnknownLevel) {
+		t.Errorf("未知级别应返回 ErrUnknownLevel，实际 %v", err)
+	}
+}
@@ -3,20 +3,24 @@ package main
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
 	"math"
 	"math/rand"
 	"os"
+	"os/signal"
+	"slices"
 	"sort"
-	"strconv"
 	"sync"
+	"syscall"
 	"time"
 
 	"block/config"
+	"block/dataloader"
+	"block/logging"
 	"block/reputation"
-
-	"github.com/xuri/excelize/v2"
+	"block/roundlog"
 )
 
 // -------- PBFT 区块链部分 --------
@@ -51,6 +55,11 @@ type Node struct {
 	mutex  sync.Mutex
 	view   int
 	seq    int
+
+	// committed 已确认的 (View, Seq)，重复的提交消息不会再次追加区块
+	committed map[[2]int]bool
+	// committedSeq 已确认的最高序号
+	committedSeq int
 }
 
 func NewNode(id string, cfg config.Config) *Node {
@@ -66,13 +75,36 @@ func (n *Node) Broadcast(msg Message) {
 func (n *Node) Receive(msg Message) {
 	n.mutex.Lock()
 	defer n.mutex.Unlock()
-	if msg.Type == Commit {
-		n.ledger = append(n.ledger, msg.Block)
+	if msg.Type != Commit {
+		return
+	}
+	// 同一 (View, Seq) 只确认一次，防止重放或重复投递的提交消息使同一区块重复上链
+	key := [2]int{msg.View, msg.Seq}
+	if n.committed[key] {
+		return
+	}
+	if n.committed == nil {
+		n.committed = make(map[[2]int]bool)
 	}
+	n.committed[key] = true
+	if msg.Seq > n.committedSeq {
+		n.committedSeq = msg.Seq
+	}
+	n.ledger = append(n.ledger, msg.Block)
+}
+
+// CommittedSeq 返回本节点已确认的最高序号
+func (n *Node) CommittedSeq() int {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.committedSeq
 }
 
 func (n *Node) Propose(data []byte) {
-	n.seq++
+	// 提议者轮换，序号在已确认的最高序号之后继续递增，避免与其他提议者的区块冲突
+	n.mutex.Lock()
+	n.seq = max(n.seq, n.committedSeq) + 1
+	n.mutex.Unlock()
 	block := Block{Index: len(n.ledger) + 1, Timestamp: time.Now(), Data: data, PrevHash: n.lastHash()}
 	h := sha256.Sum256(append([]byte(block.PrevHash), data...))
 	block.Hash = hex.EncodeToString(h[:])
@@ -89,53 +121,59 @@ func (n *Node) lastHash() string {
 	return n.ledger[len(n.ledger)-1].Hash
 }
 
-// RawData 从 Excel 导入的轨迹数据（包含时间戳）
-type RawData struct {
-	VehicleID    string
-	Time         float64 // 单位：秒
-	X            float64
-	Y            float64
-	Speed        float64
-	Acceleration float64
-}
+// 恶意节点配置：各节点的作恶概率，从配置 maliceProbabilities 加载
+// 作恶概率为该节点每笔交易是恶意交易的概率，1.0 为完全恶意，未列出的节点为诚实节点
+var maliceProbabilities = map[string]float64{}
 
-// 随机交互配置
-const (
-	// 交互概率配置（总和应为100）
-	NoInteractionProb      = 70 // 没有交互的概率：70%
-	OneInteractionProb     = 20 // 1次交互的概率：20%
-	MultiInteractionProb   = 10 // 多次交互的概率：10%
-	MaxInteractionsPerPair = 5  // 多次交互时的最大次数
-)
+// 判断节点是否为恶意节点（作恶概率大于 0）
+func isMalicious(nodeID string) bool {
+	return maliceProbabilities[nodeID] > 0
+}
 
-// 恶意节点配置：设置哪些节点是恶意的
-var maliciousNodes = map[string]bool{
-	"3": true, // 将节点3设为恶意节点
-	// 可以添加更多恶意节点，例如: "7": true,
+// isMaliciousTx 按发送者的作恶概率判定本笔交易是否为恶意交易
+func isMaliciousTx(sender string) bool {
+	p := maliceProbabilities[sender]
+	return p > 0 && rand.Float64() < p
 }
 
-// 判断节点是否为恶意节点
-func isMalicious(nodeID string) bool {
-	return maliciousNodes[nodeID]
+// minNodes 运行模拟所需的最少节点数（车辆与 RSU 之和）：每笔交互需要一个不同于发送者的接收者
+const minNodes = 2
+
+// ErrTooFewNodes 节点数不足，无法产生任何交互
+var ErrTooFewNodes = errors.New("节点数不足")
+
+// checkNodeCount 检查节点数是否足以运行模拟：至少一辆车发送交易，且车辆与 RSU 共至少 minNodes 个
+func checkNodeCount(vehicleIDs, rsuIDs []string) error {
+	if len(vehicleIDs) == 0 {
+		return fmt.Errorf("%w: 未找到任何车辆数据", ErrTooFewNodes)
+	}
+	if n := len(vehicleIDs) + len(rsuIDs); n < minNodes {
+		return fmt.Errorf("%w: 共 %d 个节点，至少需要 %d 个节点才能产生交互", ErrTooFewNodes, n, minNodes)
+	}
+	return nil
 }
 
-// getRandomInteractionCount 返回随机的交互次数
-// 70%概率返回0（没有交互）
-// 20%概率返回1（单次交互）
-// 10%概率返回2-5（多次交互）
-func getRandomInteractionCount() int {
+// getRandomInteractionCount 按配置的交互分布返回随机的交互次数
+// NoInteractionProb% 概率没有交互，OneInteractionProb% 概率 1 次，
+// 其余 MultiInteractionProb% 概率 2~MaxInteractionsPerPair 次
+func getRandomInteractionCount(cfg config.Config) int {
 	r := rand.Intn(100)
-	if r < NoInteractionProb {
-		return 0 // 70%概率没有交互
-	} else if r < NoInteractionProb+OneInteractionProb {
-		return 1 // 20%概率1次交互
+	if r < cfg.NoInteractionProb {
+		return 0
+	} else if r < cfg.NoInteractionProb+cfg.OneInteractionProb {
+		return 1
 	} else {
-		// 10%概率2-5次交互
-		return 2 + rand.Intn(MaxInteractionsPerPair-1)
+		return 2 + rand.Intn(cfg.MaxInteractionsPerPair-1)
 	}
 }
 
 func main() {
+	logJSON := flag.Bool("logjson", false, "额外输出结构化日志 reputation_log.jsonl（每轮一行 JSON）")
+	dataPath := flag.String("data", "data.xlsx", "轨迹数据文件路径（.xlsx 或 .csv）")
+	dotPath := flag.String("dot", "", "运行结束后将信任图以 GraphViz DOT 格式写入该文件（为空则不导出）")
+	dotMinTrust := flag.Float64("dotmin", 0, "导出信任图时只保留直接意见信任度 T 不低于该值的边")
+	converge := flag.Bool("converge", false, "信誉值收敛（见配置 convergenceEpsilon/convergenceRounds）后提前结束模拟")
+	flag.Parse()
 
 	rand.Seed(time.Now().UnixNano())
 
@@ -147,105 +185,79 @@ func main() {
 	}
 	defer logFile.Close()
 
-	// 设置日志输出到文件和控制台
-	multiWriter := os.Stdout
-	log.SetOutput(logFile)
-	log.SetFlags(0) // 不显示时间戳
+	// 详细日志写入文件，简要进度输出到控制台，加载配置后按 logLevel 过滤
+	fileLog := logging.New(logFile, logging.LevelInfo)
+	console := logging.New(os.Stdout, logging.LevelInfo)
 
 	// 记录开始时间
-	log.Printf("========================================\n")
-	log.Printf("信誉系统启动时间: %s\n", time.Now().Format("2006-01-02 15:04:05"))
-	log.Printf("========================================\n\n")
+	fileLog.Infof("========================================\n")
+	fileLog.Infof("信誉系统启动时间: %s\n", time.Now().Format("2006-01-02 15:04:05"))
+	fileLog.Infof("========================================\n\n")
 
 	// 加载配置
 	cfg, err := config.LoadConfig("config/config.json")
 	if err != nil {
-		log.Printf("错误: 加载配置失败: %v\n", err)
-		fmt.Println("加载配置失败:", err)
+		fileLog.Errorf("错误: 加载配置失败: %v\n", err)
+		console.Errorf("加载配置失败: %v\n", err)
 		return
 	}
-	log.Printf("配置加载成功: rho1=%.2f, rho2=%.2f, rho3=%.2f, gamma=%.2f\n",
+	fileLog.SetLevel(cfg.Level())
+	console.SetLevel(cfg.Level())
+	maliceProbabilities = cfg.MaliceProbabilities
+	fileLog.Infof("配置加载成功: rho1=%.2f, rho2=%.2f, rho3=%.2f, gamma=%.2f\n",
 		cfg.Rho1, cfg.Rho2, cfg.Rho3, cfg.Gamma)
-	_ = multiWriter
 
-	// 读取 Excel
-	f, err := excelize.OpenFile("data.xlsx")
-	if err != nil {
-		log.Printf("错误: 打开 data.xlsx 失败: %v\n", err)
-		fmt.Println("打开 data.xlsx 失败:", err)
-		return
-	}
-	log.Printf("成功打开数据文件: data.xlsx\n")
-	sheet := f.GetSheetName(0)
-	rows, err := f.GetRows(sheet)
-	if err != nil || len(rows) < 2 {
-		log.Printf("错误: 读取表格失败或无数据\n")
-		fmt.Println("读取表格失败或无数据")
-		return
-	}
-	log.Printf("读取到 %d 行数据（包含表头）\n", len(rows))
-
-	// 解析表头
-	header := rows[0]
-	var iVID, iTime, iLong, iSpd, iLane, iAcc int
-	for idx, title := range header {
-		switch title {
-		case "vehicleID":
-			iVID = idx
-		case "time(s)":
-			iTime = idx
-		case "longitudinalDistance(m)":
-			iLong = idx
-		case "speed(m/s)":
-			iSpd = idx
-		case "laneID":
-			iLane = idx
-		case "acceleration(m/s^2)":
-			iAcc = idx
+	// 结构化日志（可选）
+	var jsonLog *roundlog.Writer
+	if *logJSON {
+		jsonLog, err = roundlog.Create("reputation_log.jsonl")
+		if err != nil {
+			fileLog.Errorf("错误: 创建结构化日志失败: %v\n", err)
+			console.Errorf("创建结构化日志失败: %v\n", err)
+			return
 		}
+		defer jsonLog.Close()
 	}
 
-	// 读取并归一化坐标，同时读取加速度
-	dataMap := make(map[string][]RawData)
-	for _, row := range rows[1:] {
-		vid := row[iVID]
-		t, _ := strconv.ParseFloat(row[iTime], 64)
-		lon, _ := strconv.ParseFloat(row[iLong], 64)
-		x := lon
-		laneIDInt, _ := strconv.Atoi(row[iLane])
-		y := float64(laneIDInt-1) * 3.5
-		spd, _ := strconv.ParseFloat(row[iSpd], 64)
-		acc, _ := strconv.ParseFloat(row[iAcc], 64)
-
-		dataMap[vid] = append(dataMap[vid], RawData{
-			VehicleID:    vid,
-			Time:         t,
-			X:            x,
-			Y:            y,
-			Speed:        spd,
-			Acceleration: acc,
-		})
+	// 读取轨迹数据（支持 .xlsx 与 .csv）
+	dataMap, loadReport, err := dataloader.LoadTrajectories(*dataPath, dataloader.LoadOptions{
+		RejectOutliers:  cfg.RejectTrajectoryOutliers,
+		MaxAcceleration: cfg.MaxAcceleration,
+	})
+	if err != nil {
+		fileLog.Errorf("错误: 读取数据文件失败: %v\n", err)
+		console.Errorf("读取数据文件失败: %v\n", err)
+		return
 	}
-
-	// 按时间排序
-	for _, slice := range dataMap {
-		sort.Slice(slice, func(i, j int) bool { return slice[i].Time < slice[j].Time })
+	fileLog.Infof("成功读取数据文件: %s\n", *dataPath)
+	if len(loadReport.Skipped) > 0 {
+		for _, rowErr := range loadReport.Skipped {
+			fileLog.Warnf("跳过数据行: %v\n", rowErr)
+		}
+		fileLog.Warnf("⚠️ %s\n", loadReport.Summary())
+		console.Warnf("⚠️ 数据文件%s，详见日志\n", loadReport.Summary())
 	}
 
 	// 初始化 PBFT 节点
+	// RSU 不产生轨迹数据，数据文件中出现的 RSU 节点ID不作为车辆参与模拟
 	var vehicleIDs []string
 	for vid := range dataMap {
+		if cfg.IsRSU(vid) {
+			fileLog.Warnf("⚠️ 节点 %s 被配置为 RSU，忽略其轨迹数据\n", vid)
+			continue
+		}
 		vehicleIDs = append(vehicleIDs, vid)
 	}
 	sort.Strings(vehicleIDs)
-	if len(vehicleIDs) == 0 {
-		log.Printf("错误: 未找到任何车辆数据\n")
-		fmt.Println("未找到任何车辆数据")
+	rsuIDs := slices.Sorted(slices.Values(cfg.RSUNodes))
+	if err := checkNodeCount(vehicleIDs, rsuIDs); err != nil {
+		fileLog.Errorf("错误: %v\n", err)
+		console.Errorf("%v\n", err)
 		return
 	}
-	log.Printf("\n节点初始化:\n")
-	log.Printf("总节点数: %d\n", len(vehicleIDs))
-	log.Printf("节点列表: %v\n", vehicleIDs)
+	fileLog.Infof("\n节点初始化:\n")
+	fileLog.Infof("总节点数: %d\n", len(vehicleIDs))
+	fileLog.Infof("节点列表: %v\n", vehicleIDs)
 
 	// 统计恶意节点
 	var maliciousCount int
@@ -259,11 +271,15 @@ func main() {
 			honestList = append(honestList, vid)
 		}
 	}
-	log.Printf("诚实节点 (%d个): %v\n", len(honestList), honestList)
-	log.Printf("恶意节点 (%d个): %v ⚠️\n", maliciousCount, maliciousList)
+	fileLog.Infof("诚实节点 (%d个): %v\n", len(honestList), honestList)
+	fileLog.Infof("恶意节点 (%d个): %v ⚠️\n", maliciousCount, maliciousList)
+	if len(rsuIDs) > 0 {
+		fileLog.Infof("RSU 节点 (%d个): %v，只验证交易、不发送交易\n", len(rsuIDs), rsuIDs)
+	}
 
+	// RSU 与车辆一样参与 PBFT 并作为接收者评价交易
 	nodes := make(map[string]*Node)
-	for _, vid := range vehicleIDs {
+	for _, vid := range append(slices.Clone(vehicleIDs), rsuIDs...) {
 		nodes[vid] = NewNode(vid, cfg)
 	}
 	for _, n := range nodes {
@@ -273,62 +289,63 @@ func main() {
 			}
 		}
 	}
-	log.Printf("每个节点连接的对等节点数: %d\n\n", len(vehicleIDs)-1)
+	fileLog.Infof("每个节点连接的对等节点数: %d\n\n", len(nodes)-1)
 
 	// 构建轨迹向量：Speed, Direction, Acceleration
-	trajMap := make(map[string][]reputation.Vector)
-	for _, vid := range vehicleIDs {
-		pts := dataMap[vid]
-		var vecs []reputation.Vector
-		for i := range pts {
-			var dir float64
-			if i > 0 {
-				dx := pts[i].X - pts[i-1].X
-				dy := pts[i].Y - pts[i-1].Y
-				dir = math.Atan2(dy, dx)
-			}
-			vecs = append(vecs, reputation.Vector{
-				Speed:        pts[i].Speed,
-				Direction:    dir,
-				Acceleration: pts[i].Acceleration,
-			})
-		}
-		trajMap[vid] = vecs
-	}
+	trajMap := dataloader.BuildVectorMap(dataMap)
 
 	// 信誉交互 & PBFT 模拟（同之前，只是传入的新 Vector）
-	rounds := len(trajMap[vehicleIDs[0]])
-	log.Printf("开始信誉交互模拟:\n")
-	log.Printf("总轮数: %d\n", rounds)
-	log.Printf("评价模型:\n")
-	log.Printf("  📤 节点发送交易 → 📥 其他节点验证 → 📝 给发送者评价\n")
-	log.Printf("  ✅ 诚实节点发送正常交易 → 收到正面评价\n")
-	log.Printf("  ⚠️ 恶意节点发送恶意交易 → 收到负面评价\n")
-	log.Printf("交互频率:\n")
-	log.Printf("  ✅ 诚实节点: 随机交互（70%%概率无交互，20%%概率1次，10%%概率2-%d次）\n", MaxInteractionsPerPair)
-	log.Printf("  ⚠️ 恶意节点: 每轮固定1次交互\n")
+	// 车辆可能中途进入或离开场景，总轮数取最长轨迹长度
+	rounds := dataloader.MaxRounds(trajMap)
+	fileLog.Infof("开始信誉交互模拟:\n")
+	fileLog.Infof("总轮数: %d\n", rounds)
+	fileLog.Infof("评价模型:\n")
+	fileLog.Infof("  📤 节点发送交易 → 📥 其他节点验证 → 📝 给发送者评价\n")
+	fileLog.Infof("  ✅ 诚实节点发送正常交易 → 收到正面评价\n")
+	fileLog.Infof("  ⚠️ 恶意节点发送恶意交易 → 收到负面评价\n")
+	fileLog.Infof("交互频率:\n")
+	fileLog.Infof("  ✅ 诚实节点: 随机交互（%d%%概率无交互，%d%%概率1次，%d%%概率2-%d次）\n",
+		cfg.NoInteractionProb, cfg.OneInteractionProb, cfg.MultiInteractionProb, cfg.MaxInteractionsPerPair)
+	fileLog.Infof("  ⚠️ 恶意节点: 每轮固定1次交互\n")
 
 	// 显示初始信誉值
-	log.Printf("\n初始信誉值（交互前）:\n")
+	fileLog.Infof("\n初始信誉值（交互前）:\n")
 	for _, vid := range vehicleIDs {
 		initialRepu := nodes[vid].Rm.ComputeReputation(vid, time.Now())
 		nodeType := "✅诚实"
 		if isMalicious(vid) {
 			nodeType = "⚠️恶意"
 		}
-		log.Printf("  节点 %s [%s]: %.2f\n", vid, nodeType, initialRepu)
+		fileLog.Infof("  节点 %s [%s]: %.2f\n", vid, nodeType, initialRepu)
 	}
-	log.Printf("\n")
+	fileLog.Infof("\n")
 
 	interChan := make(chan reputation.Interaction)
 	var wg sync.WaitGroup
 
-	go func() {
-		for inter := range interChan {
-			nodes[inter.To].Rm.AddInteraction(inter)
-			wg.Done()
-		}
-	}()
+	// 交互消费者：InteractionWorkers 个协程并发写入信誉管理器
+	// 各协程之间不保证交互的写入顺序，信誉聚合与写入顺序无关，结果不受影响
+	var consumers sync.WaitGroup
+	for w := 0; w < cfg.InteractionWorkers; w++ {
+		consumers.Add(1)
+		go func() {
+			defer consumers.Done()
+			for inter := range interChan {
+				if err := nodes[inter.To].Rm.AddInteraction(inter); err != nil {
+					fileLog.Errorf("错误: 记录交互失败: %v\n", err)
+				}
+				wg.Done()
+			}
+		}()
+	}
+
+	// 优雅退出：收到 SIGINT/SIGTERM 后不再开始新的轮次，
+	// 处理完已发出的交互后照常输出最终统计
+	stopChan := make(chan os.Signal, 1)
+	signal.Notify(stopChan, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stopChan)
+	interrupted := false
+	completedRounds := 0
 
 	// 用于记录信誉变化
 	reputationHistory := make(map[string][]float64)
@@ -336,10 +353,36 @@ func main() {
 		reputationHistory[vid] = make([]float64, 0)
 	}
 
+	// 每轮的诚实/恶意信誉区分度，用于观察收敛过程
+	maliciousSet := make(map[string]bool)
+	for _, vid := range vehicleIDs {
+		maliciousSet[vid] = isMalicious(vid)
+	}
+	var discriminationHistory []float64
+
+	// 收敛判断（可选）：收敛后记录收敛轮次与收敛时的信誉值，并提前结束模拟
+	var convergence *reputation.ConvergenceTracker
+	if *converge {
+		convergence = reputation.NewConvergenceTracker(cfg.ConvergenceEpsilon, cfg.ConvergenceRounds)
+	}
+	convergedRound := 0
+	var convergedReputations map[string]float64
+
 	// 记录总交互次数
 	grandTotalInteractions := 0
 
 	for r := 0; r < rounds; r++ {
+		select {
+		case sig := <-stopChan:
+			fileLog.Infof("收到信号 %v，在第 %d 轮前停止模拟\n\n", sig, r+1)
+			console.Warnf("收到信号 %v，停止模拟并输出已完成 %d 轮的结果\n", sig, r)
+			interrupted = true
+		default:
+		}
+		if interrupted {
+			break
+		}
+
 		roundStartTime := time.Now()
 		proposer := nodes[vehicleIDs[r%len(vehicleIDs)]]
 		proposer.Propose([]byte(fmt.Sprintf("Round %d positions", r+1)))
@@ -351,13 +394,17 @@ func main() {
 		maliciousInteractions := 0 // 恶意节点发起的交互数量
 		honestInteractions := 0    // 诚实节点发起的交互数量
 
+		// 本轮只有有轨迹数据的车辆参与交互，RSU 只作为接收者
+		activeIDs := dataloader.ActiveVehicles(trajMap, vehicleIDs, r)
+		receiverIDs := append(slices.Clone(activeIDs), rsuIDs...)
+
 		// 为每个恶意节点随机选择一个目标（每轮只发1个交易）
 		maliciousTargets := make(map[string]string) // sender -> receiver
-		for _, sender := range vehicleIDs {
+		for _, sender := range activeIDs {
 			if isMalicious(sender) {
 				// 随机选择一个不是自己的目标节点
 				possibleTargets := make([]string, 0)
-				for _, receiver := range vehicleIDs {
+				for _, receiver := range receiverIDs {
 					if receiver != sender {
 						possibleTargets = append(possibleTargets, receiver)
 					}
@@ -369,8 +416,8 @@ func main() {
 		}
 
 		// 遍历所有可能的发送者-接收者组合
-		for _, sender := range vehicleIDs {
-			for _, receiver := range vehicleIDs {
+		for _, sender := range activeIDs {
+			for _, receiver := range receiverIDs {
 				if sender == receiver {
 					continue
 				}
@@ -386,7 +433,7 @@ func main() {
 					}
 				} else {
 					// 诚实节点：随机决定本次发送的交易次数
-					interactionCount = getRandomInteractionCount()
+					interactionCount = getRandomInteractionCount(cfg)
 				}
 
 				if interactionCount == 0 {
@@ -397,6 +444,11 @@ func main() {
 				hasInteractionCount++
 				raw := dataMap[sender][r]
 				baseTime := time.Now().Add(-time.Duration(raw.Time) * time.Second)
+				// RSU 没有轨迹，其参与的交互不计算轨迹相似度
+				var trajUser []reputation.Vector
+				if !cfg.IsRSU(receiver) {
+					trajUser = trajMap[receiver][:r+1]
+				}
 
 				for k := 0; k < interactionCount; k++ {
 					delay := time.Duration(rand.Intn(500)) * time.Millisecond
@@ -406,8 +458,8 @@ func main() {
 					// From = receiver（评价者）
 					// To = sender（被评价者，交易发送者）
 					var posEvents, negEvents int
-					if isMalicious(sender) {
-						// 如果发送者是恶意节点，发送恶意交易，接收者识别后给负面评价
+					if isMaliciousTx(sender) {
+						// 如果发送者本次发送恶意交易，接收者识别后给负面评价
 						posEvents = 0
 						negEvents = 1
 					} else {
@@ -422,7 +474,7 @@ func main() {
 						PosEvents:     posEvents,
 						NegEvents:     negEvents,
 						Timestamp:     ts,
-						TrajUser:      trajMap[receiver][:r+1],      // 评价者的轨迹
+						TrajUser:      trajUser,                     // 评价者的轨迹
 						TrajProvider:  trajMap[sender][:r+1],        // 被评价者的轨迹
 						TxType:        reputation.NormalTransaction, // ⭐ 标记为普通交易
 						UrgencyDegree: 0.0,                          // 普通交易无紧急度
@@ -446,31 +498,48 @@ func main() {
 		grandTotalInteractions += totalInteractions
 
 		// 输出信誉到控制台和日志
-		totalPairs := len(vehicleIDs) * (len(vehicleIDs) - 1)
-		interactionRate := float64(hasInteractionCount) / float64(totalPairs) * 100
-
-		log.Printf("========================================\n")
-		log.Printf("第 %d 轮信誉计算结果\n", r+1)
-		log.Printf("----------------------------------------\n")
-		log.Printf("提议者节点: %s\n", proposer.ID)
-		log.Printf("本轮交互统计:\n")
-		log.Printf("  总交互次数: %d\n", totalInteractions)
-		log.Printf("    ├─ 诚实节点发送交易: %d 次（收到正面评价）\n", honestInteractions)
-		log.Printf("    └─ 恶意节点发送交易: %d 次（收到负面评价）⚠️\n", maliciousInteractions)
-		log.Printf("  有交互的节点对: %d/%d (%.1f%%)\n", hasInteractionCount, totalPairs, interactionRate)
-		log.Printf("  无交互的节点对: %d/%d (%.1f%%)\n", noInteractionCount, totalPairs, float64(noInteractionCount)/float64(totalPairs)*100)
-		log.Printf("----------------------------------------\n")
-
-		fmt.Printf("=== 第 %d 轮信誉计算 ===\n", r+1)
+		totalPairs := len(activeIDs) * (len(activeIDs) - 1)
+		interactionRate, noInteractionRate := 0.0, 0.0
+		if totalPairs > 0 {
+			interactionRate = float64(hasInteractionCount) / float64(totalPairs) * 100
+			noInteractionRate = float64(noInteractionCount) / float64(totalPairs) * 100
+		}
+
+		fileLog.Infof("========================================\n")
+		fileLog.Infof("第 %d 轮信誉计算结果\n", r+1)
+		fileLog.Infof("----------------------------------------\n")
+		fileLog.Infof("提议者节点: %s\n", proposer.ID)
+		fileLog.Infof("本轮交互统计:\n")
+		fileLog.Infof("  总交互次数: %d\n", totalInteractions)
+		fileLog.Infof("    ├─ 诚实节点发送交易: %d 次（收到正面评价）\n", honestInteractions)
+		fileLog.Infof("    └─ 恶意节点发送交易: %d 次（收到负面评价）⚠️\n", maliciousInteractions)
+		fileLog.Infof("  有交互的节点对: %d/%d (%.1f%%)\n", hasInteractionCount, totalPairs, interactionRate)
+		fileLog.Infof("  无交互的节点对: %d/%d (%.1f%%)\n", noInteractionCount, totalPairs, noInteractionRate)
+		fileLog.Infof("----------------------------------------\n")
+
+		console.Infof("=== 第 %d 轮信誉计算 ===\n", r+1)
 
 		// 计算并记录每个节点的信誉值
 		var minRepu, maxRepu, sumRepu float64 = 1.0, 0.0, 0.0
 		var honestRepuSum, maliciousRepuSum float64
 		var honestCount, maliciousNodeCount int
+		roundReputations := make(map[string]float64)
+		// 朴素基线 pos/(pos+neg)，用于对比模型相对简单计数的区分能力
+		roundSimpleScores := make(map[string]float64)
+		roundOpinions := make(map[string]roundlog.Opinion)
 
+		// 各节点的信誉管理器缓存上一轮的意见，只重新计算新增交互或随时间衰减的被评价者
 		for idx, vid := range vehicleIDs {
-			repu := nodes[vid].Rm.ComputeReputation(vid, time.Now())
+			repu := nodes[vid].Rm.ComputeReputationDelta([]string{vid}, time.Now())[vid]
 			reputationHistory[vid] = append(reputationHistory[vid], repu)
+			roundReputations[vid] = repu
+			simple := nodes[vid].Rm.SimpleScore(vid)
+			roundSimpleScores[vid] = simple
+			if jsonLog != nil {
+				if op, ok := nodes[vid].Rm.ComputeOpinion(vid, time.Now()); ok {
+					roundOpinions[vid] = roundlog.Opinion{T: op.T, D: op.D, I: op.I}
+				}
+			}
 
 			// 计算变化量
 			change := 0.0
@@ -499,57 +568,103 @@ func main() {
 			}
 
 			// 输出到控制台
-			fmt.Printf("节点 %s [%s] → 信誉值: %.4f\n", vid, nodeType, repu)
+			console.Infof("节点 %s [%s] → 信誉值: %.4f (简单评分: %.4f)\n", vid, nodeType, repu, simple)
 
 			// 详细记录到日志
 			if change != 0 {
-				log.Printf("节点 %s [%s]: 信誉值=%.6f, 变化=%.6f (%.2f%%)\n",
-					vid, nodeType, repu, change, change*100)
+				fileLog.Infof("节点 %s [%s]: 信誉值=%.6f, 变化=%.6f (%.2f%%), 简单评分=%.6f\n",
+					vid, nodeType, repu, change, change*100, simple)
 			} else {
-				log.Printf("节点 %s [%s]: 信誉值=%.6f (首次计算)\n", vid, nodeType, repu)
+				fileLog.Infof("节点 %s [%s]: 信誉值=%.6f (首次计算), 简单评分=%.6f\n", vid, nodeType, repu, simple)
 			}
 
 			// 每5个节点换行一次以便阅读
 			if (idx+1)%5 == 0 {
-				log.Printf("\n")
+				fileLog.Infof("\n")
 			}
 		}
 
 		avgRepu := sumRepu / float64(len(vehicleIDs))
-		log.Printf("----------------------------------------\n")
-		log.Printf("统计信息:\n")
-		log.Printf("  最小信誉值: %.6f\n", minRepu)
-		log.Printf("  最大信誉值: %.6f\n", maxRepu)
-		log.Printf("  平均信誉值: %.6f\n", avgRepu)
-		log.Printf("  信誉值范围: %.6f\n", maxRepu-minRepu)
+		fileLog.Infof("----------------------------------------\n")
+		fileLog.Infof("统计信息:\n")
+		fileLog.Infof("  最小信誉值: %.6f\n", minRepu)
+		fileLog.Infof("  最大信誉值: %.6f\n", maxRepu)
+		fileLog.Infof("  平均信誉值: %.6f\n", avgRepu)
+		fileLog.Infof("  信誉值范围: %.6f\n", maxRepu-minRepu)
 
 		// 对比诚实节点和恶意节点
 		if honestCount > 0 {
-			log.Printf("  诚实节点平均信誉: %.6f ✅\n", honestRepuSum/float64(honestCount))
+			fileLog.Infof("  诚实节点平均信誉: %.6f ✅\n", honestRepuSum/float64(honestCount))
 		}
 		if maliciousNodeCount > 0 {
-			log.Printf("  恶意节点平均信誉: %.6f ⚠️\n", maliciousRepuSum/float64(maliciousNodeCount))
+			fileLog.Infof("  恶意节点平均信誉: %.6f ⚠️\n", maliciousRepuSum/float64(maliciousNodeCount))
 		}
 		if honestCount > 0 && maliciousNodeCount > 0 {
 			diff := (honestRepuSum / float64(honestCount)) - (maliciousRepuSum / float64(maliciousNodeCount))
-			log.Printf("  信誉差距: %.6f (诚实节点高出 %.2f%%)\n", diff, diff*100)
+			fileLog.Infof("  信誉差距: %.6f (诚实节点高出 %.2f%%)\n", diff, diff*100)
+		}
+		score := reputation.DiscriminationScore(roundReputations, maliciousSet)
+		discriminationHistory = append(discriminationHistory, score)
+		fileLog.Infof("  区分度: %.6f\n", score)
+		simpleScore := reputation.DiscriminationScore(roundSimpleScores, maliciousSet)
+		fileLog.Infof("  简单评分区分度: %.6f (模型高出 %.6f)\n", simpleScore, score-simpleScore)
+		if convergence != nil {
+			maxChange, converged := convergence.Observe(roundReputations)
+			if !math.IsInf(maxChange, 1) {
+				fileLog.Infof("  信誉最大变化量: %.6f\n", maxChange)
+			}
+			if converged {
+				convergedRound = r + 1
+				convergedReputations = roundReputations
+				fileLog.Infof("  ✅ 信誉值已连续 %d 轮变化小于 %g，在第 %d 轮收敛\n",
+					cfg.ConvergenceRounds, cfg.ConvergenceEpsilon, convergedRound)
+				console.Infof("信誉值在第 %d 轮收敛，提前结束模拟\n", convergedRound)
+			}
+		}
+
+		fileLog.Infof("本轮耗时: %v\n", time.Since(roundStartTime))
+		fileLog.Infof("========================================\n\n")
+
+		if jsonLog != nil {
+			err := jsonLog.Write(roundlog.RoundRecord{
+				Round:       r + 1,
+				Proposer:    proposer.ID,
+				Reputations: roundReputations,
+				Opinions:    roundOpinions,
+				Interactions: roundlog.InteractionCounts{
+					Total:     totalInteractions,
+					Honest:    honestInteractions,
+					Malicious: maliciousInteractions,
+				},
+				Validators:          []string{}, // 单链模式没有验证器组
+				DiscriminationScore: score,
+				Converged:           convergedRound == r+1,
+			})
+			if err != nil {
+				fileLog.Errorf("错误: 写入结构化日志失败: %v\n", err)
+			}
 		}
 
-		log.Printf("本轮耗时: %v\n", time.Since(roundStartTime))
-		log.Printf("========================================\n\n")
+		completedRounds++
+		if convergedRound > 0 {
+			break
+		}
 	}
 
 	close(interChan)
+	consumers.Wait()
 
 	// 最终总结
-	log.Printf("\n")
-	log.Printf("╔════════════════════════════════════════╗\n")
-	log.Printf("║         信誉系统运行总结               ║\n")
-	log.Printf("╚════════════════════════════════════════╝\n")
-	log.Printf("总轮数: %d\n", rounds)
-	log.Printf("总节点数: %d (诚实: %d, 恶意: %d)\n", len(vehicleIDs), len(honestList), len(maliciousList))
-	log.Printf("总交互次数: %d (随机交互模式)\n", grandTotalInteractions)
-	log.Printf("平均每轮交互次数: %.1f\n", float64(grandTotalInteractions)/float64(rounds))
+	fileLog.Infof("\n")
+	fileLog.Infof("╔════════════════════════════════════════╗\n")
+	fileLog.Infof("║         信誉系统运行总结               ║\n")
+	fileLog.Infof("╚════════════════════════════════════════╝\n")
+	fileLog.Infof("总轮数: %d\n", completedRounds)
+	fileLog.Infof("总节点数: %d (诚实: %d, 恶意: %d)\n", len(vehicleIDs), len(honestList), len(maliciousList))
+	fileLog.Infof("总交互次数: %d (随机交互模式)\n", grandTotalInteractions)
+	if completedRounds > 0 {
+		fileLog.Infof("平均每轮交互次数: %.1f\n", float64(grandTotalInteractions)/float64(completedRounds))
+	}
 
 	// 创建排序数组
 	type NodeReputation struct {
@@ -576,31 +691,92 @@ func main() {
 		return finalRanking[i].Reputation > finalRanking[j].Reputation
 	})
 
-	log.Printf("\n最终信誉值排名:\n")
+	fileLog.Infof("\n最终信誉值排名:\n")
 	for idx, nr := range finalRanking {
 		nodeType := "✅诚实"
 		if isMalicious(nr.ID) {
 			nodeType = "⚠️恶意"
 		}
-		log.Printf("  第 %d 名: 节点 %s [%s] = %.6f\n", idx+1, nr.ID, nodeType, nr.Reputation)
+		fileLog.Infof("  第 %d 名: 节点 %s [%s] = %.6f\n", idx+1, nr.ID, nodeType, nr.Reputation)
 	}
 
-	log.Printf("\n最终对比分析:\n")
+	fileLog.Infof("\n最终对比分析:\n")
 	if finalHonestCount > 0 {
-		log.Printf("  诚实节点最终平均信誉: %.6f ✅\n", finalHonestSum/float64(finalHonestCount))
+		fileLog.Infof("  诚实节点最终平均信誉: %.6f ✅\n", finalHonestSum/float64(finalHonestCount))
 	}
 	if finalMaliciousCount > 0 {
-		log.Printf("  恶意节点最终平均信誉: %.6f ⚠️\n", finalMaliciousSum/float64(finalMaliciousCount))
+		fileLog.Infof("  恶意节点最终平均信誉: %.6f ⚠️\n", finalMaliciousSum/float64(finalMaliciousCount))
 	}
 	if finalHonestCount > 0 && finalMaliciousCount > 0 {
 		finalDiff := (finalHonestSum / float64(finalHonestCount)) - (finalMaliciousSum / float64(finalMaliciousCount))
-		log.Printf("  最终信誉差距: %.6f\n", finalDiff)
-		log.Printf("  诚实节点信誉高出: %.2f%%\n", (finalDiff/(finalMaliciousSum/float64(finalMaliciousCount)))*100)
-		log.Printf("  ✅ 系统成功识别并惩罚了恶意节点！\n")
+		fileLog.Infof("  最终信誉差距: %.6f\n", finalDiff)
+		fileLog.Infof("  诚实节点信誉高出: %.2f%%\n", (finalDiff/(finalMaliciousSum/float64(finalMaliciousCount)))*100)
+		fileLog.Infof("  ✅ 系统成功识别并惩罚了恶意节点！\n")
+	}
+
+	// 以信誉阈值判定恶意节点的检测效果
+	finalReputations := make(map[string]float64, len(finalRanking))
+	for _, nr := range finalRanking {
+		finalReputations[nr.ID] = nr.Reputation
+	}
+	fileLog.Infof("\n恶意节点检测效果（信誉值低于阈值判定为恶意）:\n")
+	fileLog.Infof("  配置阈值: %v\n", reputation.EvaluateDetection(finalReputations, maliciousSet, cfg.DetectionThreshold))
+	fileLog.Infof("  最优阈值: %v\n", reputation.OptimalDetectionThreshold(finalReputations, maliciousSet))
+
+	if len(rsuIDs) > 0 {
+		fileLog.Infof("\nRSU 最终信誉值（未被评价时保持初始信任 %.2f）:\n", cfg.RSUInitialReputation)
+		for _, id := range rsuIDs {
+			fileLog.Infof("  RSU %s = %.6f\n", id, nodes[id].Rm.ComputeReputation(id, time.Now()))
+		}
+	}
+
+	if convergedRound > 0 {
+		fileLog.Infof("\n收敛: 第 %d 轮 (convergenceEpsilon=%g, convergenceRounds=%d)\n",
+			convergedRound, cfg.ConvergenceEpsilon, cfg.ConvergenceRounds)
+		fileLog.Infof("收敛时的信誉值:\n")
+		for _, vid := range vehicleIDs {
+			fileLog.Infof("  节点 %s = %.6f\n", vid, convergedReputations[vid])
+		}
+	} else if convergence != nil {
+		fileLog.Infof("\n收敛: 运行 %d 轮后仍未收敛\n", completedRounds)
+	}
+
+	// 振荡分析：信誉序列后半段仍来回跳动的节点说明当前参数组合不稳定
+	oscillation := reputation.DetectOscillation(reputationHistory)
+	if unstable := reputation.OscillatingNodes(oscillation, reputation.DefaultOscillationThreshold); len(unstable) > 0 {
+		fileLog.Warnf("\n⚠️ 振荡: %d 个节点的信誉未能稳定（振荡得分 ≥ %g）:\n", len(unstable), reputation.DefaultOscillationThreshold)
+		for _, vid := range unstable {
+			fileLog.Warnf("  节点 %s: 振荡得分 %.2f\n", vid, oscillation[vid])
+		}
+		console.Warnf("⚠️ %d 个节点的信誉序列振荡未稳定，详见日志\n", len(unstable))
+	} else {
+		fileLog.Infof("\n振荡: 所有节点的信誉序列均已稳定\n")
+	}
+
+	fileLog.Infof("\n区分度变化（每轮）:\n")
+	for i, score := range discriminationHistory {
+		fileLog.Infof("  第 %d 轮: %.6f\n", i+1, score)
+	}
+
+	// 导出信任图（可选）：各节点只记录针对自己的交互，合并后得到完整的信任图
+	if *dotPath != "" {
+		graph := reputation.NewReputationManager(cfg)
+		for _, vid := range vehicleIDs {
+			if err := graph.Merge(nodes[vid].Rm); err != nil {
+				fileLog.Warnf("合并节点 %s 的交互记录: %v\n", vid, err)
+			}
+		}
+		dot := graph.ExportDOTWithThreshold(time.Now(), *dotMinTrust)
+		if err := os.WriteFile(*dotPath, []byte(dot), 0644); err != nil {
+			fileLog.Errorf("错误: 导出信任图失败: %v\n", err)
+			console.Errorf("导出信任图失败: %v\n", err)
+		} else {
+			fileLog.Infof("\n信任图已导出到 %s\n", *dotPath)
+		}
 	}
 
-	log.Printf("\n结束时间: %s\n", time.Now().Format("2006-01-02 15:04:05"))
-	log.Printf("========================================\n")
+	fileLog.Infof("\n结束时间: %s\n", time.Now().Format("2006-01-02 15:04:05"))
+	fileLog.Infof("========================================\n")
 
-	fmt.Println("\n信誉值已记录到 reputation_log.txt 文件中")
+	console.Infof("\n信誉值已记录到 reputation_log.txt 文件中\n")
 }
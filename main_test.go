@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"block/config"
+)
+
+func TestReplayedCommitAppendsOnce(t *testing.T) {
+	node := NewNode("1", config.DefaultConfig())
+	msg := Message{Type: Commit, View: 0, Seq: 1, Block: Block{Index: 1, Data: []byte("tx"), Hash: "h1"}, From: "2"}
+
+	// 同一提交消息重放两次，账本只增长一次
+	node.Receive(msg)
+	node.Receive(msg)
+	if len(node.ledger) != 1 {
+		t.Fatalf("账本长度 = %d, 期望 1", len(node.ledger))
+	}
+	if node.CommittedSeq() != 1 {
+		t.Errorf("CommittedSeq() = %d, 期望 1", node.CommittedSeq())
+	}
+
+	// 新序号的提交正常追加
+	msg.Seq, msg.Block = 2, Block{Index: 2, Data: []byte("tx2"), Hash: "h2", PrevHash: "h1"}
+	node.Receive(msg)
+	if len(node.ledger) != 2 || node.CommittedSeq() != 2 {
+		t.Errorf("账本长度 = %d, CommittedSeq() = %d, 期望均为 2", len(node.ledger), node.CommittedSeq())
+	}
+}
+
+func TestCheckNodeCount(t *testing.T) {
+	tests := []struct {
+		name     string
+		vehicles []string
+		rsus     []string
+		wantErr  bool
+		wantMsg  string
+	}{
+		{"n=0", nil, nil, true, "未找到任何车辆数据"},
+		{"n=1", []string{"1"}, nil, true, "共 1 个节点，至少需要 2 个节点"},
+		{"n=2", []string{"1", "2"}, nil, false, ""},
+		{"只有 RSU", nil, []string{"R1", "R2"}, true, "未找到任何车辆数据"},
+		{"一辆车与一个 RSU", []string{"1"}, []string{"R1"}, false, ""},
+	}
+	for _, tt := range tests {
+		err := checkNodeCount(tt.vehicles, tt.rsus)
+		if !tt.wantErr {
+			if err != nil {
+				t.Errorf("%s: checkNodeCount() = %v, 期望 nil", tt.name, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrTooFewNodes) || !strings.Contains(err.Error(), tt.wantMsg) {
+			t.Errorf("%s: checkNodeCount() = %v, 期望 ErrTooFewNodes 且包含 %q", tt.name, err, tt.wantMsg)
+		}
+	}
+}
+
+func TestProposeWithTwoNodes(t *testing.T) {
+	// 通过节点数检查的最小网络：两个节点互为对等节点，提议的区块在两个节点上确认
+	a, b := NewNode("1", config.DefaultConfig()), NewNode("2", config.DefaultConfig())
+	a.Peers, b.Peers = []*Node{b}, []*Node{a}
+	a.Propose([]byte("Round 1 positions"))
+	deadline := time.Now().Add(time.Second)
+	for b.CommittedSeq() < 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if b.CommittedSeq() != 1 {
+		t.Fatalf("节点 2 的 CommittedSeq() = %d, 期望 1", b.CommittedSeq())
+	}
+}
@@ -0,0 +1,66 @@
+package reputation
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ExportDOT 以 GraphViz DOT 格式导出截至 now 的信任图，导出全部边
+func (rm *ReputationManager) ExportDOT(now time.Time) string {
+	return rm.ExportDOTWithThreshold(now, 0)
+}
+
+// ExportDOTWithThreshold 以 GraphViz DOT 格式导出截至 now 的信任图
+// 边由评价者（From）指向被评价者（To），标签为直接意见的信任度 T，
+// 只导出 T 不低于 minTrust 的边，便于大规模网络保持可读；
+// 节点按最终信誉值着色：信誉越低越红，越高越绿
+// 节点与边按 ID 排序输出，相同的交互记录总是得到相同的文本
+func (rm *ReputationManager) ExportDOTWithThreshold(now time.Time, minTrust float64) string {
+	reputations := rm.ComputeAllReputations(now)
+
+	rm.mutex.RLock()
+	direct := rm.directOpinions(rm.aggregateByPair(now), now)
+	rm.mutex.RUnlock()
+
+	nodes := make([]string, 0, len(reputations))
+	for node := range reputations {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	var b strings.Builder
+	b.WriteString("digraph trust {\n")
+	b.WriteString("  node [style=filled];\n")
+	for _, node := range nodes {
+		repu := reputations[node]
+		// 色相 0（红）到 1/3（绿）对应信誉 0 到 1
+		hue := math.Max(0, math.Min(1, repu)) / 3
+		fmt.Fprintf(&b, "  \"%s\" [label=\"%s\\n%.3f\", fillcolor=\"%.3f 0.6 1.0\"];\n",
+			escapeDOT(node), escapeDOT(node), repu, hue)
+	}
+
+	for _, to := range nodes {
+		froms := make([]string, 0, len(direct[to]))
+		for from := range direct[to] {
+			froms = append(froms, from)
+		}
+		sort.Strings(froms)
+		for _, from := range froms {
+			t := direct[to][from].Opinion.T
+			if t < minTrust {
+				continue
+			}
+			fmt.Fprintf(&b, "  \"%s\" -> \"%s\" [label=\"%.3f\"];\n", escapeDOT(from), escapeDOT(to), t)
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// escapeDOT 转义 DOT 双引号字符串中的反斜杠与双引号
+func escapeDOT(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
@@ -0,0 +1,185 @@
+package reputation
+
+import (
+	"encoding/json"
+	"flag"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"block/config"
+)
+
+// update 为 true 时重新生成黄金文件：go test ./reputation -run TestGolden -update
+var update = flag.Bool("update", false, "重新生成 testdata 下的黄金文件")
+
+// goldenInput 黄金测试的输入：固定随机种子、轮数、作恶概率与各节点轨迹
+type goldenInput struct {
+	Seed                int64               `json:"seed"`
+	Rounds              int                 `json:"rounds"`
+	MaliceProbabilities map[string]float64  `json:"maliceProbabilities"`
+	Trajectories        map[string][]Vector `json:"trajectories"`
+}
+
+// goldenOutput 黄金文件：最终信誉排名与各节点信誉值
+type goldenOutput struct {
+	Ranking     []string           `json:"ranking"`
+	Reputations map[string]float64 `json:"reputations"`
+}
+
+// replayGolden 按 main.go 的交互模型以固定种子回放若干轮交互，返回最终信誉值
+// 与 main.go 一致：发送者的每笔交易由接收者评价，恶意节点每轮只向一个随机目标发送 1 笔交易，
+// 每个节点的管理器只保存以该节点为被评价者的交互；时间戳取固定的模拟时刻，结果只由输入决定
+func replayGolden(in goldenInput, cfg config.Config) map[string]float64 {
+	rng := rand.New(rand.NewSource(in.Seed))
+	base := time.Unix(1_700_000_000, 0)
+	roundLength := 10 * time.Second
+
+	ids := make([]string, 0, len(in.Trajectories))
+	for id := range in.Trajectories {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	managers := make(map[string]*ReputationManager, len(ids))
+	for _, id := range ids {
+		managers[id] = NewReputationManager(cfg)
+	}
+
+	interactionCount := func() int {
+		r := rng.Intn(100)
+		switch {
+		case r < cfg.NoInteractionProb:
+			return 0
+		case r < cfg.NoInteractionProb+cfg.OneInteractionProb:
+			return 1
+		default:
+			return 2 + rng.Intn(cfg.MaxInteractionsPerPair-1)
+		}
+	}
+
+	for r := 0; r < in.Rounds; r++ {
+		roundStart := base.Add(time.Duration(r) * roundLength)
+
+		maliciousTargets := make(map[string]string)
+		for _, sender := range ids {
+			if in.MaliceProbabilities[sender] > 0 {
+				var targets []string
+				for _, receiver := range ids {
+					if receiver != sender {
+						targets = append(targets, receiver)
+					}
+				}
+				maliciousTargets[sender] = targets[rng.Intn(len(targets))]
+			}
+		}
+
+		for _, sender := range ids {
+			for _, receiver := range ids {
+				if sender == receiver {
+					continue
+				}
+				count := 0
+				if in.MaliceProbabilities[sender] > 0 {
+					if maliciousTargets[sender] == receiver {
+						count = 1
+					}
+				} else {
+					count = interactionCount()
+				}
+				for k := 0; k < count; k++ {
+					pos, neg := 1, 0
+					if p := in.MaliceProbabilities[sender]; p > 0 && rng.Float64() < p {
+						pos, neg = 0, 1
+					}
+					managers[sender].AddInteraction(Interaction{
+						From:         receiver,
+						To:           sender,
+						PosEvents:    pos,
+						NegEvents:    neg,
+						Timestamp:    roundStart.Add(time.Duration(rng.Intn(500)) * time.Millisecond),
+						TrajUser:     in.Trajectories[receiver][:r+1],
+						TrajProvider: in.Trajectories[sender][:r+1],
+						TxType:       NormalTransaction,
+					})
+				}
+			}
+		}
+	}
+
+	now := base.Add(time.Duration(in.Rounds) * roundLength)
+	reputations := make(map[string]float64, len(ids))
+	for _, id := range ids {
+		reputations[id] = managers[id].ComputeReputation(id, now)
+	}
+	return reputations
+}
+
+// TestGoldenReputations 以固定输入回放交互，将最终信誉排名与信誉值与黄金文件比对
+// 信誉计算的任何改动导致结果变化时测试失败；确认变化符合预期后以 -update 重新生成黄金文件
+func TestGoldenReputations(t *testing.T) {
+	raw, err := os.ReadFile(filepath.Join("testdata", "golden_input.json"))
+	if err != nil {
+		t.Fatalf("读取黄金测试输入失败: %v", err)
+	}
+	var in goldenInput
+	if err := json.Unmarshal(raw, &in); err != nil {
+		t.Fatalf("解析黄金测试输入失败: %v", err)
+	}
+
+	reputations := replayGolden(in, config.DefaultConfig())
+	got := goldenOutput{Reputations: reputations}
+	for id := range reputations {
+		got.Ranking = append(got.Ranking, id)
+	}
+	sort.Slice(got.Ranking, func(i, j int) bool {
+		a, b := got.Ranking[i], got.Ranking[j]
+		if reputations[a] != reputations[b] {
+			return reputations[a] > reputations[b]
+		}
+		return a < b
+	})
+
+	goldenPath := filepath.Join("testdata", "golden_reputations.json")
+	if *update {
+		data, err := json.MarshalIndent(got, "", "  ")
+		if err != nil {
+			t.Fatalf("序列化黄金文件失败: %v", err)
+		}
+		if err := os.WriteFile(goldenPath, append(data, '\n'), 0644); err != nil {
+			t.Fatalf("写入黄金文件失败: %v", err)
+		}
+		t.Logf("已更新黄金文件 %s", goldenPath)
+		return
+	}
+
+	data, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatalf("读取黄金文件失败（首次运行请加 -update 生成）: %v", err)
+	}
+	var want goldenOutput
+	if err := json.Unmarshal(data, &want); err != nil {
+		t.Fatalf("解析黄金文件失败: %v", err)
+	}
+
+	const hint = "信誉计算结果与黄金文件不一致；若改动是有意的，请运行 go test ./reputation -run TestGolden -update 并提交新的黄金文件"
+	if len(got.Ranking) != len(want.Ranking) {
+		t.Fatalf("排名 = %v, 黄金文件为 %v\n%s", got.Ranking, want.Ranking, hint)
+	}
+	for i := range want.Ranking {
+		if got.Ranking[i] != want.Ranking[i] {
+			t.Fatalf("排名 = %v, 黄金文件为 %v\n%s", got.Ranking, want.Ranking, hint)
+		}
+	}
+	for id, rep := range want.Reputations {
+		if math.Abs(reputations[id]-rep) > 1e-9 {
+			t.Errorf("节点 %s 信誉 = %.12f, 黄金文件为 %.12f", id, reputations[id], rep)
+		}
+	}
+	if t.Failed() {
+		t.Log(hint)
+	}
+}
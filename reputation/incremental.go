@@ -0,0 +1,154 @@
+package reputation
+
+import (
+	"maps"
+	"time"
+)
+
+// opinionCache ComputeReputationDelta 上次计算的直接意见、间接意见与信誉值
+type opinionCache struct {
+	now         time.Time
+	direct      directOpinionsMap
+	indirect    map[string]map[string]SubjectiveOpinion
+	reputations map[string]float64
+}
+
+// ComputeReputationDelta 增量计算全部被评价节点在 now 时刻的信誉值，返回 [节点]信誉值
+// changedTargets 为上次调用以来新增交互的被评价者（Interaction.To）；经 AddInteraction、AddInteractions
+// 与 Merge 新增的交互由管理器自动记录，changedTargets 可以为空，其中的节点即使没有交互记录也出现在结果中，
+// 信誉值为初始信誉值（RSU 为 RSUInitialReputation）。
+// 只重新计算变化节点的直接意见，以及路径经过它们的节点的间接意见，其余节点沿用缓存的意见；
+// now 前进时，最晚交互早于 now 的节点对的时效性随之衰减，其被评价者同样视为变化节点，因此结果与 ComputeReputation 相同。
+// 以下情况退回全量计算：首次调用、RemoveRater 之后、now 早于上次计算时刻、
+// 开启 RaterCredibility（评价者可信度使任一节点的变化影响其评价的全部节点）；
+// now 早于最晚的交互时（回放历史时刻）全量计算且不更新缓存
+func (rm *ReputationManager) ComputeReputationDelta(changedTargets []string, now time.Time) map[string]float64 {
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+
+	agg := rm.aggregateByPair(now)
+	var cache *opinionCache
+	switch {
+	case rm.latest.After(now):
+		cache = rm.fullCache(agg, now)
+	case rm.cache == nil || now.Before(rm.cache.now) || rm.cfg.RaterCredibility:
+		rm.cache = rm.fullCache(agg, now)
+		rm.changed = nil
+		cache = rm.cache
+	default:
+		changed := make(map[string]bool, len(rm.changed)+len(changedTargets))
+		maps.Copy(changed, rm.changed)
+		for _, target := range changedTargets {
+			changed[target] = true
+		}
+		if now.After(rm.cache.now) {
+			rm.addTimeDecayed(changed, agg, now)
+		}
+		rm.updateCache(agg, changed, now)
+		rm.changed = nil
+		cache = rm.cache
+	}
+
+	reputations := maps.Clone(cache.reputations)
+	for _, target := range changedTargets {
+		if _, ok := reputations[target]; !ok {
+			reputations[target] = rm.initialReputation(target)
+		}
+	}
+	return reputations
+}
+
+// markChanged 记录 target 有新增交互，供 ComputeReputationDelta 更新缓存，调用方需持有写锁
+func (rm *ReputationManager) markChanged(target string) {
+	if rm.changed == nil {
+		rm.changed = make(map[string]bool)
+	}
+	rm.changed[target] = true
+}
+
+// addTimeDecayed 将时效性在上次计算之后随 now 变化的被评价者加入 changed，调用方需持有锁
+// 交互距今的秒数不大于 0 时时效性恒为 Eta，因此只有存在最晚交互早于 now 的评价者的节点需要重新计算；
+// Rho2 为 0 时时效性不参与权重，没有节点需要重新计算
+func (rm *ReputationManager) addTimeDecayed(changed map[string]bool, agg pairAggregates, now time.Time) {
+	if rm.cfg.Rho2 == 0 {
+		return
+	}
+	for target, fromMap := range agg {
+		if changed[target] {
+			continue
+		}
+		for _, inter := range fromMap {
+			if inter.Timestamp.Before(now) {
+				changed[target] = true
+				break
+			}
+		}
+	}
+}
+
+// fullCache 全量计算全部被评价节点的意见与信誉值，调用方需持有锁
+func (rm *ReputationManager) fullCache(agg pairAggregates, now time.Time) *opinionCache {
+	cache := &opinionCache{
+		now:         now,
+		direct:      rm.directOpinions(agg, now),
+		reputations: make(map[string]float64, len(agg)),
+	}
+	if rm.cfg.UseIndirect {
+		cache.indirect = rm.computeIndirectOpinions(cache.direct)
+	}
+	for target := range cache.direct {
+		cache.reputations[target] = rm.fusedReputation(cache, target)
+	}
+	return cache
+}
+
+// updateCache 重新计算 changed 节点的直接意见，以及间接意见可能依赖它们的节点的间接意见与信誉值，
+// 调用方需持有写锁
+func (rm *ReputationManager) updateCache(agg pairAggregates, changed map[string]bool, now time.Time) {
+	cache := rm.cache
+	cache.now = now
+
+	sub := make(pairAggregates, len(changed))
+	for target := range changed {
+		if fromMap, ok := agg[target]; ok {
+			sub[target] = fromMap
+		}
+	}
+	maps.Copy(cache.direct, rm.computeDirectOpinions(sub, now, nil))
+
+	affected := make(map[string]bool, len(sub))
+	for target := range sub {
+		affected[target] = true
+	}
+	if rm.cfg.UseIndirect {
+		// 间接意见的路径为 source→target 或 source→m→target，下一跳为上一节点的评价者。
+		// 节点 c 的直接意见或评价者变化时，只影响 c 本身、c 的评价者（c 为 m 或 source）
+		// 与 c 的评价者的评价者（c 为 source）的间接意见
+		frontier := affected
+		for hop := 0; hop < indirectHopCount; hop++ {
+			next := make(map[string]bool)
+			for node := range frontier {
+				for rater := range cache.direct[node] {
+					if _, rated := cache.direct[rater]; rated && !affected[rater] {
+						next[rater] = true
+					}
+				}
+			}
+			maps.Copy(affected, next)
+			frontier = next
+		}
+		neighbors := indirectNeighbors(cache.direct)
+		for target := range affected {
+			cache.indirect[target] = rm.indirectOpinionsOf(cache.direct, neighbors, target)
+		}
+	}
+	for target := range affected {
+		cache.reputations[target] = rm.fusedReputation(cache, target)
+	}
+}
+
+// fusedReputation 由缓存的直接与间接意见计算目标节点的信誉值，与 reputationFrom 的计算相同
+func (rm *ReputationManager) fusedReputation(cache *opinionCache, target string) float64 {
+	final := rm.fuseOpinions(cache.direct[target], cache.indirect[target])
+	return final.T + rm.cfg.Gamma*final.I
+}
@@ -0,0 +1,244 @@
+package reputation
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// DiscriminationScore 计算信誉系统对诚实节点与恶意节点的区分度
+// 区分度 = 诚实节点平均信誉 − 恶意节点平均信誉，信誉值在 [0,1] 内时结果在 [-1,1] 内：
+// 1 表示完全区分，0 表示无法区分，负值表示恶意节点的信誉反而更高
+// malicious 中标记为 true 的节点为恶意节点，其余节点均视为诚实节点；
+// 任一类节点为空时无法比较，返回 0
+func DiscriminationScore(reputations map[string]float64, malicious map[string]bool) float64 {
+	var honestSum, maliciousSum float64
+	var honestCount, maliciousCount int
+	for id, repu := range reputations {
+		if malicious[id] {
+			maliciousSum += repu
+			maliciousCount++
+		} else {
+			honestSum += repu
+			honestCount++
+		}
+	}
+	if honestCount == 0 || maliciousCount == 0 {
+		return 0
+	}
+	return honestSum/float64(honestCount) - maliciousSum/float64(maliciousCount)
+}
+
+// DetectionMetrics 以信誉阈值判定恶意节点的检测效果：信誉值低于 Threshold 的节点判定为恶意
+// 恶意节点为正类；分母为 0 时对应的比率为 0
+type DetectionMetrics struct {
+	Threshold float64
+
+	TruePositives  int // 被判定为恶意的恶意节点
+	FalsePositives int // 被判定为恶意的诚实节点
+	FalseNegatives int // 未被判定为恶意的恶意节点
+	TrueNegatives  int // 未被判定为恶意的诚实节点
+
+	Precision         float64 // TP/(TP+FP)
+	Recall            float64 // TP/(TP+FN)
+	F1                float64 // 2PR/(P+R)
+	FalsePositiveRate float64 // FP/(FP+TN)，诚实节点被误判的比例
+	FalseNegativeRate float64 // FN/(TP+FN)，恶意节点被漏判的比例
+}
+
+// String 返回检测效果的单行摘要
+func (m DetectionMetrics) String() string {
+	return fmt.Sprintf("阈值=%.4f 精确率=%.4f 召回率=%.4f F1=%.4f 误报率=%.4f 漏报率=%.4f (TP=%d FP=%d FN=%d TN=%d)",
+		m.Threshold, m.Precision, m.Recall, m.F1, m.FalsePositiveRate, m.FalseNegativeRate,
+		m.TruePositives, m.FalsePositives, m.FalseNegatives, m.TrueNegatives)
+}
+
+// ratio 返回 a/b，b 为 0 时返回 0
+func ratio(a, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+// EvaluateDetection 以 threshold 判定恶意节点，与 malicious 中的真实标签比较
+// malicious 中标记为 true 的节点为恶意节点，其余节点均视为诚实节点
+func EvaluateDetection(reputations map[string]float64, malicious map[string]bool, threshold float64) DetectionMetrics {
+	m := DetectionMetrics{Threshold: threshold}
+	for id, repu := range reputations {
+		flagged := repu < threshold
+		switch {
+		case flagged && malicious[id]:
+			m.TruePositives++
+		case flagged:
+			m.FalsePositives++
+		case malicious[id]:
+			m.FalseNegatives++
+		default:
+			m.TrueNegatives++
+		}
+	}
+	m.Precision = ratio(m.TruePositives, m.TruePositives+m.FalsePositives)
+	m.Recall = ratio(m.TruePositives, m.TruePositives+m.FalseNegatives)
+	if m.Precision+m.Recall > 0 {
+		m.F1 = 2 * m.Precision * m.Recall / (m.Precision + m.Recall)
+	}
+	m.FalsePositiveRate = ratio(m.FalsePositives, m.FalsePositives+m.TrueNegatives)
+	m.FalseNegativeRate = ratio(m.FalseNegatives, m.TruePositives+m.FalseNegatives)
+	return m
+}
+
+// OptimalDetectionThreshold 搜索使 F1 最大的信誉阈值，返回该阈值下的检测效果
+// 候选阈值为相邻不同信誉值的中点以及略高于最大信誉值的值（判定全部节点为恶意），
+// F1 相同时取较小的阈值；没有节点时返回阈值为 0 的空结果
+func OptimalDetectionThreshold(reputations map[string]float64, malicious map[string]bool) DetectionMetrics {
+	values := make([]float64, 0, len(reputations))
+	for _, repu := range reputations {
+		values = append(values, repu)
+	}
+	sort.Float64s(values)
+
+	var candidates []float64
+	for i := 1; i < len(values); i++ {
+		if values[i] > values[i-1] {
+			candidates = append(candidates, (values[i-1]+values[i])/2)
+		}
+	}
+	if len(values) > 0 {
+		candidates = append(candidates, math.Nextafter(values[len(values)-1], math.Inf(1)))
+	}
+
+	best := DetectionMetrics{}
+	for i, threshold := range candidates {
+		m := EvaluateDetection(reputations, malicious, threshold)
+		if i == 0 || m.F1 > best.F1 {
+			best = m
+		}
+	}
+	return best
+}
+
+// SimpleScore 计算朴素基线信誉：以 target 为被评价者的全部交互中正面事件的比例 pos/(pos+neg)
+// 不考虑时效、轨迹相似度、交易类型与不确定性，用于衡量主观逻辑模型相对简单计数的增益；
+// 没有任何事件时返回初始信誉值
+func (rm *ReputationManager) SimpleScore(target string) float64 {
+	rm.mutex.RLock()
+	defer rm.mutex.RUnlock()
+
+	var pos, neg int
+	for _, agg := range rm.agg[target] {
+		pos += agg.PosEvents
+		neg += agg.NegEvents
+	}
+	if pos+neg == 0 {
+		return rm.initialReputation(target)
+	}
+	return float64(pos) / float64(pos+neg)
+}
+
+// ConvergenceTracker 逐轮跟踪信誉值，判断其是否已经收敛：
+// 连续 Rounds 轮中，所有节点信誉值相对上一轮的最大变化量都小于 Epsilon 时认为收敛
+type ConvergenceTracker struct {
+	Epsilon float64 // 每轮最大变化量的阈值
+	Rounds  int     // 需要连续满足阈值的轮数
+
+	prev   map[string]float64
+	stable int
+}
+
+// NewConvergenceTracker 创建收敛判断器
+func NewConvergenceTracker(epsilon float64, rounds int) *ConvergenceTracker {
+	return &ConvergenceTracker{Epsilon: epsilon, Rounds: rounds}
+}
+
+// Observe 记录一轮的信誉值，返回相对上一轮的最大变化量以及是否已经收敛
+// 第一轮没有可比较的上一轮，最大变化量为 +Inf；上一轮未出现的节点与 InitialReputation 比较
+func (c *ConvergenceTracker) Observe(reputations map[string]float64) (maxChange float64, converged bool) {
+	if c.prev == nil {
+		maxChange = math.Inf(1)
+	} else {
+		for id, repu := range reputations {
+			prev, ok := c.prev[id]
+			if !ok {
+				prev = InitialReputation
+			}
+			maxChange = math.Max(maxChange, math.Abs(repu-prev))
+		}
+	}
+
+	c.prev = make(map[string]float64, len(reputations))
+	for id, repu := range reputations {
+		c.prev[id] = repu
+	}
+
+	if maxChange < c.Epsilon {
+		c.stable++
+	} else {
+		c.stable = 0
+	}
+	return maxChange, c.stable >= c.Rounds
+}
+
+// 信誉振荡检测的默认参数
+const (
+	// OscillationTolerance 相邻两轮信誉变化量的绝对值小于该值时视为未变化，不计入方向反转
+	OscillationTolerance = 1e-4
+	// DefaultOscillationThreshold 振荡得分不低于该值的节点视为信誉未能稳定
+	DefaultOscillationThreshold = 0.5
+)
+
+// DetectOscillation 对模拟结束后各节点的逐轮信誉序列做振荡分析，返回每个节点的振荡得分 [0,1]
+// 只分析序列的后半段（收敛的序列此时应已稳定，后半段不足 3 个点时分析整个序列）：相邻两轮的变化量构成差分序列，
+// 得分 = 相邻差分方向反转的次数 / 可比较的差分对数；变化量小于 OscillationTolerance 的差分视为未变化，
+// 既不构成反转，也不打断反转的计数。单调或已稳定的序列得分为 0，每轮来回跳动的序列得分为 1；
+// 少于 3 个点的序列无法判断，得分为 0
+func DetectOscillation(history map[string][]float64) map[string]float64 {
+	scores := make(map[string]float64, len(history))
+	for id, series := range history {
+		tail := series[len(series)/2:]
+		if len(tail) < 3 {
+			tail = series
+		}
+		scores[id] = oscillationScore(tail)
+	}
+	return scores
+}
+
+// oscillationScore 计算单个序列的差分方向反转频率
+func oscillationScore(series []float64) float64 {
+	if len(series) < 3 {
+		return 0
+	}
+	reversals := 0
+	prevSign := 0
+	for i := 1; i < len(series); i++ {
+		diff := series[i] - series[i-1]
+		sign := 0
+		switch {
+		case diff > OscillationTolerance:
+			sign = 1
+		case diff < -OscillationTolerance:
+			sign = -1
+		}
+		if sign != 0 {
+			if prevSign != 0 && sign != prevSign {
+				reversals++
+			}
+			prevSign = sign
+		}
+	}
+	return float64(reversals) / float64(len(series)-2)
+}
+
+// OscillatingNodes 返回振荡得分不低于 threshold 的节点（按节点ID排序），即信誉序列始终未能稳定的节点
+// 非空结果说明当前参数组合下信誉未收敛，可作为参数调优的自动化信号
+func OscillatingNodes(scores map[string]float64, threshold float64) []string {
+	var ids []string
+	for id, score := range scores {
+		if score >= threshold {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
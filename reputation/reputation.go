@@ -1,17 +1,40 @@
 package reputation
 
 import (
+	"block/clock"
 	"block/config"
+	"block/logging"
+	"errors"
 	"fmt"
+	"maps"
 	"math"
+	"os"
+	"slices"
+	"sort"
+	"sync"
 	"time"
 )
 
 // Vector 表示轨迹点（速度、方向、加速度）
+// Time 为轨迹点的绝对模拟时间（秒），用于对齐进入场景时间不同的两条轨迹；
+// 轨迹中所有点的 Time 均为 0 时视为不带时间
 type Vector struct {
-	Speed        float64
-	Direction    float64
-	Acceleration float64
+	Speed        float64 `json:"speed"`
+	Direction    float64 `json:"direction"`
+	Acceleration float64 `json:"acceleration"`
+	Time         float64 `json:"time,omitempty"`
+}
+
+// component 返回第 i 个分量，顺序与 config.TrajDimensionNames 一致：0 速度、1 方向、2 加速度
+func (v Vector) component(i int) float64 {
+	switch i {
+	case 0:
+		return v.Speed
+	case 1:
+		return v.Direction
+	default:
+		return v.Acceleration
+	}
 }
 
 // TransactionType 交易类型
@@ -23,16 +46,26 @@ const (
 )
 
 // Interaction 表示一次交互事件
+// JSON 格式（字段名保持稳定，供复现用的交互文件使用）：
+//
+//	{"from": "1", "to": "2", "pos": 1, "neg": 0,
+//	 "timestamp": "2024-01-01T00:00:00Z",
+//	 "trajUser": [{"speed": 10, "direction": 0, "acceleration": 0.5}],
+//	 "trajProvider": [{"speed": 11, "direction": 0, "acceleration": 0.2}],
+//	 "txType": 0, "urgency": 0}
+//
+// timestamp 为 RFC 3339 格式；txType 0 为普通交易、1 为紧急交易；
+// 轨迹、txType 与 urgency 可省略
 type Interaction struct {
-	From          string          // 交互发起者
-	To            string          // 交互接收者
-	PosEvents     int             // 正面事件数量
-	NegEvents     int             // 负面事件数量
-	Timestamp     time.Time       // 事件发生时间
-	TrajUser      []Vector        // 信任者轨迹
-	TrajProvider  []Vector        // 被信任者轨迹
-	TxType        TransactionType // 交易类型（普通/紧急）
-	UrgencyDegree float64         // 紧急度（仅紧急交易有效）
+	From          string          `json:"from"`                   // 交互发起者
+	To            string          `json:"to"`                     // 交互接收者
+	PosEvents     int             `json:"pos"`                    // 正面事件数量
+	NegEvents     int             `json:"neg"`                    // 负面事件数量
+	Timestamp     time.Time       `json:"timestamp"`              // 事件发生时间
+	TrajUser      []Vector        `json:"trajUser,omitempty"`     // 信任者轨迹
+	TrajProvider  []Vector        `json:"trajProvider,omitempty"` // 被信任者轨迹
+	TxType        TransactionType `json:"txType"`                 // 交易类型（普通/紧急）
+	UrgencyDegree float64         `json:"urgency,omitempty"`      // 紧急度（仅紧急交易有效）
 }
 
 // SubjectiveOpinion 主观意见三元组
@@ -66,20 +99,349 @@ const (
 	MaxWeightMultiplier = 8.0
 )
 
+// ReputationProvider 信誉服务的抽象，共识与验证器选取只依赖该接口，
+// 测试中可注入返回固定信誉值的实现，无需真实的信誉计算
+type ReputationProvider interface {
+	// ComputeReputation 计算节点在 now 时刻的信誉值
+	ComputeReputation(id string, now time.Time) float64
+	// AddInteraction 记录一次交互
+	AddInteraction(inter Interaction) error
+}
+
+// ReputationManager 实现 ReputationProvider
+var _ ReputationProvider = (*ReputationManager)(nil)
+
 // ReputationManager 管理信誉计算
+// 所有方法均可并发调用：写操作持有写锁，信誉计算持有读锁
 type ReputationManager struct {
+	mutex        sync.RWMutex
 	cfg          config.Config
 	interactions []Interaction
+	// agg 按 (To,From) 增量维护的聚合交互，避免每次计算信誉时重新扫描全部交互
+	agg pairAggregates
+	// latest 全部交互中最晚的时间戳，计算时刻不早于它时可直接使用增量聚合结果
+	latest time.Time
+	// logger 逐节点对的意见计算以 debug 级别输出
+	logger logging.Logger
+	// cache ComputeReputationDelta 缓存的意见与信誉值，RemoveRater 后失效
+	cache *opinionCache
+	// changed 上次 ComputeReputationDelta 更新缓存以来新增交互的被评价者
+	changed map[string]bool
+	// similarity 替换轨迹相似度的计算（测试用），为 nil 时使用 computeTrajectorySimilarity
+	similarity func(user, prov []Vector) float64
+	// clock 节点本地时钟，默认系统时钟，可用 SetClock 注入时钟偏差
+	clock clock.Clock
+	// compacted 上次压缩后保留的交互数，自动压缩按此后新增的交互数触发
+	compacted int
+	// compactedKeys 已压缩进聚合交互的原始交互标识，Merge 据此继续去重
+	compactedKeys map[InteractionKey]bool
+	// compactedThrough 最近一次压缩时最晚交互的时间戳，零值表示从未压缩；
+	// 回放早于它的时刻时，被压缩的交互只能按聚合交互计算
+	compactedThrough time.Time
 }
 
-// NewReputationManager 创建管理器
+// NewReputationManager 创建管理器，日志输出到标准输出，级别由 cfg.LogLevel 决定
 func NewReputationManager(cfg config.Config) *ReputationManager {
-	return &ReputationManager{cfg: cfg}
+	return &ReputationManager{
+		cfg:    cfg,
+		agg:    make(pairAggregates),
+		logger: logging.New(os.Stdout, cfg.Level()),
+		clock:  clock.SystemClock{},
+	}
+}
+
+// SetLogger 设置日志输出
+func (rm *ReputationManager) SetLogger(logger logging.Logger) {
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+	rm.logger = logger
+}
+
+// SetClock 设置节点本地时钟，用于模拟节点之间的时钟偏差
+func (rm *ReputationManager) SetClock(c clock.Clock) {
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+	rm.clock = c
+}
+
+// Now 返回节点本地时钟的当前时间，调用方以它作为计算信誉值与记录交互的时刻
+func (rm *ReputationManager) Now() time.Time {
+	rm.mutex.RLock()
+	defer rm.mutex.RUnlock()
+	return rm.clock.Now()
+}
+
+// ErrSelfInteraction 交互的发起者与接收者相同（节点自评）
+var ErrSelfInteraction = errors.New("交互的发起者与接收者相同，节点不能评价自己")
+
+// AddInteraction 添加交互记录，并同步更新按节点对的聚合结果
+// From 与 To 相同的自评交互会抬高自身信誉，直接拒绝并返回 ErrSelfInteraction
+func (rm *ReputationManager) AddInteraction(inter Interaction) error {
+	if inter.From == inter.To {
+		return fmt.Errorf("%w: 节点 %s", ErrSelfInteraction, inter.From)
+	}
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+	rm.addLocked(inter)
+	return nil
 }
 
-// AddInteraction 添加交互记录
-func (rm *ReputationManager) AddInteraction(inter Interaction) {
+// AddInteractions 批量添加交互记录（如回放交互日志），只获取一次写锁
+// 批量添加是原子的：任一交互为自评时整批拒绝，返回的错误包含其序号并包装 ErrSelfInteraction
+func (rm *ReputationManager) AddInteractions(inters []Interaction) error {
+	for i, inter := range inters {
+		if inter.From == inter.To {
+			return fmt.Errorf("第 %d 条交互: %w: 节点 %s", i+1, ErrSelfInteraction, inter.From)
+		}
+	}
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+	rm.interactions = slices.Grow(rm.interactions, len(inters))
+	for _, inter := range inters {
+		rm.addLocked(inter)
+	}
+	return nil
+}
+
+// addLocked 记录交互并更新聚合结果，调用方需持有写锁
+func (rm *ReputationManager) addLocked(inter Interaction) {
 	rm.interactions = append(rm.interactions, inter)
+	rm.mergeIntoAggregate(inter)
+	rm.markChanged(inter.To)
+	if inter.Timestamp.After(rm.latest) {
+		rm.latest = inter.Timestamp
+	}
+	if threshold := rm.cfg.CompactThreshold; threshold > 0 && len(rm.interactions)-rm.compacted >= threshold {
+		rm.compactLocked()
+	}
+}
+
+// InteractionKey 交互的标识：同一发起者、接收者与时间戳的交互视为同一次观测
+type InteractionKey struct {
+	From      string
+	To        string
+	Timestamp int64 // UnixNano
+}
+
+// Key 返回交互的标识，用于节点间交换交互记录时去重
+func (inter Interaction) Key() InteractionKey {
+	return InteractionKey{From: inter.From, To: inter.To, Timestamp: inter.Timestamp.UnixNano()}
+}
+
+// ErrCompactedMerge 来源管理器已压缩交互记录，本地也有记录的节点对无法逐条去重
+var ErrCompactedMerge = errors.New("来源管理器已压缩交互记录，双方都有记录的节点对无法去重")
+
+// Merge 导入另一个管理器的交互记录（模拟节点间的 gossip 传播）
+// 按 InteractionKey 去重（包括本地已压缩的交互），已存在的交互不会重复导入，因此重复合并不会改变结果。
+// 来源已压缩时，其聚合交互无法与本地交互逐条去重：本地没有记录的节点对整体导入（保留来源的聚合结果），
+// 本地已有记录的节点对跳过，并返回包装 ErrCompactedMerge 的错误说明跳过的交互数
+func (rm *ReputationManager) Merge(other *ReputationManager) error {
+	if other == nil || other == rm {
+		return nil
+	}
+	// 先复制对方的交互记录再加锁，避免同时持有两个管理器的锁
+	src := other.mergeSource()
+
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+	if src.compactedThrough.IsZero() {
+		seen := make(map[InteractionKey]bool, len(rm.interactions)+len(rm.compactedKeys))
+		maps.Copy(seen, rm.compactedKeys)
+		for _, inter := range rm.interactions {
+			seen[inter.Key()] = true
+		}
+		for _, inter := range src.interactions {
+			key := inter.Key()
+			if seen[key] || inter.From == inter.To {
+				continue
+			}
+			seen[key] = true
+			rm.addLocked(inter)
+		}
+		return nil
+	}
+
+	type pair struct{ to, from string }
+	imported := make(map[pair]bool)
+	skipped := 0
+	for _, inter := range src.interactions {
+		p := pair{to: inter.To, from: inter.From}
+		if !imported[p] {
+			if _, known := rm.agg[p.to][p.from]; known {
+				skipped++
+				continue
+			}
+			imported[p] = true
+		}
+		rm.interactions = append(rm.interactions, inter)
+		if inter.Timestamp.After(rm.latest) {
+			rm.latest = inter.Timestamp
+		}
+	}
+	for p := range imported {
+		if _, ok := rm.agg[p.to]; !ok {
+			rm.agg[p.to] = make(map[string]pairAggregate)
+		}
+		rm.agg[p.to][p.from] = src.agg[p.to][p.from]
+		rm.markChanged(p.to)
+	}
+	if rm.compactedKeys == nil {
+		rm.compactedKeys = make(map[InteractionKey]bool)
+	}
+	for key := range src.compactedKeys {
+		if imported[pair{to: key.To, from: key.From}] {
+			rm.compactedKeys[key] = true
+		}
+	}
+	if src.compactedThrough.After(rm.compactedThrough) {
+		rm.compactedThrough = src.compactedThrough
+	}
+	if skipped > 0 {
+		return fmt.Errorf("%w: 跳过 %d 条交互", ErrCompactedMerge, skipped)
+	}
+	return nil
+}
+
+// mergeSource Merge 从来源管理器复制的状态
+type mergeSource struct {
+	interactions     []Interaction
+	agg              pairAggregates // 仅来源已压缩时复制
+	compactedKeys    map[InteractionKey]bool
+	compactedThrough time.Time
+}
+
+// mergeSource 在读锁下复制 Merge 需要的状态
+func (rm *ReputationManager) mergeSource() mergeSource {
+	rm.mutex.RLock()
+	defer rm.mutex.RUnlock()
+
+	src := mergeSource{
+		interactions:     slices.Clone(rm.interactions),
+		compactedThrough: rm.compactedThrough,
+	}
+	if !rm.compactedThrough.IsZero() {
+		src.agg = make(pairAggregates, len(rm.agg))
+		for to, fromMap := range rm.agg {
+			src.agg[to] = maps.Clone(fromMap)
+		}
+		src.compactedKeys = maps.Clone(rm.compactedKeys)
+	}
+	return src
+}
+
+// RemoveRater 删除评价者 from 发起的全部交互，返回删除的交互数（压缩后的聚合交互按一条计）
+// 用于事后认定某评价者为恶意节点时，按其评价从未存在的情形重新计算信誉，衡量其影响；
+// 增量聚合结果按 (To,From) 索引，直接删除该评价者的各项即可，无需按剩余交互重建，压缩后同样准确
+func (rm *ReputationManager) RemoveRater(from string) int {
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+
+	kept := make([]Interaction, 0, len(rm.interactions))
+	compacted := 0
+	rm.latest = time.Time{}
+	for i, inter := range rm.interactions {
+		if inter.From == from {
+			continue
+		}
+		kept = append(kept, inter)
+		if i < rm.compacted {
+			compacted++
+		}
+		if inter.Timestamp.After(rm.latest) {
+			rm.latest = inter.Timestamp
+		}
+	}
+	removed := len(rm.interactions) - len(kept)
+	if removed == 0 {
+		return 0
+	}
+
+	rm.interactions = kept
+	rm.compacted = compacted
+	for to, fromMap := range rm.agg {
+		delete(fromMap, from)
+		if len(fromMap) == 0 {
+			delete(rm.agg, to)
+		}
+	}
+	for key := range rm.compactedKeys {
+		if key.From == from {
+			delete(rm.compactedKeys, key)
+		}
+	}
+	rm.cache = nil
+	return removed
+}
+
+// Compact 将交互记录压缩为每个 (To,From,交易类型) 一条聚合交互，返回压缩后的交互数
+// 聚合交互的正负事件数为该类型的累计值，时间戳与轨迹取该类型最晚的交互，紧急度取该类型最早的交互；
+// 增量聚合结果不受影响，因此压缩前后 now 不早于最晚交互时的 ComputeReputation 与 ReputationByTxType 结果完全相同。
+// 被压缩交互的标识仍然保留，Merge 的去重不受影响（标识远小于带轨迹的交互，内存仍大幅减少）；
+// 代价是丢失逐条交互的历史：History 与快照只包含聚合交互，
+// 回放早于压缩时刻（CompactedThrough）的历史时刻只能基于聚合交互，计算时输出警告
+func (rm *ReputationManager) Compact() int {
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+	return rm.compactLocked()
+}
+
+// CompactedThrough 返回最近一次压缩时最晚交互的时间戳，从未压缩时为零值
+func (rm *ReputationManager) CompactedThrough() time.Time {
+	rm.mutex.RLock()
+	defer rm.mutex.RUnlock()
+	return rm.compactedThrough
+}
+
+// compactLocked 按 (To,From,交易类型) 聚合全部交互记录并替换之，调用方需持有写锁
+// 增量聚合结果本身不变（保留最早交互的时间戳），意见缓存仍然有效
+func (rm *ReputationManager) compactLocked() int {
+	if rm.compactedKeys == nil {
+		rm.compactedKeys = make(map[InteractionKey]bool, len(rm.interactions))
+	}
+	byType := make(map[TransactionType]pairAggregates)
+	for _, inter := range rm.interactions {
+		rm.compactedKeys[inter.Key()] = true
+		agg, ok := byType[inter.TxType]
+		if !ok {
+			agg = make(pairAggregates)
+			byType[inter.TxType] = agg
+		}
+		agg.merge(inter)
+	}
+
+	compacted := make([]Interaction, 0, len(rm.agg))
+	for _, txType := range slices.Sorted(maps.Keys(byType)) {
+		agg := byType[txType]
+		for _, to := range slices.Sorted(maps.Keys(agg)) {
+			for _, from := range slices.Sorted(maps.Keys(agg[to])) {
+				compacted = append(compacted, agg[to][from].Interaction)
+			}
+		}
+	}
+	rm.interactions = compacted
+	rm.compacted = len(compacted)
+	if rm.latest.After(rm.compactedThrough) {
+		rm.compactedThrough = rm.latest
+	}
+	return len(compacted)
+}
+
+// History 返回以 target 为被评价者的全部交互记录，按时间升序排列
+// 每条记录包含评价者、正负事件数、交易类型与紧急度，可用于解释信誉值的变化原因
+func (rm *ReputationManager) History(target string) []Interaction {
+	rm.mutex.RLock()
+	defer rm.mutex.RUnlock()
+
+	var history []Interaction
+	for _, inter := range rm.interactions {
+		if inter.To == target {
+			history = append(history, inter)
+		}
+	}
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].Timestamp.Before(history[j].Timestamp)
+	})
+	return history
 }
 
 // CalculateTransactionWeight 计算交易类型对信誉的影响权重
@@ -113,134 +475,444 @@ func CalculateTransactionWeight(txType TransactionType, urgencyDegree float64) f
 }
 
 // ComputeReputation 计算最终信誉值
+// now 可以是任意历史时刻：时间戳晚于 now 的交互不参与计算，便于回放信誉随时间的变化
 func (rm *ReputationManager) ComputeReputation(target string, now time.Time) float64 {
-	agg := rm.aggregateByPair()
+	rm.mutex.RLock()
+	defer rm.mutex.RUnlock()
+	return rm.reputationFrom(rm.aggregateByPair(now), target, now)
+}
+
+// ComputeAllReputations 计算截至 now 出现过的节点（评价者与被评价者）的信誉值
+// 只作为评价者出现的节点没有被评价记录，信誉值为初始信誉值
+func (rm *ReputationManager) ComputeAllReputations(now time.Time) map[string]float64 {
+	rm.mutex.RLock()
+	nodes := make(map[string]bool)
+	for _, inter := range rm.interactions {
+		if inter.Timestamp.After(now) {
+			continue
+		}
+		nodes[inter.From] = true
+		nodes[inter.To] = true
+	}
+	rm.mutex.RUnlock()
+
+	reputations := make(map[string]float64, len(nodes))
+	for node := range nodes {
+		reputations[node] = rm.ComputeReputation(node, now)
+	}
+	return reputations
+}
+
+// ComputeOpinion 计算目标节点融合直接与间接意见后的主观意见
+// 只使用时间戳不晚于 now 的交互；目标节点截至 now 没有任何交互记录时返回 false
+func (rm *ReputationManager) ComputeOpinion(target string, now time.Time) (SubjectiveOpinion, bool) {
+	rm.mutex.RLock()
+	defer rm.mutex.RUnlock()
+	return rm.opinionFrom(rm.aggregateByPair(now), target, now)
+}
+
+// DirectOpinion 计算评价者 from 对被评价者 to 截至 now 的直接意见和权重，与完整计算中的直接意见一致
+// 平均事件数与 θ 仍由 to 的全部评价者共同决定，但不计算其他被评价者的直接意见（开启 RaterCredibility 时除外，
+// 评价者可信度需要全部直接意见）；from 截至 now 没有评价过 to 时返回 false
+func (rm *ReputationManager) DirectOpinion(from, to string, now time.Time) (DirectOpinion, bool) {
+	rm.mutex.RLock()
+	defer rm.mutex.RUnlock()
+
+	agg := rm.aggregateByPair(now)
+	if _, exists := agg[to][from]; !exists {
+		return DirectOpinion{}, false
+	}
+	var opinions map[string]DirectOpinion
+	if rm.cfg.RaterCredibility {
+		opinions = rm.directOpinions(agg, now)[to]
+	} else {
+		opinions = rm.directOpinionsOf(to, agg[to], now, nil)
+	}
+	return opinions[from], true
+}
+
+// ReputationByTxType 分别只用普通交易与只用紧急交易的交互计算目标节点的信誉值，
+// 用于观察节点的信誉有多少来自紧急交易、紧急交易的加权是否符合预期
+// 每种类型单独聚合并计算直接与间接意见（交易类型权重照常生效）；
+// 截至 now 没有该类型交互的节点，该类型的信誉值为初始信誉值
+func (rm *ReputationManager) ReputationByTxType(target string, now time.Time) (normal, emergency float64) {
+	rm.mutex.RLock()
+	defer rm.mutex.RUnlock()
+
+	normalAgg, emergencyAgg := make(pairAggregates), make(pairAggregates)
+	for _, inter := range rm.interactions {
+		if inter.Timestamp.After(now) {
+			continue
+		}
+		if inter.TxType == EmergencyTransaction {
+			emergencyAgg.merge(inter)
+		} else {
+			normalAgg.merge(inter)
+		}
+	}
+	return rm.reputationFrom(normalAgg, target, now), rm.reputationFrom(emergencyAgg, target, now)
+}
+
+// reputationFrom 基于给定的聚合交互计算目标节点的信誉值，调用方需持有锁
+func (rm *ReputationManager) reputationFrom(agg pairAggregates, target string, now time.Time) float64 {
+	final, ok := rm.opinionFrom(agg, target, now)
 
 	// 如果目标节点没有任何交互记录，返回初始信誉值
+	if !ok {
+		return rm.initialReputation(target)
+	}
+
+	return final.T + rm.cfg.Gamma*final.I
+}
+
+// initialReputation 返回节点没有被评价记录时的信誉值：RSU 为 RSUInitialReputation，车辆为 InitialReputation
+// RSU 不发送交易，通常不会被评价，信誉值一直保持初始信任；
+// 一旦有以 RSU 为被评价者的交互（如作为紧急区块提议者获得的出块奖励评价），即与车辆一样完全由交互计算，初始信任不再参与
+func (rm *ReputationManager) initialReputation(id string) float64 {
+	if rm.cfg.IsRSU(id) {
+		return rm.cfg.RSUInitialReputation
+	}
+	return InitialReputation
+}
+
+// opinionFrom 基于给定的聚合交互计算目标节点融合后的主观意见，调用方需持有锁
+func (rm *ReputationManager) opinionFrom(agg pairAggregates, target string, now time.Time) (SubjectiveOpinion, bool) {
 	if _, exists := agg[target]; !exists {
-		return InitialReputation
+		return SubjectiveOpinion{}, false
 	}
 
-	direct := rm.computeDirectOpinions(agg, now)
+	direct := rm.directOpinions(agg, now)
+	if !rm.cfg.UseIndirect {
+		// 只采信直接意见：跳过间接意见的路径搜索，融合退化为直接意见的加权平均
+		return rm.fuseOpinions(direct[target], nil), true
+	}
 	indirect := rm.computeIndirectOpinions(direct)
-	final := rm.fuseOpinions(direct[target], indirect[target])
-	return final.T + rm.cfg.Gamma*final.I
+	return rm.fuseOpinions(direct[target], indirect[target]), true
 }
 
-// aggregateByPair 聚合交互按 (To,From)
-func (rm *ReputationManager) aggregateByPair() map[string]map[string]Interaction {
-	agg := make(map[string]map[string]Interaction)
+// directOpinions 计算聚合交互对应的直接意见，开启 RaterCredibility 时按评价者可信度加权
+func (rm *ReputationManager) directOpinions(agg pairAggregates, now time.Time) directOpinionsMap {
+	direct := rm.computeDirectOpinions(agg, now, nil)
+	if rm.cfg.RaterCredibility {
+		direct = rm.computeDirectOpinions(agg, now, raterCredibility(direct, rm.cfg.Gamma, rm.initialReputation))
+	}
+	return direct
+}
+
+// aggregateByPair 返回截至 now 按 (To,From) 聚合的交互，调用方需持有锁且只读不改
+// now 不早于最晚的交互时直接返回 AddInteraction 中增量维护的聚合结果；
+// 否则（回放历史时刻）排除时间戳晚于 now 的交互后重新聚合
+func (rm *ReputationManager) aggregateByPair(now time.Time) pairAggregates {
+	if !rm.latest.After(now) {
+		return rm.agg
+	}
+	if now.Before(rm.compactedThrough) {
+		rm.logger.Warnf("回放时刻 %s 早于交互记录的压缩时刻 %s，被压缩的交互只能按聚合交互计算\n",
+			now.Format(time.RFC3339), rm.compactedThrough.Format(time.RFC3339))
+	}
+	agg := make(pairAggregates)
 	for _, inter := range rm.interactions {
-		if _, ok := agg[inter.To]; !ok {
-			agg[inter.To] = make(map[string]Interaction)
+		if !inter.Timestamp.After(now) {
+			agg.merge(inter)
 		}
-		exist, ok := agg[inter.To][inter.From]
-		if !ok {
-			agg[inter.To][inter.From] = inter
-		} else {
-			exist.PosEvents += inter.PosEvents
-			exist.NegEvents += inter.NegEvents
-			if inter.Timestamp.After(exist.Timestamp) {
-				exist.Timestamp = inter.Timestamp
-				exist.TrajUser = inter.TrajUser
-				exist.TrajProvider = inter.TrajProvider
+	}
+	return agg
+}
+
+// pairAggregate 一对节点 (To,From) 的聚合交互
+type pairAggregate struct {
+	Interaction           // 聚合后的交互：事件数为累计值，时间戳与轨迹取最晚的交互
+	earliest    time.Time // 最早交互的时间戳，交易类型与紧急度取自该交互
+}
+
+// pairAggregates 按 [To][From] 索引的聚合交互
+type pairAggregates map[string]map[string]pairAggregate
+
+// mergeIntoAggregate 将一条交互合并进增量维护的聚合结果
+func (rm *ReputationManager) mergeIntoAggregate(inter Interaction) {
+	if rm.agg == nil {
+		rm.agg = make(pairAggregates)
+	}
+	rm.agg.merge(inter)
+}
+
+// merge 将一条交互合并进聚合结果：正负事件数累加，
+// 时间戳最晚的交互决定时间戳与轨迹，时间戳最早的交互决定交易类型与紧急度；
+// 时间戳相同时按确定性规则取舍，因此聚合结果与交互的添加顺序无关，
+// 多个协程并发添加交互不影响信誉计算
+func (agg pairAggregates) merge(inter Interaction) {
+	// 防御性检查：自评交互不参与聚合
+	if inter.From == inter.To {
+		return
+	}
+	if _, ok := agg[inter.To]; !ok {
+		agg[inter.To] = make(map[string]pairAggregate)
+	}
+	exist, ok := agg[inter.To][inter.From]
+	if !ok {
+		agg[inter.To][inter.From] = pairAggregate{Interaction: inter, earliest: inter.Timestamp}
+		return
+	}
+	exist.PosEvents += inter.PosEvents
+	exist.NegEvents += inter.NegEvents
+	if inter.Timestamp.After(exist.Timestamp) ||
+		(inter.Timestamp.Equal(exist.Timestamp) && trajectoriesLess(exist.Interaction, inter)) {
+		exist.Timestamp = inter.Timestamp
+		exist.TrajUser = inter.TrajUser
+		exist.TrajProvider = inter.TrajProvider
+	}
+	if inter.Timestamp.Before(exist.earliest) ||
+		(inter.Timestamp.Equal(exist.earliest) && txKindLess(exist.Interaction, inter)) {
+		exist.earliest = inter.Timestamp
+		exist.TxType = inter.TxType
+		exist.UrgencyDegree = inter.UrgencyDegree
+	}
+	agg[inter.To][inter.From] = exist
+}
+
+// txKindLess 时间戳相同时交易类型与紧急度的取舍规则：交易类型更大（紧急交易）者优先，其次紧急度更高者优先
+func txKindLess(a, b Interaction) bool {
+	if a.TxType != b.TxType {
+		return a.TxType < b.TxType
+	}
+	return a.UrgencyDegree < b.UrgencyDegree
+}
+
+// trajectoriesLess 时间戳相同时轨迹的取舍规则：按 (TrajUser, TrajProvider) 的字典序，更大者优先
+func trajectoriesLess(a, b Interaction) bool {
+	if c := compareTrajectories(a.TrajUser, b.TrajUser); c != 0 {
+		return c < 0
+	}
+	return compareTrajectories(a.TrajProvider, b.TrajProvider) < 0
+}
+
+// compareTrajectories 按轨迹点逐分量比较两条轨迹的字典序，返回 -1、0 或 1
+func compareTrajectories(a, b []Vector) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		for _, d := range [3][2]float64{
+			{a[i].Speed, b[i].Speed},
+			{a[i].Direction, b[i].Direction},
+			{a[i].Acceleration, b[i].Acceleration},
+		} {
+			if d[0] < d[1] {
+				return -1
+			}
+			if d[0] > d[1] {
+				return 1
 			}
-			agg[inter.To][inter.From] = exist
 		}
 	}
-	return agg
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return 1
+	}
+	return 0
 }
 
 // computeDirectOpinions 计算每对节点的直接意见和权重，并输出调试信息
 type directOpinionsMap map[string]map[string]DirectOpinion
 
+// credibility 为评价者的可信度 [0,1]，交互权重按评价者可信度缩放；为 nil 时所有评价者同等可信
 func (rm *ReputationManager) computeDirectOpinions(
-	agg map[string]map[string]Interaction,
+	agg pairAggregates,
 	now time.Time,
+	credibility func(rater string) float64,
 ) directOpinionsMap {
 	direct := make(directOpinionsMap)
 	for to, fromMap := range agg {
-		// 计算平均事件数
-		var sumCnt float64
-		for _, inter := range fromMap {
-			sumCnt += float64(inter.PosEvents + inter.NegEvents)
-		}
-		avgCnt := 1.0
-		if len(fromMap) > 0 {
-			avgCnt = sumCnt / float64(len(fromMap))
-		}
-		// 计算 θ
-		var errNum, errDen float64
-		tmp := make(map[string]DirectOpinion)
-		for from, inter := range fromMap {
-			Fi := float64(inter.PosEvents+inter.NegEvents) / avgCnt
-			delta := now.Sub(inter.Timestamp).Seconds()
-			fmt.Printf("DEBUG now=%s inter.Timestamp=%s \n", now.Format("2006-01-02 15:04:05"), inter.Timestamp.Format("2006-01-02 15:04:05"))
-			var TIM float64
-			if delta <= 0 {
-				// TODO: 目前每轮所有节点都是delta < 0
-				// TIM == 1
-				TIM = rm.cfg.Eta
-			} else {
-				TIM = rm.cfg.Eta * math.Pow(delta, -rm.cfg.Epsilon)
+		direct[to] = rm.directOpinionsOf(to, fromMap, now, credibility)
+	}
+	return direct
+}
+
+// directOpinionsOf 计算所有评价者对被评价者 to 的直接意见和权重
+// 平均事件数与 θ 由 to 的全部评价者共同决定，因此单对节点的直接意见也需基于 to 的全部聚合交互计算
+func (rm *ReputationManager) directOpinionsOf(
+	to string,
+	fromMap map[string]pairAggregate,
+	now time.Time,
+	credibility func(rater string) float64,
+) map[string]DirectOpinion {
+	// 计算平均事件数（按正负事件权重加权）
+	var sumCnt float64
+	for _, inter := range fromMap {
+		pos, neg := rm.weightedEvents(inter.Interaction)
+		sumCnt += pos + neg
+	}
+	// 所有交互都没有事件时平均数为 0，保持为 1 以免 Fi = 0/0 使权重变为 NaN
+	avgCnt := 1.0
+	if len(fromMap) > 0 && sumCnt > 0 {
+		avgCnt = sumCnt / float64(len(fromMap))
+	}
+	// 计算 θ
+	var errNum, errDen float64
+	tmp := make(map[string]DirectOpinion)
+	for from, inter := range fromMap {
+		pos, neg := rm.weightedEvents(inter.Interaction)
+		Fi := (pos + neg) / avgCnt
+		delta := now.Sub(inter.Timestamp).Seconds()
+		rm.logger.Debugf("DEBUG now=%s inter.Timestamp=%s \n", now.Format("2006-01-02 15:04:05"), inter.Timestamp.Format("2006-01-02 15:04:05"))
+		TIM := rm.timeDecay(inter.TxType, delta)
+		// 原始权重计算，按评价者可信度缩放，低信誉节点的评价（包括恶意差评）影响更小；
+		// MinTrajSimilarity 为负时相似度项可能拉低权重，权重至少为 0
+		var sim, baseWeight float64
+		if !rm.cfg.UseTrajectorySimilarity || rm.cfg.IsRSU(from) || rm.cfg.IsRSU(to) {
+			// 关闭轨迹相似度或 RSU 参与（RSU 没有轨迹数据）时不计算轨迹相似度，
+			// 频率与时效性两项按 Rho1、Rho2 重新归一化
+			sim = math.NaN()
+			if rho := rm.cfg.Rho1 + rm.cfg.Rho2; rho > 0 {
+				baseWeight = (rm.cfg.Rho1*Fi + rm.cfg.Rho2*TIM) / rho
 			}
-			sim := rm.computeTrajectorySimilarity(inter.TrajUser, inter.TrajProvider)
+		} else {
+			// 轨迹相似度限制在 [MinTrajSimilarity,1] 内，反相关的轨迹不会使权重变号
+			sim = math.Max(rm.cfg.MinTrajSimilarity, math.Min(1, rm.trajectorySimilarity(inter.TrajUser, inter.TrajProvider)))
+			baseWeight = math.Max(0, rm.cfg.Rho1*Fi+rm.cfg.Rho2*TIM+rm.cfg.Rho3*sim)
+		}
+		cred := 1.0
+		if credibility != nil {
+			cred = credibility(from)
+		}
+		baseWeight *= cred
 
-			// 原始权重计算
-			baseWeight := rm.cfg.Rho1*Fi + rm.cfg.Rho2*TIM + rm.cfg.Rho3*sim
+		// ⭐ 新增：计算交易类型影响权重
+		txWeight := rm.transactionWeight(inter.Interaction)
 
-			// ⭐ 新增：计算交易类型影响权重
-			txWeight := CalculateTransactionWeight(inter.TxType, inter.UrgencyDegree)
+		// ⭐ 最终权重 = 原始权重 × 交易类型权重
+		weight := baseWeight * txWeight
 
-			// ⭐ 最终权重 = 原始权重 × 交易类型权重
-			weight := baseWeight * txWeight
+		// 修改：不确定度由交互次数决定，而不是轨迹相似度
+		totalEvents := pos + neg
+		Ii := 2.0 / (2.0 + totalEvents)
 
-			// 修改：不确定度由交互次数决定，而不是轨迹相似度
-			totalEvents := float64(inter.PosEvents + inter.NegEvents)
-			Ii := 2.0 / (2.0 + totalEvents)
+		// 调试输出（增加交易类型和权重信息）
+		txTypeStr := "Normal"
+		if inter.TxType == EmergencyTransaction {
+			txTypeStr = "Emergency"
+		}
+		rm.logger.Debugf("DEBUG Direct: to=%s from=%s delta=%.3f TIM=%.3f sim=%.3f cred=%.3f baseWeight=%.3f txType=%s txWeight=%.3f finalWeight=%.3f totalEvents=%.0f Ii=%.3f\n",
+			to, from, delta, TIM, sim, cred, baseWeight, txTypeStr, txWeight, weight, totalEvents, Ii)
 
-			// 调试输出（增加交易类型和权重信息）
-			txTypeStr := "Normal"
-			if inter.TxType == EmergencyTransaction {
-				txTypeStr = "Emergency"
-			}
-			fmt.Printf("DEBUG Direct: to=%s from=%s delta=%.3f TIM=%.3f sim=%.3f baseWeight=%.3f txType=%s txWeight=%.3f finalWeight=%.3f totalEvents=%.0f Ii=%.3f\n",
-				to, from, delta, TIM, sim, baseWeight, txTypeStr, txWeight, weight, totalEvents, Ii)
-
-			tmp[from] = DirectOpinion{Opinion: SubjectiveOpinion{I: Ii}, Weight: weight}
-			errNum += weight * float64(inter.NegEvents)
-			errDen += weight
-		}
-		theta := 0.0
-		if errDen != 0 {
-			theta = rm.cfg.Mu / (1 + math.Exp(errNum/errDen))
-		}
-		// 填充 Opinion.T 和 Opinion.D，并调试
-		direct[to] = make(map[string]DirectOpinion)
-		for from, inter := range fromMap {
-			d := tmp[from]
-			alpha := (1 - theta) * float64(inter.PosEvents)
-			beta := theta * float64(inter.NegEvents)
-			sumEvt := alpha + beta
-			if sumEvt > 0 {
-				d.Opinion.T = (1 - d.Opinion.I) * alpha / sumEvt
-				d.Opinion.D = (1 - d.Opinion.I) * beta / sumEvt
-			}
-			// fmt.Printf("DEBUG T/D: to=%s from=%s T=%.3f D=%.3f theta=%.3f\n", to, from, d.Opinion.T, d.Opinion.D, theta)
-			direct[to][from] = d
+		tmp[from] = DirectOpinion{Opinion: SubjectiveOpinion{I: Ii}, Weight: weight}
+		errNum += weight * neg
+		errDen += weight
+	}
+	// θ = Mu / (1 + exp(steepness × (ratio - midpoint)))，ratio 为加权负面事件比
+	theta := 0.0
+	if errDen != 0 {
+		ratio := errNum / errDen
+		theta = rm.cfg.Mu / (1 + math.Exp(rm.cfg.ThetaSteepness*(ratio-rm.cfg.ThetaMidpoint)))
+	}
+	// 填充 Opinion.T 和 Opinion.D，并调试
+	opinions := make(map[string]DirectOpinion, len(fromMap))
+	for from, inter := range fromMap {
+		d := tmp[from]
+		pos, neg := rm.weightedEvents(inter.Interaction)
+		alpha := (1 - theta) * pos
+		beta := theta * neg
+		sumEvt := alpha + beta
+		if sumEvt > 0 {
+			d.Opinion.T = (1 - d.Opinion.I) * alpha / sumEvt
+			d.Opinion.D = (1 - d.Opinion.I) * beta / sumEvt
 		}
+		// fmt.Printf("DEBUG T/D: to=%s from=%s T=%.3f D=%.3f theta=%.3f\n", to, from, d.Opinion.T, d.Opinion.D, theta)
+		opinions[from] = d
 	}
-	return direct
+	return opinions
+}
+
+// transactionWeight 计算交互的交易类型权重：在 CalculateTransactionWeight 的基础上，
+// 含负面事件的紧急交易再乘以 NegEmergencyMultiplier，使惩罚的放大独立于奖励的放大（不受 MaxWeightMultiplier 约束）
+func (rm *ReputationManager) transactionWeight(inter Interaction) float64 {
+	weight := CalculateTransactionWeight(inter.TxType, inter.UrgencyDegree)
+	if inter.TxType == EmergencyTransaction && inter.NegEvents > 0 {
+		weight *= rm.cfg.NegEmergencyMultiplier
+	}
+	return weight
+}
+
+// timeDecay 计算时效性，delta 为交互距今的秒数
+// 幂律衰减（默认）TIM = Eta × delta^(-ε)，紧急交易使用 EpsilonEmergency，普通交易使用 Epsilon；
+// 指数衰减 TIM = Eta × exp(-ln2 × delta/HalfLife)，两类交易使用相同的半衰期
+func (rm *ReputationManager) timeDecay(txType TransactionType, delta float64) float64 {
+	if delta <= 0 {
+		// TODO: 目前每轮所有节点都是delta < 0
+		// TIM == 1
+		return rm.cfg.Eta
+	}
+	if rm.cfg.TimeDecay == config.TimeDecayExponential {
+		return rm.cfg.Eta * math.Exp(-math.Ln2*delta/rm.cfg.HalfLife)
+	}
+	epsilon := rm.cfg.Epsilon
+	if txType == EmergencyTransaction {
+		epsilon = rm.cfg.EmergencyEpsilon()
+	}
+	return rm.cfg.Eta * math.Pow(delta, -epsilon)
+}
+
+// raterCredibility 根据直接意见估计各节点作为评价者的可信度：
+// 取其他节点对它的直接意见按权重平均后的 T + Gamma×I，限制在 [0,1] 内；
+// 没有被评价过的节点可信度为其初始信誉值（RSU 为 RSUInitialReputation）
+// 只使用直接意见，避免评价者信誉与被评价者信誉相互递归
+func raterCredibility(direct directOpinionsMap, gamma float64, initial func(id string) float64) func(rater string) float64 {
+	cred := make(map[string]float64, len(direct))
+	for node, fromMap := range direct {
+		var sumW, sumRepu float64
+		for _, d := range fromMap {
+			sumW += d.Weight
+			sumRepu += (d.Opinion.T + gamma*d.Opinion.I) * d.Weight
+		}
+		if sumW > 0 {
+			cred[node] = math.Max(0, math.Min(1, sumRepu/sumW))
+		}
+	}
+	return func(rater string) float64 {
+		if c, ok := cred[rater]; ok {
+			return c
+		}
+		return initial(rater)
+	}
+}
+
+// weightedEvents 返回按 PosWeight、NegWeight 加权后的正负事件数
+func (rm *ReputationManager) weightedEvents(inter Interaction) (pos, neg float64) {
+	return rm.cfg.PosWeight * float64(inter.PosEvents), rm.cfg.NegWeight * float64(inter.NegEvents)
 }
 
+// indirectHopCount 间接意见路径最多允许的边数（即 indirectHopCount+1 个节点），可根据需要调整或从 cfg 中读取
+const indirectHopCount = 2
+
 // computeIndirectOpinions 基于直接意见生成多跳间接意见
 func (rm *ReputationManager) computeIndirectOpinions(
 	direct directOpinionsMap,
 ) map[string]map[string]SubjectiveOpinion {
-	// 最多允许 hopCount 条边（即 hopCount+1 个节点），可根据需要调整或从 cfg 中读取
-	const hopCount = 2
-
 	indirect := make(map[string]map[string]SubjectiveOpinion)
+	neighbors := indirectNeighbors(direct)
+	for target := range direct {
+		indirect[target] = rm.indirectOpinionsOf(direct, neighbors, target)
+	}
+	return indirect
+}
+
+// indirectNeighbors 返回每个节点的评价者列表 [节点][]评价者，用于间接意见的路径搜索
+func indirectNeighbors(direct directOpinionsMap) map[string][]string {
+	neighbors := make(map[string][]string, len(direct))
+	for node, fromMap := range direct {
+		for from := range fromMap {
+			neighbors[node] = append(neighbors[node], from)
+		}
+		sort.Strings(neighbors[node])
+	}
+	return neighbors
+}
+
+// indirectOpinionsOf 计算各 source 节点经多跳路径对 target 的间接意见 [source]意见
+func (rm *ReputationManager) indirectOpinionsOf(
+	direct directOpinionsMap,
+	neighbors map[string][]string,
+	target string,
+) map[string]SubjectiveOpinion {
 	// 辅助函数：判断 slice 中是否包含元素 s
 	contains := func(slice []string, s string) bool {
 		for _, v := range slice {
@@ -251,78 +923,82 @@ func (rm *ReputationManager) computeIndirectOpinions(
 		return false
 	}
 
-	for target, _ := range direct {
-		indirect[target] = make(map[string]SubjectiveOpinion)
-		// 对每个可能的 source 节点
-		for source := range direct {
-			if source == target {
-				continue
+	maxPaths := rm.cfg.MaxPaths
+
+	opinions := make(map[string]SubjectiveOpinion)
+	// 对每个可能的 source 节点
+	for source := range direct {
+		if source == target {
+			continue
+		}
+		// 收集从 source 到 target 的路径，设置了 MaxPaths 时收集到上限即停止
+		var paths [][]string
+		var dfs func(path []string)
+		dfs = func(path []string) {
+			if maxPaths > 0 && len(paths) >= maxPaths {
+				return
 			}
-			// 收集所有从 source 到 target 的路径
-			var paths [][]string
-			var dfs func(path []string)
-			dfs = func(path []string) {
-				last := path[len(path)-1]
-				// 如果超过 hopCount 条边，就返回
-				if len(path)-1 > hopCount {
-					return
-				}
-				// 找到一条以 target 结尾的路径，且非直接源（len(path)>1）
-				if last == target && len(path) > 1 {
-					p := make([]string, len(path))
-					copy(p, path)
-					paths = append(paths, p)
-					return
-				}
-				// 否则继续沿 direct[last] 的邻居扩展
-				for next := range direct[last] {
-					if contains(path, next) {
-						continue // 避免环路
-					}
-					dfs(append(path, next))
-				}
+			last := path[len(path)-1]
+			// 如果超过 indirectHopCount 条边，就返回
+			if len(path)-1 > indirectHopCount {
+				return
+			}
+			// 找到一条以 target 结尾的路径，且非直接源（len(path)>1）
+			if last == target && len(path) > 1 {
+				p := make([]string, len(path))
+				copy(p, path)
+				paths = append(paths, p)
+				return
 			}
-			dfs([]string{source})
-
-			// 对每条路径做折扣运算并累加
-			var sumW float64
-			for _, path := range paths {
-				// 路径示例: [source, m1, ..., target]
-				// 初始化为路径起点
-				T, D, I := 1.0, 0.0, 0.0
-				w := 1.0
-				// 遍历路径上的每一条边
-				for i := 0; i < len(path)-1; i++ {
-					from := path[i]
-					toNode := path[i+1]
-					// directOpinionsMap 是映射 direct[toNode][from]
-					d := direct[toNode][from]
-					// 折扣算子（discounting）：
-					Tnew := T * d.Opinion.T
-					Dnew := T * d.Opinion.D
-					Inew := D + I + T*d.Opinion.I
-					T, D, I = Tnew, Dnew, Inew
-					w *= d.Weight
+			// 否则继续沿 direct[last] 的邻居扩展
+			for _, next := range neighbors[last] {
+				if contains(path, next) {
+					continue // 避免环路
 				}
-				// 累加加权意见
-				agg := indirect[target][source]
-				agg.T += T * w
-				agg.D += D * w
-				agg.I += I * w
-				indirect[target][source] = agg
-				sumW += w
+				dfs(append(path, next))
 			}
-			// 归一化
-			if sumW > 0 {
-				v := indirect[target][source]
-				v.T /= sumW
-				v.D /= sumW
-				v.I /= sumW
-				indirect[target][source] = v
+		}
+		dfs([]string{source})
+
+		// 对每条路径做折扣运算并累加
+		var sumW float64
+		for _, path := range paths {
+			// 路径示例: [source, m1, ..., target]
+			// 初始化为路径起点
+			T, D, I := 1.0, 0.0, 0.0
+			// 逐跳衰减：路径每多一条边，权重再乘一次 Lambda，单边路径不衰减
+			w := math.Pow(rm.cfg.Lambda, float64(len(path)-2))
+			// 遍历路径上的每一条边
+			for i := 0; i < len(path)-1; i++ {
+				from := path[i]
+				toNode := path[i+1]
+				// directOpinionsMap 是映射 direct[toNode][from]
+				d := direct[toNode][from]
+				// 折扣算子（discounting）：
+				Tnew := T * d.Opinion.T
+				Dnew := T * d.Opinion.D
+				Inew := D + I + T*d.Opinion.I
+				T, D, I = Tnew, Dnew, Inew
+				w *= d.Weight
 			}
+			// 累加加权意见
+			agg := opinions[source]
+			agg.T += T * w
+			agg.D += D * w
+			agg.I += I * w
+			opinions[source] = agg
+			sumW += w
+		}
+		// 归一化
+		if sumW > 0 {
+			v := opinions[source]
+			v.T /= sumW
+			v.D /= sumW
+			v.I /= sumW
+			opinions[source] = v
 		}
 	}
-	return indirect
+	return opinions
 }
 
 // fuseOpinions 融合直接与间接意见
@@ -362,48 +1038,203 @@ func (rm *ReputationManager) fuseOpinions(
 		Dind = sumDind / float64(len(ind))
 		Iind = sumIind / float64(len(ind))
 	}
-	// 共识算子融合 - 按照论文公式(13)
-	// k = I^dir_C * I^ind_C + T^ind_C * I^dir_C + D^ind_C * I^dir_C
-	k := Idir*Iind + Tind*Idir + Dind*Idir
+	direct := SubjectiveOpinion{T: Tdir, D: Ddir, I: Idir}
+	indirect := SubjectiveOpinion{T: Tind, D: Dind, I: Iind}
+
+	// DirectWeight 决定直接意见的主导程度：1 只用直接意见，0 只用间接意见，
+	// 0.5 为原始的共识融合；其余取值在共识融合结果与对应一侧意见之间线性插值
+	w := rm.cfg.DirectWeight
+	switch {
+	case w >= 1:
+		return direct
+	case w <= 0:
+		return indirect
+	}
+	fused := consensusOpinion(direct, indirect)
+	if w >= 0.5 {
+		return blendOpinions(fused, direct, 2*w-1)
+	}
+	return blendOpinions(indirect, fused, 2*w)
+}
+
+// consensusOpinion 共识算子融合 - 按照论文公式(13)
+// k = I^dir_C * I^ind_C + T^ind_C * I^dir_C + D^ind_C * I^dir_C
+func consensusOpinion(dir, ind SubjectiveOpinion) SubjectiveOpinion {
+	k := dir.I*ind.I + ind.T*dir.I + ind.D*dir.I
+	// 直接意见没有不确定度时 k = 0，共识算子无定义（0/0 得到 NaN），退化为直接意见
+	if k == 0 {
+		return dir
+	}
+	return SubjectiveOpinion{
+		T: (dir.T*ind.I + ind.T*dir.I) / k,
+		D: (dir.D*ind.I + ind.D*dir.I) / k,
+		I: (dir.I * ind.I) / k,
+	}
+}
+
+// blendOpinions 按比例 t ∈ [0,1] 在意见 a 与 b 之间线性插值，t=0 为 a，t=1 为 b
+func blendOpinions(a, b SubjectiveOpinion, t float64) SubjectiveOpinion {
 	return SubjectiveOpinion{
-		T: (Tdir*Iind + Tind*Idir) / k,
-		D: (Ddir*Iind + Dind*Idir) / k,
-		I: (Idir * Iind) / k,
+		T: (1-t)*a.T + t*b.T,
+		D: (1-t)*a.D + t*b.D,
+		I: (1-t)*a.I + t*b.I,
 	}
 }
 
-// computeTrajectorySimilarity 计算轨迹相似度：速度、方向、加速度三分量
+// trajectorySimilarity 计算轨迹相似度，设置了 similarity 时使用它
+func (rm *ReputationManager) trajectorySimilarity(user, prov []Vector) float64 {
+	if rm.similarity != nil {
+		return rm.similarity(user, prov)
+	}
+	return rm.computeTrajectorySimilarity(user, prov)
+}
+
+// computeTrajectorySimilarity 计算轨迹相似度：速度、方向、加速度三分量中 TrajDimensions 启用的分量
+// 启用的分量各自独立计算相似度，再按重新归一化的 Tau 加权（见 Config.TrajDimensionWeights）：
+//   - cosine（默认）: 分量序列的余弦相似度，取值 [-1,1]，只反映变化模式，
+//     两车方向模式一致但速度相差很大时仍判为完全相似
+//   - euclidean: 分量序列逐点差值的均方根 d，相似度 1/(1+d) ∈ (0,1]
+//   - manhattan: 分量序列逐点差值绝对值的平均 d，相似度 1/(1+d) ∈ (0,1]
+//
+// 距离类度量对幅值敏感，且各分量使用原始单位（速度 m/s、方向 rad、加速度 m/s²），
+// 因此速度分量的差异通常主导距离，方向分量的差异最小。
+// 距离按点数取平均，轨迹变长不会单调拉低相似度。
 func (rm *ReputationManager) computeTrajectorySimilarity(user, prov []Vector) float64 {
-	n := len(user)
-	if len(prov) < n {
-		n = len(prov)
+	user, prov = alignTrajectories(user, prov)
+	weights := rm.cfg.TrajDimensionWeights()
+
+	// 启用的分量加权融合，未启用的分量（权重为 0）不提取序列也不计算相似度；
+	// 相似度无定义的分量（如余弦度量下加速度全为 0）不参与融合，其余分量的权重重新归一化，
+	// 避免该分量按 0 计入而拉低整体相似度
+	var sum, usedWeight float64
+	allDefined := true
+	for i, w := range weights {
+		if w == 0 {
+			continue
+		}
+		a, b := make([]float64, len(user)), make([]float64, len(prov))
+		for j := range user {
+			a[j], b[j] = user[j].component(i), prov[j].component(i)
+		}
+		sim, ok := rm.componentSimilarity(a, b)
+		if !ok {
+			allDefined = false
+			continue
+		}
+		sum += weights[i] * sim
+		usedWeight += weights[i]
 	}
-	var uspd, vspd, udir, vdir, uacc, vacc []float64
-	for i := 0; i < n; i++ {
-		uspd = append(uspd, user[i].Speed)
-		vspd = append(vspd, prov[i].Speed)
-		udir = append(udir, user[i].Direction)
-		vdir = append(vdir, prov[i].Direction)
-		uacc = append(uacc, user[i].Acceleration)
-		vacc = append(vacc, prov[i].Acceleration)
-	}
-	sspd := cosineSimilarity(uspd, vspd)
-	sdir := cosineSimilarity(udir, vdir)
-	sacc := cosineSimilarity(uacc, vacc)
-	// fmt.Println("DEBUG Trajectory: sspd=", sspd, "sdir=", sdir, "sacc=", sacc)
-	// 三者加权融合，使用配置中的 Tau1、Tau2、Tau3
-	return rm.cfg.Tau1*sspd + rm.cfg.Tau2*sdir + rm.cfg.Tau3*sacc
-}
-
-// cosineSimilarity 保持不变
+	if allDefined || usedWeight == 0 {
+		return sum
+	}
+	return sum / usedWeight
+}
+
+// timeAlignTolerance 按绝对时间对齐轨迹点时允许的时间误差（秒）
+const timeAlignTolerance = 1e-6
+
+// alignTrajectories 按绝对模拟时间对齐两条轨迹，返回等长的两组轨迹点，第 i 对点处于同一时刻
+// 进入场景时间不同的两辆车，轨迹下标相同的点并不处于同一时刻，只比较时间重叠部分的点；
+// 两条轨迹都不带时间时退回按下标从头对齐，取共同前缀。轨迹点需按时间升序排列
+func alignTrajectories(user, prov []Vector) ([]Vector, []Vector) {
+	if !hasTimes(user) && !hasTimes(prov) {
+		n := len(user)
+		if len(prov) < n {
+			n = len(prov)
+		}
+		return user[:n], prov[:n]
+	}
+
+	var alignedUser, alignedProv []Vector
+	for i, j := 0, 0; i < len(user) && j < len(prov); {
+		d := user[i].Time - prov[j].Time
+		switch {
+		case math.Abs(d) <= timeAlignTolerance:
+			alignedUser = append(alignedUser, user[i])
+			alignedProv = append(alignedProv, prov[j])
+			i++
+			j++
+		case d < 0:
+			i++
+		default:
+			j++
+		}
+	}
+	return alignedUser, alignedProv
+}
+
+// hasTimes 判断轨迹是否带有绝对时间
+func hasTimes(traj []Vector) bool {
+	for _, v := range traj {
+		if v.Time != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// componentSimilarity 按配置的度量方式计算单个分量序列的相似度
+// 第二个返回值表示相似度是否有定义：空序列没有可比较的数据，各度量方式均无定义；
+// 余弦度量下任一序列全为 0 时夹角无定义
+func (rm *ReputationManager) componentSimilarity(a, b []float64) (float64, bool) {
+	if len(a) == 0 || len(b) == 0 {
+		return 0, false
+	}
+	switch rm.cfg.TrajDistanceMetric {
+	case config.TrajMetricEuclidean:
+		return 1 / (1 + euclideanDistance(a, b)), true
+	case config.TrajMetricManhattan:
+		return 1 / (1 + manhattanDistance(a, b)), true
+	default:
+		if isZeroVector(a) || isZeroVector(b) {
+			return 0, false
+		}
+		return cosineSimilarity(a, b), true
+	}
+}
+
+// isZeroVector 判断序列是否全为 0
+func isZeroVector(a []float64) bool {
+	for _, v := range a {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// euclideanDistance 逐点差值的均方根（a、b 等长且非空）
+func euclideanDistance(a, b []float64) float64 {
+	var sum float64
+	for i := range a {
+		d := a[i] - b[i]
+		sum += d * d
+	}
+	return math.Sqrt(sum / float64(len(a)))
+}
+
+// manhattanDistance 逐点差值绝对值的平均（a、b 等长且非空）
+func manhattanDistance(a, b []float64) float64 {
+	var sum float64
+	for i := range a {
+		sum += math.Abs(a[i] - b[i])
+	}
+	return sum / float64(len(a))
+}
+
+// cosineSimilarity 计算两个序列的余弦相似度，取值 [-1,1]
+// 长度不一致时只使用两者的公共前缀计算分子和分母，避免分母混入未参与分子计算的元素；
+// 公共前缀为空或任一向量为零向量时返回 0
 func cosineSimilarity(a, b []float64) float64 {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
 	var num, sa, sb float64
-	for i := range a {
+	for i := 0; i < n; i++ {
 		num += a[i] * b[i]
 		sa += a[i] * a[i]
-	}
-	for _, v := range b {
-		sb += v * v
+		sb += b[i] * b[i]
 	}
 	if sa == 0 || sb == 0 {
 		return 0
@@ -0,0 +1,1757 @@
+package reputation
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"block/config"
+	"block/logging"
+)
+
+// newBenchManager 构建含 n 条交互的信誉管理器，交互分布在 nodes 个节点之间
+func newBenchManager(n, nodes int) *ReputationManager {
+	rm := NewReputationManager(config.DefaultConfig())
+	base := time.Unix(0, 0)
+	traj := []Vector{{Speed: 10, Direction: 0, Acceleration: 1}, {Speed: 11, Direction: 0.1, Acceleration: 0.5}}
+	for i := 0; i < n; i++ {
+		from := i % nodes
+		to := (i/nodes + from + 1) % nodes
+		if to == from {
+			to = (to + 1) % nodes
+		}
+		rm.AddInteraction(Interaction{
+			From:         strconv.Itoa(from),
+			To:           strconv.Itoa(to),
+			PosEvents:    1,
+			NegEvents:    i % 2,
+			Timestamp:    base.Add(time.Duration(i) * time.Millisecond),
+			TrajUser:     traj,
+			TrajProvider: traj,
+		})
+	}
+	return rm
+}
+
+func BenchmarkComputeReputation100k(b *testing.B) {
+	rm := newBenchManager(100000, 20)
+	now := time.Unix(200, 0)
+
+	// computeDirectOpinions 会输出调试信息，基准测试期间丢弃标准输出
+	stdout := os.Stdout
+	if devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0); err == nil {
+		os.Stdout = devNull
+		defer func() { os.Stdout = stdout; devNull.Close() }()
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rm.ComputeReputation("0", now)
+	}
+}
+
+// eventStream 向 rm 写入若干评价者对 target 的交互：每个评价者 pos 次正面、neg 次负面
+func eventStream(rm *ReputationManager, target string, raters []string, pos, neg int) {
+	base := time.Unix(0, 0)
+	traj := []Vector{{Speed: 10, Acceleration: 1}, {Speed: 12, Direction: 0.1, Acceleration: 0.5}}
+	for i, from := range raters {
+		rm.AddInteraction(Interaction{
+			From:         from,
+			To:           target,
+			PosEvents:    pos,
+			NegEvents:    neg,
+			Timestamp:    base.Add(time.Duration(i) * time.Second),
+			TrajUser:     traj,
+			TrajProvider: traj,
+		})
+	}
+}
+
+func TestAsymmetricEventWeights(t *testing.T) {
+	raters := []string{"1", "2", "3"}
+	now := time.Unix(10, 0)
+
+	symmetric := NewReputationManager(config.DefaultConfig())
+	eventStream(symmetric, "0", raters, 5, 1)
+	repSym := symmetric.ComputeReputation("0", now)
+
+	cfg := config.DefaultConfig()
+	cfg.NegWeight = 3
+	asymmetric := NewReputationManager(cfg)
+	eventStream(asymmetric, "0", raters, 5, 1)
+	repAsym := asymmetric.ComputeReputation("0", now)
+
+	if repAsym == repSym {
+		t.Errorf("NegWeight=3 时信誉 %.4f 不应与对称加权相同", repAsym)
+	}
+
+	// 一次负面事件按 3 倍计，应与对称加权下的三次负面事件相当
+	tripled := NewReputationManager(config.DefaultConfig())
+	eventStream(tripled, "0", raters, 5, 3)
+	repTripled := tripled.ComputeReputation("0", now)
+	if diff := repAsym - repTripled; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("NegWeight=3 的信誉 %.6f 与三次负面事件的信誉 %.6f 不一致", repAsym, repTripled)
+	}
+}
+
+func TestMergeDisjointManagers(t *testing.T) {
+	now := time.Unix(10, 0)
+	a := NewReputationManager(config.DefaultConfig())
+	eventStream(a, "A0", []string{"A1", "A2"}, 4, 1)
+	eventStream(a, "A1", []string{"A0", "A2"}, 2, 0)
+	b := NewReputationManager(config.DefaultConfig())
+	eventStream(b, "B0", []string{"B1", "B2"}, 1, 3)
+
+	want := map[string]float64{
+		"A0": a.ComputeReputation("A0", now),
+		"A1": a.ComputeReputation("A1", now),
+		"B0": b.ComputeReputation("B0", now),
+	}
+
+	merged := NewReputationManager(config.DefaultConfig())
+	merged.Merge(a)
+	merged.Merge(b)
+	merged.Merge(a) // 重复合并应被去重
+	if got := len(merged.interactions); got != len(a.interactions)+len(b.interactions) {
+		t.Fatalf("合并后交互数 = %d, 期望 %d", got, len(a.interactions)+len(b.interactions))
+	}
+	for target, rep := range want {
+		if got := merged.ComputeReputation(target, now); math.Abs(got-rep) > 1e-12 {
+			t.Errorf("节点 %s 合并后信誉 = %.6f, 期望 %.6f", target, got, rep)
+		}
+	}
+}
+
+func TestRemoveRater(t *testing.T) {
+	now := time.Unix(10, 0)
+	rm := NewReputationManager(config.DefaultConfig())
+	eventStream(rm, "A", []string{"1", "2"}, 3, 0)
+	eventStream(rm, "A", []string{"bad"}, 0, 5)
+	eventStream(rm, "C", []string{"bad"}, 0, 2)
+	eventStream(rm, "B", []string{"3", "4"}, 2, 1)
+
+	beforeA := rm.ComputeReputation("A", now)
+	beforeB := rm.ComputeReputation("B", now)
+
+	if n := rm.RemoveRater("bad"); n != 2 {
+		t.Fatalf("RemoveRater 删除 %d 条交互, 期望 2 条", n)
+	}
+	if n := rm.RemoveRater("bad"); n != 0 {
+		t.Errorf("重复删除应返回 0，实际 %d", n)
+	}
+
+	afterA := rm.ComputeReputation("A", now)
+	if afterA <= beforeA {
+		t.Errorf("删除差评者后 A 的信誉 %.6f 应高于删除前的 %.6f", afterA, beforeA)
+	}
+	if got := rm.ComputeReputation("B", now); math.Abs(got-beforeB) > 1e-12 {
+		t.Errorf("未被 bad 评价的 B 信誉 = %.6f, 期望保持 %.6f", got, beforeB)
+	}
+	if got := rm.ComputeReputation("C", now); got != InitialReputation {
+		t.Errorf("只被 bad 评价的 C 信誉 = %.6f, 期望恢复初始值", got)
+	}
+
+	// 增量聚合结果与从未记录 bad 的评价时一致
+	fresh := NewReputationManager(config.DefaultConfig())
+	eventStream(fresh, "A", []string{"1", "2"}, 3, 0)
+	eventStream(fresh, "B", []string{"3", "4"}, 2, 1)
+	if want := fresh.ComputeReputation("A", now); math.Abs(afterA-want) > 1e-12 {
+		t.Errorf("删除后 A 的信誉 = %.6f, 期望与未记录 bad 时一致 %.6f", afterA, want)
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	rm := newBenchManager(500, 6)
+	rm.AddInteraction(Interaction{
+		From: "0", To: "1", PosEvents: 1, Timestamp: time.Now(),
+		TxType: EmergencyTransaction, UrgencyDegree: 0.7,
+	})
+	now := time.Now().Add(time.Minute)
+
+	data, err := json.Marshal(rm.Snapshot())
+	if err != nil {
+		t.Fatalf("序列化快照失败: %v", err)
+	}
+	var snap ReputationSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		t.Fatalf("反序列化快照失败: %v", err)
+	}
+	restored := RestoreReputation(config.DefaultConfig(), snap)
+
+	for i := 0; i < 6; i++ {
+		id := strconv.Itoa(i)
+		want := rm.ComputeReputation(id, now)
+		// map 遍历顺序不固定，浮点累加顺序不同会带来末位误差
+		if got := restored.ComputeReputation(id, now); math.Abs(got-want) > 1e-9 {
+			t.Errorf("节点 %s 恢复后信誉 = %v, 期望 %v", id, got, want)
+		}
+	}
+}
+
+func TestDirectWeightResistsContradictoryIndirect(t *testing.T) {
+	// 强直接证据：两个评价者都给出高信任、低不确定度的意见
+	dir := map[string]DirectOpinion{
+		"1": {Opinion: SubjectiveOpinion{T: 0.9, D: 0.02, I: 0.08}, Weight: 2},
+		"2": {Opinion: SubjectiveOpinion{T: 0.88, D: 0.04, I: 0.08}, Weight: 2},
+	}
+	// 相互矛盾的间接意见：网络中传来的多为否定
+	ind := map[string]SubjectiveOpinion{
+		"3": {T: 0.05, D: 0.85, I: 0.1},
+		"4": {T: 0.1, D: 0.8, I: 0.1},
+	}
+
+	fuse := func(w float64) SubjectiveOpinion {
+		cfg := config.DefaultConfig()
+		cfg.DirectWeight = w
+		return NewReputationManager(cfg).fuseOpinions(dir, ind)
+	}
+	directOnly := fuse(1)
+	if math.Abs(directOnly.T-0.89) > 1e-12 || math.Abs(directOnly.D-0.03) > 1e-12 {
+		t.Errorf("DirectWeight=1 应只采信直接意见，实际 %+v", directOnly)
+	}
+	if indirectOnly := fuse(0); math.Abs(indirectOnly.D-0.825) > 1e-12 {
+		t.Errorf("DirectWeight=0 应只采信间接意见，实际 %+v", indirectOnly)
+	}
+
+	balanced, high := fuse(0.5), fuse(0.95)
+	if high.D >= balanced.D {
+		t.Errorf("DirectWeight=0.95 的否定度 %.4f 应低于默认权重的 %.4f", high.D, balanced.D)
+	}
+	if math.Abs(high.T-directOnly.T) > 0.1 || high.D-directOnly.D > 0.1 {
+		t.Errorf("DirectWeight=0.95 时意见 %+v 被间接意见明显拉偏，直接意见为 %+v", high, directOnly)
+	}
+}
+
+func TestConsensusOpinionZeroK(t *testing.T) {
+	// 直接意见的不确定度为 0 时 k 恒为 0，无论间接意见如何都应得到直接意见而不是 NaN
+	dir := SubjectiveOpinion{T: 0.7, D: 0.3, I: 0}
+	for _, ind := range []SubjectiveOpinion{
+		{T: 0.5, D: 0.3, I: 0.2},
+		{T: 1, D: 0, I: 0},
+		{},
+	} {
+		got := consensusOpinion(dir, ind)
+		if got != dir {
+			t.Errorf("consensusOpinion(%+v, %+v) = %+v, 期望直接意见 %+v", dir, ind, got, dir)
+		}
+	}
+
+	// k > 0 时按公式 (13) 计算
+	got := consensusOpinion(SubjectiveOpinion{T: 0.6, D: 0.2, I: 0.2}, SubjectiveOpinion{T: 0.5, D: 0.3, I: 0.2})
+	want := SubjectiveOpinion{T: 1.1, D: 0.5, I: 0.2}
+	if math.Abs(got.T-want.T) > 1e-9 || math.Abs(got.D-want.D) > 1e-9 || math.Abs(got.I-want.I) > 1e-9 {
+		t.Errorf("consensusOpinion = %+v, 期望 %+v", got, want)
+	}
+}
+
+func TestFuseOpinionsKnownValues(t *testing.T) {
+	tests := []struct {
+		name string
+		dir  map[string]DirectOpinion
+		ind  map[string]SubjectiveOpinion
+		want SubjectiveOpinion
+	}{
+		{
+			// 无间接意见时为直接意见按权重的加权平均
+			name: "只有直接意见",
+			dir: map[string]DirectOpinion{
+				"a": {Opinion: SubjectiveOpinion{T: 0.8, D: 0.1, I: 0.1}, Weight: 3},
+				"b": {Opinion: SubjectiveOpinion{T: 0.2, D: 0.6, I: 0.2}, Weight: 1},
+			},
+			want: SubjectiveOpinion{T: 0.65, D: 0.225, I: 0.125},
+		},
+		{
+			// k = 0.2×0.2 + 0.5×0.2 + 0.3×0.2 = 0.2
+			// T = (0.6×0.2 + 0.5×0.2)/k，D = (0.2×0.2 + 0.3×0.2)/k，I = 0.2×0.2/k
+			// 公式 (13) 不做归一化，T+D+I = 1 - I_ind + I_ind/I_dir，此处为 1.8
+			name: "单条直接意见与单条间接意见",
+			dir:  map[string]DirectOpinion{"a": {Opinion: SubjectiveOpinion{T: 0.6, D: 0.2, I: 0.2}, Weight: 1}},
+			ind:  map[string]SubjectiveOpinion{"x": {T: 0.5, D: 0.3, I: 0.2}},
+			want: SubjectiveOpinion{T: 1.1, D: 0.5, I: 0.2},
+		},
+		{
+			// 间接意见取算术平均后为 (0.5, 0.3, 0.2)，与上一例相同
+			name: "间接意见取平均",
+			dir:  map[string]DirectOpinion{"a": {Opinion: SubjectiveOpinion{T: 0.6, D: 0.2, I: 0.2}, Weight: 2}},
+			ind: map[string]SubjectiveOpinion{
+				"x": {T: 0.4, D: 0.4, I: 0.2},
+				"y": {T: 0.6, D: 0.2, I: 0.2},
+			},
+			want: SubjectiveOpinion{T: 1.1, D: 0.5, I: 0.2},
+		},
+		{
+			// 直接意见没有不确定度时 k = 0，共识算子无定义，退化为直接意见
+			name: "k=0",
+			dir:  map[string]DirectOpinion{"a": {Opinion: SubjectiveOpinion{T: 0.7, D: 0.3, I: 0}, Weight: 1}},
+			ind:  map[string]SubjectiveOpinion{"x": {T: 0.5, D: 0.3, I: 0.2}},
+			want: SubjectiveOpinion{T: 0.7, D: 0.3, I: 0},
+		},
+		{
+			// k = 1e-9 × (0.3+0.7+0)，T = 0.3×1e-9/k，D = 0.7×1e-9/k：结果有限，取间接意见
+			name: "k 趋近 0",
+			dir:  map[string]DirectOpinion{"a": {Opinion: SubjectiveOpinion{T: 0.6, D: 0.4 - 1e-9, I: 1e-9}, Weight: 1}},
+			ind:  map[string]SubjectiveOpinion{"x": {T: 0.3, D: 0.7, I: 0}},
+			want: SubjectiveOpinion{T: 0.3, D: 0.7, I: 0},
+		},
+		{
+			// 直接意见的总权重为 0 时没有可用的直接证据
+			name: "直接意见权重为 0",
+			dir:  map[string]DirectOpinion{"a": {Opinion: SubjectiveOpinion{T: 0.9, D: 0.1}, Weight: 0}},
+			want: SubjectiveOpinion{},
+		},
+	}
+
+	rm := NewReputationManager(config.DefaultConfig())
+	for _, tt := range tests {
+		got := rm.fuseOpinions(tt.dir, tt.ind)
+		if math.Abs(got.T-tt.want.T) > 1e-9 || math.Abs(got.D-tt.want.D) > 1e-9 || math.Abs(got.I-tt.want.I) > 1e-9 {
+			t.Errorf("%s: fuseOpinions = %+v, 期望 %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSingleInteractionReputation(t *testing.T) {
+	discardStdout(t)
+	cfg := config.DefaultConfig()
+	// 只有正负事件时 θ 由加权负面事件比决定：ratio = neg
+	theta := func(ratio float64) float64 {
+		return cfg.Mu / (1 + math.Exp(cfg.ThetaSteepness*(ratio-cfg.ThetaMidpoint)))
+	}
+	tests := []struct {
+		name     string
+		pos, neg int
+		want     SubjectiveOpinion
+	}{
+		// I = 2/(2+n)，只有正面事件时 T = 1-I
+		{"单次正面交互", 3, 0, SubjectiveOpinion{T: 0.6, D: 0, I: 0.4}},
+		// 只有负面事件时 D = 1-I
+		{"单次负面交互", 0, 2, SubjectiveOpinion{T: 0, D: 0.5, I: 0.5}},
+		// α = (1-θ)×2, β = θ×2, T = (1-I)α/(α+β), D = (1-I)β/(α+β)
+		{"正负事件各半", 2, 2, SubjectiveOpinion{T: 2.0 / 3 * (1 - theta(2)), D: 2.0 / 3 * theta(2), I: 1.0 / 3}},
+		// 没有事件时完全不确定
+		{"没有事件", 0, 0, SubjectiveOpinion{T: 0, D: 0, I: 1}},
+	}
+
+	now := time.Unix(100, 0)
+	for _, tt := range tests {
+		rm := NewReputationManager(cfg)
+		rm.AddInteraction(Interaction{From: "A", To: "B", PosEvents: tt.pos, NegEvents: tt.neg, Timestamp: now})
+		got, ok := rm.ComputeOpinion("B", now)
+		if !ok {
+			t.Fatalf("%s: 节点 B 应有交互记录", tt.name)
+		}
+		if math.Abs(got.T-tt.want.T) > 1e-9 || math.Abs(got.D-tt.want.D) > 1e-9 || math.Abs(got.I-tt.want.I) > 1e-9 {
+			t.Errorf("%s: 意见 = %+v, 期望 %+v", tt.name, got, tt.want)
+		}
+		wantRepu := tt.want.T + cfg.Gamma*tt.want.I
+		if repu := rm.ComputeReputation("B", now); math.Abs(repu-wantRepu) > 1e-9 {
+			t.Errorf("%s: 信誉 = %v, 期望 T+γI = %v", tt.name, repu, wantRepu)
+		}
+	}
+}
+
+func TestSelfInteractionRejected(t *testing.T) {
+	now := time.Unix(10, 0)
+	rm := NewReputationManager(config.DefaultConfig())
+	eventStream(rm, "0", []string{"1", "2"}, 2, 2)
+	before := rm.ComputeReputation("0", now)
+
+	err := rm.AddInteraction(Interaction{From: "0", To: "0", PosEvents: 100, Timestamp: time.Unix(5, 0)})
+	if !errors.Is(err, ErrSelfInteraction) {
+		t.Fatalf("自评交互应返回 ErrSelfInteraction，实际 %v", err)
+	}
+	if got := rm.ComputeReputation("0", now); math.Abs(got-before) > 1e-9 {
+		t.Errorf("自评后信誉 %.6f 与自评前 %.6f 不同", got, before)
+	}
+	if n := len(rm.History("0")); n != 2 {
+		t.Errorf("自评交互不应被记录，历史交互数 = %d", n)
+	}
+
+	// 聚合层的防御性检查：绕过 AddInteraction 也不会计入自评
+	rm.mergeIntoAggregate(Interaction{From: "0", To: "0", PosEvents: 100})
+	if _, ok := rm.agg["0"]["0"]; ok {
+		t.Error("自评交互不应进入聚合结果")
+	}
+}
+
+func TestZeroComponentRenormalizesTrajWeights(t *testing.T) {
+	rm := NewReputationManager(config.DefaultConfig())
+	// 速度、方向完全一致，加速度全为 0（余弦相似度无定义）
+	traj := []Vector{{Speed: 10, Direction: 0.1}, {Speed: 12, Direction: 0.2}, {Speed: 11, Direction: 0.3}}
+	if got := rm.computeTrajectorySimilarity(traj, traj); math.Abs(got-1) > 1e-12 {
+		t.Errorf("加速度全为 0 时相似度 = %v, 期望按剩余分量归一化后为 1", got)
+	}
+
+	// 各分量都有定义时保持原加权和
+	full := []Vector{{Speed: 10, Direction: 0.1, Acceleration: 1}, {Speed: 12, Direction: 0.2, Acceleration: -1}}
+	if got := rm.computeTrajectorySimilarity(full, full); math.Abs(got-1) > 1e-12 {
+		t.Errorf("完全相同的轨迹相似度 = %v, 期望 1", got)
+	}
+
+	// 所有分量都无定义时相似度为 0
+	if got := rm.computeTrajectorySimilarity(nil, nil); got != 0 {
+		t.Errorf("空轨迹相似度 = %v, 期望 0", got)
+	}
+}
+
+func TestTrajDimensionsSelectComponents(t *testing.T) {
+	// 欧氏度量下速度完全一致（相似度 1），方向相差 1（0.5），加速度相差 3（0.25）
+	user := []Vector{{Speed: 10}, {Speed: 12}}
+	prov := []Vector{{Speed: 10, Direction: 1, Acceleration: 3}, {Speed: 12, Direction: 1, Acceleration: 3}}
+
+	tests := []struct {
+		dims []string
+		want float64
+	}{
+		{[]string{config.TrajDimSpeed}, 1},
+		{[]string{config.TrajDimDirection}, 0.5},
+		{[]string{config.TrajDimSpeed, config.TrajDimDirection}, (0.4*1 + 0.4*0.5) / 0.8},
+		{[]string{config.TrajDimDirection, config.TrajDimAcceleration}, (0.4*0.5 + 0.2*0.25) / 0.6},
+		{config.TrajDimensionNames, 0.4*1 + 0.4*0.5 + 0.2*0.25},
+	}
+	for _, tt := range tests {
+		cfg := config.DefaultConfig()
+		cfg.TrajDistanceMetric = config.TrajMetricEuclidean
+		cfg.TrajDimensions = tt.dims
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("%v: %v", tt.dims, err)
+		}
+		rm := NewReputationManager(cfg)
+		if got := rm.computeTrajectorySimilarity(user, prov); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("trajDimensions=%v 时相似度 = %v, 期望 %v", tt.dims, got, tt.want)
+		}
+	}
+
+	// 只启用速度时，余弦度量下全为 0 的加速度不参与计算，也不影响归一化
+	cfg := config.DefaultConfig()
+	cfg.TrajDimensions = []string{config.TrajDimSpeed}
+	rm := NewReputationManager(cfg)
+	if got := rm.computeTrajectorySimilarity(user, user); math.Abs(got-1) > 1e-12 {
+		t.Errorf("只启用速度时相同轨迹的相似度 = %v, 期望 1", got)
+	}
+}
+
+func TestTrajectoriesAlignedByAbsoluteTime(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.TrajDistanceMetric = config.TrajMetricEuclidean
+	rm := NewReputationManager(cfg)
+
+	// 两车在 t=1~3 同行（轨迹点完全一致），评价者在 t=0 先进入场景，被评价者到 t=4 才离开
+	user := []Vector{
+		{Speed: 5, Direction: 0.5, Acceleration: 2, Time: 0},
+		{Speed: 10, Direction: 0.1, Acceleration: 1, Time: 1},
+		{Speed: 12, Direction: 0.2, Acceleration: -1, Time: 2},
+		{Speed: 11, Direction: 0.3, Acceleration: 0.5, Time: 3},
+	}
+	prov := []Vector{
+		{Speed: 10, Direction: 0.1, Acceleration: 1, Time: 1},
+		{Speed: 12, Direction: 0.2, Acceleration: -1, Time: 2},
+		{Speed: 11, Direction: 0.3, Acceleration: 0.5, Time: 3},
+		{Speed: 20, Direction: 0.9, Acceleration: 3, Time: 4},
+	}
+	alignedUser, alignedProv := alignTrajectories(user, prov)
+	if len(alignedUser) != 3 || alignedUser[0].Time != 1 || alignedProv[2].Time != 3 {
+		t.Fatalf("对齐后的轨迹 = %v / %v, 期望 t=1~3 的 3 对点", alignedUser, alignedProv)
+	}
+	if got := rm.computeTrajectorySimilarity(user, prov); math.Abs(got-1) > 1e-12 {
+		t.Errorf("按时间对齐后同行部分完全一致，相似度 = %v, 期望 1", got)
+	}
+
+	// 不带时间的轨迹按下标对齐：下标相同的点处于不同时刻，相似度被错位拉低
+	untimed := func(traj []Vector) []Vector {
+		out := append([]Vector(nil), traj...)
+		for i := range out {
+			out[i].Time = 0
+		}
+		return out
+	}
+	if got := rm.computeTrajectorySimilarity(untimed(user), untimed(prov)); got >= 1-1e-9 {
+		t.Errorf("按下标对齐的错位轨迹相似度 = %v, 期望低于 1", got)
+	}
+
+	// 时间不重叠的轨迹没有可比较的点
+	later := []Vector{{Speed: 10, Time: 10}, {Speed: 12, Time: 11}}
+	if got := rm.computeTrajectorySimilarity(user, later); got != 0 {
+		t.Errorf("时间不重叠的轨迹相似度 = %v, 期望 0", got)
+	}
+}
+
+func TestConcurrentAddInteractionStress(t *testing.T) {
+	const nodes, perWorker, workers = 8, 2000, 8
+	base := time.Unix(0, 0)
+	trajA := []Vector{{Speed: 10, Acceleration: 1}, {Speed: 11, Direction: 0.1}}
+	trajB := []Vector{{Speed: 9, Acceleration: -1}, {Speed: 12, Direction: 0.2}}
+
+	// 交互流中包含时间戳相同但轨迹、交易类型不同的交互，检验聚合与顺序无关
+	var stream []Interaction
+	for i := 0; i < perWorker*workers; i++ {
+		from, to := i%nodes, (i/nodes+i+1)%nodes
+		if from == to {
+			continue
+		}
+		traj, txType := trajA, NormalTransaction
+		if i%3 == 0 {
+			traj, txType = trajB, EmergencyTransaction
+		}
+		stream = append(stream, Interaction{
+			From:          strconv.Itoa(from),
+			To:            strconv.Itoa(to),
+			PosEvents:     1,
+			NegEvents:     i % 2,
+			Timestamp:     base.Add(time.Duration(i/50) * time.Second),
+			TrajUser:      traj,
+			TrajProvider:  traj,
+			TxType:        txType,
+			UrgencyDegree: float64(i%7) / 7,
+		})
+	}
+
+	sequential := NewReputationManager(config.DefaultConfig())
+	for _, inter := range stream {
+		sequential.AddInteraction(inter)
+	}
+
+	concurrent := NewReputationManager(config.DefaultConfig())
+	ch := make(chan Interaction)
+	done := make(chan struct{})
+	for w := 0; w < workers; w++ {
+		go func() {
+			for inter := range ch {
+				if err := concurrent.AddInteraction(inter); err != nil {
+					t.Error(err)
+				}
+			}
+			done <- struct{}{}
+		}()
+	}
+	// 写入期间并发读取信誉，检验读写锁
+	go func() {
+		for i := 0; i < 20; i++ {
+			concurrent.ComputeReputation("0", base)
+		}
+		done <- struct{}{}
+	}()
+	// 逆序投递，进一步打乱写入顺序
+	for i := len(stream) - 1; i >= 0; i-- {
+		ch <- stream[i]
+	}
+	close(ch)
+	for w := 0; w < workers+1; w++ {
+		<-done
+	}
+
+	if got, want := len(concurrent.interactions), len(sequential.interactions); got != want {
+		t.Fatalf("并发写入后交互数 = %d, 期望 %d", got, want)
+	}
+	for to, fromMap := range sequential.agg {
+		for from, want := range fromMap {
+			got := concurrent.agg[to][from]
+			if got.PosEvents != want.PosEvents || got.NegEvents != want.NegEvents ||
+				!got.Timestamp.Equal(want.Timestamp) || got.TxType != want.TxType ||
+				got.UrgencyDegree != want.UrgencyDegree ||
+				compareTrajectories(got.TrajUser, want.TrajUser) != 0 {
+				t.Fatalf("(%s,%s) 并发聚合 %+v 与顺序聚合 %+v 不一致", to, from, got.Interaction, want.Interaction)
+			}
+		}
+	}
+}
+
+func TestBulkAddMatchesIndividualAdds(t *testing.T) {
+	base := time.Unix(0, 0)
+	traj := []Vector{{Speed: 10, Acceleration: 1}, {Speed: 11, Direction: 0.1}}
+	var batch []Interaction
+	for i := 0; i < 200; i++ {
+		from, to := i%5, (i/5+i+1)%5
+		if from == to {
+			continue
+		}
+		batch = append(batch, Interaction{
+			From:         strconv.Itoa(from),
+			To:           strconv.Itoa(to),
+			PosEvents:    1 + i%3,
+			NegEvents:    i % 2,
+			Timestamp:    base.Add(time.Duration(i) * 100 * time.Millisecond),
+			TrajUser:     traj,
+			TrajProvider: traj,
+		})
+	}
+
+	individual := NewReputationManager(config.DefaultConfig())
+	individual.SetLogger(logging.Discard())
+	for _, inter := range batch {
+		individual.AddInteraction(inter)
+	}
+	bulk := NewReputationManager(config.DefaultConfig())
+	bulk.SetLogger(logging.Discard())
+	if err := bulk.AddInteractions(batch); err != nil {
+		t.Fatalf("AddInteractions: %v", err)
+	}
+
+	now := base.Add(30 * time.Second)
+	want, got := individual.ComputeAllReputations(now), bulk.ComputeAllReputations(now)
+	if len(got) != len(want) {
+		t.Fatalf("批量添加得到 %d 个节点的信誉, 期望 %d 个", len(got), len(want))
+	}
+	for id, rep := range want {
+		if math.Abs(got[id]-rep) > 1e-12 {
+			t.Errorf("节点 %s 批量添加信誉 = %.12f, 逐条添加为 %.12f", id, got[id], rep)
+		}
+	}
+
+	// 批量中含自评交互时整批拒绝，已有记录不变
+	bad := []Interaction{batch[0], {From: "1", To: "1", PosEvents: 100, Timestamp: now}}
+	if err := bulk.AddInteractions(bad); !errors.Is(err, ErrSelfInteraction) {
+		t.Fatalf("含自评交互的批量应返回 ErrSelfInteraction，实际 %v", err)
+	}
+	if n := len(bulk.interactions); n != len(batch) {
+		t.Errorf("被拒绝的批量不应写入任何交互，交互数 = %d, 期望 %d", n, len(batch))
+	}
+}
+
+func TestReputationByTxType(t *testing.T) {
+	now := time.Unix(20, 0)
+	traj := []Vector{{Speed: 10, Acceleration: 1}, {Speed: 12, Direction: 0.1, Acceleration: 0.5}}
+	newInter := func(from, to string, pos, neg int, txType TransactionType) Interaction {
+		return Interaction{
+			From: from, To: to, PosEvents: pos, NegEvents: neg,
+			Timestamp: time.Unix(5, 0), TrajUser: traj, TrajProvider: traj,
+			TxType: txType, UrgencyDegree: 0.5,
+		}
+	}
+
+	// 节点 0 普通交易表现良好，紧急交易表现恶劣；节点 9 只有普通交易
+	rm := NewReputationManager(config.DefaultConfig())
+	rm.SetLogger(logging.Discard())
+	rm.AddInteractions([]Interaction{
+		newInter("1", "0", 3, 0, NormalTransaction),
+		newInter("2", "0", 3, 0, NormalTransaction),
+		newInter("3", "0", 0, 3, EmergencyTransaction),
+		newInter("4", "0", 0, 3, EmergencyTransaction),
+		newInter("1", "9", 3, 0, NormalTransaction),
+	})
+
+	normalOnly := NewReputationManager(config.DefaultConfig())
+	normalOnly.SetLogger(logging.Discard())
+	normalOnly.AddInteractions(rm.History("0")[:2])
+	emergencyOnly := NewReputationManager(config.DefaultConfig())
+	emergencyOnly.SetLogger(logging.Discard())
+	emergencyOnly.AddInteractions(rm.History("0")[2:])
+
+	normal, emergency := rm.ReputationByTxType("0", now)
+	if want := normalOnly.ComputeReputation("0", now); math.Abs(normal-want) > 1e-12 {
+		t.Errorf("普通交易信誉 = %.6f, 期望只含普通交易时的 %.6f", normal, want)
+	}
+	if want := emergencyOnly.ComputeReputation("0", now); math.Abs(emergency-want) > 1e-12 {
+		t.Errorf("紧急交易信誉 = %.6f, 期望只含紧急交易时的 %.6f", emergency, want)
+	}
+	if normal <= emergency {
+		t.Errorf("普通交易信誉 %.6f 应高于紧急交易信誉 %.6f", normal, emergency)
+	}
+
+	// 没有紧急交易的节点，紧急交易信誉为初始值，普通交易信誉即总信誉
+	normal, emergency = rm.ReputationByTxType("9", now)
+	if emergency != InitialReputation {
+		t.Errorf("节点 9 紧急交易信誉 = %.6f, 期望初始值 %.2f", emergency, InitialReputation)
+	}
+	if total := rm.ComputeReputation("9", now); math.Abs(normal-total) > 1e-12 {
+		t.Errorf("节点 9 普通交易信誉 = %.6f, 期望等于总信誉 %.6f", normal, total)
+	}
+}
+
+func TestDiscriminationScore(t *testing.T) {
+	malicious := map[string]bool{"m1": true, "m2": true}
+	tests := []struct {
+		name        string
+		reputations map[string]float64
+		want        float64
+	}{
+		{"完全区分", map[string]float64{"h1": 1, "h2": 1, "m1": 0, "m2": 0}, 1},
+		{"无法区分", map[string]float64{"h1": 0.6, "m1": 0.6}, 0},
+		{"按组平均", map[string]float64{"h1": 0.9, "h2": 0.7, "m1": 0.2, "m2": 0.4}, 0.5},
+		{"恶意节点更高", map[string]float64{"h1": 0.3, "m1": 0.8}, -0.5},
+		{"缺少恶意节点", map[string]float64{"h1": 0.9, "h2": 0.1}, 0},
+		{"缺少诚实节点", map[string]float64{"m1": 0.9}, 0},
+		{"空输入", nil, 0},
+	}
+	for _, tt := range tests {
+		if got := DiscriminationScore(tt.reputations, malicious); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: DiscriminationScore = %v, 期望 %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestConvergenceTracker(t *testing.T) {
+	tracker := NewConvergenceTracker(0.01, 2)
+	rounds := []struct {
+		reputations map[string]float64
+		maxChange   float64
+		converged   bool
+	}{
+		{map[string]float64{"a": 0.5, "b": 0.5}, math.Inf(1), false},
+		{map[string]float64{"a": 0.6, "b": 0.45}, 0.1, false},
+		{map[string]float64{"a": 0.605, "b": 0.45}, 0.005, false},
+		// 一次较大的波动使稳定轮数清零
+		{map[string]float64{"a": 0.605, "b": 0.42}, 0.03, false},
+		{map[string]float64{"a": 0.603, "b": 0.421}, 0.002, false},
+		{map[string]float64{"a": 0.604, "b": 0.421}, 0.001, true},
+		// 新出现的节点与初始信誉值比较
+		{map[string]float64{"a": 0.604, "b": 0.421, "c": 0.8}, 0.3, false},
+	}
+	for i, rd := range rounds {
+		maxChange, converged := tracker.Observe(rd.reputations)
+		if math.Abs(maxChange-rd.maxChange) > 1e-9 && !(math.IsInf(rd.maxChange, 1) && math.IsInf(maxChange, 1)) {
+			t.Errorf("第 %d 轮最大变化量 = %v, 期望 %v", i+1, maxChange, rd.maxChange)
+		}
+		if converged != rd.converged {
+			t.Errorf("第 %d 轮收敛 = %v, 期望 %v", i+1, converged, rd.converged)
+		}
+	}
+}
+
+func TestDetectOscillation(t *testing.T) {
+	const rounds = 20
+	history := map[string][]float64{
+		"short": {0.5, 0.9},
+	}
+	for i := 0; i < rounds; i++ {
+		// 每轮在 0.4 与 0.6 之间来回跳动，始终不稳定
+		history["flip"] = append(history["flip"], 0.5+0.1*math.Pow(-1, float64(i)))
+		// 单调趋近 0.8
+		history["converge"] = append(history["converge"], 0.8-0.3*math.Pow(0.5, float64(i)))
+		// 振幅衰减的振荡：前期来回跳动，后半段变化量低于容差，视为已稳定
+		history["damped"] = append(history["damped"], 0.7+0.2*math.Pow(-0.4, float64(i)))
+		// 每两轮反转一次方向
+		history["slow"] = append(history["slow"], []float64{0.5, 0.6, 0.7, 0.6}[i%4])
+	}
+
+	scores := DetectOscillation(history)
+	want := map[string]float64{"flip": 1, "converge": 0, "damped": 0, "slow": 0.5, "short": 0}
+	for id, w := range want {
+		if math.Abs(scores[id]-w) > 1e-9 {
+			t.Errorf("节点 %s 振荡得分 = %.4f, 期望 %.4f", id, scores[id], w)
+		}
+	}
+
+	if got := OscillatingNodes(scores, DefaultOscillationThreshold); strings.Join(got, ",") != "flip,slow" {
+		t.Errorf("OscillatingNodes = %v, 期望 [flip slow]", got)
+	}
+	if got := OscillatingNodes(scores, 0.9); strings.Join(got, ",") != "flip" {
+		t.Errorf("阈值 0.9 时 OscillatingNodes = %v, 期望 [flip]", got)
+	}
+}
+
+func TestSimpleScore(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.NegWeight = 3 // 基线只统计原始事件数，不受事件权重影响
+	rm := NewReputationManager(cfg)
+	eventStream(rm, "a", []string{"1", "2"}, 3, 1)
+	eventStream(rm, "b", []string{"1"}, 0, 2)
+
+	for target, want := range map[string]float64{"a": 0.75, "b": 0, "c": InitialReputation} {
+		if got := rm.SimpleScore(target); math.Abs(got-want) > 1e-9 {
+			t.Errorf("SimpleScore(%s) = %v, 期望 %v", target, got, want)
+		}
+	}
+}
+
+func TestReputationAtHistoricalTimestamps(t *testing.T) {
+	rm := NewReputationManager(config.DefaultConfig())
+	base := time.Unix(1000, 0)
+	traj := []Vector{{Speed: 10, Acceleration: 1}, {Speed: 12, Direction: 0.1, Acceleration: 0.5}}
+	// 节点 T 始终诚实：每个时刻收到评价者 A、B 各一次正面评价
+	for step := 0; step < 3; step++ {
+		for _, from := range []string{"A", "B"} {
+			rm.AddInteraction(Interaction{
+				From:         from,
+				To:           "T",
+				PosEvents:    1,
+				Timestamp:    base.Add(time.Duration(step) * time.Minute),
+				TrajUser:     traj,
+				TrajProvider: traj,
+			})
+		}
+	}
+
+	// 早于全部交互的时刻没有可用记录，返回初始信誉值
+	if got := rm.ComputeReputation("T", base.Add(-time.Second)); got != InitialReputation {
+		t.Errorf("首次交互之前的信誉 = %.6f, 期望初始信誉 %.6f", got, InitialReputation)
+	}
+	if got := rm.ComputeAllReputations(base.Add(-time.Second)); len(got) != 0 {
+		t.Errorf("首次交互之前出现的节点 = %v, 期望为空", got)
+	}
+
+	var prevTrust float64
+	for step := 0; step < 3; step++ {
+		at := base.Add(time.Duration(step) * time.Minute)
+		op, ok := rm.ComputeOpinion("T", at)
+		if !ok {
+			t.Fatalf("时刻 %d 没有交互记录", step)
+		}
+		if step > 0 && op.T <= prevTrust {
+			t.Errorf("时刻 %d 的信任度 %.6f 未高于上一时刻的 %.6f", step, op.T, prevTrust)
+		}
+		prevTrust = op.T
+
+		// 回放结果与只包含截至该时刻交互的管理器一致
+		replay := NewReputationManager(config.DefaultConfig())
+		for _, inter := range rm.History("T") {
+			if !inter.Timestamp.After(at) {
+				replay.AddInteraction(inter)
+			}
+		}
+		if got, want := rm.ComputeReputation("T", at), replay.ComputeReputation("T", at); math.Abs(got-want) > 1e-9 {
+			t.Errorf("时刻 %d 回放信誉 = %.6f, 期望 %.6f", step, got, want)
+		}
+	}
+}
+
+func TestEmergencyEvidenceDecaysSlower(t *testing.T) {
+	cfg := config.DefaultConfig()
+	slower := 0.1
+	cfg.EpsilonEmergency = &slower
+	rm := NewReputationManager(cfg)
+
+	// 一小时前的两次交互，分别为普通交易与紧急交易
+	age := time.Hour.Seconds()
+	normal := rm.timeDecay(NormalTransaction, age)
+	emergency := rm.timeDecay(EmergencyTransaction, age)
+	if emergency <= normal {
+		t.Errorf("ε 紧急=%.1f < ε 普通=%.1f 时紧急交易时效性 %.6f 应高于普通交易 %.6f",
+			slower, cfg.Epsilon, emergency, normal)
+	}
+
+	// 同样久远的交互在直接意见权重中保留更多的时效性分量
+	base := time.Unix(0, 0)
+	traj := []Vector{{Speed: 10, Acceleration: 1}, {Speed: 12, Direction: 0.1, Acceleration: 0.5}}
+	for _, inter := range []Interaction{
+		{From: "A", To: "N", PosEvents: 1, Timestamp: base, TrajUser: traj, TrajProvider: traj, TxType: NormalTransaction},
+		{From: "A", To: "E", PosEvents: 1, Timestamp: base, TrajUser: traj, TrajProvider: traj, TxType: EmergencyTransaction},
+	} {
+		rm.AddInteraction(inter)
+	}
+	direct := rm.computeDirectOpinions(rm.agg, base.Add(time.Hour), nil)
+	normalBase := direct["N"]["A"].Weight / CalculateTransactionWeight(NormalTransaction, 0)
+	emergencyBase := direct["E"]["A"].Weight / CalculateTransactionWeight(EmergencyTransaction, 0)
+	if emergencyBase <= normalBase {
+		t.Errorf("紧急交易的基础权重 %.6f 应高于同样久远的普通交易 %.6f", emergencyBase, normalBase)
+	}
+
+	// 未设置 EpsilonEmergency 时两类交易衰减相同
+	uniform := NewReputationManager(config.DefaultConfig())
+	if a, b := uniform.timeDecay(NormalTransaction, age), uniform.timeDecay(EmergencyTransaction, age); a != b {
+		t.Errorf("未设置 EpsilonEmergency 时时效性应相同: 普通 %.6f, 紧急 %.6f", a, b)
+	}
+}
+
+func TestExponentialDecayHalfLife(t *testing.T) {
+	power := NewReputationManager(config.DefaultConfig())
+	cfg := config.DefaultConfig()
+	cfg.TimeDecay = config.TimeDecayExponential
+	cfg.HalfLife = 60
+	exponential := NewReputationManager(cfg)
+
+	tests := []struct {
+		delta            float64
+		power, exp       float64 // 幂律 delta^(-0.5) 与指数 2^(-delta/60)
+		exponentialLower bool    // 指数衰减是否低于幂律衰减
+	}{
+		{1, 1, math.Pow(2, -1.0/60), true},
+		{15, 1 / math.Sqrt(15), math.Pow(2, -0.25), false},
+		{60, 1 / math.Sqrt(60), 0.5, false},
+		{120, 1 / math.Sqrt(120), 0.25, false},
+		{600, 1 / math.Sqrt(600), math.Pow(2, -10), true},
+	}
+	for _, tt := range tests {
+		p := power.timeDecay(NormalTransaction, tt.delta)
+		e := exponential.timeDecay(NormalTransaction, tt.delta)
+		if math.Abs(p-tt.power) > 1e-12 || math.Abs(e-tt.exp) > 1e-12 {
+			t.Errorf("delta=%v: 幂律 %.6f（期望 %.6f），指数 %.6f（期望 %.6f）", tt.delta, p, tt.power, e, tt.exp)
+		}
+		if (e < p) != tt.exponentialLower {
+			t.Errorf("delta=%v: 指数衰减 %.6f 与幂律衰减 %.6f 的大小关系不符", tt.delta, e, p)
+		}
+	}
+
+	// 每经过一个半衰期时效性减半，且两类交易相同
+	for _, txType := range []TransactionType{NormalTransaction, EmergencyTransaction} {
+		for k := 1; k <= 4; k++ {
+			ratio := exponential.timeDecay(txType, float64(k+1)*60) / exponential.timeDecay(txType, float64(k)*60)
+			if math.Abs(ratio-0.5) > 1e-12 {
+				t.Errorf("第 %d 个半衰期后的衰减比例 = %.6f, 期望 0.5", k+1, ratio)
+			}
+		}
+	}
+}
+
+// newFullyConnectedManager 构建 n 个节点两两互评的信誉管理器（全连接图）
+func newFullyConnectedManager(cfg config.Config, n int) *ReputationManager {
+	rm := NewReputationManager(cfg)
+	base := time.Unix(0, 0)
+	traj := []Vector{{Speed: 10, Acceleration: 1}, {Speed: 12, Direction: 0.1, Acceleration: 0.5}}
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if i == j {
+				continue
+			}
+			rm.AddInteraction(Interaction{
+				From:         strconv.Itoa(i),
+				To:           strconv.Itoa(j),
+				PosEvents:    1 + (i+j)%3,
+				NegEvents:    (i * j) % 2,
+				Timestamp:    base.Add(time.Duration(i*n+j) * time.Millisecond),
+				TrajUser:     traj,
+				TrajProvider: traj,
+			})
+		}
+	}
+	return rm
+}
+
+// discardStdout 在测试期间丢弃标准输出（computeDirectOpinions 会输出调试信息）
+func discardStdout(tb testing.TB) {
+	stdout := os.Stdout
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		return
+	}
+	os.Stdout = devNull
+	tb.Cleanup(func() { os.Stdout = stdout; devNull.Close() })
+}
+
+func TestMaxPathsBoundsIndirectOpinions(t *testing.T) {
+	discardStdout(t)
+	now := time.Unix(100, 0)
+	repu := func(maxPaths int) float64 {
+		cfg := config.DefaultConfig()
+		cfg.MaxPaths = maxPaths
+		return newFullyConnectedManager(cfg, 6).ComputeReputation("0", now)
+	}
+
+	// 6 个节点时每对 (source,target) 最多 1+4 条路径，上限不小于路径数时结果与不限制相同
+	unlimited := repu(0)
+	if got := repu(5); math.Abs(got-unlimited) > 1e-9 {
+		t.Errorf("MaxPaths=5 信誉 = %.9f, 期望与不限制时相同 %.9f", got, unlimited)
+	}
+
+	// 截断后的结果是确定的
+	capped := repu(1)
+	for i := 0; i < 3; i++ {
+		if got := repu(1); math.Abs(got-capped) > 1e-9 {
+			t.Fatalf("MaxPaths=1 第 %d 次计算信誉 = %.9f, 与首次 %.9f 不同", i+2, got, capped)
+		}
+	}
+	if math.IsNaN(capped) {
+		t.Error("MaxPaths=1 时信誉为 NaN")
+	}
+}
+
+func BenchmarkIndirectOpinionsFullyConnected40(b *testing.B) {
+	discardStdout(b)
+	now := time.Unix(100, 0)
+	for _, maxPaths := range []int{0, 5} {
+		cfg := config.DefaultConfig()
+		cfg.MaxPaths = maxPaths
+		rm := newFullyConnectedManager(cfg, 40)
+		b.Run("maxPaths="+strconv.Itoa(maxPaths), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				rm.ComputeReputation("0", now)
+			}
+		})
+	}
+}
+
+func TestRaterCredibilityDampensBadmouthing(t *testing.T) {
+	discardStdout(t)
+	base := time.Unix(0, 0)
+	traj := []Vector{{Speed: 10, Acceleration: 1}, {Speed: 12, Direction: 0.1, Acceleration: 0.5}}
+	now := base.Add(time.Minute)
+	honest := []string{"A", "B", "C"}
+
+	build := func(credibility, badmouth bool) float64 {
+		cfg := config.DefaultConfig()
+		cfg.RaterCredibility = credibility
+		rm := NewReputationManager(cfg)
+		add := func(from, to string, pos, neg int) {
+			rm.AddInteraction(Interaction{
+				From: from, To: to, PosEvents: pos, NegEvents: neg,
+				Timestamp: base, TrajUser: traj, TrajProvider: traj,
+			})
+		}
+		// 诚实节点之间以及对 H 的评价均为正面，M 被诚实节点一致差评
+		for _, from := range honest {
+			for _, to := range append(honest, "H") {
+				if from != to {
+					add(from, to, 5, 0)
+				}
+			}
+			add(from, "M", 0, 5)
+		}
+		if badmouth {
+			// M 从未与 H 交易，却对 H 发出大量负面评价
+			add("M", "H", 0, 10)
+		}
+		return rm.ComputeReputation("H", now)
+	}
+
+	for _, credibility := range []bool{false, true} {
+		clean := build(credibility, false)
+		attacked := build(credibility, true)
+		t.Logf("raterCredibility=%v: 无攻击 %.6f, 受诋毁 %.6f", credibility, clean, attacked)
+	}
+	drop := build(false, false) - build(false, true)
+	dampened := build(true, false) - build(true, true)
+	if drop <= 0 {
+		t.Fatalf("未开启评价者可信度时诋毁应降低信誉，实际变化 %.6f", -drop)
+	}
+	if dampened >= drop/2 {
+		t.Errorf("开启评价者可信度后诋毁使信誉下降 %.6f, 期望明显小于未开启时的 %.6f", dampened, drop)
+	}
+}
+
+func TestExportDOT(t *testing.T) {
+	discardStdout(t)
+	base := time.Unix(0, 0)
+	traj := []Vector{{Speed: 10, Acceleration: 1}, {Speed: 12, Direction: 0.1, Acceleration: 0.5}}
+	rm := NewReputationManager(config.DefaultConfig())
+	add := func(from, to string, pos, neg int) {
+		rm.AddInteraction(Interaction{
+			From: from, To: to, PosEvents: pos, NegEvents: neg,
+			Timestamp: base, TrajUser: traj, TrajProvider: traj,
+		})
+	}
+	add("A", "B", 5, 0)
+	add("B", "A", 5, 0)
+	add("A", "M", 0, 5)
+
+	now := base.Add(time.Minute)
+	dot := rm.ExportDOT(now)
+	if !strings.HasPrefix(dot, "digraph trust {") || !strings.HasSuffix(dot, "}\n") {
+		t.Fatalf("导出结果不是完整的 digraph:\n%s", dot)
+	}
+	for _, want := range []string{`"A" -> "B"`, `"B" -> "A"`, `"A" -> "M"`, `"M" [label=`} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("导出结果缺少 %s:\n%s", want, dot)
+		}
+	}
+	if dot != rm.ExportDOT(now) {
+		t.Error("相同的交互记录两次导出的结果不同")
+	}
+
+	filtered := rm.ExportDOTWithThreshold(now, 0.5)
+	if strings.Contains(filtered, `"A" -> "M"`) {
+		t.Errorf("信任度低于阈值的边应被过滤:\n%s", filtered)
+	}
+	if !strings.Contains(filtered, `"A" -> "B"`) || !strings.Contains(filtered, `"M" [label=`) {
+		t.Errorf("过滤边时应保留高信任度的边与全部节点:\n%s", filtered)
+	}
+}
+
+func TestDirectOnlySkipsIndirectOpinions(t *testing.T) {
+	discardStdout(t)
+	now := time.Unix(100, 0)
+	opinion := func(useIndirect bool, directWeight float64) SubjectiveOpinion {
+		cfg := config.DefaultConfig()
+		cfg.UseIndirect = useIndirect
+		cfg.DirectWeight = directWeight
+		op, ok := newFullyConnectedManager(cfg, 6).ComputeOpinion("0", now)
+		if !ok {
+			t.Fatal("节点 0 应有交互记录")
+		}
+		return op
+	}
+
+	directOnly := opinion(false, 0.5)
+	if sum := directOnly.T + directOnly.D + directOnly.I; math.Abs(sum-1) > 1e-9 {
+		t.Errorf("只采信直接意见时 T+D+I = %.9f, 期望 1", sum)
+	}
+	// 与 DirectWeight=1（仍计算间接意见但不采信）的结果一致
+	if want := opinion(true, 1); math.Abs(directOnly.T-want.T) > 1e-9 ||
+		math.Abs(directOnly.D-want.D) > 1e-9 || math.Abs(directOnly.I-want.I) > 1e-9 {
+		t.Errorf("UseIndirect=false 意见 %+v, 期望与 DirectWeight=1 的 %+v 相同", directOnly, want)
+	}
+	for _, v := range []float64{directOnly.T, directOnly.D, directOnly.I} {
+		if v < 0 || v > 1 {
+			t.Fatalf("只采信直接意见时意见分量应在 [0,1] 内，实际 %+v", directOnly)
+		}
+	}
+	// 全连接图中间接意见不为空，融合后的结果与只用直接意见不同
+	if fused := opinion(true, 0.5); math.Abs(fused.T-directOnly.T) < 1e-6 && math.Abs(fused.I-directOnly.I) < 1e-6 {
+		t.Errorf("融合间接意见后的意见 %+v 应与只用直接意见的 %+v 不同", fused, directOnly)
+	}
+}
+
+func TestNegEmergencyMultiplierPunishesHarderThanRewards(t *testing.T) {
+	raters := []string{"1", "2", "3"}
+	now := time.Unix(10, 0)
+	traj := []Vector{{Speed: 10, Acceleration: 1}, {Speed: 12, Direction: 0.1, Acceleration: 0.5}}
+
+	// impact 返回评价者 4 的一次紧急交互使节点 0 的信誉相对基线的变化量
+	impact := func(multiplier float64, pos, neg int) float64 {
+		cfg := config.DefaultConfig()
+		cfg.NegEmergencyMultiplier = multiplier
+		rm := NewReputationManager(cfg)
+		eventStream(rm, "0", raters, 3, 1)
+		baseline := rm.ComputeReputation("0", now)
+		rm.AddInteraction(Interaction{
+			From: "4", To: "0", PosEvents: pos, NegEvents: neg, Timestamp: time.Unix(5, 0),
+			TrajUser: traj, TrajProvider: traj, TxType: EmergencyTransaction,
+		})
+		return rm.ComputeReputation("0", now) - baseline
+	}
+
+	symReward, symPenalty := impact(1, 1, 0), impact(1, 0, 1)
+	asymReward, asymPenalty := impact(3, 1, 0), impact(3, 0, 1)
+
+	// 惩罚倍数只作用于含负面事件的紧急交互，诚实紧急交互的奖励不变
+	if math.Abs(asymReward-symReward) > 1e-12 {
+		t.Errorf("正面紧急交互的影响随惩罚倍数变化: 倍数 1 为 %.6f, 倍数 3 为 %.6f", symReward, asymReward)
+	}
+	// 负面紧急交互在倍数 3 下使信誉下降更多，正负影响的差距随之扩大
+	if asymPenalty >= symPenalty {
+		t.Errorf("倍数 3 时负面紧急交互的影响 %.6f 应低于倍数 1 时的 %.6f", asymPenalty, symPenalty)
+	}
+	if asymReward-asymPenalty <= symReward-symPenalty {
+		t.Errorf("倍数 3 时正负影响之差 %.6f 应大于倍数 1 时的 %.6f",
+			asymReward-asymPenalty, symReward-symPenalty)
+	}
+
+	// 直接意见权重中体现为独立的惩罚倍数
+	cfg := config.DefaultConfig()
+	cfg.NegEmergencyMultiplier = 3
+	rm := NewReputationManager(cfg)
+	pos := Interaction{TxType: EmergencyTransaction, PosEvents: 1}
+	neg := Interaction{TxType: EmergencyTransaction, NegEvents: 1}
+	if got, want := rm.transactionWeight(neg), 3*rm.transactionWeight(pos); math.Abs(got-want) > 1e-12 {
+		t.Errorf("负面紧急交互的交易类型权重 = %.3f, 期望 %.3f", got, want)
+	}
+}
+
+func TestComputeReputationDeltaMatchesFullRecomputation(t *testing.T) {
+	rm := NewReputationManager(config.DefaultConfig())
+	rm.SetLogger(logging.Discard())
+	traj := []Vector{{Speed: 10, Acceleration: 1}, {Speed: 12, Direction: 0.1, Acceleration: 0.5}}
+	now := time.Unix(1000, 0)
+
+	// 每轮新增少量交互，只把本轮的被评价者作为 changedTargets
+	nodes := []string{"1", "2", "3", "4", "5", "6", "7", "8"}
+	for round := 0; round < 6; round++ {
+		var changed []string
+		for i := 0; i < 4; i++ {
+			from := nodes[(round*3+i)%len(nodes)]
+			to := nodes[(round*5+i*3+1)%len(nodes)]
+			if from == to {
+				continue
+			}
+			rm.AddInteraction(Interaction{
+				From: from, To: to, PosEvents: 1 + i%2, NegEvents: (round + i) % 3 / 2,
+				Timestamp: time.Unix(int64(round*10+i), 0), TrajUser: traj, TrajProvider: traj,
+			})
+			changed = append(changed, to)
+		}
+
+		got := rm.ComputeReputationDelta(changed, now)
+		for target := range rm.agg {
+			want := rm.ComputeReputation(target, now)
+			if math.Abs(got[target]-want) > 1e-9 {
+				t.Errorf("第 %d 轮节点 %s: 增量信誉 %.12f, 全量信誉 %.12f", round, target, got[target], want)
+			}
+		}
+		if len(got) != len(rm.agg) {
+			t.Errorf("第 %d 轮返回 %d 个节点的信誉值, 期望 %d 个", round, len(got), len(rm.agg))
+		}
+	}
+
+	// 没有交互记录的节点为初始信誉值
+	if got := rm.ComputeReputationDelta([]string{"unknown"}, now); got["unknown"] != InitialReputation {
+		t.Errorf("无交互节点的信誉值 = %v, 期望 %v", got["unknown"], InitialReputation)
+	}
+}
+
+func TestComputeReputationDeltaReusesUnaffectedNodes(t *testing.T) {
+	rm := NewReputationManager(config.DefaultConfig())
+	rm.SetLogger(logging.Discard())
+	traj := []Vector{{Speed: 10, Acceleration: 1}, {Speed: 12, Direction: 0.1, Acceleration: 0.5}}
+	// 统计轨迹相似度的计算次数，即重新计算的直接意见数
+	calls := 0
+	rm.similarity = func(user, prov []Vector) float64 {
+		calls++
+		return rm.computeTrajectorySimilarity(user, prov)
+	}
+	add := func(from, to string, pos, neg int, sec int64) {
+		rm.AddInteraction(Interaction{
+			From: from, To: to, PosEvents: pos, NegEvents: neg, Timestamp: time.Unix(sec, 0), TrajUser: traj, TrajProvider: traj,
+		})
+	}
+	// 两个互不相连的分量：A↔B 与 C、D、E；C 的两个评价时间不同，时间推进后两者的时效性权重此消彼长
+	add("A", "B", 1, 0, 1)
+	add("B", "A", 1, 0, 1)
+	add("D", "C", 1, 0, 1)
+	add("E", "C", 0, 1, 8)
+	add("C", "D", 1, 0, 1)
+	now := time.Unix(10, 0)
+	first := rm.ComputeReputationDelta(nil, now)
+
+	// 时刻不变时只有 A 收到新交互：只重新计算 A 的直接意见，C、D 沿用缓存
+	add("B", "A", 1, 0, 5)
+	calls = 0
+	second := rm.ComputeReputationDelta(nil, now)
+	if calls != 1 {
+		t.Errorf("重新计算了 %d 条直接意见, 期望只有 A 的 1 条", calls)
+	}
+	if second["C"] != first["C"] || second["D"] != first["D"] {
+		t.Errorf("未受影响的节点应沿用缓存: C %.6f→%.6f, D %.6f→%.6f", first["C"], second["C"], first["D"], second["D"])
+	}
+	for _, target := range []string{"A", "B"} {
+		if want := rm.ComputeReputation(target, now); math.Abs(second[target]-want) > 1e-9 {
+			t.Errorf("受影响的节点 %s: 增量信誉 %.9f, 全量信誉 %.9f", target, second[target], want)
+		}
+	}
+
+	// 时间推进后时效性衰减，C 虽然没有新交互也要重新计算，结果与全量计算相同
+	later := time.Unix(100, 0)
+	if rm.ComputeReputation("C", later) == first["C"] {
+		t.Fatal("时间推进后 C 的全量信誉应因时效衰减而变化，否则本测试无法区分缓存与重新计算")
+	}
+	third := rm.ComputeReputationDelta(nil, later)
+	for target, got := range third {
+		if want := rm.ComputeReputation(target, later); math.Abs(got-want) > 1e-9 {
+			t.Errorf("时间推进后节点 %s: 增量信誉 %.9f, 全量信誉 %.9f", target, got, want)
+		}
+	}
+
+	// RemoveRater 使缓存失效，之后全量重建
+	rm.RemoveRater("E")
+	fourth := rm.ComputeReputationDelta(nil, later)
+	if want := rm.ComputeReputation("C", later); fourth["C"] != want {
+		t.Errorf("RemoveRater 后 C 的信誉 = %.9f, 期望全量结果 %.9f", fourth["C"], want)
+	}
+}
+
+func TestComputeReputationDeltaMatchesFullRecomputationAsTimeAdvances(t *testing.T) {
+	for _, decay := range []string{config.TimeDecayPower, config.TimeDecayExponential} {
+		cfg := config.DefaultConfig()
+		cfg.TimeDecay = decay
+		rm := NewReputationManager(cfg)
+		rm.SetLogger(logging.Discard())
+		inters := compactionStream(240, 8)
+
+		// 每轮新增一批交互并推进计算时刻，被评价者由管理器自动记录，不传 changedTargets
+		for round := 0; round < 8; round++ {
+			if err := rm.AddInteractions(inters[round*30 : (round+1)*30]); err != nil {
+				t.Fatal(err)
+			}
+			now := time.Unix(int64((round+1)*30+round*7), 0)
+			got := rm.ComputeReputationDelta(nil, now)
+			want := rm.ComputeAllReputations(now)
+			if len(got) != len(want) {
+				t.Errorf("%s 第 %d 轮返回 %d 个节点的信誉值, 期望 %d 个", decay, round, len(got), len(want))
+			}
+			for target, w := range want {
+				if math.Abs(got[target]-w) > 1e-9 {
+					t.Errorf("%s 第 %d 轮节点 %s: 增量信誉 %.12f, 全量信誉 %.12f", decay, round, target, got[target], w)
+				}
+			}
+		}
+	}
+}
+
+func TestAntiCorrelatedTrajectoriesKeepWeightsNonNegative(t *testing.T) {
+	user := []Vector{{Speed: 10, Direction: 0.2, Acceleration: 1}, {Speed: 20, Direction: 0.4, Acceleration: 2}}
+	prov := []Vector{{Speed: -10, Direction: -0.2, Acceleration: -1}, {Speed: -20, Direction: -0.4, Acceleration: -2}}
+	base := time.Unix(0, 0)
+	now := base.Add(time.Hour)
+
+	for _, minSim := range []float64{0, -1} {
+		cfg := config.DefaultConfig()
+		// 相似度项占主导，负相似度足以抵消其余两项
+		cfg.Rho1, cfg.Rho2, cfg.Rho3 = 0.1, 0.1, 0.8
+		cfg.MinTrajSimilarity = minSim
+		rm := NewReputationManager(cfg)
+		rm.SetLogger(logging.Discard())
+
+		if sim := rm.computeTrajectorySimilarity(user, prov); sim > -0.99 {
+			t.Fatalf("反相关轨迹的余弦相似度 = %.4f, 期望接近 -1", sim)
+		}
+		for _, from := range []string{"A", "B"} {
+			rm.AddInteraction(Interaction{From: from, To: "X", PosEvents: 1, Timestamp: base, TrajUser: user, TrajProvider: prov})
+		}
+		rm.AddInteraction(Interaction{From: "C", To: "X", NegEvents: 1, Timestamp: base, TrajUser: user, TrajProvider: user})
+
+		direct := rm.computeDirectOpinions(rm.agg, now, nil)
+		for from, d := range direct["X"] {
+			if d.Weight < 0 {
+				t.Errorf("MinTrajSimilarity=%g 时评价者 %s 的权重 = %.4f, 不应为负", minSim, from, d.Weight)
+			}
+		}
+		rep := rm.ComputeReputation("X", now)
+		if math.IsNaN(rep) || rep < 0 {
+			t.Errorf("MinTrajSimilarity=%g 时信誉 = %v", minSim, rep)
+		}
+	}
+}
+
+func TestMixedVehicleRSUTopology(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.RSUNodes = []string{"R1", "R2"}
+	cfg.RaterCredibility = true
+	rm := NewReputationManager(cfg)
+	rm.SetLogger(logging.Discard())
+
+	traj := []Vector{{Speed: 10, Acceleration: 1, Time: 1}, {Speed: 12, Direction: 0.1, Acceleration: 0.5, Time: 2}}
+	base := time.Unix(0, 0)
+	now := base.Add(10 * time.Second)
+	// 车辆之间互相评价并带有轨迹；RSU 只作为评价者，没有轨迹
+	for _, inter := range []Interaction{
+		{From: "V1", To: "V2", PosEvents: 2, Timestamp: base, TrajUser: traj, TrajProvider: traj},
+		{From: "V2", To: "V1", PosEvents: 2, Timestamp: base, TrajUser: traj, TrajProvider: traj},
+		{From: "V3", To: "V1", NegEvents: 1, Timestamp: base, TrajUser: traj, TrajProvider: traj},
+		{From: "R1", To: "V1", PosEvents: 2, Timestamp: base, TrajProvider: traj},
+		{From: "R2", To: "V3", NegEvents: 2, Timestamp: base, TrajProvider: traj},
+	} {
+		if err := rm.AddInteraction(inter); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// RSU 没有被评价记录，信誉值为 RSU 的初始信任，高于车辆的初始信誉值
+	for _, id := range cfg.RSUNodes {
+		if got := rm.ComputeReputation(id, now); got != cfg.RSUInitialReputation {
+			t.Errorf("RSU %s 的信誉 = %.4f, 期望 %.4f", id, got, cfg.RSUInitialReputation)
+		}
+	}
+	if got := rm.ComputeReputation("V4", now); got != InitialReputation {
+		t.Errorf("未被评价的车辆信誉 = %.4f, 期望 %.4f", got, InitialReputation)
+	}
+
+	// RSU 的评价不计算轨迹相似度，频率与时效性两项重新归一化：
+	// R1 与 V2 对 V1 的事件数、时间相同，V2 的轨迹完全相同（相似度 1），
+	// 因此 R1 的权重 = (V2 的权重 - Rho3) / (Rho1 + Rho2)，而不是按相似度 0 被压低
+	plain := rm.computeDirectOpinions(rm.agg, now, nil)
+	rsu, vehicle := plain["V1"]["R1"], plain["V1"]["V2"]
+	if want := (vehicle.Weight - cfg.Rho3) / (cfg.Rho1 + cfg.Rho2); math.Abs(rsu.Weight-want) > 1e-9 {
+		t.Errorf("RSU 的评价权重 = %.6f, 期望 %.6f", rsu.Weight, want)
+	}
+
+	// 开启评价者可信度时，从未被评价的 RSU 按初始信任计入，从未被评价的车辆按车辆的初始信誉值计入
+	cred := raterCredibility(plain, cfg.Gamma, rm.initialReputation)
+	if cred("R1") != cfg.RSUInitialReputation || cred("V4") != InitialReputation {
+		t.Errorf("可信度: R1=%.4f, V4=%.4f", cred("R1"), cred("V4"))
+	}
+	if got := rm.ComputeReputation("V1", now); math.IsNaN(got) || got <= 0 {
+		t.Errorf("V1 的信誉 = %v", got)
+	}
+}
+
+func TestLambdaPrefersShortIndirectPaths(t *testing.T) {
+	// S 到 X 有两条路径：单边路径 S→X 权重低且不信任，两条边的路径 S→M→X 权重高且信任
+	// 路径沿评价者扩展，direct[b][a] 为 a 对 b 的直接意见
+	distrust := SubjectiveOpinion{T: 0.1, D: 0.8, I: 0.1}
+	trust := SubjectiveOpinion{T: 0.9, D: 0.05, I: 0.05}
+	direct := directOpinionsMap{
+		"X": {"S": {Opinion: distrust, Weight: 0.1}, "M": {Opinion: trust, Weight: 1}},
+		"M": {"S": {Opinion: trust, Weight: 1}, "X": {Opinion: trust, Weight: 1}},
+		"S": {"X": {Opinion: trust, Weight: 1}, "M": {Opinion: trust, Weight: 1}},
+	}
+	indirect := func(lambda float64) SubjectiveOpinion {
+		cfg := config.DefaultConfig()
+		cfg.Lambda = lambda
+		rm := NewReputationManager(cfg)
+		rm.SetLogger(logging.Discard())
+		return rm.computeIndirectOpinions(direct)["X"]["S"]
+	}
+
+	// Lambda=1 不衰减，强边组成的长路径占主导
+	if op := indirect(1); op.T <= op.D {
+		t.Errorf("Lambda=1 时 S 对 X 的间接意见 %+v，期望长路径的信任占主导", op)
+	}
+	// Lambda=0.05 时长路径权重 1×0.05 低于短路径的 0.1，短路径的不信任占主导
+	if op := indirect(0.05); op.D <= op.T {
+		t.Errorf("Lambda=0.05 时 S 对 X 的间接意见 %+v，期望短路径的不信任占主导", op)
+	}
+}
+
+func TestDetectionMetrics(t *testing.T) {
+	malicious := map[string]bool{"m1": true, "m2": true, "m3": true}
+	reputations := map[string]float64{
+		"h1": 0.9, "h2": 0.8, "h3": 0.7, "h4": 0.35,
+		"m1": 0.1, "m2": 0.3, "m3": 0.6,
+	}
+
+	// 阈值 0.5：m1、m2 与诚实节点 h4 被判定为恶意，m3 漏判
+	m := EvaluateDetection(reputations, malicious, 0.5)
+	if m.TruePositives != 2 || m.FalsePositives != 1 || m.FalseNegatives != 1 || m.TrueNegatives != 3 {
+		t.Fatalf("混淆矩阵 = %+v, 期望 TP=2 FP=1 FN=1 TN=3", m)
+	}
+	for _, c := range []struct {
+		name      string
+		got, want float64
+	}{
+		{"精确率", m.Precision, 2.0 / 3},
+		{"召回率", m.Recall, 2.0 / 3},
+		{"F1", m.F1, 2.0 / 3},
+		{"误报率", m.FalsePositiveRate, 0.25},
+		{"漏报率", m.FalseNegativeRate, 1.0 / 3},
+	} {
+		if math.Abs(c.got-c.want) > 1e-9 {
+			t.Errorf("%s = %.6f, 期望 %.6f", c.name, c.got, c.want)
+		}
+	}
+
+	// 最优阈值 (0.6+0.7)/2 把全部恶意节点判定为恶意，只误判 h4：P=3/4, R=1, F1=6/7
+	best := OptimalDetectionThreshold(reputations, malicious)
+	if math.Abs(best.Threshold-0.65) > 1e-9 || math.Abs(best.F1-6.0/7) > 1e-9 {
+		t.Errorf("最优阈值 = %.4f (F1=%.4f), 期望 0.65 (F1=%.4f)", best.Threshold, best.F1, 6.0/7)
+	}
+
+	// 完全可分时最优阈值达到 F1=1，且取最小的可分阈值
+	separable := map[string]float64{"h1": 0.9, "h2": 0.8, "m1": 0.2, "m2": 0.3, "m3": 0.4}
+	if best := OptimalDetectionThreshold(separable, malicious); best.F1 != 1 || math.Abs(best.Threshold-0.6) > 1e-9 {
+		t.Errorf("可分数据的最优阈值 = %.4f (F1=%.4f), 期望 0.6 (F1=1)", best.Threshold, best.F1)
+	}
+
+	// 没有恶意节点或没有节点时各比率为 0，不产生 NaN
+	honestOnly := EvaluateDetection(map[string]float64{"h1": 0.2}, nil, 0.5)
+	if honestOnly.Precision != 0 || honestOnly.Recall != 0 || honestOnly.F1 != 0 || honestOnly.FalsePositiveRate != 1 {
+		t.Errorf("只有诚实节点时 = %+v", honestOnly)
+	}
+	if empty := OptimalDetectionThreshold(nil, malicious); empty.F1 != 0 || math.IsNaN(empty.Threshold) {
+		t.Errorf("空输入时 = %+v", empty)
+	}
+}
+
+func TestDisabledTrajectorySimilaritySkipsComputation(t *testing.T) {
+	traj := []Vector{{Speed: 10, Acceleration: 1, Time: 1}, {Speed: 12, Direction: 0.1, Acceleration: 0.5, Time: 2}}
+	base := time.Unix(0, 0)
+	now := base.Add(10 * time.Second)
+
+	run := func(useSimilarity bool) (calls int, direct directOpinionsMap) {
+		cfg := config.DefaultConfig()
+		cfg.UseTrajectorySimilarity = useSimilarity
+		rm := NewReputationManager(cfg)
+		rm.SetLogger(logging.Discard())
+		rm.similarity = func(user, prov []Vector) float64 {
+			calls++
+			return rm.computeTrajectorySimilarity(user, prov)
+		}
+		for _, inter := range []Interaction{
+			{From: "A", To: "B", PosEvents: 2, Timestamp: base, TrajUser: traj, TrajProvider: traj},
+			{From: "C", To: "B", NegEvents: 1, Timestamp: base, TrajUser: traj, TrajProvider: traj},
+			{From: "B", To: "A", PosEvents: 1, Timestamp: base, TrajUser: traj, TrajProvider: traj},
+		} {
+			if err := rm.AddInteraction(inter); err != nil {
+				t.Fatal(err)
+			}
+		}
+		rm.ComputeReputation("B", now)
+		return calls, rm.computeDirectOpinions(rm.agg, now, nil)
+	}
+
+	if calls, _ := run(true); calls == 0 {
+		t.Fatal("开启轨迹相似度时应计算轨迹相似度")
+	}
+	calls, direct := run(false)
+	if calls != 0 {
+		t.Errorf("关闭轨迹相似度时仍计算了 %d 次轨迹相似度", calls)
+	}
+
+	// 权重按 Rho1、Rho2 重新归一化：A 的评价事件数为 B 的评价者平均值的 4/3 倍
+	cfg := config.DefaultConfig()
+	rm := NewReputationManager(cfg)
+	fi := 2 / 1.5
+	tim := rm.timeDecay(NormalTransaction, now.Sub(base).Seconds())
+	want := (cfg.Rho1*fi + cfg.Rho2*tim) / (cfg.Rho1 + cfg.Rho2)
+	if got := direct["B"]["A"].Weight; math.Abs(got-want) > 1e-9 {
+		t.Errorf("关闭轨迹相似度时 A 的评价权重 = %.6f, 期望 %.6f", got, want)
+	}
+}
+
+func TestDirectOpinionOfSinglePair(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.UseTrajectorySimilarity = false
+	cfg.PosWeight, cfg.NegWeight = 1, 1
+	now := time.Unix(100, 0)
+	rm := NewReputationManager(cfg)
+	rm.SetLogger(logging.Discard())
+	for _, inter := range []Interaction{
+		{From: "A", To: "B", PosEvents: 3, Timestamp: now},
+		{From: "C", To: "B", PosEvents: 1, NegEvents: 1, Timestamp: now},
+		{From: "B", To: "A", NegEvents: 4, Timestamp: now},
+	} {
+		if err := rm.AddInteraction(inter); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// B 的评价者平均事件数为 2.5：Fi(A) = 3/2.5，Fi(C) = 2/2.5；交互时刻即 now，TIM = Eta
+	weight := func(fi float64) float64 {
+		return (cfg.Rho1*fi + cfg.Rho2*cfg.Eta) / (cfg.Rho1 + cfg.Rho2) * NormalTxWeight
+	}
+	wA, wC := weight(1.2), weight(0.8)
+	// 加权负面事件比只来自 C 的 1 个负面事件
+	ratio := wC * 1 / (wA + wC)
+	theta := cfg.Mu / (1 + math.Exp(cfg.ThetaSteepness*(ratio-cfg.ThetaMidpoint)))
+	tests := []struct {
+		from, to string
+		want     DirectOpinion
+	}{
+		// I = 2/(2+3)，只有正面事件时 T = 1-I
+		{"A", "B", DirectOpinion{Opinion: SubjectiveOpinion{T: 0.6, I: 0.4}, Weight: wA}},
+		// I = 2/(2+2)，α = 1-θ, β = θ
+		{"C", "B", DirectOpinion{Opinion: SubjectiveOpinion{T: 0.5 * (1 - theta), D: 0.5 * theta, I: 0.5}, Weight: wC}},
+	}
+	direct := rm.computeDirectOpinions(rm.agg, now, nil)
+	for _, tt := range tests {
+		got, ok := rm.DirectOpinion(tt.from, tt.to, now)
+		if !ok {
+			t.Fatalf("%s→%s 应有直接意见", tt.from, tt.to)
+		}
+		if math.Abs(got.Weight-tt.want.Weight) > 1e-9 || math.Abs(got.Opinion.T-tt.want.Opinion.T) > 1e-9 ||
+			math.Abs(got.Opinion.D-tt.want.Opinion.D) > 1e-9 || math.Abs(got.Opinion.I-tt.want.Opinion.I) > 1e-9 {
+			t.Errorf("%s→%s 直接意见 = %+v, 期望 %+v", tt.from, tt.to, got, tt.want)
+		}
+		if full := direct[tt.to][tt.from]; got != full {
+			t.Errorf("%s→%s 直接意见 = %+v, 与完整计算 %+v 不一致", tt.from, tt.to, got, full)
+		}
+	}
+
+	// 没有交互的节点对与时间戳晚于 now 的交互都不产生直接意见
+	if _, ok := rm.DirectOpinion("A", "C", now); ok {
+		t.Error("A 没有评价过 C，不应有直接意见")
+	}
+	if _, ok := rm.DirectOpinion("A", "B", now.Add(-time.Second)); ok {
+		t.Error("交互晚于 now 时不应有直接意见")
+	}
+}
+
+// compactionStream 生成 n 条交互：节点 0~nodes-1 之间反复交互，普通与紧急交易交替，轨迹随序号变化
+func compactionStream(n, nodes int) []Interaction {
+	base := time.Unix(0, 0)
+	inters := make([]Interaction, 0, n)
+	for i := 0; i < n; i++ {
+		from := i % nodes
+		to := (i/nodes + from + 1) % nodes
+		if to == from {
+			to = (to + 1) % nodes
+		}
+		traj := []Vector{{Speed: float64(10 + i%7), Acceleration: 1}, {Speed: 12, Direction: 0.1 * float64(i%3), Acceleration: 0.5}}
+		inter := Interaction{
+			From:         strconv.Itoa(from),
+			To:           strconv.Itoa(to),
+			PosEvents:    1 + i%3,
+			NegEvents:    i % 2,
+			Timestamp:    base.Add(time.Duration(i) * time.Second),
+			TrajUser:     traj,
+			TrajProvider: traj,
+		}
+		if i%4 == 0 {
+			inter.TxType, inter.UrgencyDegree = EmergencyTransaction, float64(i%10)/10
+		}
+		inters = append(inters, inter)
+	}
+	return inters
+}
+
+func TestCompactPreservesReputations(t *testing.T) {
+	cfg := config.DefaultConfig()
+	inters := compactionStream(200, 6)
+	newManager := func(cfg config.Config) *ReputationManager {
+		rm := NewReputationManager(cfg)
+		rm.SetLogger(logging.Discard())
+		return rm
+	}
+	full, compacted := newManager(cfg), newManager(cfg)
+	if err := full.AddInteractions(inters[:150]); err != nil {
+		t.Fatal(err)
+	}
+	if err := compacted.AddInteractions(inters[:150]); err != nil {
+		t.Fatal(err)
+	}
+
+	pairs := typedPairCount(inters[:150])
+	if got := compacted.Compact(); got != pairs {
+		t.Fatalf("压缩后交互数 = %d, 期望每对节点每种交易类型一条共 %d 条", got, pairs)
+	}
+	if got := len(compacted.Snapshot().Interactions); got != pairs {
+		t.Fatalf("快照中的交互数 = %d, 期望 %d", got, pairs)
+	}
+
+	// 压缩前后以及压缩后继续添加交互，信誉值都与保留全部交互时一致
+	assertSame := func(stage string, now time.Time) {
+		t.Helper()
+		want, got := full.ComputeAllReputations(now), compacted.ComputeAllReputations(now)
+		if len(got) != len(want) {
+			t.Fatalf("%s: 节点数 = %d, 期望 %d", stage, len(got), len(want))
+		}
+		for node, w := range want {
+			if math.Abs(got[node]-w) > 1e-12 {
+				t.Errorf("%s: 节点 %s 信誉 = %v, 期望 %v", stage, node, got[node], w)
+			}
+		}
+	}
+	assertSame("压缩后", time.Unix(150, 0))
+	full.AddInteractions(inters[150:])
+	compacted.AddInteractions(inters[150:])
+	assertSame("压缩后继续添加交互", time.Unix(300, 0))
+
+	// 自动压缩：交互记录少于节点对数与阈值之和，信誉值同样不变
+	pairs = typedPairCount(inters)
+	cfg.CompactThreshold = 20
+	auto := newManager(cfg)
+	for _, inter := range inters {
+		if err := auto.AddInteraction(inter); err != nil {
+			t.Fatal(err)
+		}
+		if n := len(auto.Snapshot().Interactions); n >= pairs+cfg.CompactThreshold {
+			t.Fatalf("自动压缩后交互记录仍有 %d 条，应少于 %d 条", n, pairs+cfg.CompactThreshold)
+		}
+	}
+	compacted = auto
+	assertSame("自动压缩", time.Unix(300, 0))
+}
+
+// typedPairCount 返回交互涉及的 (To,From,交易类型) 组合数，即压缩后的交互数
+func typedPairCount(inters []Interaction) int {
+	type typedPair struct {
+		to, from string
+		txType   TransactionType
+	}
+	seen := make(map[typedPair]bool)
+	for _, inter := range inters {
+		seen[typedPair{inter.To, inter.From, inter.TxType}] = true
+	}
+	return len(seen)
+}
+
+func TestReputationByTxTypeAfterCompact(t *testing.T) {
+	inters := compactionStream(200, 6)
+	full := NewReputationManager(config.DefaultConfig())
+	full.SetLogger(logging.Discard())
+	compacted := NewReputationManager(config.DefaultConfig())
+	compacted.SetLogger(logging.Discard())
+	if err := full.AddInteractions(inters); err != nil {
+		t.Fatal(err)
+	}
+	if err := compacted.AddInteractions(inters); err != nil {
+		t.Fatal(err)
+	}
+	compacted.Compact()
+
+	// 同一节点对既有普通交互也有紧急交互，压缩后两种类型仍分别聚合
+	now := time.Unix(300, 0)
+	for i := 0; i < 6; i++ {
+		target := strconv.Itoa(i)
+		wantNormal, wantEmergency := full.ReputationByTxType(target, now)
+		gotNormal, gotEmergency := compacted.ReputationByTxType(target, now)
+		if math.Abs(gotNormal-wantNormal) > 1e-12 || math.Abs(gotEmergency-wantEmergency) > 1e-12 {
+			t.Errorf("节点 %s 压缩后按类型拆分的信誉 = %v / %v, 期望 %v / %v",
+				target, gotNormal, gotEmergency, wantNormal, wantEmergency)
+		}
+	}
+}
+
+func TestMergeAfterCompact(t *testing.T) {
+	inters := compactionStream(120, 5)
+	newManager := func() *ReputationManager {
+		rm := NewReputationManager(config.DefaultConfig())
+		rm.SetLogger(logging.Discard())
+		return rm
+	}
+	source := newManager()
+	if err := source.AddInteractions(inters); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(200, 0)
+	want := source.ComputeAllReputations(now)
+	assertSame := func(stage string, rm *ReputationManager) {
+		t.Helper()
+		got := rm.ComputeAllReputations(now)
+		for node, w := range want {
+			if math.Abs(got[node]-w) > 1e-12 {
+				t.Errorf("%s: 节点 %s 信誉 = %v, 期望 %v", stage, node, got[node], w)
+			}
+		}
+	}
+
+	// 本地压缩后再次合并同一来源：已压缩的交互仍被去重，不会重复计数
+	local := newManager()
+	if err := local.Merge(source); err != nil {
+		t.Fatal(err)
+	}
+	local.Compact()
+	if err := local.Merge(source); err != nil {
+		t.Fatal(err)
+	}
+	assertSame("本地压缩后重复合并", local)
+
+	// 来源已压缩：本地没有记录的节点对整体导入，结果与来源一致
+	compactedSource := newManager()
+	if err := compactedSource.AddInteractions(inters); err != nil {
+		t.Fatal(err)
+	}
+	compactedSource.Compact()
+	fresh := newManager()
+	if err := fresh.Merge(compactedSource); err != nil {
+		t.Fatal(err)
+	}
+	assertSame("合并已压缩的来源", fresh)
+
+	// 本地已有记录的节点对无法与聚合交互去重，跳过并返回 ErrCompactedMerge，结果不变
+	if err := fresh.Merge(compactedSource); !errors.Is(err, ErrCompactedMerge) {
+		t.Fatalf("重复合并已压缩的来源返回 %v, 期望 ErrCompactedMerge", err)
+	}
+	assertSame("重复合并已压缩的来源", fresh)
+}
+
+func TestRemoveRaterAfterCompact(t *testing.T) {
+	inters := compactionStream(150, 6)
+	full := NewReputationManager(config.DefaultConfig())
+	full.SetLogger(logging.Discard())
+	compacted := NewReputationManager(config.DefaultConfig())
+	compacted.SetLogger(logging.Discard())
+	if err := full.AddInteractions(inters); err != nil {
+		t.Fatal(err)
+	}
+	if err := compacted.AddInteractions(inters); err != nil {
+		t.Fatal(err)
+	}
+	compacted.Compact()
+
+	full.RemoveRater("2")
+	compacted.RemoveRater("2")
+	now := time.Unix(200, 0)
+	want, got := full.ComputeAllReputations(now), compacted.ComputeAllReputations(now)
+	for node, w := range want {
+		if math.Abs(got[node]-w) > 1e-12 {
+			t.Errorf("节点 %s 压缩后删除评价者的信誉 = %v, 期望 %v", node, got[node], w)
+		}
+	}
+}
+
+func TestCosineSimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []float64
+		want float64
+	}{
+		{"相同方向", []float64{1, 2, 3}, []float64{2, 4, 6}, 1},
+		{"相反方向", []float64{1, -1}, []float64{-1, 1}, -1},
+		{"正交", []float64{1, 0}, []float64{0, 1}, 0},
+		// 长度不一致时只用公共前缀，多出的元素既不计入分子也不计入分母
+		{"a 更长", []float64{1, 0, 5}, []float64{1, 0}, 1},
+		{"b 更长", []float64{3, 4}, []float64{3, 4, -7, 2}, 1},
+		{"公共前缀为零向量", []float64{0, 0, 3}, []float64{0, 0}, 0},
+		{"空向量", nil, []float64{1, 2}, 0},
+		{"均为空", nil, nil, 0},
+		{"零向量", []float64{0, 0, 0}, []float64{1, 2, 3}, 0},
+	}
+	for _, tt := range tests {
+		if got := cosineSimilarity(tt.a, tt.b); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("%s: cosineSimilarity(%v, %v) = %v, 期望 %v", tt.name, tt.a, tt.b, got, tt.want)
+		}
+	}
+}
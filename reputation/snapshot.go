@@ -0,0 +1,101 @@
+package reputation
+
+import (
+	"encoding/json"
+	"time"
+
+	"block/config"
+)
+
+// ReputationSnapshot 信誉状态快照，用于长时间实验的检查点保存与恢复
+// 快照保存完整的交互记录，恢复时按原顺序重放即可重建聚合结果
+type ReputationSnapshot struct {
+	Interactions []Interaction
+}
+
+// Snapshot 返回当前信誉状态的快照
+func (rm *ReputationManager) Snapshot() ReputationSnapshot {
+	rm.mutex.RLock()
+	defer rm.mutex.RUnlock()
+
+	interactions := make([]Interaction, len(rm.interactions))
+	copy(interactions, rm.interactions)
+	return ReputationSnapshot{Interactions: interactions}
+}
+
+// RestoreReputation 由快照重建信誉管理器
+func RestoreReputation(cfg config.Config, snapshot ReputationSnapshot) *ReputationManager {
+	rm := NewReputationManager(cfg)
+	for _, inter := range snapshot.Interactions {
+		rm.AddInteraction(inter)
+	}
+	return rm
+}
+
+// snapshotVersion 快照序列化格式版本
+const snapshotVersion = 1
+
+// snapshotJSON 快照的序列化格式
+type snapshotJSON struct {
+	Version      int               `json:"version"`
+	Interactions []interactionJSON `json:"interactions"`
+}
+
+// interactionJSON 交互记录的序列化格式
+// 时间戳保存为 Unix 纳秒，避免时区与单调时钟读数在往返中引入差异
+type interactionJSON struct {
+	From          string          `json:"from"`
+	To            string          `json:"to"`
+	PosEvents     int             `json:"pos"`
+	NegEvents     int             `json:"neg"`
+	Timestamp     int64           `json:"timestampUnixNano"`
+	TrajUser      []Vector        `json:"trajUser,omitempty"`
+	TrajProvider  []Vector        `json:"trajProvider,omitempty"`
+	TxType        TransactionType `json:"txType"`
+	UrgencyDegree float64         `json:"urgency,omitempty"`
+}
+
+// MarshalJSON 序列化快照
+func (s ReputationSnapshot) MarshalJSON() ([]byte, error) {
+	out := snapshotJSON{
+		Version:      snapshotVersion,
+		Interactions: make([]interactionJSON, 0, len(s.Interactions)),
+	}
+	for _, inter := range s.Interactions {
+		out.Interactions = append(out.Interactions, interactionJSON{
+			From:          inter.From,
+			To:            inter.To,
+			PosEvents:     inter.PosEvents,
+			NegEvents:     inter.NegEvents,
+			Timestamp:     inter.Timestamp.UnixNano(),
+			TrajUser:      inter.TrajUser,
+			TrajProvider:  inter.TrajProvider,
+			TxType:        inter.TxType,
+			UrgencyDegree: inter.UrgencyDegree,
+		})
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON 反序列化快照
+func (s *ReputationSnapshot) UnmarshalJSON(data []byte) error {
+	var in snapshotJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	s.Interactions = make([]Interaction, 0, len(in.Interactions))
+	for _, inter := range in.Interactions {
+		s.Interactions = append(s.Interactions, Interaction{
+			From:          inter.From,
+			To:            inter.To,
+			PosEvents:     inter.PosEvents,
+			NegEvents:     inter.NegEvents,
+			Timestamp:     time.Unix(0, inter.Timestamp),
+			TrajUser:      inter.TrajUser,
+			TrajProvider:  inter.TrajProvider,
+			TxType:        inter.TxType,
+			UrgencyDegree: inter.UrgencyDegree,
+		})
+	}
+	return nil
+}
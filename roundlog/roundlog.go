@@ -0,0 +1,112 @@
+package roundlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// InteractionCounts 一轮中的交互统计
+type InteractionCounts struct {
+	Total     int `json:"total"`     // 总交互次数
+	Honest    int `json:"honest"`    // 诚实节点发送交易次数
+	Malicious int `json:"malicious"` // 恶意节点发送交易次数
+}
+
+// Opinion 节点融合后的主观意见三元组
+type Opinion struct {
+	T float64 `json:"t"` // 信任度
+	D float64 `json:"d"` // 否定度
+	I float64 `json:"i"` // 不确定度
+}
+
+// BlockRecord 紧急区块的链接信息
+type BlockRecord struct {
+	Index    int    `json:"index"`
+	Hash     string `json:"hash"`
+	PrevHash string `json:"prevHash"`
+}
+
+// RoundRecord 一轮模拟的结构化记录，每轮输出为 JSON Lines 中的一行
+type RoundRecord struct {
+	Round             int                `json:"round"`                       // 轮次（从 1 开始）
+	Proposer          string             `json:"proposer"`                    // 普通链提议者
+	EmergencyProposer string             `json:"emergencyProposer,omitempty"` // 紧急链提议者（双链模式）
+	Reputations       map[string]float64 `json:"reputations"`                 // 各节点本轮信誉值
+	Opinions          map[string]Opinion `json:"opinions,omitempty"`          // 各节点本轮融合意见（无交互的节点不记录）
+	Interactions      InteractionCounts  `json:"interactions"`                // 本轮交互统计
+	Validators        []string           `json:"validators"`                  // 本轮验证器节点
+
+	// ValidatorReputations 本轮活跃验证器的信誉值（验证器组中记录的值）
+	ValidatorReputations map[string]float64 `json:"validatorReputations,omitempty"`
+
+	// DiscriminationScore 本轮诚实节点与恶意节点的信誉区分度，见 reputation.DiscriminationScore
+	DiscriminationScore float64 `json:"discriminationScore"`
+	// Converged 本轮是否判定信誉值已收敛（模拟在该轮后提前结束）
+	Converged bool `json:"converged,omitempty"`
+
+	// SelectionReputations 本轮重新选取验证器时各候选节点的信誉值，未重选的轮次为空
+	SelectionReputations map[string]float64 `json:"selectionReputations,omitempty"`
+	// EmergencyBlocks 本轮新上链的紧急区块（第 1 轮包含创世区块）
+	EmergencyBlocks []BlockRecord `json:"emergencyBlocks,omitempty"`
+}
+
+// Writer 以 JSON Lines 格式逐轮写出记录
+type Writer struct {
+	file *os.File
+	buf  *bufio.Writer
+	enc  *json.Encoder
+}
+
+// Create 创建（覆盖）结构化日志文件
+func Create(path string) (*Writer, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	buf := bufio.NewWriter(file)
+	return &Writer{file: file, buf: buf, enc: json.NewEncoder(buf)}, nil
+}
+
+// Write 写出一轮记录，并立即刷新到文件，便于运行中途查看
+func (w *Writer) Write(rec RoundRecord) error {
+	if err := w.enc.Encode(rec); err != nil {
+		return err
+	}
+	return w.buf.Flush()
+}
+
+// Close 关闭日志文件
+func (w *Writer) Close() error {
+	if err := w.buf.Flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// ReadAll 读取结构化日志中的全部轮次记录
+func ReadAll(path string) ([]RoundRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []RoundRecord
+	dec := json.NewDecoder(bufio.NewReader(file))
+	for {
+		var rec RoundRecord
+		err := dec.Decode(&rec)
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return records, fmt.Errorf("解析第 %d 条记录失败: %w", len(records)+1, err)
+		}
+		records = append(records, rec)
+	}
+}
@@ -0,0 +1,234 @@
+// Package trace 定义双链模拟的事件轨迹文件，用于精确重放一次模拟
+//
+// 轨迹文件是一个 JSON 对象，完整记录每轮的普通交互与紧急交易，重放时不再随机生成：
+//
+//	{
+//	  "version": 1,
+//	  "rounds": 3,
+//	  "interactions": [
+//	    {"round": 1, "sender": "1", "receiver": "2", "malicious": false, "age": 12.5}
+//	  ],
+//	  "emergencyTransactions": [
+//	    {"round": 1, "id": "ETx-0-1-0", "sender": "1", "age": 2, "deadline": 8, "malicious": false}
+//	  ]
+//	}
+//
+// version: 格式版本，目前为 1
+// rounds: 模拟轮数，省略时为事件中出现的最大轮次；大于最大轮次时末尾几轮没有事件
+// interactions: 普通交互，sender 发送一笔交易，receiver 按 malicious 给出负面或正面评价；
+// age 为交互发生在重放该事件之前的秒数（交互的时效性由它决定）
+// emergencyTransactions: 紧急交易，id 在整个轨迹中唯一；age 为交易产生于重放之前的秒数，
+// deadline 为重放时距截止时间的秒数；malicious 决定验证器确认区块后给发送者的评价
+//
+// round 从 1 开始；同一轮的事件按文件中的顺序重放。轨迹只记录事件，车辆轨迹仍从数据文件读取
+package trace
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"block/emergency"
+)
+
+// Version 轨迹文件的格式版本
+const Version = 1
+
+// ErrInvalidTrace 轨迹文件中的事件不合法
+var ErrInvalidTrace = errors.New("轨迹文件不合法")
+
+// Interaction 一次普通交互：Sender 发送交易，Receiver 给出评价
+type Interaction struct {
+	Round     int     `json:"round"`
+	Sender    string  `json:"sender"`
+	Receiver  string  `json:"receiver"`
+	Malicious bool    `json:"malicious"`
+	Age       float64 `json:"age"` // 交互发生在重放之前的秒数
+}
+
+// EmergencyTransaction 一笔紧急交易
+type EmergencyTransaction struct {
+	Round     int     `json:"round"`
+	ID        string  `json:"id"`
+	Sender    string  `json:"sender"`
+	Age       float64 `json:"age"`      // 交易产生于重放之前的秒数
+	Deadline  float64 `json:"deadline"` // 重放时距截止时间的秒数
+	Malicious bool    `json:"malicious"`
+}
+
+// Trace 一次双链模拟的完整事件轨迹
+type Trace struct {
+	Version               int                    `json:"version"`
+	Rounds                int                    `json:"rounds,omitempty"`
+	Interactions          []Interaction          `json:"interactions"`
+	EmergencyTransactions []EmergencyTransaction `json:"emergencyTransactions"`
+}
+
+// Load 读取并校验轨迹文件
+func Load(path string) (*Trace, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var t Trace
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("解析轨迹文件 %s: %w", path, err)
+	}
+	if t.Version != Version {
+		return nil, fmt.Errorf("轨迹文件 %s 的版本 %d 不受支持（期望 %d）", path, t.Version, Version)
+	}
+	if err := t.Validate(); err != nil {
+		return nil, fmt.Errorf("轨迹文件 %s: %w", path, err)
+	}
+	return &t, nil
+}
+
+// Validate 检查事件字段：轮次从 1 开始且不超过 Rounds（已设置时），节点ID非空，
+// 交互的发送者与接收者不同，时间不为负，紧急交易ID非空且唯一
+func (t *Trace) Validate() error {
+	checkRound := func(kind string, i, round int) error {
+		if round < 1 || (t.Rounds > 0 && round > t.Rounds) {
+			return fmt.Errorf("%w: 第 %d 个%s的轮次 %d 超出范围", ErrInvalidTrace, i+1, kind, round)
+		}
+		return nil
+	}
+	if t.Rounds < 0 {
+		return fmt.Errorf("%w: rounds=%d 为负", ErrInvalidTrace, t.Rounds)
+	}
+	for i, inter := range t.Interactions {
+		if err := checkRound("交互", i, inter.Round); err != nil {
+			return err
+		}
+		if inter.Sender == "" || inter.Receiver == "" || inter.Sender == inter.Receiver {
+			return fmt.Errorf("%w: 第 %d 个交互的发送者 %q 与接收者 %q 为空或相同",
+				ErrInvalidTrace, i+1, inter.Sender, inter.Receiver)
+		}
+		if inter.Age < 0 {
+			return fmt.Errorf("%w: 第 %d 个交互的 age=%g 为负", ErrInvalidTrace, i+1, inter.Age)
+		}
+	}
+	seen := make(map[string]bool, len(t.EmergencyTransactions))
+	for i, tx := range t.EmergencyTransactions {
+		if err := checkRound("紧急交易", i, tx.Round); err != nil {
+			return err
+		}
+		if tx.ID == "" || seen[tx.ID] {
+			return fmt.Errorf("%w: 第 %d 个紧急交易的ID %q 为空或重复", ErrInvalidTrace, i+1, tx.ID)
+		}
+		seen[tx.ID] = true
+		if tx.Sender == "" {
+			return fmt.Errorf("%w: 紧急交易 %s 的发送者为空", ErrInvalidTrace, tx.ID)
+		}
+		if tx.Age < 0 {
+			return fmt.Errorf("%w: 紧急交易 %s 的 age=%g 为负", ErrInvalidTrace, tx.ID, tx.Age)
+		}
+	}
+	return nil
+}
+
+// RoundCount 返回模拟轮数：设置了 Rounds 时为 Rounds，否则为事件中出现的最大轮次
+func (t *Trace) RoundCount() int {
+	if t.Rounds > 0 {
+		return t.Rounds
+	}
+	n := 0
+	for _, inter := range t.Interactions {
+		n = max(n, inter.Round)
+	}
+	for _, tx := range t.EmergencyTransactions {
+		n = max(n, tx.Round)
+	}
+	return n
+}
+
+// InteractionsIn 返回第 round 轮的普通交互，保持文件中的顺序
+func (t *Trace) InteractionsIn(round int) []Interaction {
+	var out []Interaction
+	for _, inter := range t.Interactions {
+		if inter.Round == round {
+			out = append(out, inter)
+		}
+	}
+	return out
+}
+
+// EmergencyTransactionsIn 返回第 round 轮的紧急交易，保持文件中的顺序
+func (t *Trace) EmergencyTransactionsIn(round int) []EmergencyTransaction {
+	var out []EmergencyTransaction
+	for _, tx := range t.EmergencyTransactions {
+		if tx.Round == round {
+			out = append(out, tx)
+		}
+	}
+	return out
+}
+
+// NodeIDs 返回轨迹中出现的全部节点ID（升序）
+func (t *Trace) NodeIDs() []string {
+	set := make(map[string]bool)
+	for _, inter := range t.Interactions {
+		set[inter.Sender] = true
+		set[inter.Receiver] = true
+	}
+	for _, tx := range t.EmergencyTransactions {
+		set[tx.Sender] = true
+	}
+	ids := make([]string, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// seconds 将轨迹中的秒数转换为 time.Duration
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// Timestamp 返回在 now 重放时交互的时间戳
+func (inter Interaction) Timestamp(now time.Time) time.Time {
+	return now.Add(-seconds(inter.Age))
+}
+
+// ProductTime 返回在 now 重放时交易的产生时间
+func (tx EmergencyTransaction) ProductTime(now time.Time) time.Time {
+	return now.Add(-seconds(tx.Age))
+}
+
+// DeadlineTime 返回在 now 重放时交易的截止时间
+func (tx EmergencyTransaction) DeadlineTime(now time.Time) time.Time {
+	return now.Add(seconds(tx.Deadline))
+}
+
+// Validator 按轨迹中记录的 malicious 评价紧急交易
+// 轨迹中没有的交易（如从交易池文件恢复的交易）交给 Fallback 评价
+type Validator struct {
+	Malicious map[string]bool // 紧急交易ID -> 是否为恶意交易
+	Fallback  emergency.TransactionValidator
+}
+
+// NewValidator 创建按轨迹评价紧急交易的评价器
+func NewValidator(t *Trace, fallback emergency.TransactionValidator) *Validator {
+	malicious := make(map[string]bool, len(t.EmergencyTransactions))
+	for _, tx := range t.EmergencyTransactions {
+		malicious[tx.ID] = tx.Malicious
+	}
+	return &Validator{Malicious: malicious, Fallback: fallback}
+}
+
+// Evaluate 轨迹中的恶意交易记 1 次负面事件，其余轨迹交易记 1 次正面事件
+func (v *Validator) Evaluate(tx *emergency.EmergencyTransaction) (pos, neg int) {
+	bad, ok := v.Malicious[tx.ID]
+	switch {
+	case !ok:
+		return v.Fallback.Evaluate(tx)
+	case bad:
+		return 0, 1
+	default:
+		return 1, 0
+	}
+}
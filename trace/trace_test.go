@@ -0,0 +1,99 @@
+package trace
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"block/emergency"
+)
+
+func writeTrace(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "trace.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadReplaysEventsByRound(t *testing.T) {
+	path := writeTrace(t, `{
+		"version": 1,
+		"interactions": [
+			{"round": 2, "sender": "1", "receiver": "2", "age": 3},
+			{"round": 1, "sender": "2", "receiver": "1", "malicious": true, "age": 1.5},
+			{"round": 2, "sender": "3", "receiver": "1"}
+		],
+		"emergencyTransactions": [
+			{"round": 3, "id": "e1", "sender": "3", "age": 2, "deadline": 8, "malicious": true},
+			{"round": 3, "id": "e2", "sender": "1", "deadline": 5}
+		]
+	}`)
+	tr, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.RoundCount() != 3 {
+		t.Errorf("RoundCount() = %d, 期望 3（事件中的最大轮次）", tr.RoundCount())
+	}
+	if got := tr.InteractionsIn(2); len(got) != 2 || got[0].Sender != "1" || got[1].Sender != "3" {
+		t.Errorf("第 2 轮交互 = %+v, 期望按文件顺序的发送者 1、3", got)
+	}
+	if got := tr.EmergencyTransactionsIn(1); len(got) != 0 {
+		t.Errorf("第 1 轮紧急交易 = %+v, 期望为空", got)
+	}
+	if got := tr.NodeIDs(); len(got) != 3 || got[0] != "1" || got[2] != "3" {
+		t.Errorf("NodeIDs() = %v, 期望 [1 2 3]", got)
+	}
+
+	now := time.Unix(1000, 0)
+	if ts := tr.InteractionsIn(1)[0].Timestamp(now); !ts.Equal(now.Add(-1500 * time.Millisecond)) {
+		t.Errorf("交互时间戳 = %v, 期望 now-1.5s", ts)
+	}
+	e1 := tr.EmergencyTransactionsIn(3)[0]
+	if !e1.ProductTime(now).Equal(now.Add(-2*time.Second)) || !e1.DeadlineTime(now).Equal(now.Add(8*time.Second)) {
+		t.Errorf("紧急交易产生/截止时间 = %v/%v", e1.ProductTime(now), e1.DeadlineTime(now))
+	}
+
+	// 轨迹中的交易按记录评价，其余交易交给 Fallback
+	v := NewValidator(tr, emergency.NewMaliciousSenderValidator(map[string]bool{"1": true}))
+	for _, tc := range []struct {
+		id, sender string
+		wantNeg    int
+	}{
+		{"e1", "3", 1},
+		{"e2", "1", 0},
+		{"restored", "1", 1},
+	} {
+		if _, neg := v.Evaluate(&emergency.EmergencyTransaction{ID: tc.id, VehicleID: tc.sender}); neg != tc.wantNeg {
+			t.Errorf("交易 %s 的负面事件数 = %d, 期望 %d", tc.id, neg, tc.wantNeg)
+		}
+	}
+}
+
+func TestLoadRejectsInvalidTrace(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"轮次为 0", `{"version": 1, "interactions": [{"round": 0, "sender": "1", "receiver": "2"}]}`},
+		{"轮次超过 rounds", `{"version": 1, "rounds": 1, "interactions": [{"round": 2, "sender": "1", "receiver": "2"}]}`},
+		{"发送者与接收者相同", `{"version": 1, "interactions": [{"round": 1, "sender": "1", "receiver": "1"}]}`},
+		{"age 为负", `{"version": 1, "interactions": [{"round": 1, "sender": "1", "receiver": "2", "age": -1}]}`},
+		{"紧急交易ID重复", `{"version": 1, "emergencyTransactions": [
+			{"round": 1, "id": "e", "sender": "1"}, {"round": 2, "id": "e", "sender": "2"}]}`},
+		{"紧急交易发送者为空", `{"version": 1, "emergencyTransactions": [{"round": 1, "id": "e"}]}`},
+	}
+	for _, tt := range tests {
+		if _, err := Load(writeTrace(t, tt.content)); !errors.Is(err, ErrInvalidTrace) {
+			t.Errorf("%s: Load() = %v, 期望 ErrInvalidTrace", tt.name, err)
+		}
+	}
+
+	if _, err := Load(writeTrace(t, `{"version": 2}`)); err == nil {
+		t.Error("不支持的版本应加载失败")
+	}
+}